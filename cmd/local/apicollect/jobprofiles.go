@@ -57,7 +57,19 @@ func GetNumberOfJobProfilesCollected(c *conf.CollectConf, hook shutdown.Hook) (t
 			}
 			queriesjsons := []string{}
 			for _, file := range files {
-				queriesjsons = append(queriesjsons, path.Join(c.QueriesOutDir(), file.Name()))
+				loc := path.Join(c.QueriesOutDir(), file.Name())
+				if !file.IsDir() {
+					queriesjsons = append(queriesjsons, loc)
+					continue
+				}
+				// with several dremio jvms on the node every jvm has a sub node folder
+				subFiles, err := os.ReadDir(loc)
+				if err != nil {
+					return 0, 0, err
+				}
+				for _, subFile := range subFiles {
+					queriesjsons = append(queriesjsons, path.Join(loc, subFile.Name()))
+				}
 			}
 
 			if len(queriesjsons) == 0 {
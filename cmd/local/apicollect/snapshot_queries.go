@@ -0,0 +1,59 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// apicollect provides all the methods that collect via the API, this is a substantial part of the activities of DDC so it gets it's own package
+package apicollect
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/restclient"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+)
+
+// SnapshotSystemTables are queried with every capture of an incident snapshot, they have the threads
+// and the memory of every node of the cluster at that time
+var SnapshotSystemTables = []string{"threads", "memory"}
+
+// snapshotRowLimit is the first page of results, the snapshot is about the moment and not the whole table
+const snapshotRowLimit = 500
+
+// QuerySystemTableToFile submits SELECT * FROM sys.<systable> and writes the first page of its results to file
+func QuerySystemTableToFile(ctx context.Context, c *conf.CollectConf, hook shutdown.CancelHook, systable, file string) error {
+	headers := map[string]string{"Content-Type": "application/json"}
+	sqlurl, joburl := sqlURLs(c)
+	sqlbody := fmt.Sprintf("{\"sql\": \"SELECT * FROM sys.%v LIMIT %v\"}", systable, snapshotRowLimit)
+	jobid, err := restclient.PostQuery(hook, sqlurl, c.DremioPATToken(), headers, sqlbody)
+	if err != nil {
+		return fmt.Errorf("unable to query sys.%v: %w", systable, err)
+	}
+	if err := checkJobState(ctx, c, hook, joburl+jobid, headers); err != nil {
+		return fmt.Errorf("unable to retrieve sys.%v: %w", systable, err)
+	}
+	resultsurl := fmt.Sprintf("%v%v/results?offset=0&limit=%v", joburl, jobid, snapshotRowLimit)
+	body, err := restclient.APIRequest(hook, resultsurl, c.DremioPATToken(), "GET", headers)
+	if err != nil {
+		return fmt.Errorf("unable to retrieve job results from %s: %w", resultsurl, err)
+	}
+	if err := os.WriteFile(filepath.Clean(file), body, 0o600); err != nil {
+		return fmt.Errorf("unable to write sys.%v results: %w", systable, err)
+	}
+	simplelog.Debugf("SUCCESS - Created %v", file)
+	return nil
+}
@@ -62,14 +62,8 @@ func downloadSysTable(ctx context.Context, c *conf.CollectConf, hook shutdown.Ca
 	tablerowlimit := strconv.Itoa(c.SystemTablesRowLimit())
 
 	headers := map[string]string{"Content-Type": "application/json"}
-	var joburl, sqlurl, jobresultsurl string
-	if !c.IsDremioCloud() {
-		sqlurl = c.DremioEndpoint() + "/api/v3/sql"
-		joburl = c.DremioEndpoint() + "/api/v3/job/"
-	} else {
-		sqlurl = c.DremioEndpoint() + "/v0/projects/" + c.DremioCloudProjectID() + "/sql"
-		joburl = c.DremioEndpoint() + "/v0/projects/" + c.DremioCloudProjectID() + "/job/"
-	}
+	sqlurl, joburl := sqlURLs(c)
+	var jobresultsurl string
 
 	sql := "SELECT * FROM sys." + systable
 	// job history is limited by the number of days, all other sys tables are limited by the number of rows
@@ -103,6 +97,14 @@ func downloadSysTable(ctx context.Context, c *conf.CollectConf, hook shutdown.Ca
 	return nil
 }
 
+// sqlURLs are the urls to submit a query and to read its job
+func sqlURLs(c *conf.CollectConf) (sqlurl, joburl string) {
+	if !c.IsDremioCloud() {
+		return c.DremioEndpoint() + "/api/v3/sql", c.DremioEndpoint() + "/api/v3/job/"
+	}
+	return c.DremioEndpoint() + "/v0/projects/" + c.DremioCloudProjectID() + "/sql", c.DremioEndpoint() + "/v0/projects/" + c.DremioCloudProjectID() + "/job/"
+}
+
 func checkJobState(ctx context.Context, c *conf.CollectConf, hook shutdown.CancelHook, jobstateurl string, headers map[string]string) error {
 	sleepms := 200 // Consider moving to config
 	jobstate := "RUNNING"
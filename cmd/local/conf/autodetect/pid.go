@@ -22,6 +22,7 @@ import (
 	"strings"
 
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/ddcio"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/hotspot"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
 )
@@ -55,11 +56,120 @@ func GetDremioPIDFromText(psOutput string) (int, error) {
 	return strconv.Atoi(pidText)
 }
 
-// GetDremioPID calls ps aux and finds the DremioDaemon (filtering out the preview engine)
+// GetDremioPIDFromJVMs picks the DremioDaemon out of the jvms found through their hsperfdata (filtering out the preview engine)
+func GetDremioPIDFromJVMs(jvms []hotspot.JVM) (int, error) {
+	var pids []int
+	for _, j := range jvms {
+		if !strings.HasSuffix(j.MainClass, "DremioDaemon") {
+			continue
+		}
+		if strings.Contains(j.JVMArgs+" "+j.Args, "/etc/dremio/preview") {
+			continue
+		}
+		pids = append(pids, j.PID)
+	}
+	switch len(pids) {
+	case 0:
+		return -1, fmt.Errorf("no DremioDaemon in the %v jvm(s) with hsperfdata", len(jvms))
+	case 1:
+		return pids[0], nil
+	default:
+		return -1, fmt.Errorf("too many DremioDaemon jvms with hsperfdata: %v", pids)
+	}
+}
+
+// GetDremioPID finds the DremioDaemon through the hsperfdata files of the running jvms
+// and when that fails calls ps aux (filtering out the preview engine)
 func GetDremioPID(hook shutdown.Hook) (int, error) {
+	jvms, err := hotspot.DiscoverJVMs("/tmp")
+	if err == nil {
+		var pid int
+		if pid, err = GetDremioPIDFromJVMs(jvms); err == nil {
+			return pid, nil
+		}
+	}
+	simplelog.Debugf("unable to find the dremio pid through hsperfdata, falling back to ps: %v", err)
 	var psOutput bytes.Buffer
 	if err := ddcio.Shell(hook, &psOutput, "ps aux | grep DremioDaemon | grep -v grep | grep -v /etc/dremio/preview"); err != nil {
 		simplelog.Warningf("attempting to get full ps aux output failed: %v", err)
 	}
 	return GetDremioPIDFromText(psOutput.String())
 }
+
+// DremioJVM is a DremioDaemon process running on the host
+type DremioJVM struct {
+	PID int
+	// Preview is the preview engine, it runs with the /etc/dremio/preview conf dir
+	Preview bool
+	// Args is the command line, it carries the -Dservices.* overrides of dremio.conf
+	Args string
+}
+
+// DremioJVMsFromText reads every DremioDaemon of the output of
+// "ps aux | grep DremioDaemon | grep -v grep"
+func DremioJVMsFromText(psOutput string) ([]DremioJVM, error) {
+	var jvms []DremioJVM
+	for _, line := range strings.Split(psOutput, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.Contains(line, "DremioDaemon") {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("no pid for dremio found in text '%v': %w", line, err)
+		}
+		var args string
+		// ps aux has 10 columns before the command
+		if len(fields) > 10 {
+			args = strings.Join(fields[10:], " ")
+		}
+		jvms = append(jvms, DremioJVM{
+			PID:     pid,
+			Preview: strings.Contains(args, "/etc/dremio/preview"),
+			Args:    args,
+		})
+	}
+	return jvms, nil
+}
+
+// DremioJVMsFromPerfData picks the DremioDaemons out of the jvms found through their hsperfdata
+func DremioJVMsFromPerfData(jvms []hotspot.JVM) []DremioJVM {
+	var dremioJVMs []DremioJVM
+	for _, j := range jvms {
+		if !strings.HasSuffix(j.MainClass, "DremioDaemon") {
+			continue
+		}
+		args := strings.TrimSpace(j.JVMArgs + " " + j.MainClass + " " + j.Args)
+		dremioJVMs = append(dremioJVMs, DremioJVM{
+			PID:     j.PID,
+			Preview: strings.Contains(args, "/etc/dremio/preview"),
+			Args:    args,
+		})
+	}
+	return dremioJVMs
+}
+
+// FindDremioJVMs finds every DremioDaemon of the host, including the preview engine, through the
+// hsperfdata files of the running jvms and when there are none through ps aux
+func FindDremioJVMs(hook shutdown.Hook) ([]DremioJVM, error) {
+	jvms, err := hotspot.DiscoverJVMs("/tmp")
+	if err == nil {
+		if dremioJVMs := DremioJVMsFromPerfData(jvms); len(dremioJVMs) > 0 {
+			return dremioJVMs, nil
+		}
+		err = fmt.Errorf("no DremioDaemon in the %v jvm(s) with hsperfdata", len(jvms))
+	}
+	simplelog.Debugf("unable to find the dremio jvms through hsperfdata, falling back to ps: %v", err)
+	var psOutput bytes.Buffer
+	if err := ddcio.Shell(hook, &psOutput, "ps aux | grep DremioDaemon | grep -v grep"); err != nil {
+		simplelog.Warningf("attempting to get full ps aux output failed: %v", err)
+	}
+	dremioJVMs, err := DremioJVMsFromText(psOutput.String())
+	if err != nil {
+		return nil, err
+	}
+	if len(dremioJVMs) == 0 {
+		return nil, fmt.Errorf("no DremioDaemon in the ps output '%v'", strings.TrimSpace(psOutput.String()))
+	}
+	return dremioJVMs, nil
+}
@@ -15,9 +15,11 @@
 package autodetect_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf/autodetect"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/hotspot"
 )
 
 func TestGetDremioPIDFromTextHasNoText(t *testing.T) {
@@ -77,3 +79,77 @@ func TestGetK8sPID(t *testing.T) {
 		t.Errorf("Unexpected value for pid. Got %v, expected 1", pid)
 	}
 }
+
+func TestGetDremioPIDFromJVMs(t *testing.T) {
+	jvms := []hotspot.JVM{
+		{PID: 10, MainClass: "org.apache.zookeeper.server.quorum.QuorumPeerMain"},
+		{PID: 20, MainClass: "com.dremio.dac.daemon.DremioDaemon", JVMArgs: "-Ddremio.conf=/etc/dremio/preview/dremio.conf"},
+		{PID: 30, MainClass: "com.dremio.dac.daemon.DremioDaemon", JVMArgs: "-Xmx2048m"},
+	}
+	pid, err := autodetect.GetDremioPIDFromJVMs(jvms)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pid != 30 {
+		t.Errorf("Unexpected value for pid. Got %v, expected 30", pid)
+	}
+	if _, err := autodetect.GetDremioPIDFromJVMs(jvms[:1]); err == nil {
+		t.Error("expected an error without a DremioDaemon")
+	}
+	if _, err := autodetect.GetDremioPIDFromJVMs(append(jvms, hotspot.JVM{PID: 40, MainClass: "com.dremio.dac.daemon.DremioDaemon"})); err == nil {
+		t.Error("expected an error for two DremioDaemon jvms")
+	}
+}
+
+func TestDremioJVMsFromText(t *testing.T) {
+	psOutput := `dremio    3139  6.5 20.7 8311440 3340972 ?     Ssl  08:04   2:21 /usr/bin/java -Ddremio.log.path=/var/log/dremio/coordinator -Dservices.executor.enabled=false -cp /opt/dremio/conf:/opt/dremio/jars/* com.dremio.dac.daemon.DremioDaemon
+dremio    3320  5.1 18.2 8311440 3340972 ?     Ssl  08:05   1:10 /usr/bin/java -Ddremio.log.path=/var/log/dremio/executor -Dservices.coordinator.enabled=false -cp /opt/dremio/conf:/opt/dremio/jars/* com.dremio.dac.daemon.DremioDaemon
+dremio    3401  1.0  2.2 8311440 3340972 ?     Ssl  08:05   0:10 /usr/bin/java -cp /etc/dremio/preview:/opt/dremio/jars/* com.dremio.dac.daemon.DremioDaemon
+`
+	jvms, err := autodetect.DremioJVMsFromText(psOutput)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(jvms) != 3 {
+		t.Fatalf("expected 3 jvms but got %v", len(jvms))
+	}
+	for i, expected := range []struct {
+		pid     int
+		preview bool
+		arg     string
+	}{
+		{3139, false, "-Dservices.executor.enabled=false"},
+		{3320, false, "-Dservices.coordinator.enabled=false"},
+		{3401, true, "com.dremio.dac.daemon.DremioDaemon"},
+	} {
+		if jvms[i].PID != expected.pid {
+			t.Errorf("expected pid %v but got %v", expected.pid, jvms[i].PID)
+		}
+		if jvms[i].Preview != expected.preview {
+			t.Errorf("expected preview %v for pid %v", expected.preview, jvms[i].PID)
+		}
+		if !strings.Contains(jvms[i].Args, expected.arg) {
+			t.Errorf("expected args of pid %v to contain %v but were '%v'", jvms[i].PID, expected.arg, jvms[i].Args)
+		}
+		if strings.Contains(jvms[i].Args, "08:0") {
+			t.Errorf("expected the ps columns to be dropped from the args '%v'", jvms[i].Args)
+		}
+	}
+}
+
+func TestDremioJVMsFromPerfData(t *testing.T) {
+	jvms := autodetect.DremioJVMsFromPerfData([]hotspot.JVM{
+		{PID: 10, MainClass: "com.dremio.dac.daemon.DremioDaemon", JVMArgs: "-Dservices.executor.enabled=false"},
+		{PID: 11, MainClass: "org.apache.zookeeper.server.quorum.QuorumPeerMain"},
+		{PID: 12, MainClass: "com.dremio.dac.daemon.DremioDaemon", JVMArgs: "-Ddremio.conf=/etc/dremio/preview"},
+	})
+	if len(jvms) != 2 {
+		t.Fatalf("expected 2 dremio jvms but got %v", jvms)
+	}
+	if jvms[0].PID != 10 || jvms[0].Preview || !strings.Contains(jvms[0].Args, "-Dservices.executor.enabled=false") {
+		t.Errorf("unexpected first jvm %#v", jvms[0])
+	}
+	if jvms[1].PID != 12 || !jvms[1].Preview {
+		t.Errorf("expected pid 12 to be the preview engine %#v", jvms[1])
+	}
+}
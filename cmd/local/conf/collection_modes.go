@@ -0,0 +1,308 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/collects"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/validation"
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v3"
+)
+
+// CollectionMode is an entry of collection-modes in ddc.yaml, a named set of key overrides
+// applied on top of the mode it extends
+type CollectionMode struct {
+	Name        string
+	Description string
+	// Extends is a built-in mode or another custom mode, defaults to standard
+	Extends   string
+	Overrides map[string]interface{}
+}
+
+// ResolvedMode is a --collect option with every mode it extends applied
+type ResolvedMode struct {
+	Name        string
+	Description string
+	// Base is the built-in mode the defaults come from
+	Base string
+	// Lineage is the mode followed by the modes it extends, it ends with Base
+	Lineage []string
+	// Overrides are the keys set by the mode and the modes it extends, the closest mode wins
+	Overrides map[string]interface{}
+}
+
+var (
+	builtInCollectionModes = []string{collects.QuickCollection, collects.StandardCollection, collects.StandardPlusJSTACKCollection, collects.HealthCheckCollection}
+	collectionModeKeys     = []string{"description", "extends", "overrides"}
+	collectionModeName     = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]*$`)
+	// keys without a default that a mode can still set
+	extraModeKeys = []string{KeyJobProfilesNumHighQueryCost, KeyJobProfilesNumSlowExec, KeyJobProfilesNumRecentErrors, KeyJobProfilesNumSlowPlanning, KeyFrom, KeyTo}
+	// keys that are not collection settings so a mode cannot set them
+	reservedModeKeys = []string{KeyCollectionMode, KeyCollectionModes, KeyCollectionModesFile, KeyCustomCollectors}
+)
+
+// CollectionModeNames are the built-in modes followed by the custom modes in name order
+func CollectionModeNames(modes map[string]CollectionMode) []string {
+	return append(append([]string{}, builtInCollectionModes...), customModeNames(modes)...)
+}
+
+func customModeNames(modes map[string]CollectionMode) []string {
+	var names []string
+	for name := range modes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseCollectionModes reads collection-modes from ddc.yaml and from the collection-modes-file when one is set,
+// a mode declared in both is an error
+func ParseCollectionModes(confData map[string]interface{}, ddcYamlLoc string) (map[string]CollectionMode, error) {
+	raw, err := loadCollectionModes(confData, ddcYamlLoc)
+	if err != nil {
+		return nil, err
+	}
+	modes := make(map[string]CollectionMode)
+	for name, v := range raw {
+		m, err := parseCollectionMode(name, v)
+		if err != nil {
+			return nil, fmt.Errorf("INVALID CONFIGURATION: %v '%v': %w", KeyCollectionModes, name, err)
+		}
+		modes[name] = m
+	}
+	// resolving every mode catches unknown modes in extends and loops before the mode is used
+	for _, name := range customModeNames(modes) {
+		if _, err := ResolveCollectionMode(modes, name); err != nil {
+			return nil, err
+		}
+	}
+	return modes, nil
+}
+
+// loadCollectionModes merges the collection-modes of ddc.yaml and of the modes file
+func loadCollectionModes(confData map[string]interface{}, ddcYamlLoc string) (map[string]interface{}, error) {
+	merged := make(map[string]interface{})
+	if v := confData[KeyCollectionModes]; v != nil {
+		modes, err := cast.ToStringMapE(v)
+		if err != nil {
+			return nil, fmt.Errorf("INVALID CONFIGURATION: %v must be a map of mode names: %w", KeyCollectionModes, err)
+		}
+		for name, m := range modes {
+			merged[name] = m
+		}
+	}
+	modesFile := GetString(confData, KeyCollectionModesFile)
+	if modesFile == "" {
+		return merged, nil
+	}
+	if !filepath.IsAbs(modesFile) {
+		modesFile = filepath.Join(filepath.Dir(ddcYamlLoc), modesFile)
+	}
+	b, err := os.ReadFile(filepath.Clean(modesFile))
+	if err != nil {
+		return nil, fmt.Errorf("INVALID CONFIGURATION: %v %v not readable: %w", KeyCollectionModesFile, modesFile, err)
+	}
+	var fileData map[string]interface{}
+	if err := yaml.Unmarshal(b, &fileData); err != nil {
+		return nil, fmt.Errorf("INVALID CONFIGURATION: %v %v is not valid yaml: %w", KeyCollectionModesFile, modesFile, err)
+	}
+	if len(fileData) != 1 || fileData[KeyCollectionModes] == nil {
+		return nil, fmt.Errorf("INVALID CONFIGURATION: %v %v must only have the %v key", KeyCollectionModesFile, modesFile, KeyCollectionModes)
+	}
+	modes, err := cast.ToStringMapE(fileData[KeyCollectionModes])
+	if err != nil {
+		return nil, fmt.Errorf("INVALID CONFIGURATION: %v in %v must be a map of mode names: %w", KeyCollectionModes, modesFile, err)
+	}
+	for name, m := range modes {
+		if _, ok := merged[name]; ok {
+			return nil, fmt.Errorf("INVALID CONFIGURATION: collection mode '%v' is declared in both %v and %v", name, ddcYamlLoc, modesFile)
+		}
+		merged[name] = m
+	}
+	simplelog.Infof("read %v collection modes from %v", len(modes), modesFile)
+	return merged, nil
+}
+
+func parseCollectionMode(name string, v interface{}) (CollectionMode, error) {
+	m := CollectionMode{Name: name, Extends: collects.StandardCollection}
+	if slices.Contains(builtInCollectionModes, name) {
+		return m, fmt.Errorf("the name is already used by a built-in mode, the built-in modes are %v", strings.Join(builtInCollectionModes, ", "))
+	}
+	if !collectionModeName.MatchString(name) {
+		return m, fmt.Errorf("name must only use letters, numbers, '.', '_', '+' and '-'")
+	}
+	entry, err := cast.ToStringMapE(v)
+	if err != nil {
+		return m, fmt.Errorf("must be a map with the keys %v: %w", strings.Join(collectionModeKeys, ", "), err)
+	}
+	var unknown []string
+	for k := range entry {
+		if !slices.Contains(collectionModeKeys, k) {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return m, fmt.Errorf("unknown keys %v, the supported keys are %v, put the ddc.yaml keys to change under overrides", strings.Join(unknown, ", "), strings.Join(collectionModeKeys, ", "))
+	}
+	m.Description = cast.ToString(entry["description"])
+	if extends := cast.ToString(entry["extends"]); extends != "" {
+		m.Extends = extends
+	}
+	if entry["overrides"] == nil {
+		return m, nil
+	}
+	m.Overrides, err = cast.ToStringMapE(entry["overrides"])
+	if err != nil {
+		return m, fmt.Errorf("overrides must be a map of ddc.yaml keys to values: %w", err)
+	}
+	// the defaults give the type every key is read as
+	defaults := make(map[string]interface{})
+	SetViperDefaults(defaults, "", 0, collects.StandardCollection)
+	for _, k := range sortedKeys(m.Overrides) {
+		if slices.Contains(reservedModeKeys, k) {
+			return m, fmt.Errorf("overrides cannot set %v", k)
+		}
+		def, ok := defaults[k]
+		if !ok && !slices.Contains(extraModeKeys, k) {
+			return m, fmt.Errorf("overrides has the unknown key '%v', it must be a ddc.yaml key such as %v", k, KeyCollectJStack)
+		}
+		if err := checkOverrideType(def, m.Overrides[k]); err != nil {
+			return m, fmt.Errorf("overrides key '%v': %w", k, err)
+		}
+	}
+	return m, nil
+}
+
+// checkOverrideType makes sure the value can be read like the default of the key
+func checkOverrideType(def, v interface{}) error {
+	var err error
+	switch def.(type) {
+	case bool:
+		_, err = cast.ToBoolE(v)
+	case int, int64, uint64:
+		_, err = cast.ToInt64E(v)
+	case map[string]interface{}:
+		_, err = cast.ToStringMapE(v)
+	case []string:
+		_, err = cast.ToStringSliceE(v)
+	default:
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			err = fmt.Errorf("expected a single value")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("'%v' is not a valid value: %w", v, err)
+	}
+	return nil
+}
+
+// ResolveCollectionMode follows extends from the mode to its built-in mode, a built-in mode resolves to itself
+func ResolveCollectionMode(modes map[string]CollectionMode, name string) (ResolvedMode, error) {
+	resolved := ResolvedMode{Name: name, Overrides: make(map[string]interface{})}
+	current := name
+	for {
+		if slices.Contains(resolved.Lineage, current) {
+			return resolved, fmt.Errorf("INVALID CONFIGURATION: collection mode '%v' extends itself: %v -> %v", name, strings.Join(resolved.Lineage, " -> "), current)
+		}
+		resolved.Lineage = append(resolved.Lineage, current)
+		if slices.Contains(builtInCollectionModes, current) {
+			resolved.Base = current
+			return resolved, nil
+		}
+		m, ok := modes[current]
+		if !ok {
+			if current == name {
+				return resolved, validation.ValidateCollectMode(name, customModeNames(modes)...)
+			}
+			return resolved, fmt.Errorf("INVALID CONFIGURATION: collection mode '%v' extends '%v' which is not a mode, the modes are %v", resolved.Lineage[len(resolved.Lineage)-2], current, strings.Join(CollectionModeNames(modes), ", "))
+		}
+		if resolved.Description == "" {
+			resolved.Description = m.Description
+		}
+		for k, v := range m.Overrides {
+			if _, ok := resolved.Overrides[k]; !ok {
+				resolved.Overrides[k] = v
+			}
+		}
+		current = m.Extends
+	}
+}
+
+// ApplyCollectionMode sets the keys of a custom mode on the configuration, the command line overrides still win.
+// The returned Base is the built-in mode to take the remaining defaults from
+func ApplyCollectionMode(confData map[string]interface{}, modes map[string]CollectionMode, name string, overrides map[string]string) (ResolvedMode, error) {
+	resolved, err := ResolveCollectionMode(modes, name)
+	if err != nil {
+		return resolved, err
+	}
+	for _, k := range sortedKeys(resolved.Overrides) {
+		if _, ok := overrides[k]; ok {
+			simplelog.Infof("collection mode %v sets %v but it was passed on the command line", name, k)
+			continue
+		}
+		confData[k] = resolved.Overrides[k]
+	}
+	if len(resolved.Lineage) > 1 {
+		simplelog.Infof("collection mode %v (%v) extends %v and sets %v keys", name, resolved.Description, strings.Join(resolved.Lineage[1:], " -> "), len(resolved.Overrides))
+	}
+	return resolved, nil
+}
+
+// InlineCollectionModes writes a copy of ddc.yaml to dir with the modes of the collection-modes-file added to it,
+// the nodes only receive ddc.yaml. Without a modes file the ddc.yaml location is returned unchanged
+func InlineCollectionModes(ddcYamlLoc, dir string) (string, error) {
+	confData, err := ParseConfig(ddcYamlLoc, make(map[string]string))
+	if err != nil {
+		return "", err
+	}
+	if GetString(confData, KeyCollectionModesFile) == "" {
+		return ddcYamlLoc, nil
+	}
+	modes, err := loadCollectionModes(confData, ddcYamlLoc)
+	if err != nil {
+		return "", err
+	}
+	delete(confData, KeyCollectionModesFile)
+	confData[KeyCollectionModes] = modes
+	b, err := yaml.Marshal(confData)
+	if err != nil {
+		return "", fmt.Errorf("unable to write the collection modes into ddc.yaml: %w", err)
+	}
+	inlined := filepath.Join(dir, "ddc.yaml")
+	if err := os.WriteFile(inlined, b, 0o600); err != nil {
+		return "", fmt.Errorf("unable to write the collection modes into %v: %w", inlined, err)
+	}
+	return inlined, nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,212 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/collects"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
+	"gopkg.in/yaml.v3"
+)
+
+// writeModesConf writes a ddc.yaml with the modes yaml appended and returns its location
+func writeModesConf(t *testing.T, modes string) string {
+	t.Helper()
+	dir := t.TempDir()
+	content := "dremio-log-dir: " + filepath.Join("testdata", "logs") + "\n" +
+		"dremio-conf-dir: " + filepath.Join("testdata", "conf") + "\n" +
+		"tarball-out-dir: " + filepath.Join(dir, "out") + "\n" +
+		"dremio-pid-detection: false\n" +
+		"disable-rest-api: true\n" +
+		"node-name: node1\n" +
+		modes
+	ddcYaml := filepath.Join(dir, "ddc.yaml")
+	if err := os.WriteFile(ddcYaml, []byte(strings.ReplaceAll(content, "\\", "\\\\")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return ddcYaml
+}
+
+const perfAndSecurityModes = `
+collect-audit-log: true
+collection-modes:
+  perf:
+    description: jfr, jstack and ttop without logs
+    extends: standard+jstack
+    overrides:
+      collect-server-logs: false
+      collect-queries-json: false
+      collect-gc-logs: false
+  perf-long:
+    extends: perf
+    overrides:
+      dremio-jfr-time-seconds: 600
+      collect-gc-logs: true
+collection-modes-file: modes.yaml
+`
+
+const securityModesFile = `
+collection-modes:
+  security-review:
+    extends: light
+    overrides:
+      collect-audit-log: true
+      collect-server-logs: false
+      collect-jfr: false
+`
+
+func TestReadConfWithCustomCollectionModes(t *testing.T) {
+	ddcYaml := writeModesConf(t, perfAndSecurityModes)
+	if err := os.WriteFile(filepath.Join(filepath.Dir(ddcYaml), "modes.yaml"), []byte(securityModesFile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+
+	c, err := conf.ReadConf(hook, map[string]string{conf.KeyCollectQueriesJSON: "true"}, ddcYaml, "perf-long")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.CollectionMode() != "perf-long" {
+		t.Errorf("expected mode perf-long but got %v", c.CollectionMode())
+	}
+	if got := strings.Join(c.CollectionModeLineage(), " -> "); got != "perf-long -> perf -> standard+jstack" {
+		t.Errorf("unexpected lineage %v", got)
+	}
+	if c.DremioLogsNumDays() != 7 {
+		t.Errorf("expected the standard+jstack defaults but got %v days of logs", c.DremioLogsNumDays())
+	}
+	if c.CollectServerLogs() {
+		t.Error("expected server logs turned off by perf")
+	}
+	if !c.CollectGCLogs() {
+		t.Error("expected perf-long to win over perf for the gc logs")
+	}
+	if c.DremioJFRTimeSeconds() != 600 {
+		t.Errorf("expected 600 seconds of jfr but got %v", c.DremioJFRTimeSeconds())
+	}
+	if !c.CollectQueriesJSON() {
+		t.Error("expected the command line to win over the mode")
+	}
+
+	c, err = conf.ReadConf(hook, map[string]string{}, ddcYaml, "security-review")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.CollectServerLogs() || !c.CollectAuditLogs() {
+		t.Errorf("expected only the audit log from the modes file mode: server logs %v audit %v", c.CollectServerLogs(), c.CollectAuditLogs())
+	}
+	if c.DremioLogsNumDays() != 2 {
+		t.Errorf("expected the light defaults but got %v days of logs", c.DremioLogsNumDays())
+	}
+
+	_, err = conf.ReadConf(hook, map[string]string{}, ddcYaml, "perf2")
+	if err == nil {
+		t.Fatal("expected an error for an unknown mode")
+	}
+	for _, expected := range []string{"'perf2'", "standard+jstack", "perf, perf-long, security-review"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected '%v' in '%v'", expected, err)
+		}
+	}
+}
+
+func TestCollectionModeErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		modes    string
+		expected string
+	}{
+		{"built-in name", "collection-modes:\n  standard:\n    extends: light\n", "already used by a built-in mode"},
+		{"unknown extends", "collection-modes:\n  perf:\n    extends: heavy\n", "collection mode 'perf' extends 'heavy' which is not a mode"},
+		{"loop", "collection-modes:\n  a:\n    extends: b\n  b:\n    extends: a\n", "extends itself: a -> b -> a"},
+		{"unknown entry key", "collection-modes:\n  perf:\n    collect-jstack: true\n", "unknown keys collect-jstack"},
+		{"unknown override", "collection-modes:\n  perf:\n    overrides:\n      collect-jstacks: true\n", "unknown key 'collect-jstacks'"},
+		{"reserved override", "collection-modes:\n  perf:\n    overrides:\n      custom-collectors: []\n", "overrides cannot set custom-collectors"},
+		{"bad value", "collection-modes:\n  perf:\n    overrides:\n      collect-jstack: often\n", "overrides key 'collect-jstack': 'often' is not a valid value"},
+		{"bad name", "collection-modes:\n  perf mode:\n    extends: light\n", "name must only use"},
+		{"missing modes file", "collection-modes-file: missing.yaml\n", "collection-modes-file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data map[string]interface{}
+			if err := yaml.Unmarshal([]byte(tt.modes), &data); err != nil {
+				t.Fatal(err)
+			}
+			_, err := conf.ParseCollectionModes(data, filepath.Join(t.TempDir(), "ddc.yaml"))
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected an error with '%v' but got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestCollectionModeDeclaredTwice(t *testing.T) {
+	ddcYaml := writeModesConf(t, "collection-modes:\n  security-review:\n    extends: light\ncollection-modes-file: modes.yaml\n")
+	if err := os.WriteFile(filepath.Join(filepath.Dir(ddcYaml), "modes.yaml"), []byte(securityModesFile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+	_, err := conf.ReadConf(hook, map[string]string{}, ddcYaml, collects.StandardCollection)
+	if err == nil || !strings.Contains(err.Error(), "'security-review' is declared in both") {
+		t.Errorf("expected an error for the mode declared twice but got %v", err)
+	}
+}
+
+func TestInlineCollectionModes(t *testing.T) {
+	ddcYaml := writeModesConf(t, perfAndSecurityModes)
+	dir := filepath.Dir(ddcYaml)
+	if err := os.WriteFile(filepath.Join(dir, "modes.yaml"), []byte(securityModesFile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	outDir := t.TempDir()
+	inlined, err := conf.InlineCollectionModes(ddcYaml, outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inlined != filepath.Join(outDir, "ddc.yaml") {
+		t.Fatalf("expected a copy in %v but got %v", outDir, inlined)
+	}
+	// the copy no longer needs the modes file
+	if err := os.Remove(filepath.Join(dir, "modes.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := conf.ParseConfig(inlined, map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := data[conf.KeyCollectionModesFile]; ok {
+		t.Error("expected the modes file key to be removed")
+	}
+	modes, err := conf.ParseCollectionModes(data, inlined)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(conf.CollectionModeNames(modes), ","); got != "light,standard,standard+jstack,health-check,perf,perf-long,security-review" {
+		t.Errorf("unexpected modes %v", got)
+	}
+
+	// without a modes file ddc.yaml is used as is
+	plain := writeModesConf(t, "")
+	if got, err := conf.InlineCollectionModes(plain, outDir); err != nil || got != plain {
+		t.Errorf("expected %v unchanged but got %v %v", plain, got, err)
+	}
+}
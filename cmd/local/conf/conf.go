@@ -16,7 +16,6 @@
 package conf
 
 import (
-	"bufio"
 	"bytes"
 	"errors"
 	"fmt"
@@ -35,6 +34,8 @@ import (
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/dirs"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/snapshot"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/timewindow"
 	"github.com/google/uuid"
 	"github.com/spf13/cast"
 )
@@ -91,6 +92,8 @@ type CollectConf struct {
 	collectAuditLogs           bool
 	collectJVMFlags            bool
 	captureHeapDump            bool
+	heapDumpLiveObjects        bool
+	heapDumpDir                string
 	isDremioCloud              bool
 	dremioCloudProjectID       string
 	dremioCloudAppEndpoint     string
@@ -100,7 +103,22 @@ type CollectConf struct {
 	tarballOutDir                     string
 	dremioTtopTimeSeconds             int
 	dremioTtopFreqSeconds             int
+	hotThreadsTopN                    int
+	jvmPerfCountersTimeSeconds        int
+	jvmPerfCountersFreqSeconds        int
+	jvmClassHistogramCount            int
+	jvmClassHistogramIntervalSeconds  int
+	jvmClassHistogramTrendFreqSeconds int
+	jvmClassHistogramTrendTimeSeconds int
+	jvmClassHistogramTrendTopN        int
 	dremioJFRTimeSeconds              int
+	jfrSettings                       string
+	jfrSettingsJFC                    string
+	jfrMaxSizeMB                      int
+	jfrMaxAgeSeconds                  int
+	jfrDumpExisting                   bool
+	jfrExistingRecordings             []string
+	jfrExistingMaxAgeSeconds          int
 	dremioJStackFreqSeconds           int
 	dremioJStackTimeSeconds           int
 	dremioLogsNumDays                 int
@@ -120,6 +138,7 @@ type CollectConf struct {
 	collectDremioConfiguration        bool
 	collectReflectionLogs             bool
 	collectVacuumLogs                 bool
+	collectExtraLogs                  bool
 	collectSystemTablesExport         bool
 	collectSystemTablesTimeoutSeconds int
 	systemTablesRowLimit              int
@@ -128,15 +147,57 @@ type CollectConf struct {
 	collectDiskUsage                  bool
 	collectGCLogs                     bool
 	collectTtop                       bool
+	collectHotThreads                 bool
+	collectJVMPerfCounters            bool
+	collectJVMNativeMemory            bool
+	collectJVMHeapInfo                bool
+	collectJVMClassHistogram          bool
+	collectJVMClassHistogramTrend     bool
+	collectJVMSystemProperties        bool
+	collectJVMInfo                    bool
+	collectJVMCodeCache               bool
+	collectJVMMetaspace               bool
 	collectWLM                        bool
 	nodeName                          string
 	restHTTPTimeout                   int
 	minFreeSpaceCheckGB               uint64
+	maxCPUJobs                        int
+	maxRESTJobs                       int
+	maxJVMAttachJobs                  int
+	window                            timewindow.Window
+	snapshotAt                        time.Time
+	snapshotCount                     int
+	snapshotIntervalSeconds           int
+	snapshotClockOffsetMS             int
+	collectSnapshot                   bool
+	collectSnapshotREST               bool
+	watchRules                        []WatchRule
+	watchDir                          string
+	watchMaxCaptures                  int
+	watchPollSeconds                  int
+	watchCaptureSeconds               int
+	watchLogTailSeconds               int
+	maxLogsTotalBytes                 int64
+	maxLogTypeBytes                   map[string]int64
+	customCollectors                  []CustomCollector
+	collectionMode                    string
+	collectionModeLineage             []string
+	dremioHome                        string
+	confFilesInclude                  []string
+	confFilesExclude                  []string
+	confFilesMaxFileSizeBytes         int64
+	confFilesMaxTotalSizeBytes        int64
 
 	// variables
 	systemtables            []string
 	systemtablesdremiocloud []string
 	dremioPID               int
+	// dremioJVMs are the dremio jvms found on the node, empty when the pid was configured or not detected
+	dremioJVMs []DremioJVM
+	// subNode is the folder of one of several dremio jvms of the node, see ForJVM
+	subNode string
+	// parsed is the ddc.yaml with defaults and overrides applied, used to report the effective configuration
+	parsed map[string]interface{}
 }
 
 func ValidateAPICredentials(c *CollectConf, hook shutdown.Hook) error {
@@ -152,26 +213,6 @@ func ValidateAPICredentials(c *CollectConf, hook shutdown.Hook) error {
 	return err
 }
 
-func DetectRocksDB(dremioHome string, dremioConfDir string) string {
-	dremioConfFile := filepath.Join(dremioConfDir, "dremio.conf")
-	content, err := os.ReadFile(filepath.Clean(dremioConfFile))
-	if err != nil {
-		simplelog.Errorf("configuration directory incorrect : %v", err)
-	}
-	confValues, err := parseAndResolveConfig(string(content), dremioHome)
-	if err != nil {
-		simplelog.Errorf("configuration directory incorrect : %v", err)
-	}
-	// searching rocksdb
-	var rocksDBDir string
-	if value, ok := confValues["db"]; ok {
-		rocksDBDir = value
-	} else {
-		rocksDBDir = filepath.Join(dremioHome, "data", "db")
-	}
-	return rocksDBDir
-}
-
 func SystemTableList() []string {
 	return []string{
 		"\\\"tables\\\"",
@@ -225,8 +266,17 @@ func ReadConf(hook shutdown.Hook, overrides map[string]string, ddcYamlLoc, colle
 		hostName = fmt.Sprintf("unknown-%v", uuid.New())
 	}
 
-	SetViperDefaults(confData, hostName, defaultCaptureSeconds, collectionMode)
+	modes, err := ParseCollectionModes(confData, ddcYamlLoc)
+	if err != nil {
+		return &CollectConf{}, err
+	}
+	mode, err := ApplyCollectionMode(confData, modes, collectionMode, overrides)
+	if err != nil {
+		return &CollectConf{}, err
+	}
+	SetViperDefaults(confData, hostName, defaultCaptureSeconds, mode.Base)
 	c := &CollectConf{}
+	c.parsed = confData
 	c.systemtables = SystemTableList()
 	c.systemtablesdremiocloud = []string{
 		"organization.clouds",
@@ -318,14 +368,69 @@ func ReadConf(hook shutdown.Hook, overrides map[string]string, ddcYamlLoc, colle
 	c.collectMetaRefreshLogs = GetBool(confData, KeyCollectMetaRefreshLog)
 	c.collectReflectionLogs = GetBool(confData, KeyCollectReflectionLog)
 	c.collectVacuumLogs = GetBool(confData, KeyCollectVacuumLog)
+	c.collectExtraLogs = GetBool(confData, KeyCollectExtraLogs)
 	c.collectGCLogs = GetBool(confData, KeyCollectGCLogs)
 	c.dremioUsername = GetString(confData, KeyDremioUsername)
 	c.disableFreeSpaceCheck = GetBool(confData, KeyDisableFreeSpaceCheck)
 	c.minFreeSpaceCheckGB = GetUint64(confData, KeyMinFreeSpaceGB)
 	c.disableRESTAPI = GetBool(confData, KeyDisableRESTAPI)
+	c.maxCPUJobs = atLeastOne(confData, KeyMaxCPUJobs)
+	c.maxRESTJobs = atLeastOne(confData, KeyMaxRESTJobs)
+	c.maxJVMAttachJobs = atLeastOne(confData, KeyMaxJVMAttachJobs)
+	c.window, err = timewindow.Parse(GetString(confData, KeyFrom), GetString(confData, KeyTo), time.Now())
+	if err != nil {
+		return &CollectConf{}, err
+	}
+	if at, ok := confData[KeySnapshotAt].(time.Time); ok {
+		// yaml reads an unquoted RFC3339 value as a timestamp
+		c.snapshotAt = at
+	} else if c.snapshotAt, err = snapshot.ParseAt(GetString(confData, KeySnapshotAt), time.Now()); err != nil {
+		return &CollectConf{}, fmt.Errorf("INVALID CONFIGURATION: %v: %w", KeySnapshotAt, err)
+	}
+	c.snapshotCount = GetInt(confData, KeySnapshotCount)
+	c.snapshotIntervalSeconds = GetInt(confData, KeySnapshotIntervalSeconds)
+	c.snapshotClockOffsetMS = GetInt(confData, KeySnapshotClockOffsetMS)
+	if c.snapshotCount < 1 || c.snapshotIntervalSeconds < 1 {
+		return &CollectConf{}, fmt.Errorf("INVALID CONFIGURATION: %v and %v must be at least 1", KeySnapshotCount, KeySnapshotIntervalSeconds)
+	}
+	c.maxLogsTotalBytes, c.maxLogTypeBytes, err = parseLogBudgets(confData)
+	if err != nil {
+		return &CollectConf{}, err
+	}
+	c.confFilesInclude, c.confFilesExclude, err = parseConfFilePatterns(confData)
+	if err != nil {
+		return &CollectConf{}, err
+	}
+	if c.confFilesMaxFileSizeBytes, err = mbToBytes(confData, KeyConfFilesMaxFileSizeMB); err != nil {
+		return &CollectConf{}, fmt.Errorf("INVALID CONFIGURATION: %w", err)
+	}
+	if c.confFilesMaxTotalSizeBytes, err = mbToBytes(confData, KeyConfFilesMaxTotalSizeMB); err != nil {
+		return &CollectConf{}, fmt.Errorf("INVALID CONFIGURATION: %w", err)
+	}
+	c.collectionMode = collectionMode
+	c.collectionModeLineage = mode.Lineage
+	c.customCollectors, err = ParseCustomCollectors(confData[KeyCustomCollectors], CollectionModeNames(modes))
+	if err != nil {
+		return &CollectConf{}, err
+	}
+	c.watchRules, err = ParseWatchRules(confData[KeyWatchRules], time.Duration(GetInt(confData, KeyWatchCooldownSeconds))*time.Second)
+	if err != nil {
+		return &CollectConf{}, err
+	}
+	c.watchDir = GetString(confData, KeyWatchDir)
+	c.watchMaxCaptures = GetInt(confData, KeyWatchMaxCaptures)
+	c.watchPollSeconds = GetInt(confData, KeyWatchPollSeconds)
+	c.watchCaptureSeconds = GetInt(confData, KeyWatchCaptureSeconds)
+	c.watchLogTailSeconds = GetInt(confData, KeyWatchLogTailSeconds)
+	if c.watchMaxCaptures < 1 || c.watchPollSeconds < 1 || c.watchCaptureSeconds < 1 || c.watchLogTailSeconds < 1 {
+		return &CollectConf{}, fmt.Errorf("INVALID CONFIGURATION: %v, %v, %v and %v must be at least 1", KeyWatchMaxCaptures, KeyWatchPollSeconds, KeyWatchCaptureSeconds, KeyWatchLogTailSeconds)
+	}
+	if GetInt(confData, KeyWatchCooldownSeconds) < 0 {
+		return &CollectConf{}, fmt.Errorf("INVALID CONFIGURATION: %v must be 0 or more", KeyWatchCooldownSeconds)
+	}
 
 	c.dremioPATToken = GetString(confData, KeyDremioPatToken)
-	if c.dremioPATToken == "" && collectionMode == collects.HealthCheckCollection && !c.disableRESTAPI {
+	if c.dremioPATToken == "" && mode.Base == collects.HealthCheckCollection && !c.disableRESTAPI {
 		return &CollectConf{}, errors.New("INVALID CONFIGURATION: the pat is not set and --collect health-check mode requires one")
 	}
 	c.collectDremioConfiguration = GetBool(confData, KeyCollectDremioConfiguration)
@@ -338,6 +443,22 @@ func ReadConf(hook shutdown.Hook, overrides map[string]string, ddcYamlLoc, colle
 
 	// jfr config
 	c.dremioJFRTimeSeconds = GetInt(confData, KeyDremioJFRTimeSeconds)
+	c.jfrSettings = GetString(confData, KeyJFRSettings)
+	if c.jfrSettings == "" {
+		c.jfrSettings = "profile"
+	}
+	c.jfrSettingsJFC = GetString(confData, KeyJFRSettingsJFC)
+	c.jfrMaxSizeMB = GetInt(confData, KeyJFRMaxSizeMB)
+	c.jfrMaxAgeSeconds = GetInt(confData, KeyJFRMaxAgeSeconds)
+	c.jfrExistingMaxAgeSeconds = GetInt(confData, KeyJFRExistingMaxAgeSeconds)
+	if c.jfrMaxSizeMB < 0 || c.jfrMaxAgeSeconds < 0 || c.jfrExistingMaxAgeSeconds < 0 {
+		return &CollectConf{}, fmt.Errorf("INVALID CONFIGURATION: %v, %v and %v cannot be negative", KeyJFRMaxSizeMB, KeyJFRMaxAgeSeconds, KeyJFRExistingMaxAgeSeconds)
+	}
+	c.jfrDumpExisting = GetBool(confData, KeyJFRDumpExisting)
+	c.jfrExistingRecordings, err = optionalList(confData, KeyJFRExistingRecordings)
+	if err != nil {
+		return &CollectConf{}, fmt.Errorf("INVALID CONFIGURATION: %v must be a list of recording names: %w", KeyJFRExistingRecordings, err)
+	}
 	// jstack config
 	c.dremioJStackTimeSeconds = GetInt(confData, KeyDremioJStackTimeSeconds)
 	c.dremioJStackFreqSeconds = GetInt(confData, KeyDremioJStackFreqSeconds)
@@ -347,18 +468,46 @@ func ReadConf(hook shutdown.Hook, overrides map[string]string, ddcYamlLoc, colle
 	c.dremioTtopFreqSeconds = GetInt(confData, KeyDremioTtopFreqSeconds)
 	c.dremioTtopTimeSeconds = GetInt(confData, KeyDremioTtopTimeSeconds)
 
+	// hsperfdata
+	c.collectJVMPerfCounters = GetBool(confData, KeyCollectJVMPerfCounters)
+	c.jvmPerfCountersFreqSeconds = GetInt(confData, KeyJVMPerfCountersFreqSeconds)
+	c.jvmPerfCountersTimeSeconds = GetInt(confData, KeyJVMPerfCountersTimeSeconds)
+
+	// extended jvm diagnostics
+	c.jvmClassHistogramCount = GetInt(confData, KeyJVMClassHistogramCount)
+	c.jvmClassHistogramIntervalSeconds = GetInt(confData, KeyJVMClassHistogramIntervalSeconds)
+	c.jvmClassHistogramTrendFreqSeconds = GetInt(confData, KeyJVMClassHistogramTrendFreqSeconds)
+	c.jvmClassHistogramTrendTimeSeconds = GetInt(confData, KeyJVMClassHistogramTrendTimeSeconds)
+	c.jvmClassHistogramTrendTopN = GetInt(confData, KeyJVMClassHistogramTrendTopN)
+
 	c.dremioPID = GetInt(confData, KeyDremioPid)
 	if c.dremioPID < 1 && c.dremioPIDDetection {
-		dremioPID, err := autodetect.GetDremioPID(hook)
+		jvms, err := autodetect.FindDremioJVMs(hook)
 		if err != nil {
 			simplelog.Errorf("disabling Heap Dump Capture, Jstack and JFR collection: %v", err)
 		} else {
-			c.dremioPID = dremioPID
+			c.dremioJVMs = ClassifyDremioJVMs(hook, jvms)
+			collected := c.CollectedDremioJVMs()
+			if len(collected) == 0 {
+				simplelog.Errorf("disabling Heap Dump Capture, Jstack and JFR collection: only the preview engine is running %v", c.dremioJVMs)
+			} else {
+				// the first jvm is the master or coordinator when there is one, it is used for the node level jobs
+				c.dremioPID = collected[0].PID
+			}
+			if len(collected) > 1 {
+				msg := fmt.Sprintf("found %v dremio jvms, each is collected into its own folder: %v", len(collected), collected)
+				fmt.Println(msg)
+				simplelog.Info(msg)
+			}
 		}
 	}
 	dremioPIDIsValid := c.dremioPID > 0
 	if dremioPIDIsValid {
 		gcLogPattern, logDir, err := autodetect.FindGCLogLocation(hook, c.dremioPID)
+		if err == nil && (gcLogPattern == "" || logDir == "") {
+			// the jvm has no gc logging flags, an empty pattern would make the gc log job own the whole logs dir
+			err = errors.New("no gc log location or pattern in the jvm flags")
+		}
 		if err != nil {
 			msg := fmt.Sprintf("GC LOG DETECTION DISABLED: will rely on ddc.yaml configuration as ddc is unable to retrieve configuration from pid %v: %v", c.dremioPID, err)
 			consoleprint.ErrorPrint(msg)
@@ -375,13 +524,28 @@ func ReadConf(hook shutdown.Hook, overrides map[string]string, ddcYamlLoc, colle
 	}
 	// captures that wont work if the dremioPID is invalid
 	c.captureHeapDump = GetBool(confData, KeyCaptureHeapDump) && dremioPIDIsValid
+	c.heapDumpLiveObjects = GetBool(confData, KeyHeapDumpLiveObjects)
+	c.heapDumpDir = GetString(confData, KeyHeapDumpDir)
 	c.collectJFR = GetBool(confData, KeyCollectJFR) && dremioPIDIsValid
 	c.collectJStack = GetBool(confData, KeyCollectJStack) && dremioPIDIsValid
+	// the cpu of the threads is sampled with every thread dump
+	c.collectHotThreads = GetBool(confData, KeyCollectHotThreads) && c.collectJStack
+	c.hotThreadsTopN = GetInt(confData, KeyHotThreadsTopN)
+	c.collectSnapshot = !c.snapshotAt.IsZero() && dremioPIDIsValid
+	c.collectJVMPerfCounters = c.collectJVMPerfCounters && dremioPIDIsValid
+	c.collectJVMNativeMemory = GetBool(confData, KeyCollectJVMNativeMemory) && dremioPIDIsValid
+	c.collectJVMHeapInfo = GetBool(confData, KeyCollectJVMHeapInfo) && dremioPIDIsValid
+	c.collectJVMClassHistogramTrend = GetBool(confData, KeyCollectJVMClassHistogramTrend) && dremioPIDIsValid
+	c.collectJVMClassHistogram = GetBool(confData, KeyCollectJVMClassHistogram) && c.jvmClassHistogramCount > 0 && dremioPIDIsValid
+	c.collectJVMSystemProperties = GetBool(confData, KeyCollectJVMSystemProperties) && dremioPIDIsValid
+	c.collectJVMInfo = GetBool(confData, KeyCollectJVMInfo) && dremioPIDIsValid
+	c.collectJVMCodeCache = GetBool(confData, KeyCollectJVMCodeCache) && dremioPIDIsValid
+	c.collectJVMMetaspace = GetBool(confData, KeyCollectJVMMetaspace) && dremioPIDIsValid
 
 	// we do not want to validate configuration of logs for dremio cloud
 	if !c.isDremioCloud {
 		var detectedConfig DremioConfig
-		capturesATypeOfLog := c.collectServerLogs || c.collectAccelerationLogs || c.collectAccessLogs || c.collectAuditLogs || c.collectMetaRefreshLogs || c.collectReflectionLogs
+		capturesATypeOfLog := c.collectServerLogs || c.collectAccelerationLogs || c.collectAccessLogs || c.collectAuditLogs || c.collectMetaRefreshLogs || c.collectReflectionLogs || c.collectExtraLogs
 		if capturesATypeOfLog {
 			// enable some autodetected directories
 			if dremioPIDIsValid {
@@ -395,6 +559,7 @@ func ReadConf(hook shutdown.Hook, overrides map[string]string, ddcYamlLoc, colle
 					simplelog.Infof("configured values retrieved from ps output: %v:%v, %v:%v", KeyDremioLogDir, detectedConfig.LogDir, KeyCollectDremioConfiguration, detectedConfig.ConfDir)
 					c.dremioLogDir = detectedConfig.LogDir
 					c.dremioConfDir = detectedConfig.ConfDir
+					c.dremioHome = detectedConfig.Home
 				}
 			} else {
 				consoleprint.ErrorPrint("AUTODETECTION DISABLED: will rely on ddc.yaml configuration as the ddc user does not have permissions to the dremio process consider using --sudo-user to resolve this")
@@ -528,6 +693,7 @@ func ReadConf(hook shutdown.Hook, overrides map[string]string, ddcYamlLoc, colle
 		c.collectSystemTablesExport = false
 		c.systemTablesRowLimit = 0
 		c.collectKVStoreReport = false
+		c.collectSnapshotREST = false
 	} else {
 		numberJobProfilesToCollect, jobProfilesNumHighQueryCost, jobProfilesNumSlowExec, jobProfilesNumRecentErrors, jobProfilesNumSlowPlanning := CalculateJobProfileSettingsWithViperConfig(c)
 		c.numberJobProfilesToCollect = numberJobProfilesToCollect
@@ -539,6 +705,7 @@ func ReadConf(hook shutdown.Hook, overrides map[string]string, ddcYamlLoc, colle
 		c.collectSystemTablesExport = GetBool(confData, KeyCollectSystemTablesExport)
 		c.systemTablesRowLimit = GetInt(confData, KeySystemTablesRowLimit)
 		c.collectKVStoreReport = GetBool(confData, KeyCollectKVStoreReport)
+		c.collectSnapshotREST = c.collectSnapshot
 		restclient.InitClient(c.allowInsecureSSL, c.restHTTPTimeout)
 		// validate rest api configuration
 		if err := ValidateAPICredentials(c, hook); err != nil {
@@ -566,52 +733,6 @@ func ReadConf(hook shutdown.Hook, overrides map[string]string, ddcYamlLoc, colle
 	return c, nil
 }
 
-// parseAndResolveConfig parses the dremio.conf content and resolves placeholders based on the provided DREMIO_HOME.
-func parseAndResolveConfig(confContent, dremioHome string) (map[string]string, error) {
-	scanner := bufio.NewScanner(strings.NewReader(confContent))
-	config := make(map[string]string)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		// Replace DREMIO_HOME placeholder
-
-		line = strings.ReplaceAll(line, "${DREMIO_HOME}", dremioHome)
-		trimmedLine := strings.TrimSpace(line)
-
-		// Skip empty lines and comments
-		if trimmedLine == "" || strings.HasPrefix(trimmedLine, "#") {
-			continue
-		}
-
-		parts := strings.SplitN(trimmedLine, ":", 2)
-		if len(parts) != 2 {
-			continue
-		}
-
-		key := strings.TrimSpace(parts[0])
-		value := strings.Trim(parts[1], " ,\"'")
-
-		// Store in map
-		config[key] = value
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
-	local := ""
-	for key, value := range config {
-		if strings.Contains("local", key) || strings.Contains("path.local", key) {
-			local = value
-			break
-		}
-	}
-	for key, value := range config {
-		config[key] = strings.ReplaceAll(value, "${paths.local}", local)
-	}
-
-	return config, nil
-}
-
 // DremioConfig represents the configuration details for Dremio.
 type DremioConfig struct {
 	Home    string
@@ -708,6 +829,52 @@ func (c CollectConf) DisableFreeSpaceCheck() bool {
 	return c.disableFreeSpaceCheck
 }
 
+// EffectiveConfig is the configuration after defaults, overrides and autodetection were applied.
+// The PAT is never included.
+func (c *CollectConf) EffectiveConfig() map[string]interface{} {
+	effective := make(map[string]interface{}, len(c.parsed))
+	for k, v := range c.parsed {
+		effective[k] = v
+	}
+	effective[KeyNodeName] = c.nodeName
+	effective[KeyTarballOutDir] = c.tarballOutDir
+	effective[KeyDremioPid] = c.dremioPID
+	effective[KeyDremioLogDir] = c.dremioLogDir
+	effective[KeyDremioConfDir] = c.dremioConfDir
+	effective[KeyDremioGCLogsDir] = c.gcLogsDir
+	effective[KeyDremioGCFilePattern] = c.dremioGCFilePattern
+	effective[KeyDremioEndpoint] = c.dremioEndpoint
+	effective[KeyCaptureHeapDump] = c.captureHeapDump
+	effective[KeyHeapDumpLiveObjects] = c.heapDumpLiveObjects
+	effective[KeyHeapDumpDir] = c.HeapDumpDir()
+	effective[KeyCollectJFR] = c.collectJFR
+	effective[KeyJFRMaxAgeSeconds] = c.JFRMaxAgeSeconds()
+	effective[KeyCollectJStack] = c.collectJStack
+	effective[KeyCollectHotThreads] = c.collectHotThreads
+	if !c.snapshotAt.IsZero() {
+		effective[KeySnapshotAt] = c.snapshotAt.Format(time.RFC3339Nano)
+	}
+	effective[KeyCollectJVMPerfCounters] = c.collectJVMPerfCounters
+	effective[KeyCollectJVMNativeMemory] = c.collectJVMNativeMemory
+	effective[KeyCollectJVMHeapInfo] = c.collectJVMHeapInfo
+	effective[KeyCollectJVMClassHistogram] = c.collectJVMClassHistogram
+	effective[KeyCollectJVMClassHistogramTrend] = c.collectJVMClassHistogramTrend
+	effective[KeyCollectJVMSystemProperties] = c.collectJVMSystemProperties
+	effective[KeyCollectJVMInfo] = c.collectJVMInfo
+	effective[KeyCollectJVMCodeCache] = c.collectJVMCodeCache
+	effective[KeyCollectJVMMetaspace] = c.collectJVMMetaspace
+	effective[KeyCollectWLM] = c.collectWLM
+	effective[KeyCollectSystemTablesExport] = c.collectSystemTablesExport
+	effective[KeyCollectKVStoreReport] = c.collectKVStoreReport
+	effective[KeyNumberJobProfiles] = c.numberJobProfilesToCollect
+	if c.dremioPATToken != "" {
+		effective[KeyDremioPatToken] = "REDACTED"
+	} else {
+		delete(effective, KeyDremioPatToken)
+	}
+	return effective
+}
+
 func (c *CollectConf) GcLogsDir() string {
 	return c.gcLogsDir
 }
@@ -724,6 +891,19 @@ func (c *CollectConf) CaptureHeapDump() bool {
 	return c.captureHeapDump
 }
 
+// HeapDumpLiveObjects dumps only reachable objects, this runs a full gc first but gives a smaller dump
+func (c *CollectConf) HeapDumpLiveObjects() bool {
+	return c.heapDumpLiveObjects
+}
+
+// HeapDumpDir is where the jvm writes the raw heap dump before it is compressed, the output dir unless configured
+func (c *CollectConf) HeapDumpDir() string {
+	if c.heapDumpDir == "" {
+		return c.outputDir
+	}
+	return c.heapDumpDir
+}
+
 func (c *CollectConf) CollectWLM() bool {
 	return c.collectWLM
 }
@@ -784,6 +964,11 @@ func (c *CollectConf) CollectVacuumLogs() bool {
 	return c.collectVacuumLogs
 }
 
+// CollectExtraLogs collects the logs of appenders in logback.xml that none of the other log jobs collect
+func (c *CollectConf) CollectExtraLogs() bool {
+	return c.collectExtraLogs
+}
+
 func (c *CollectConf) CollectAccelerationLogs() bool {
 	return c.collectAccelerationLogs
 }
@@ -805,10 +990,12 @@ func (c *CollectConf) CollectAuditLogs() bool {
 }
 
 func (c *CollectConf) TtopOutDir() string {
-	return filepath.Join(c.outputDir, "ttop", c.nodeName)
+	return filepath.Join(c.outputDir, "ttop", c.nodeName, c.subNode)
 }
 
-func (c *CollectConf) HeapDumpsOutDir() string { return filepath.Join(c.outputDir, "heap-dumps") }
+func (c *CollectConf) HeapDumpsOutDir() string {
+	return filepath.Join(c.outputDir, "heap-dumps", c.subNode)
+}
 
 func (c *CollectConf) JobProfilesOutDir() string {
 	return filepath.Join(c.outputDir, "job-profiles", c.nodeName)
@@ -829,23 +1016,71 @@ func (c *CollectConf) ClusterStatsOutDir() string {
 func (c *CollectConf) WLMOutDir() string { return filepath.Join(c.outputDir, "wlm", c.nodeName) }
 
 // works on all nodes but includes node name in file name
-func (c *CollectConf) JFROutDir() string { return filepath.Join(c.outputDir, "jfr") }
+func (c *CollectConf) JFROutDir() string { return filepath.Join(c.outputDir, "jfr", c.subNode) }
 
-// per node out directories
+// per node out directories, the ones written by the jvm and log jobs get a sub node folder when
+// there is more than one dremio jvm on the node
 func (c *CollectConf) ConfigurationOutDir() string {
-	return filepath.Join(c.outputDir, "configuration", c.nodeName)
+	return filepath.Join(c.outputDir, "configuration", c.nodeName, c.subNode)
+}
+func (c *CollectConf) LogsOutDir() string {
+	return filepath.Join(c.outputDir, "logs", c.nodeName, c.subNode)
 }
-func (c *CollectConf) LogsOutDir() string { return filepath.Join(c.outputDir, "logs", c.nodeName) }
 func (c *CollectConf) NodeInfoOutDir() string {
-	return filepath.Join(c.outputDir, "node-info", c.nodeName)
+	return filepath.Join(c.outputDir, "node-info", c.nodeName, c.subNode)
+}
+
+// CustomOutDir has a folder per custom collector
+func (c *CollectConf) CustomOutDir() string {
+	return filepath.Join(c.outputDir, "custom", c.nodeName)
 }
 
 func (c *CollectConf) QueriesOutDir() string {
-	return filepath.Join(c.outputDir, "queries", c.nodeName)
+	return filepath.Join(c.outputDir, "queries", c.nodeName, c.subNode)
+}
+
+// JVMOutDir has the output of the extended jvm diagnostics such as the class histograms
+func (c *CollectConf) JVMOutDir() string {
+	return filepath.Join(c.outputDir, "jvm", c.nodeName, c.subNode)
+}
+
+// SnapshotOutDir has the captures of the incident snapshot
+func (c *CollectConf) SnapshotOutDir() string {
+	return filepath.Join(c.outputDir, "snapshot", c.nodeName, c.subNode)
+}
+
+// CollectSnapshot is true when an incident snapshot was requested with snapshot-at and there is a dremio jvm
+func (c *CollectConf) CollectSnapshot() bool {
+	return c.collectSnapshot
+}
+
+// CollectSnapshotREST adds the system tables queried through the rest api to the snapshot of a coordinator
+func (c *CollectConf) CollectSnapshotREST() bool {
+	return c.collectSnapshotREST
+}
+
+// SnapshotAt is when the first capture of the incident snapshot is taken on the clock of ddc
+func (c *CollectConf) SnapshotAt() time.Time {
+	return c.snapshotAt
+}
+
+// SnapshotCount is the number of captures of the incident snapshot
+func (c *CollectConf) SnapshotCount() int {
+	return c.snapshotCount
+}
+
+// SnapshotInterval is the time between the captures of the incident snapshot
+func (c *CollectConf) SnapshotInterval() time.Duration {
+	return time.Duration(c.snapshotIntervalSeconds) * time.Second
+}
+
+// SnapshotClockOffset is how far the clock of the node is ahead of the clock of ddc
+func (c *CollectConf) SnapshotClockOffset() time.Duration {
+	return time.Duration(c.snapshotClockOffsetMS) * time.Millisecond
 }
 
 func (c *CollectConf) ThreadDumpsOutDir() string {
-	return filepath.Join(c.outputDir, "jfr", "thread-dumps", c.nodeName)
+	return filepath.Join(c.outputDir, "jfr", "thread-dumps", c.nodeName, c.subNode)
 }
 
 func (c *CollectConf) DremioEndpoint() string {
@@ -884,6 +1119,131 @@ func (c *CollectConf) NumberThreads() int {
 	return c.numberThreads
 }
 
+// CollectionWindow is the --from and --to window, when it is not set the number of days is used
+func (c *CollectConf) CollectionWindow() timewindow.Window {
+	return c.window
+}
+
+// MaxCPUJobs is how many jobs that copy and compress files run at once
+func (c *CollectConf) MaxCPUJobs() int {
+	return c.maxCPUJobs
+}
+
+// MaxRESTJobs is how many jobs that call the Dremio REST API run at once
+func (c *CollectConf) MaxRESTJobs() int {
+	return c.maxRESTJobs
+}
+
+// MaxJVMAttachJobs is how many jobs that attach to the Dremio JVM run at once
+func (c *CollectConf) MaxJVMAttachJobs() int {
+	return c.maxJVMAttachJobs
+}
+
+// MaxLogsTotalBytes is how many bytes of logs are collected on the node, 0 is no limit
+func (c *CollectConf) MaxLogsTotalBytes() int64 {
+	return c.maxLogsTotalBytes
+}
+
+// MaxLogTypeBytes is how many bytes are collected per log type such as server or gc, the default
+// key applies to every log type not listed
+func (c *CollectConf) MaxLogTypeBytes() map[string]int64 {
+	return c.maxLogTypeBytes
+}
+
+// parseLogBudgets reads the log size caps in MB and returns them in bytes
+func parseLogBudgets(confData map[string]interface{}) (int64, map[string]int64, error) {
+	const mb = 1024 * 1024
+	total, err := cast.ToInt64E(confData[KeyMaxLogsTotalMB])
+	if err != nil || total < 0 {
+		return 0, nil, fmt.Errorf("INVALID CONFIGURATION: %v must be a number of MB of 0 or more but was '%v'", KeyMaxLogsTotalMB, confData[KeyMaxLogsTotalMB])
+	}
+	perType := make(map[string]int64)
+	raw, err := cast.ToStringMapE(confData[KeyMaxLogTypeMB])
+	if err != nil {
+		return 0, nil, fmt.Errorf("INVALID CONFIGURATION: %v must be a map of log type to MB such as 'server: 1024': %w", KeyMaxLogTypeMB, err)
+	}
+	for logType, v := range raw {
+		limit, err := cast.ToInt64E(v)
+		if err != nil || limit < 0 {
+			return 0, nil, fmt.Errorf("INVALID CONFIGURATION: %v for %v must be a number of MB of 0 or more but was '%v'", KeyMaxLogTypeMB, logType, v)
+		}
+		perType[logType] = limit * mb
+	}
+	return total * mb, perType, nil
+}
+
+// parseConfFilePatterns reads the include and exclude globs for the files of the dremio conf dir
+func parseConfFilePatterns(confData map[string]interface{}) (include, exclude []string, err error) {
+	for _, k := range []string{KeyConfFilesInclude, KeyConfFilesExclude} {
+		patterns, err := optionalList(confData, k)
+		if err != nil {
+			return nil, nil, fmt.Errorf("INVALID CONFIGURATION: %v must be a list of globs: %w", k, err)
+		}
+		for _, p := range patterns {
+			if _, err := filepath.Match(p, ""); err != nil {
+				return nil, nil, fmt.Errorf("INVALID CONFIGURATION: %v has the invalid glob '%v': %w", k, p, err)
+			}
+		}
+		if k == KeyConfFilesInclude {
+			include = patterns
+		} else {
+			exclude = patterns
+		}
+	}
+	return include, exclude, nil
+}
+
+// ConfFilesInclude are the globs of the files collected from the dremio conf dir, matched against the
+// path relative to the conf dir and the file name
+func (c *CollectConf) ConfFilesInclude() []string {
+	return c.confFilesInclude
+}
+
+// ConfFilesExclude are the globs of the files and folders of the dremio conf dir that are never collected
+func (c *CollectConf) ConfFilesExclude() []string {
+	return c.confFilesExclude
+}
+
+// ConfFilesMaxFileSizeBytes skips bigger files of the dremio conf dir, 0 is no limit
+func (c *CollectConf) ConfFilesMaxFileSizeBytes() int64 {
+	return c.confFilesMaxFileSizeBytes
+}
+
+// ConfFilesMaxTotalSizeBytes stops copying files of the dremio conf dir once reached, 0 is no limit
+func (c *CollectConf) ConfFilesMaxTotalSizeBytes() int64 {
+	return c.confFilesMaxTotalSizeBytes
+}
+
+// CustomCollectors are the custom-collectors entries of ddc.yaml
+func (c *CollectConf) CustomCollectors() []CustomCollector {
+	return c.customCollectors
+}
+
+// CollectionMode is the --collect mode such as light, standard or a custom mode from ddc.yaml
+func (c *CollectConf) CollectionMode() string {
+	return c.collectionMode
+}
+
+// DremioHome is the DREMIO_HOME detected from the dremio process, empty when it was not detected
+func (c *CollectConf) DremioHome() string {
+	return c.dremioHome
+}
+
+// CollectionModeLineage is the collection mode followed by the modes it extends, ending with a built-in mode
+func (c *CollectConf) CollectionModeLineage() []string {
+	return c.collectionModeLineage
+}
+
+// atLeastOne reads a job limit, a limit below one would stop those jobs from ever running
+func atLeastOne(confData map[string]interface{}, key string) int {
+	v := GetInt(confData, key)
+	if v < 1 {
+		simplelog.Warningf("invalid value %v for %v, using 1", v, key)
+		return 1
+	}
+	return v
+}
+
 func (c *CollectConf) JobProfilesNumSlowPlanning() int {
 	return c.jobProfilesNumSlowPlanning
 }
@@ -916,6 +1276,46 @@ func (c *CollectConf) DremioJFRTimeSeconds() int {
 	return c.dremioJFRTimeSeconds
 }
 
+// JFRSettings is the settings of the JFR recording, the name of a .jfc shipped with the jdk such as profile or
+// default or the path of a .jfc on the node
+func (c *CollectConf) JFRSettings() string {
+	return c.jfrSettings
+}
+
+// JFRSettingsJFC is the content of a .jfc set in ddc.yaml, when set it is used instead of JFRSettings
+func (c *CollectConf) JFRSettingsJFC() string {
+	return c.jfrSettingsJFC
+}
+
+// JFRMaxSizeMB caps the size of the JFR recording and of the dumps of existing recordings, 0 is no limit
+func (c *CollectConf) JFRMaxSizeMB() int {
+	return c.jfrMaxSizeMB
+}
+
+// JFRMaxAgeSeconds is how much data the JFR recording keeps, 0 keeps the whole recording of DremioJFRTimeSeconds
+func (c *CollectConf) JFRMaxAgeSeconds() int {
+	if c.jfrMaxAgeSeconds == 0 {
+		return c.dremioJFRTimeSeconds
+	}
+	return c.jfrMaxAgeSeconds
+}
+
+// JFRDumpExisting dumps the JFR recordings already running in the jvm, when one is dumped no new recording is started
+func (c *CollectConf) JFRDumpExisting() bool {
+	return c.jfrDumpExisting
+}
+
+// JFRExistingRecordings are the names of the existing recordings to dump, empty dumps all of them
+func (c *CollectConf) JFRExistingRecordings() []string {
+	return c.jfrExistingRecordings
+}
+
+// JFRExistingMaxAgeSeconds is how far back the existing recordings are dumped when there is no collection
+// window, 0 dumps all the data they hold
+func (c *CollectConf) JFRExistingMaxAgeSeconds() int {
+	return c.jfrExistingMaxAgeSeconds
+}
+
 func (c *CollectConf) DremioTtopTimeSeconds() int {
 	return c.dremioTtopTimeSeconds
 }
@@ -928,6 +1328,80 @@ func (c *CollectConf) CollectTtop() bool {
 	return c.collectTtop
 }
 
+// CollectHotThreads samples the cpu of every thread from /proc with each thread dump
+func (c *CollectConf) CollectHotThreads() bool {
+	return c.collectHotThreads
+}
+
+// HotThreadsTopN is how many of the hottest threads are kept for each thread dump
+func (c *CollectConf) HotThreadsTopN() int {
+	return c.hotThreadsTopN
+}
+
+func (c *CollectConf) CollectJVMPerfCounters() bool {
+	return c.collectJVMPerfCounters
+}
+
+func (c *CollectConf) JVMPerfCountersFreqSeconds() int {
+	return c.jvmPerfCountersFreqSeconds
+}
+
+func (c *CollectConf) JVMPerfCountersTimeSeconds() int {
+	return c.jvmPerfCountersTimeSeconds
+}
+
+func (c *CollectConf) CollectJVMNativeMemory() bool {
+	return c.collectJVMNativeMemory
+}
+
+func (c *CollectConf) CollectJVMHeapInfo() bool {
+	return c.collectJVMHeapInfo
+}
+
+func (c *CollectConf) CollectJVMClassHistogram() bool {
+	return c.collectJVMClassHistogram
+}
+
+func (c *CollectConf) JVMClassHistogramCount() int {
+	return c.jvmClassHistogramCount
+}
+
+func (c *CollectConf) JVMClassHistogramIntervalSeconds() int {
+	return c.jvmClassHistogramIntervalSeconds
+}
+
+func (c *CollectConf) CollectJVMClassHistogramTrend() bool {
+	return c.collectJVMClassHistogramTrend
+}
+
+func (c *CollectConf) JVMClassHistogramTrendFreqSeconds() int {
+	return c.jvmClassHistogramTrendFreqSeconds
+}
+
+func (c *CollectConf) JVMClassHistogramTrendTimeSeconds() int {
+	return c.jvmClassHistogramTrendTimeSeconds
+}
+
+func (c *CollectConf) JVMClassHistogramTrendTopN() int {
+	return c.jvmClassHistogramTrendTopN
+}
+
+func (c *CollectConf) CollectJVMSystemProperties() bool {
+	return c.collectJVMSystemProperties
+}
+
+func (c *CollectConf) CollectJVMInfo() bool {
+	return c.collectJVMInfo
+}
+
+func (c *CollectConf) CollectJVMCodeCache() bool {
+	return c.collectJVMCodeCache
+}
+
+func (c *CollectConf) CollectJVMMetaspace() bool {
+	return c.collectJVMMetaspace
+}
+
 func (c *CollectConf) DremioJStackTimeSeconds() int {
 	return c.dremioJStackTimeSeconds
 }
@@ -971,3 +1445,33 @@ func (c *CollectConf) CollectSystemTablesTimeoutSeconds() int {
 func (c *CollectConf) CollectClusterIDTimeoutSeconds() int {
 	return c.collectClusterIDTimeoutSeconds
 }
+
+// WatchRules are the watch-rules entries of ddc.yaml
+func (c *CollectConf) WatchRules() []WatchRule {
+	return c.watchRules
+}
+
+// WatchDir is where ddc watch keeps its captures
+func (c *CollectConf) WatchDir() string {
+	return c.watchDir
+}
+
+// WatchMaxCaptures is how many captures ddc watch keeps, the oldest are removed
+func (c *CollectConf) WatchMaxCaptures() int {
+	return c.watchMaxCaptures
+}
+
+// WatchPollInterval is how often ddc watch reads the logs and the heap
+func (c *CollectConf) WatchPollInterval() time.Duration {
+	return time.Duration(c.watchPollSeconds) * time.Second
+}
+
+// WatchCaptureSeconds is how long a capture takes thread dumps and records JFR for
+func (c *CollectConf) WatchCaptureSeconds() int {
+	return c.watchCaptureSeconds
+}
+
+// WatchLogTail is how much of the logs before the trigger a capture keeps
+func (c *CollectConf) WatchLogTail() time.Duration {
+	return time.Duration(c.watchLogTailSeconds) * time.Second
+}
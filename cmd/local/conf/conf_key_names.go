@@ -33,6 +33,8 @@ const (
 	KeyDremioRocksdbDir                  = "dremio-rocksdb-dir"
 	KeyCollectDremioConfiguration        = "collect-dremio-configuration"
 	KeyCaptureHeapDump                   = "capture-heap-dump"
+	KeyHeapDumpLiveObjects               = "heap-dump-live-objects"
+	KeyHeapDumpDir                       = "heap-dump-dir"
 	KeyNumberJobProfiles                 = "number-job-profiles"
 	KeyDremioEndpoint                    = "dremio-endpoint"
 	KeyTarballOutDir                     = "tarball-out-dir"
@@ -47,6 +49,7 @@ const (
 	KeyCollectMetaRefreshLog             = "collect-meta-refresh-log"
 	KeyCollectReflectionLog              = "collect-reflection-log"
 	KeyCollectVacuumLog                  = "collect-vacuum-log"
+	KeyCollectExtraLogs                  = "collect-extra-logs"
 	KeyCollectGCLogs                     = "collect-gc-logs"
 	KeyCollectJFR                        = "collect-jfr"
 	KeyCollectJStack                     = "collect-jstack"
@@ -57,9 +60,34 @@ const (
 	KeyCollectKVStoreReport              = "collect-kvstore-report"
 	KeyDremioJStackTimeSeconds           = "dremio-jstack-time-seconds"
 	KeyDremioJFRTimeSeconds              = "dremio-jfr-time-seconds"
+	KeyJFRSettings                       = "jfr-settings"
+	KeyJFRSettingsJFC                    = "jfr-settings-jfc"
+	KeyJFRMaxSizeMB                      = "jfr-maxsize-mb"
+	KeyJFRMaxAgeSeconds                  = "jfr-maxage-seconds"
+	KeyJFRDumpExisting                   = "jfr-dump-existing"
+	KeyJFRExistingRecordings             = "jfr-existing-recordings"
+	KeyJFRExistingMaxAgeSeconds          = "jfr-existing-maxage-seconds"
 	KeyDremioJStackFreqSeconds           = "dremio-jstack-freq-seconds"
 	KeyDremioTtopFreqSeconds             = "dremio-ttop-freq-seconds"
 	KeyDremioTtopTimeSeconds             = "dremio-ttop-time-seconds"
+	KeyCollectHotThreads                 = "collect-hot-threads"
+	KeyHotThreadsTopN                    = "hot-threads-top-n"
+	KeyCollectJVMPerfCounters            = "collect-jvm-perfcounters"
+	KeyJVMPerfCountersFreqSeconds        = "jvm-perfcounters-freq-seconds"
+	KeyJVMPerfCountersTimeSeconds        = "jvm-perfcounters-time-seconds"
+	KeyCollectJVMNativeMemory            = "collect-jvm-native-memory"
+	KeyCollectJVMHeapInfo                = "collect-jvm-heap-info"
+	KeyCollectJVMClassHistogram          = "collect-jvm-class-histogram"
+	KeyJVMClassHistogramCount            = "jvm-class-histogram-count"
+	KeyJVMClassHistogramIntervalSeconds  = "jvm-class-histogram-interval-seconds"
+	KeyCollectJVMClassHistogramTrend     = "collect-jvm-class-histogram-trend"
+	KeyJVMClassHistogramTrendFreqSeconds = "jvm-class-histogram-trend-freq-seconds"
+	KeyJVMClassHistogramTrendTimeSeconds = "jvm-class-histogram-trend-time-seconds"
+	KeyJVMClassHistogramTrendTopN        = "jvm-class-histogram-trend-top-n"
+	KeyCollectJVMSystemProperties        = "collect-jvm-system-properties"
+	KeyCollectJVMInfo                    = "collect-jvm-vm-info"
+	KeyCollectJVMCodeCache               = "collect-jvm-codecache"
+	KeyCollectJVMMetaspace               = "collect-jvm-metaspace"
 	KeyDremioGCLogsDir                   = "dremio-gclogs-dir"
 	KeyNodeName                          = "node-name"
 	KeyAcceptCollectionConsent           = "accept-collection-consent"
@@ -76,4 +104,44 @@ const (
 	KeyCollectionMode                    = "collect"
 	KeyCollectClusterIDTimeoutSeconds    = "collect-cluster-id-timeout-seconds"
 	KeyCollectSystemTablesTimeoutSeconds = "collect-system-tables-timeout-seconds"
+	KeyMaxCollectionTime                 = "max-collection-time"
+	// KeyMaxCPUJobs, KeyMaxRESTJobs and KeyMaxJVMAttachJobs limit how many local-collect jobs of each resource class run at once
+	KeyMaxCPUJobs       = "max-cpu-jobs"
+	KeyMaxRESTJobs      = "max-rest-jobs"
+	KeyMaxJVMAttachJobs = "max-jvm-attach-jobs"
+	// KeyFrom and KeyTo are an absolute RFC3339 window that replaces the number of days for logs and trims them to it
+	KeyFrom = "from"
+	KeyTo   = "to"
+	// KeySnapshotAt starts an incident snapshot, KeySnapshotCount captures every KeySnapshotIntervalSeconds taken at the same
+	// instants on every node. KeySnapshotClockOffsetMS is how far the clock of the node is ahead of the clock of ddc
+	KeySnapshotAt              = "snapshot-at"
+	KeySnapshotCount           = "snapshot-count"
+	KeySnapshotIntervalSeconds = "snapshot-interval-seconds"
+	KeySnapshotClockOffsetMS   = "snapshot-clock-offset-ms"
+	// KeyMaxLogsTotalMB and KeyMaxLogTypeMB cap the size of the logs collected on a node, the newest data is kept
+	KeyMaxLogsTotalMB = "max-logs-total-mb"
+	KeyMaxLogTypeMB   = "max-log-type-mb"
+	// KeyCustomCollectors is a list of extra files and commands to collect, see CustomCollector
+	KeyCustomCollectors = "custom-collectors"
+	// KeyWatchRules are the rules ddc watch takes a capture on, see WatchRule. Captures are kept in KeyWatchDir,
+	// the oldest removed past KeyWatchMaxCaptures. KeyWatchPollSeconds is how often the logs and heap are read,
+	// KeyWatchCooldownSeconds the default time a rule is ignored after a capture, KeyWatchCaptureSeconds how long
+	// thread dumps and JFR are taken for and KeyWatchLogTailSeconds how much of the logs before the trigger are kept
+	KeyWatchRules           = "watch-rules"
+	KeyWatchDir             = "watch-dir"
+	KeyWatchMaxCaptures     = "watch-max-captures"
+	KeyWatchPollSeconds     = "watch-poll-seconds"
+	KeyWatchCooldownSeconds = "watch-cooldown-seconds"
+	KeyWatchCaptureSeconds  = "watch-capture-seconds"
+	KeyWatchLogTailSeconds  = "watch-log-tail-seconds"
+	// KeyCollectionModes declares named --collect modes, each a set of key overrides on top of another mode
+	KeyCollectionModes = "collection-modes"
+	// KeyCollectionModesFile is a yaml file with more collection-modes, relative paths are from the ddc.yaml folder
+	KeyCollectionModesFile = "collection-modes-file"
+	// KeyConfFilesInclude and KeyConfFilesExclude are globs selecting the files of the dremio conf dir to collect besides
+	// dremio.conf, dremio-env and the logback files, KeyConfFilesMaxFileSizeMB and KeyConfFilesMaxTotalSizeMB limit their size
+	KeyConfFilesInclude        = "conf-files-include"
+	KeyConfFilesExclude        = "conf-files-exclude"
+	KeyConfFilesMaxFileSizeMB  = "conf-files-max-file-size-mb"
+	KeyConfFilesMaxTotalSizeMB = "conf-files-max-total-size-mb"
 )
@@ -25,7 +25,9 @@ import (
 	"testing"
 
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf/autodetect"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/collects"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/hocon"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
 )
@@ -593,3 +595,95 @@ func TestEnvVarsForLogging(t *testing.T) {
 
 	afterEachConfTest()
 }
+
+func TestConfReadsLogBudgets(t *testing.T) {
+	genericConfSetup("")
+	defer afterEachConfTest()
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+	overrides[conf.KeyMaxLogsTotalMB] = "10"
+	overrides[conf.KeyMaxLogTypeMB] = `{"server": 2, "default": 1}`
+	cfg, err = conf.ReadConf(hook, overrides, cfgFilePath, collects.StandardCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MaxLogsTotalBytes() != 10*1024*1024 {
+		t.Errorf("expected 10 MB total but got %v bytes", cfg.MaxLogsTotalBytes())
+	}
+	perType := cfg.MaxLogTypeBytes()
+	if perType["server"] != 2*1024*1024 || perType["default"] != 1024*1024 {
+		t.Errorf("unexpected per log type budgets %v", perType)
+	}
+
+	overrides[conf.KeyMaxLogsTotalMB] = "-1"
+	if _, err := conf.ReadConf(hook, overrides, cfgFilePath, collects.StandardCollection); err == nil || !strings.Contains(err.Error(), conf.KeyMaxLogsTotalMB) {
+		t.Errorf("expected an error for a negative total budget but got %v", err)
+	}
+}
+
+func TestDetectRocksDB(t *testing.T) {
+	confDir := t.TempDir()
+	write := func(text string) {
+		if err := os.WriteFile(filepath.Join(confDir, "dremio.conf"), []byte(text), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("paths: {\n  local: ${DREMIO_HOME}\"/mydata\"\n  dist: \"pdfs://\"${paths.local}\"/pdfs\"\n}\n")
+	if dir := conf.DetectRocksDB("/opt/dremio", confDir); dir != filepath.Join("/opt/dremio", "mydata", "db") {
+		t.Errorf("expected the db under paths.local but got %v", dir)
+	}
+	write("paths {\n  local: /var/lib/dremio\n  db: ${paths.local}/catalog-db\n}\n")
+	if dir := conf.DetectRocksDB("/opt/dremio", confDir); dir != "/var/lib/dremio/catalog-db" {
+		t.Errorf("expected paths.db but got %v", dir)
+	}
+	if dir := conf.DetectRocksDB("/opt/dremio", filepath.Join(confDir, "missing")); dir != filepath.Join("/opt/dremio", "data", "db") {
+		t.Errorf("expected the default db dir but got %v", dir)
+	}
+	for dist, expected := range map[string]string{"dremioAzureStorage://:///c/p": "azure", "hdfs://nn:8020/d": "hdfs", "file:///d": "local", "dremiogcs:///b": "gcs", "/no/scheme": "unknown"} {
+		if got := conf.DistStoreType(dist); got != expected {
+			t.Errorf("expected %v for %v but got %v", expected, dist, got)
+		}
+	}
+}
+
+func TestDremioRoles(t *testing.T) {
+	dremioConf, err := hocon.Parse("services: {\n  coordinator.enabled: false\n  executor.enabled: true\n}\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		cfg      *hocon.Config
+		args     string
+		expected string
+	}{
+		{nil, "", "master+executor"},
+		{dremioConf, "", "executor"},
+		{dremioConf, "-Dservices.coordinator.enabled=true -Dservices.executor.enabled=false", "master"},
+		{nil, "-Dservices.coordinator.master.enabled=false -Dservices.executor.enabled=false", "coordinator"},
+		{nil, "-Dservices.coordinator.enabled=false -Dservices.executor.enabled=false", "unknown"},
+	} {
+		if role := conf.RoleName(conf.DremioRoles(tc.cfg, tc.args)); role != tc.expected {
+			t.Errorf("expected %v for args '%v' but got %v", tc.expected, tc.args, role)
+		}
+	}
+}
+
+func TestClassifyDremioJVMs(t *testing.T) {
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+	// the pids do not exist so the roles come from the jvm flags alone
+	jvms := conf.ClassifyDremioJVMs(hook, []autodetect.DremioJVM{
+		{PID: 999999903, Preview: true},
+		{PID: 999999902, Args: "-Dservices.coordinator.enabled=false"},
+		{PID: 999999901, Args: "-Dservices.coordinator.enabled=false"},
+		{PID: 999999904, Args: "-Dservices.executor.enabled=false"},
+	})
+	var subNodes []string
+	for _, j := range jvms {
+		subNodes = append(subNodes, j.SubNode())
+	}
+	expected := []string{"master-999999904", "executor-999999901", "executor-999999902", "preview-999999903"}
+	if strings.Join(subNodes, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v but got %v", expected, subNodes)
+	}
+}
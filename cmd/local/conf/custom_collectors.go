@@ -0,0 +1,209 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+// CustomCollector is an entry of custom-collectors in ddc.yaml, it either copies the files matching
+// the globs or runs the command and writes its output
+type CustomCollector struct {
+	Name  string
+	Files []string
+	// MaxFileSizeBytes skips bigger files, 0 is no limit
+	MaxFileSizeBytes int64
+	// MaxTotalSizeBytes stops copying files once reached, 0 is no limit
+	MaxTotalSizeBytes int64
+	Command           string
+	TimeoutSeconds    int
+	// OutputFile is where the command output is written, defaults to <name>.txt
+	OutputFile string
+	// Modes are the collection modes the collector runs in, empty is every mode
+	Modes []string
+	// MaskPatterns are replaced in the collected text
+	MaskPatterns []*regexp.Regexp
+	// MaskSecrets masks the values of lines that look like they contain a password or secret
+	MaskSecrets bool
+}
+
+// AllowsMode is true when the collector runs in the collection mode, pass the lineage of a custom mode
+// so a collector limited to standard also runs in the modes extending it
+func (cc CustomCollector) AllowsMode(lineage ...string) bool {
+	if len(cc.Modes) == 0 {
+		return true
+	}
+	for _, m := range cc.Modes {
+		if slices.Contains(lineage, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// JobName is the name the collector is reported with in the job results
+func (cc CustomCollector) JobName() string {
+	return fmt.Sprintf("CUSTOM COLLECTION %v", cc.Name)
+}
+
+const defaultCustomCollectorTimeoutSeconds = 60
+
+// SkippedFile lists the files a collector left out and why, it is written next to what the collector collected
+const SkippedFile = "ddc-skipped.txt"
+
+var (
+	customCollectorKeys = []string{"name", "files", "max-file-size-mb", "max-total-size-mb", "command", "timeout-seconds", "output-file", "modes", "mask-patterns", "mask-secrets"}
+	safeName            = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+)
+
+// ParseCustomCollectors reads the custom-collectors list, every problem with an entry is an error naming the entry.
+// modes are the collection modes an entry can be limited to, see CollectionModeNames
+func ParseCustomCollectors(raw interface{}, modes []string) ([]CustomCollector, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	entries, err := cast.ToSliceE(raw)
+	if err != nil {
+		return nil, fmt.Errorf("INVALID CONFIGURATION: %v must be a list: %w", KeyCustomCollectors, err)
+	}
+	var collectors []CustomCollector
+	names := make(map[string]bool)
+	for i, e := range entries {
+		entry, err := cast.ToStringMapE(e)
+		if err != nil {
+			return nil, fmt.Errorf("INVALID CONFIGURATION: %v entry %v must be a map: %w", KeyCustomCollectors, i+1, err)
+		}
+		cc, err := parseCustomCollector(entry, modes)
+		if err != nil {
+			label := fmt.Sprintf("entry %v", i+1)
+			if cc.Name != "" {
+				label = fmt.Sprintf("'%v'", cc.Name)
+			}
+			return nil, fmt.Errorf("INVALID CONFIGURATION: %v %v: %w", KeyCustomCollectors, label, err)
+		}
+		if names[cc.Name] {
+			return nil, fmt.Errorf("INVALID CONFIGURATION: %v has more than one entry named '%v'", KeyCustomCollectors, cc.Name)
+		}
+		names[cc.Name] = true
+		collectors = append(collectors, cc)
+	}
+	return collectors, nil
+}
+
+func parseCustomCollector(entry map[string]interface{}, modes []string) (CustomCollector, error) {
+	var cc CustomCollector
+	cc.Name = cast.ToString(entry["name"])
+	var unknown []string
+	for k := range entry {
+		if !slices.Contains(customCollectorKeys, k) {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return cc, fmt.Errorf("unknown keys %v, the supported keys are %v", strings.Join(unknown, ", "), strings.Join(customCollectorKeys, ", "))
+	}
+	if !safeName.MatchString(cc.Name) {
+		return cc, fmt.Errorf("name '%v' must only use letters, numbers, '.', '_' and '-' as it is used as the folder name", cc.Name)
+	}
+	var err error
+	if cc.Files, err = optionalList(entry, "files"); err != nil {
+		return cc, fmt.Errorf("files must be a list of globs: %w", err)
+	}
+	cc.Command = strings.TrimSpace(cast.ToString(entry["command"]))
+	if len(cc.Files) == 0 && cc.Command == "" {
+		return cc, fmt.Errorf("either files or command is required")
+	}
+	if len(cc.Files) > 0 && cc.Command != "" {
+		return cc, fmt.Errorf("only one of files or command can be set, use two entries to do both")
+	}
+	for _, f := range cc.Files {
+		if _, err := filepath.Match(f, ""); err != nil {
+			return cc, fmt.Errorf("invalid glob '%v': %w", f, err)
+		}
+	}
+	if cc.MaxFileSizeBytes, err = mbToBytes(entry, "max-file-size-mb"); err != nil {
+		return cc, err
+	}
+	if cc.MaxTotalSizeBytes, err = mbToBytes(entry, "max-total-size-mb"); err != nil {
+		return cc, err
+	}
+	cc.TimeoutSeconds = defaultCustomCollectorTimeoutSeconds
+	if v, ok := entry["timeout-seconds"]; ok {
+		if cc.TimeoutSeconds, err = cast.ToIntE(v); err != nil || cc.TimeoutSeconds < 1 {
+			return cc, fmt.Errorf("timeout-seconds must be at least 1 but was '%v'", v)
+		}
+	}
+	cc.OutputFile = cast.ToString(entry["output-file"])
+	if cc.OutputFile == "" {
+		cc.OutputFile = cc.Name + ".txt"
+	}
+	if filepath.Base(cc.OutputFile) != cc.OutputFile {
+		return cc, fmt.Errorf("output-file '%v' must be a file name without a directory", cc.OutputFile)
+	}
+	if cc.Modes, err = optionalList(entry, "modes"); err != nil {
+		return cc, fmt.Errorf("modes must be a list: %w", err)
+	}
+	for _, m := range cc.Modes {
+		if !slices.Contains(modes, m) {
+			return cc, fmt.Errorf("unknown mode '%v', the modes are %v", m, strings.Join(modes, ", "))
+		}
+	}
+	patterns, err := optionalList(entry, "mask-patterns")
+	if err != nil {
+		return cc, fmt.Errorf("mask-patterns must be a list of regular expressions: %w", err)
+	}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return cc, fmt.Errorf("invalid mask pattern '%v': %w", p, err)
+		}
+		cc.MaskPatterns = append(cc.MaskPatterns, re)
+	}
+	if v, ok := entry["mask-secrets"]; ok {
+		if cc.MaskSecrets, err = cast.ToBoolE(v); err != nil {
+			return cc, fmt.Errorf("mask-secrets must be true or false but was '%v'", v)
+		}
+	}
+	return cc, nil
+}
+
+// optionalList reads a list of strings, a missing key is an empty list
+func optionalList(entry map[string]interface{}, key string) ([]string, error) {
+	v, ok := entry[key]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	return cast.ToStringSliceE(v)
+}
+
+func mbToBytes(entry map[string]interface{}, key string) (int64, error) {
+	v, ok := entry[key]
+	if !ok {
+		return 0, nil
+	}
+	mb, err := cast.ToInt64E(v)
+	if err != nil || mb < 0 {
+		return 0, fmt.Errorf("%v must be a number of MB of 0 or more but was '%v'", key, v)
+	}
+	return mb * 1024 * 1024, nil
+}
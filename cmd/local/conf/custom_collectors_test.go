@@ -0,0 +1,100 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"gopkg.in/yaml.v3"
+)
+
+func parseCustom(t *testing.T, text string) ([]conf.CustomCollector, error) {
+	var data map[string]interface{}
+	if err := yaml.Unmarshal([]byte(text), &data); err != nil {
+		t.Fatal(err)
+	}
+	return conf.ParseCustomCollectors(data[conf.KeyCustomCollectors], conf.CollectionModeNames(nil))
+}
+
+func TestParseCustomCollectors(t *testing.T) {
+	collectors, err := parseCustom(t, `
+custom-collectors:
+  - name: hosts
+    files: ["/etc/hosts", "/opt/dremio/conf/connectors/*.json"]
+    max-file-size-mb: 1
+    max-total-size-mb: 10
+  - name: vendor-health
+    command: /opt/vendor/health.sh --all
+    timeout-seconds: 30
+    modes: [standard, health-check]
+    mask-patterns: ["token=\\S+"]
+    mask-secrets: true
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collectors) != 2 {
+		t.Fatalf("expected 2 collectors but got %v", len(collectors))
+	}
+	hosts, vendor := collectors[0], collectors[1]
+	if len(hosts.Files) != 2 || hosts.MaxFileSizeBytes != 1024*1024 || hosts.MaxTotalSizeBytes != 10*1024*1024 || hosts.TimeoutSeconds != 60 {
+		t.Errorf("unexpected files collector %#v", hosts)
+	}
+	if !hosts.AllowsMode("light") {
+		t.Error("expected a collector without modes to run in every mode")
+	}
+	if vendor.OutputFile != "vendor-health.txt" || vendor.TimeoutSeconds != 30 || !vendor.MaskSecrets || len(vendor.MaskPatterns) != 1 {
+		t.Errorf("unexpected command collector %#v", vendor)
+	}
+	if vendor.AllowsMode("light") || !vendor.AllowsMode("health-check") {
+		t.Errorf("expected the command collector to only allow its modes but has %v", vendor.Modes)
+	}
+	if !vendor.AllowsMode("perf", "standard") {
+		t.Error("expected the command collector to run in a custom mode extending one of its modes")
+	}
+	if vendor.JobName() != "CUSTOM COLLECTION vendor-health" {
+		t.Errorf("unexpected job name %v", vendor.JobName())
+	}
+}
+
+func TestParseCustomCollectorsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		expected string
+	}{
+		{"not a list", "custom-collectors: hosts", "must be a list"},
+		{"no name", "custom-collectors:\n  - command: ls", "name '' must only use"},
+		{"bad name", "custom-collectors:\n  - name: ../etc\n    command: ls", "name '../etc' must only use"},
+		{"nothing to do", "custom-collectors:\n  - name: a", "'a': either files or command is required"},
+		{"both", "custom-collectors:\n  - name: a\n    command: ls\n    files: [/etc/hosts]", "only one of files or command"},
+		{"unknown key", "custom-collectors:\n  - name: a\n    comand: ls", "unknown keys comand"},
+		{"unknown mode", "custom-collectors:\n  - name: a\n    command: ls\n    modes: [full]", "unknown mode 'full'"},
+		{"bad timeout", "custom-collectors:\n  - name: a\n    command: ls\n    timeout-seconds: 0", "timeout-seconds must be at least 1"},
+		{"bad pattern", "custom-collectors:\n  - name: a\n    command: ls\n    mask-patterns: ['(']", "invalid mask pattern"},
+		{"output dir", "custom-collectors:\n  - name: a\n    command: ls\n    output-file: ../a.txt", "must be a file name"},
+		{"duplicate", "custom-collectors:\n  - name: a\n    command: ls\n  - name: a\n    command: ls", "more than one entry named 'a'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseCustom(t, tt.yaml)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected error containing %q but got %v", tt.expected, err)
+			}
+		})
+	}
+}
@@ -30,21 +30,29 @@ func SetViperDefaults(confData map[string]interface{}, hostName string, defaultC
 	if collectionMode == collects.QuickCollection {
 		setDefault(confData, KeyCollectJFR, false)
 		setDefault(confData, KeyCollectTtop, false)
+		setDefault(confData, KeyCollectJVMPerfCounters, false)
 		setDefault(confData, KeyDremioLogsNumDays, 2)
 		setDefault(confData, KeyDremioQueriesJSONNumDays, 2)
 		setDefault(confData, KeyNumberThreads, 1)
 	} else {
 		setDefault(confData, KeyCollectJFR, true)
 		setDefault(confData, KeyCollectTtop, true)
+		setDefault(confData, KeyCollectJVMPerfCounters, true)
 		setDefault(confData, KeyDremioLogsNumDays, 7)
 		setDefault(confData, KeyDremioQueriesJSONNumDays, 30)
 		setDefault(confData, KeyNumberThreads, 2)
 	}
-	if collectionMode == collects.StandardPlusJSTACKCollection {
-		setDefault(confData, KeyCollectJStack, true)
-	} else {
-		setDefault(confData, KeyCollectJStack, false)
-	}
+	// the extended jvm diagnostics are what memory escalations need on top of the thread dumps
+	extendedJVMDiagnostics := collectionMode == collects.StandardPlusJSTACKCollection
+	setDefault(confData, KeyCollectJStack, extendedJVMDiagnostics)
+	setDefault(confData, KeyCollectJVMNativeMemory, extendedJVMDiagnostics)
+	setDefault(confData, KeyCollectJVMHeapInfo, extendedJVMDiagnostics)
+	setDefault(confData, KeyCollectJVMClassHistogram, extendedJVMDiagnostics)
+	setDefault(confData, KeyCollectJVMClassHistogramTrend, extendedJVMDiagnostics)
+	setDefault(confData, KeyCollectJVMSystemProperties, extendedJVMDiagnostics)
+	setDefault(confData, KeyCollectJVMInfo, extendedJVMDiagnostics)
+	setDefault(confData, KeyCollectJVMCodeCache, extendedJVMDiagnostics)
+	setDefault(confData, KeyCollectJVMMetaspace, extendedJVMDiagnostics)
 	if collectionMode == collects.HealthCheckCollection {
 		setDefault(confData, KeyNumberJobProfiles, 25000)
 	} else {
@@ -67,6 +75,8 @@ func SetViperDefaults(confData map[string]interface{}, hostName string, defaultC
 	setDefault(confData, KeyDremioRocksdbDir, "/opt/dremio/data/db")
 	setDefault(confData, KeyCollectDremioConfiguration, true)
 	setDefault(confData, KeyCaptureHeapDump, false)
+	setDefault(confData, KeyHeapDumpLiveObjects, false)
+	setDefault(confData, KeyHeapDumpDir, "")
 	setDefault(confData, KeyDremioEndpoint, "http://localhost:9047")
 	setDefault(confData, KeyTarballOutDir, "/tmp/ddc")
 	setDefault(confData, KeyCollectOSConfig, true)
@@ -78,6 +88,7 @@ func SetViperDefaults(confData map[string]interface{}, hostName string, defaultC
 	setDefault(confData, KeyCollectMetaRefreshLog, true)
 	setDefault(confData, KeyCollectReflectionLog, true)
 	setDefault(confData, KeyCollectVacuumLog, true)
+	setDefault(confData, KeyCollectExtraLogs, true)
 	setDefault(confData, KeyCollectGCLogs, true)
 	setDefault(confData, KeyCollectSystemTablesExport, true)
 	setDefault(confData, KeySystemTablesRowLimit, 100000)
@@ -85,9 +96,36 @@ func SetViperDefaults(confData map[string]interface{}, hostName string, defaultC
 	setDefault(confData, KeyCollectKVStoreReport, true)
 	setDefault(confData, KeyDremioJStackTimeSeconds, defaultCaptureSeconds)
 	setDefault(confData, KeyDremioJFRTimeSeconds, defaultCaptureSeconds)
+	setDefault(confData, KeyJFRSettings, "profile")
+	setDefault(confData, KeyJFRSettingsJFC, "")
+	setDefault(confData, KeyJFRMaxSizeMB, 0)
+	setDefault(confData, KeyJFRMaxAgeSeconds, 0)
+	setDefault(confData, KeyJFRDumpExisting, false)
+	setDefault(confData, KeyJFRExistingRecordings, []string{})
+	setDefault(confData, KeyJFRExistingMaxAgeSeconds, 0)
 	setDefault(confData, KeyDremioJStackFreqSeconds, 1)
 	setDefault(confData, KeyDremioTtopFreqSeconds, 1)
 	setDefault(confData, KeyDremioTtopTimeSeconds, defaultCaptureSeconds)
+	setDefault(confData, KeyCollectHotThreads, true)
+	setDefault(confData, KeyHotThreadsTopN, 10)
+	setDefault(confData, KeySnapshotAt, "")
+	setDefault(confData, KeySnapshotCount, 3)
+	setDefault(confData, KeySnapshotIntervalSeconds, 10)
+	setDefault(confData, KeySnapshotClockOffsetMS, 0)
+	setDefault(confData, KeyWatchDir, "/tmp/ddc-watch")
+	setDefault(confData, KeyWatchMaxCaptures, 5)
+	setDefault(confData, KeyWatchPollSeconds, 5)
+	setDefault(confData, KeyWatchCooldownSeconds, 900)
+	setDefault(confData, KeyWatchCaptureSeconds, 30)
+	setDefault(confData, KeyWatchLogTailSeconds, 600)
+	setDefault(confData, KeyJVMPerfCountersFreqSeconds, 5)
+	setDefault(confData, KeyJVMPerfCountersTimeSeconds, defaultCaptureSeconds)
+	setDefault(confData, KeyJVMClassHistogramCount, 3)
+	setDefault(confData, KeyJVMClassHistogramIntervalSeconds, 10)
+	// every sample forces a full gc, which takes seconds on the large heaps the trend is meant for
+	setDefault(confData, KeyJVMClassHistogramTrendFreqSeconds, 30)
+	setDefault(confData, KeyJVMClassHistogramTrendTimeSeconds, defaultCaptureSeconds)
+	setDefault(confData, KeyJVMClassHistogramTrendTopN, 20)
 	setDefault(confData, KeyDremioGCLogsDir, "")
 	setDefault(confData, KeyNodeName, hostName)
 	setDefault(confData, KeyAcceptCollectionConsent, true)
@@ -99,4 +137,14 @@ func SetViperDefaults(confData map[string]interface{}, hostName string, defaultC
 	setDefault(confData, KeyMinFreeSpaceGB, 40)
 	setDefault(confData, KeyCollectSystemTablesTimeoutSeconds, 60)
 	setDefault(confData, KeyCollectClusterIDTimeoutSeconds, 60)
+	setDefault(confData, KeyMaxCPUJobs, 2)
+	setDefault(confData, KeyMaxRESTJobs, 2)
+	setDefault(confData, KeyMaxJVMAttachJobs, 3)
+	setDefault(confData, KeyMaxLogsTotalMB, 0)
+	setDefault(confData, KeyMaxLogTypeMB, map[string]interface{}{})
+	setDefault(confData, KeyConfFilesInclude, []string{"*"})
+	// keystores and keys are secrets that cannot be masked, ..* are the hidden folders of kubernetes config map mounts
+	setDefault(confData, KeyConfFilesExclude, []string{"*.jks", "*.jceks", "*.p12", "*.pfx", "*.keystore", "*.truststore", "*.keytab", "*.key", "*.pem", "..*"})
+	setDefault(confData, KeyConfFilesMaxFileSizeMB, 10)
+	setDefault(confData, KeyConfFilesMaxTotalSizeMB, 100)
 }
@@ -55,6 +55,8 @@ func TestSetViperDefaultsWithHealthCheck(t *testing.T) {
 		{conf.KeyDremioRocksdbDir, "/opt/dremio/data/db"},
 		{conf.KeyCollectDremioConfiguration, true},
 		{conf.KeyCaptureHeapDump, false},
+		{conf.KeyHeapDumpLiveObjects, false},
+		{conf.KeyHeapDumpDir, ""},
 		{conf.KeyNumberJobProfiles, 25000},
 		{conf.KeyDremioEndpoint, "http://localhost:9047"},
 		{conf.KeyTarballOutDir, "/tmp/ddc"},
@@ -74,12 +76,41 @@ func TestSetViperDefaultsWithHealthCheck(t *testing.T) {
 		{conf.KeyCollectSystemTablesExport, true},
 		{conf.KeyCollectWLM, true},
 		{conf.KeyCollectTtop, true},
+		{conf.KeyCollectJVMPerfCounters, true},
 		{conf.KeyCollectKVStoreReport, true},
 		{conf.KeyDremioJStackTimeSeconds, defaultCaptureSeconds},
 		{conf.KeyDremioJFRTimeSeconds, defaultCaptureSeconds},
+		{conf.KeyJFRSettings, "profile"},
+		{conf.KeyJFRSettingsJFC, ""},
+		{conf.KeyJFRMaxSizeMB, 0},
+		{conf.KeyJFRMaxAgeSeconds, 0},
+		{conf.KeyJFRDumpExisting, false},
+		{conf.KeyJFRExistingMaxAgeSeconds, 0},
 		{conf.KeyDremioJStackFreqSeconds, 1},
 		{conf.KeyDremioTtopFreqSeconds, 1},
 		{conf.KeyDremioTtopTimeSeconds, defaultCaptureSeconds},
+		{conf.KeyCollectHotThreads, true},
+		{conf.KeyHotThreadsTopN, 10},
+		{conf.KeySnapshotAt, ""},
+		{conf.KeySnapshotCount, 3},
+		{conf.KeySnapshotIntervalSeconds, 10},
+		{conf.KeySnapshotClockOffsetMS, 0},
+		{conf.KeyWatchDir, "/tmp/ddc-watch"},
+		{conf.KeyWatchMaxCaptures, 5},
+		{conf.KeyWatchPollSeconds, 5},
+		{conf.KeyWatchCooldownSeconds, 900},
+		{conf.KeyWatchCaptureSeconds, 30},
+		{conf.KeyWatchLogTailSeconds, 600},
+		{conf.KeyJVMPerfCountersFreqSeconds, 5},
+		{conf.KeyJVMPerfCountersTimeSeconds, defaultCaptureSeconds},
+		{conf.KeyCollectJVMNativeMemory, false},
+		{conf.KeyCollectJVMClassHistogram, false},
+		{conf.KeyJVMClassHistogramCount, 3},
+		{conf.KeyJVMClassHistogramIntervalSeconds, 10},
+		{conf.KeyCollectJVMClassHistogramTrend, false},
+		{conf.KeyJVMClassHistogramTrendFreqSeconds, 30},
+		{conf.KeyJVMClassHistogramTrendTimeSeconds, defaultCaptureSeconds},
+		{conf.KeyJVMClassHistogramTrendTopN, 20},
 		{conf.KeyDremioGCLogsDir, ""},
 		{conf.KeyNodeName, hostName},
 		{conf.KeyAcceptCollectionConsent, true},
@@ -117,6 +148,8 @@ func TestSetViperDefaultsQuickCollect(t *testing.T) {
 		{conf.KeyDremioRocksdbDir, "/opt/dremio/data/db"},
 		{conf.KeyCollectDremioConfiguration, true},
 		{conf.KeyCaptureHeapDump, false},
+		{conf.KeyHeapDumpLiveObjects, false},
+		{conf.KeyHeapDumpDir, ""},
 		{conf.KeyNumberJobProfiles, 20},
 		{conf.KeyDremioEndpoint, "http://localhost:9047"},
 		{conf.KeyTarballOutDir, "/tmp/ddc"},
@@ -136,12 +169,41 @@ func TestSetViperDefaultsQuickCollect(t *testing.T) {
 		{conf.KeyCollectSystemTablesExport, true},
 		{conf.KeyCollectWLM, true},
 		{conf.KeyCollectTtop, false},
+		{conf.KeyCollectJVMPerfCounters, false},
 		{conf.KeyCollectKVStoreReport, true},
 		{conf.KeyDremioJStackTimeSeconds, defaultCaptureSeconds},
 		{conf.KeyDremioJFRTimeSeconds, defaultCaptureSeconds},
+		{conf.KeyJFRSettings, "profile"},
+		{conf.KeyJFRSettingsJFC, ""},
+		{conf.KeyJFRMaxSizeMB, 0},
+		{conf.KeyJFRMaxAgeSeconds, 0},
+		{conf.KeyJFRDumpExisting, false},
+		{conf.KeyJFRExistingMaxAgeSeconds, 0},
 		{conf.KeyDremioJStackFreqSeconds, 1},
 		{conf.KeyDremioTtopFreqSeconds, 1},
 		{conf.KeyDremioTtopTimeSeconds, defaultCaptureSeconds},
+		{conf.KeyCollectHotThreads, true},
+		{conf.KeyHotThreadsTopN, 10},
+		{conf.KeySnapshotAt, ""},
+		{conf.KeySnapshotCount, 3},
+		{conf.KeySnapshotIntervalSeconds, 10},
+		{conf.KeySnapshotClockOffsetMS, 0},
+		{conf.KeyWatchDir, "/tmp/ddc-watch"},
+		{conf.KeyWatchMaxCaptures, 5},
+		{conf.KeyWatchPollSeconds, 5},
+		{conf.KeyWatchCooldownSeconds, 900},
+		{conf.KeyWatchCaptureSeconds, 30},
+		{conf.KeyWatchLogTailSeconds, 600},
+		{conf.KeyJVMPerfCountersFreqSeconds, 5},
+		{conf.KeyJVMPerfCountersTimeSeconds, defaultCaptureSeconds},
+		{conf.KeyCollectJVMNativeMemory, false},
+		{conf.KeyCollectJVMClassHistogram, false},
+		{conf.KeyJVMClassHistogramCount, 3},
+		{conf.KeyJVMClassHistogramIntervalSeconds, 10},
+		{conf.KeyCollectJVMClassHistogramTrend, false},
+		{conf.KeyJVMClassHistogramTrendFreqSeconds, 30},
+		{conf.KeyJVMClassHistogramTrendTimeSeconds, defaultCaptureSeconds},
+		{conf.KeyJVMClassHistogramTrendTopN, 20},
 		{conf.KeyDremioGCLogsDir, ""},
 		{conf.KeyNodeName, hostName},
 		{conf.KeyAcceptCollectionConsent, true},
@@ -177,6 +239,8 @@ func TestSetViperDefaults(t *testing.T) {
 		{conf.KeyDremioRocksdbDir, "/opt/dremio/data/db"},
 		{conf.KeyCollectDremioConfiguration, true},
 		{conf.KeyCaptureHeapDump, false},
+		{conf.KeyHeapDumpLiveObjects, false},
+		{conf.KeyHeapDumpDir, ""},
 		{conf.KeyNumberJobProfiles, 20},
 		{conf.KeyDremioEndpoint, "http://localhost:9047"},
 		{conf.KeyTarballOutDir, "/tmp/ddc"},
@@ -196,12 +260,41 @@ func TestSetViperDefaults(t *testing.T) {
 		{conf.KeyCollectSystemTablesExport, true},
 		{conf.KeyCollectWLM, true},
 		{conf.KeyCollectTtop, true},
+		{conf.KeyCollectJVMPerfCounters, true},
 		{conf.KeyCollectKVStoreReport, true},
 		{conf.KeyDremioJStackTimeSeconds, defaultCaptureSeconds},
 		{conf.KeyDremioJFRTimeSeconds, defaultCaptureSeconds},
+		{conf.KeyJFRSettings, "profile"},
+		{conf.KeyJFRSettingsJFC, ""},
+		{conf.KeyJFRMaxSizeMB, 0},
+		{conf.KeyJFRMaxAgeSeconds, 0},
+		{conf.KeyJFRDumpExisting, false},
+		{conf.KeyJFRExistingMaxAgeSeconds, 0},
 		{conf.KeyDremioJStackFreqSeconds, 1},
 		{conf.KeyDremioTtopFreqSeconds, 1},
 		{conf.KeyDremioTtopTimeSeconds, defaultCaptureSeconds},
+		{conf.KeyCollectHotThreads, true},
+		{conf.KeyHotThreadsTopN, 10},
+		{conf.KeySnapshotAt, ""},
+		{conf.KeySnapshotCount, 3},
+		{conf.KeySnapshotIntervalSeconds, 10},
+		{conf.KeySnapshotClockOffsetMS, 0},
+		{conf.KeyWatchDir, "/tmp/ddc-watch"},
+		{conf.KeyWatchMaxCaptures, 5},
+		{conf.KeyWatchPollSeconds, 5},
+		{conf.KeyWatchCooldownSeconds, 900},
+		{conf.KeyWatchCaptureSeconds, 30},
+		{conf.KeyWatchLogTailSeconds, 600},
+		{conf.KeyJVMPerfCountersFreqSeconds, 5},
+		{conf.KeyJVMPerfCountersTimeSeconds, defaultCaptureSeconds},
+		{conf.KeyCollectJVMNativeMemory, false},
+		{conf.KeyCollectJVMClassHistogram, false},
+		{conf.KeyJVMClassHistogramCount, 3},
+		{conf.KeyJVMClassHistogramIntervalSeconds, 10},
+		{conf.KeyCollectJVMClassHistogramTrend, false},
+		{conf.KeyJVMClassHistogramTrendFreqSeconds, 30},
+		{conf.KeyJVMClassHistogramTrendTimeSeconds, defaultCaptureSeconds},
+		{conf.KeyJVMClassHistogramTrendTopN, 20},
 		{conf.KeyDremioGCLogsDir, ""},
 		{conf.KeyNodeName, hostName},
 		{conf.KeyAcceptCollectionConsent, true},
@@ -215,3 +308,22 @@ func TestSetViperDefaults(t *testing.T) {
 		}
 	}
 }
+
+func TestSetViperDefaultsStandardPlusJStack(t *testing.T) {
+	confData, _, _ := setupTestSetViperDefaults(collects.StandardPlusJSTACKCollection)
+	for _, key := range []string{
+		conf.KeyCollectJStack,
+		conf.KeyCollectJVMNativeMemory,
+		conf.KeyCollectJVMHeapInfo,
+		conf.KeyCollectJVMClassHistogram,
+		conf.KeyCollectJVMSystemProperties,
+		conf.KeyCollectJVMInfo,
+		conf.KeyCollectJVMCodeCache,
+		conf.KeyCollectJVMMetaspace,
+		conf.KeyCollectJVMClassHistogramTrend,
+	} {
+		if actual := confData[key]; actual != true {
+			t.Errorf("Unexpected value for '%s'. Got %v, expected true", key, actual)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/hocon"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+)
+
+// ParseDremioConf parses dremio.conf of the conf dir, dremioHome is used for ${DREMIO_HOME} when it is set
+func ParseDremioConf(dremioHome, dremioConfDir string) (*hocon.Config, error) {
+	dremioConfFile := filepath.Join(dremioConfDir, "dremio.conf")
+	content, err := os.ReadFile(filepath.Clean(dremioConfFile))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %v: %w", dremioConfFile, err)
+	}
+	vars := make(map[string]string)
+	if dremioHome != "" {
+		vars["DREMIO_HOME"] = dremioHome
+	}
+	cfg, err := hocon.Parse(string(content), vars)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %v: %w", dremioConfFile, err)
+	}
+	return cfg, nil
+}
+
+// DremioLocalPath is paths.local or the dremio default of DREMIO_HOME/data
+func DremioLocalPath(cfg *hocon.Config, dremioHome string) string {
+	if v, ok := cfg.GetString("paths.local"); ok && v != "" {
+		return v
+	}
+	return filepath.Join(dremioHome, "data")
+}
+
+// DremioDBPath is where rocksdb is, paths.db or the dremio default of paths.local/db
+func DremioDBPath(cfg *hocon.Config, dremioHome string) string {
+	if v, ok := cfg.GetString("paths.db"); ok && v != "" {
+		return v
+	}
+	return filepath.Join(DremioLocalPath(cfg, dremioHome), "db")
+}
+
+// DremioDistPath is paths.dist or the dremio default of pdfs://paths.local/pdfs
+func DremioDistPath(cfg *hocon.Config, dremioHome string) string {
+	if v, ok := cfg.GetString("paths.dist"); ok && v != "" {
+		return v
+	}
+	return "pdfs://" + filepath.Join(DremioLocalPath(cfg, dremioHome), "pdfs")
+}
+
+// DistStoreType names the distributed store from the scheme of paths.dist such as s3, azure, gcs, hdfs, pdfs or local
+func DistStoreType(dist string) string {
+	i := strings.Index(dist, "://")
+	if i < 0 {
+		return "unknown"
+	}
+	switch scheme := strings.ToLower(dist[:i]); scheme {
+	case "file":
+		return "local"
+	case "s3", "s3a", "dremios3":
+		return "s3"
+	case "dremioazurestorage", "wasb", "wasbs", "abfs", "abfss", "adl":
+		return "azure"
+	case "dremiogcs", "gs":
+		return "gcs"
+	default:
+		return scheme
+	}
+}
+
+// DetectRocksDB finds the rocksdb dir from dremio.conf, when dremio.conf cannot be read the dremio default is used
+func DetectRocksDB(dremioHome string, dremioConfDir string) string {
+	cfg, err := ParseDremioConf(dremioHome, dremioConfDir)
+	if err != nil {
+		simplelog.Errorf("configuration directory incorrect : %v", err)
+		return filepath.Join(dremioHome, "data", "db")
+	}
+	return DremioDBPath(cfg, dremioHome)
+}
+
+// DremioRoles reads which services of dremio.conf are enabled, dremio enables every role by default.
+// The -Dservices.* system properties of jvmArgs win over dremio.conf, cfg may be nil when dremio.conf cannot be read
+func DremioRoles(cfg *hocon.Config, jvmArgs string) map[string]bool {
+	enabled := func(path string) bool {
+		prefix := "-D" + path + "="
+		for _, arg := range strings.Fields(jvmArgs) {
+			if strings.HasPrefix(arg, prefix) {
+				return strings.TrimPrefix(arg, prefix) != "false"
+			}
+		}
+		if cfg == nil {
+			return true
+		}
+		v, ok := cfg.GetString(path)
+		return !ok || v != "false"
+	}
+	coordinator := enabled("services.coordinator.enabled")
+	return map[string]bool{
+		"coordinator": coordinator,
+		"master":      coordinator && enabled("services.coordinator.master.enabled"),
+		"executor":    enabled("services.executor.enabled"),
+	}
+}
+
+// RoleName names the roles of DremioRoles such as master, coordinator, executor or master+executor
+func RoleName(roles map[string]bool) string {
+	var name string
+	switch {
+	case roles["master"]:
+		name = "master"
+	case roles["coordinator"]:
+		name = "coordinator"
+	}
+	if roles["executor"] {
+		if name == "" {
+			return "executor"
+		}
+		return name + "+executor"
+	}
+	if name == "" {
+		return "unknown"
+	}
+	return name
+}
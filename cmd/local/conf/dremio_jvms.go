@@ -0,0 +1,166 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf/autodetect"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+)
+
+// PreviewRole is the role of the preview engine, it is never collected
+const PreviewRole = "preview"
+
+// DremioJVM is a dremio jvm of the node with its role and the directories read from its environment
+type DremioJVM struct {
+	PID int `json:"pid"`
+	// Role is master, coordinator, executor, a combination such as master+executor or preview
+	Role    string `json:"role"`
+	Preview bool   `json:"preview"`
+	Home    string `json:"home,omitempty"`
+	LogDir  string `json:"logDir,omitempty"`
+	ConfDir string `json:"confDir,omitempty"`
+}
+
+// DetectDremioPID finds the dremio jvms again and returns the pid used for the node level jobs, the master or
+// coordinator when there is one
+func DetectDremioPID(hook shutdown.Hook) (int, error) {
+	jvms, err := autodetect.FindDremioJVMs(hook)
+	if err != nil {
+		return 0, err
+	}
+	for _, j := range ClassifyDremioJVMs(hook, jvms) {
+		if !j.Preview {
+			return j.PID, nil
+		}
+	}
+	return 0, errors.New("only the preview engine is running")
+}
+
+// SubNode is the folder the jvm is collected into such as executor-1234
+func (j DremioJVM) SubNode() string {
+	return fmt.Sprintf("%v-%v", j.Role, j.PID)
+}
+
+func (j DremioJVM) String() string {
+	return j.SubNode()
+}
+
+// ClassifyDremioJVMs reads the home, log and conf dirs of every jvm from ps eww and the role from its dremio.conf
+// and -Dservices.* flags. The jvms are sorted master first, then coordinators, executors and the preview engine.
+func ClassifyDremioJVMs(hook shutdown.Hook, jvms []autodetect.DremioJVM) []DremioJVM {
+	var classified []DremioJVM
+	for _, j := range jvms {
+		jvm := DremioJVM{PID: j.PID, Preview: j.Preview}
+		if j.Preview {
+			jvm.Role = PreviewRole
+			classified = append(classified, jvm)
+			continue
+		}
+		detected, err := GetConfiguredDremioValuesFromPID(hook, j.PID)
+		if err != nil {
+			simplelog.Warningf("unable to read the configuration of dremio pid %v: %v", j.PID, err)
+		} else {
+			jvm.Home = detected.Home
+			jvm.LogDir = detected.LogDir
+			jvm.ConfDir = detected.ConfDir
+		}
+		cfg, err := ParseDremioConf(jvm.Home, jvm.ConfDir)
+		if err != nil {
+			simplelog.Warningf("unable to read the roles of dremio pid %v from dremio.conf, relying on its jvm flags: %v", j.PID, err)
+		}
+		jvm.Role = RoleName(DremioRoles(cfg, j.Args))
+		classified = append(classified, jvm)
+	}
+	sort.SliceStable(classified, func(a, b int) bool {
+		ra, rb := roleRank(classified[a].Role), roleRank(classified[b].Role)
+		if ra != rb {
+			return ra < rb
+		}
+		return classified[a].PID < classified[b].PID
+	})
+	return classified
+}
+
+func roleRank(role string) int {
+	switch {
+	case strings.HasPrefix(role, "master"):
+		return 0
+	case strings.HasPrefix(role, "coordinator"):
+		return 1
+	case role == "executor":
+		return 2
+	case role == PreviewRole:
+		return 4
+	default:
+		return 3
+	}
+}
+
+// DremioJVMs are every dremio jvm found on the node including the preview engine
+func (c *CollectConf) DremioJVMs() []DremioJVM {
+	return c.dremioJVMs
+}
+
+// CollectedDremioJVMs are the dremio jvms of the node that are collected, the preview engine is skipped
+func (c *CollectConf) CollectedDremioJVMs() []DremioJVM {
+	var jvms []DremioJVM
+	for _, j := range c.dremioJVMs {
+		if !j.Preview {
+			jvms = append(jvms, j)
+		}
+	}
+	return jvms
+}
+
+// SubNode is the folder of the jvm inside the node folders, empty unless the conf came from ForJVM
+func (c *CollectConf) SubNode() string {
+	return c.subNode
+}
+
+// ForJVM is a copy of the conf for one of several dremio jvms of the node. The pid, the gc log location
+// and the log, conf and home dirs are those of the jvm and its output goes into the sub node folder.
+func (c *CollectConf) ForJVM(hook shutdown.Hook, jvm DremioJVM) *CollectConf {
+	sub := *c
+	sub.dremioPID = jvm.PID
+	sub.subNode = jvm.SubNode()
+	sub.dremioJVMs = nil
+	gcLogPattern, gcLogDir, err := autodetect.FindGCLogLocation(hook, jvm.PID)
+	if err == nil && (gcLogPattern == "" || gcLogDir == "") {
+		err = errors.New("no gc log location or pattern in the jvm flags")
+	}
+	if err != nil {
+		simplelog.Warningf("unable to detect the gc logs of dremio pid %v, using %v: %v", jvm.PID, c.gcLogsDir, err)
+	} else {
+		sub.gcLogsDir = gcLogDir
+		sub.dremioGCFilePattern = gcLogPattern
+	}
+	if jvm.Home != "" {
+		sub.dremioHome = jvm.Home
+	}
+	if jvm.LogDir != "" {
+		sub.dremioLogDir = jvm.LogDir
+	}
+	if jvm.ConfDir != "" {
+		sub.dremioConfDir = jvm.ConfDir
+		sub.dremioRocksDBDir = DetectRocksDB(sub.dremioHome, jvm.ConfDir)
+	}
+	return &sub
+}
@@ -0,0 +1,187 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// the watch log a rule reads
+const (
+	WatchLogServer = "server"
+	WatchLogGC     = "gc"
+)
+
+// what a watch capture collects
+const (
+	WatchCaptureJStack   = "jstack"
+	WatchCaptureHeapInfo = "heap-info"
+	WatchCaptureJFR      = "jfr"
+	WatchCaptureLogs     = "logs"
+)
+
+// WatchCaptures are what a capture collects when a rule does not list them
+var WatchCaptures = []string{WatchCaptureJStack, WatchCaptureHeapInfo, WatchCaptureJFR, WatchCaptureLogs}
+
+// WatchRule is an entry of watch-rules in ddc.yaml, ddc watch takes a capture when it matches.
+// Only one of LogPattern, GCPause and HeapPercent is set
+type WatchRule struct {
+	Name string
+	// LogPattern is matched against every new line of Log
+	LogPattern *regexp.Regexp
+	Log        string
+	// GCPause matches a pause of the gc logs at least this long
+	GCPause time.Duration
+	// HeapPercent matches the heap still being this full after a garbage collection
+	HeapPercent float64
+	// Cooldown is how long the rule is ignored after it took a capture
+	Cooldown time.Duration
+	// Captures are what the capture collects, see WatchCaptures
+	Captures []string
+}
+
+// Collects is true when the capture of the rule collects what
+func (r WatchRule) Collects(what string) bool {
+	return slices.Contains(r.Captures, what)
+}
+
+var watchRuleKeys = []string{"name", "log-pattern", "log", "gc-pause-ms", "heap-percent", "cooldown-seconds", "capture"}
+
+// ParseWatchRules reads the watch-rules list, every problem with an entry is an error naming the entry.
+// cooldown is used for the rules without cooldown-seconds
+func ParseWatchRules(raw interface{}, cooldown time.Duration) ([]WatchRule, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	entries, err := cast.ToSliceE(raw)
+	if err != nil {
+		return nil, fmt.Errorf("INVALID CONFIGURATION: %v must be a list: %w", KeyWatchRules, err)
+	}
+	var rules []WatchRule
+	names := make(map[string]bool)
+	for i, e := range entries {
+		entry, err := cast.ToStringMapE(e)
+		if err != nil {
+			return nil, fmt.Errorf("INVALID CONFIGURATION: %v entry %v must be a map: %w", KeyWatchRules, i+1, err)
+		}
+		r, err := parseWatchRule(entry, cooldown)
+		if err != nil {
+			label := fmt.Sprintf("entry %v", i+1)
+			if r.Name != "" {
+				label = fmt.Sprintf("'%v'", r.Name)
+			}
+			return nil, fmt.Errorf("INVALID CONFIGURATION: %v %v: %w", KeyWatchRules, label, err)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("INVALID CONFIGURATION: %v has more than one entry named '%v'", KeyWatchRules, r.Name)
+		}
+		names[r.Name] = true
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func parseWatchRule(entry map[string]interface{}, cooldown time.Duration) (WatchRule, error) {
+	var r WatchRule
+	r.Name = cast.ToString(entry["name"])
+	var unknown []string
+	for k := range entry {
+		if !slices.Contains(watchRuleKeys, k) {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return r, fmt.Errorf("unknown keys %v, the supported keys are %v", strings.Join(unknown, ", "), strings.Join(watchRuleKeys, ", "))
+	}
+	if !safeName.MatchString(r.Name) {
+		return r, fmt.Errorf("name '%v' must only use letters, numbers, '.', '_' and '-' as it is used in the capture file name", r.Name)
+	}
+	var kinds []string
+	for _, k := range []string{"log-pattern", "gc-pause-ms", "heap-percent"} {
+		if _, ok := entry[k]; ok {
+			kinds = append(kinds, k)
+		}
+	}
+	if len(kinds) != 1 {
+		return r, fmt.Errorf("exactly one of log-pattern, gc-pause-ms or heap-percent is required")
+	}
+	switch kinds[0] {
+	case "log-pattern":
+		pattern := cast.ToString(entry["log-pattern"])
+		if pattern == "" {
+			return r, fmt.Errorf("log-pattern cannot be empty")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return r, fmt.Errorf("invalid log-pattern '%v': %w", pattern, err)
+		}
+		r.LogPattern = re
+		r.Log = WatchLogServer
+		if v, ok := entry["log"]; ok {
+			r.Log = cast.ToString(v)
+		}
+		if r.Log != WatchLogServer && r.Log != WatchLogGC {
+			return r, fmt.Errorf("log must be %v or %v but was '%v'", WatchLogServer, WatchLogGC, r.Log)
+		}
+	case "gc-pause-ms":
+		ms, err := cast.ToInt64E(entry["gc-pause-ms"])
+		if err != nil || ms < 1 {
+			return r, fmt.Errorf("gc-pause-ms must be at least 1 but was '%v'", entry["gc-pause-ms"])
+		}
+		r.GCPause = time.Duration(ms) * time.Millisecond
+	case "heap-percent":
+		pct, err := cast.ToFloat64E(entry["heap-percent"])
+		if err != nil || pct <= 0 || pct > 100 {
+			return r, fmt.Errorf("heap-percent must be more than 0 and at most 100 but was '%v'", entry["heap-percent"])
+		}
+		r.HeapPercent = pct
+	}
+	if _, ok := entry["log"]; ok && r.LogPattern == nil {
+		return r, fmt.Errorf("log is only used with log-pattern")
+	}
+	r.Cooldown = cooldown
+	if v, ok := entry["cooldown-seconds"]; ok {
+		seconds, err := cast.ToIntE(v)
+		if err != nil || seconds < 0 {
+			return r, fmt.Errorf("cooldown-seconds must be 0 or more but was '%v'", v)
+		}
+		r.Cooldown = time.Duration(seconds) * time.Second
+	}
+	captures, err := optionalList(entry, "capture")
+	if err != nil {
+		return r, fmt.Errorf("capture must be a list: %w", err)
+	}
+	if _, ok := entry["capture"]; !ok {
+		captures = WatchCaptures
+	}
+	if len(captures) == 0 {
+		return r, fmt.Errorf("capture cannot be empty, the captures are %v", strings.Join(WatchCaptures, ", "))
+	}
+	for _, c := range captures {
+		if !slices.Contains(WatchCaptures, c) {
+			return r, fmt.Errorf("unknown capture '%v', the captures are %v", c, strings.Join(WatchCaptures, ", "))
+		}
+	}
+	r.Captures = captures
+	return r, nil
+}
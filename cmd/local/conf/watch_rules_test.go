@@ -0,0 +1,106 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"gopkg.in/yaml.v3"
+)
+
+func parseWatch(t *testing.T, text string) ([]conf.WatchRule, error) {
+	var data map[string]interface{}
+	if err := yaml.Unmarshal([]byte(text), &data); err != nil {
+		t.Fatal(err)
+	}
+	return conf.ParseWatchRules(data[conf.KeyWatchRules], 15*time.Minute)
+}
+
+func TestParseWatchRules(t *testing.T) {
+	rules, err := parseWatch(t, `
+watch-rules:
+  - name: oom
+    log-pattern: "OutOfMemoryError|unable to allocate"
+  - name: long-gc
+    gc-pause-ms: 2000
+    cooldown-seconds: 60
+    capture: [jstack, logs]
+  - name: full-heap
+    heap-percent: 92.5
+  - name: to-space
+    log-pattern: "to-space exhausted"
+    log: gc
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 4 {
+		t.Fatalf("expected 4 rules but got %v", len(rules))
+	}
+	oom, gc, heap, toSpace := rules[0], rules[1], rules[2], rules[3]
+	if oom.LogPattern == nil || !oom.LogPattern.MatchString("java.lang.OutOfMemoryError: Java heap space") || oom.Log != conf.WatchLogServer {
+		t.Errorf("unexpected log rule %#v", oom)
+	}
+	if oom.Cooldown != 15*time.Minute || len(oom.Captures) != len(conf.WatchCaptures) {
+		t.Errorf("expected the default cooldown and captures but was %v and %v", oom.Cooldown, oom.Captures)
+	}
+	if gc.GCPause != 2*time.Second || gc.Cooldown != time.Minute {
+		t.Errorf("unexpected gc rule %#v", gc)
+	}
+	if !gc.Collects(conf.WatchCaptureJStack) || gc.Collects(conf.WatchCaptureJFR) {
+		t.Errorf("expected the gc rule to only capture %v", gc.Captures)
+	}
+	if heap.HeapPercent != 92.5 || heap.LogPattern != nil {
+		t.Errorf("unexpected heap rule %#v", heap)
+	}
+	if toSpace.Log != conf.WatchLogGC {
+		t.Errorf("expected the gc log but was %v", toSpace.Log)
+	}
+}
+
+func TestParseWatchRulesErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		expected string
+	}{
+		{"not a list", "watch-rules: oom", "must be a list"},
+		{"no name", "watch-rules:\n  - gc-pause-ms: 10", "name '' must only use"},
+		{"no condition", "watch-rules:\n  - name: a", "'a': exactly one of"},
+		{"two conditions", "watch-rules:\n  - name: a\n    gc-pause-ms: 10\n    heap-percent: 90", "exactly one of"},
+		{"unknown key", "watch-rules:\n  - name: a\n    gc-pause: 10", "unknown keys gc-pause"},
+		{"bad pattern", "watch-rules:\n  - name: a\n    log-pattern: '('", "invalid log-pattern"},
+		{"empty pattern", "watch-rules:\n  - name: a\n    log-pattern: ''", "log-pattern cannot be empty"},
+		{"bad log", "watch-rules:\n  - name: a\n    log-pattern: x\n    log: audit", "log must be server or gc"},
+		{"log without pattern", "watch-rules:\n  - name: a\n    heap-percent: 90\n    log: gc", "log is only used with log-pattern"},
+		{"bad pause", "watch-rules:\n  - name: a\n    gc-pause-ms: 0", "gc-pause-ms must be at least 1"},
+		{"bad percent", "watch-rules:\n  - name: a\n    heap-percent: 101", "heap-percent must be more than 0"},
+		{"bad cooldown", "watch-rules:\n  - name: a\n    heap-percent: 90\n    cooldown-seconds: -1", "cooldown-seconds must be 0 or more"},
+		{"unknown capture", "watch-rules:\n  - name: a\n    heap-percent: 90\n    capture: [heap-dump]", "unknown capture 'heap-dump'"},
+		{"empty capture", "watch-rules:\n  - name: a\n    heap-percent: 90\n    capture: []", "capture cannot be empty"},
+		{"duplicate", "watch-rules:\n  - name: a\n    heap-percent: 90\n  - name: a\n    heap-percent: 95", "more than one entry named 'a'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseWatch(t, tt.yaml)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected error containing %q but got %v", tt.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configcollect
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/ddcio"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/masking"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+)
+
+// coreConfFiles are always collected by RunCollectDremioConfig whatever the include and exclude globs
+var coreConfFiles = []string{"dremio.conf", "dremio-env", "logback.xml", "logback-access.xml"}
+
+// matchesAny is true when a glob matches the path relative to the conf dir or the file name
+func matchesAny(globs []string, rel string) bool {
+	for _, g := range globs {
+		if ok, _ := filepath.Match(g, filepath.ToSlash(rel)); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(g, filepath.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isBinary looks for a NUL byte at the start of the file, binary files cannot be masked
+func isBinary(loc string) (bool, error) {
+	f, err := os.Open(filepath.Clean(loc))
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	buf := make([]byte, 8000)
+	n, err := f.Read(buf)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	return bytes.IndexByte(buf[:n], 0) >= 0, nil
+}
+
+// collectConfDir copies the other files of the conf dir that match the include globs and none of the
+// exclude globs, keeping the folders they are in. Every copy is masked and a file that fails masking is removed
+func collectConfDir(c *conf.CollectConf) error {
+	confDir := c.DremioConfDir()
+	include := c.ConfFilesInclude()
+	if len(include) == 0 {
+		return nil
+	}
+	exclude := c.ConfFilesExclude()
+	outDir := c.ConfigurationOutDir()
+	var skipped []string
+	var errs []error
+	var total int64
+	err := filepath.WalkDir(confDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == confDir {
+				return err
+			}
+			skipped = append(skipped, fmt.Sprintf("%v: %v", path, err))
+			return nil
+		}
+		rel, err := filepath.Rel(confDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if d.IsDir() {
+			if matchesAny(exclude, rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if slices.Contains(coreConfFiles, rel) || !matchesAny(include, rel) {
+			return nil
+		}
+		if matchesAny(exclude, rel) {
+			skipped = append(skipped, fmt.Sprintf("%v: excluded by %v", rel, conf.KeyConfFilesExclude))
+			return nil
+		}
+		// config maps mount their files as symlinks so the target is what is checked
+		fi, err := os.Stat(path)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%v: %v", rel, err))
+			return nil
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		if max := c.ConfFilesMaxFileSizeBytes(); max > 0 && fi.Size() > max {
+			skipped = append(skipped, fmt.Sprintf("%v: %v bytes is over the max file size of %v bytes", rel, fi.Size(), max))
+			return nil
+		}
+		if max := c.ConfFilesMaxTotalSizeBytes(); max > 0 && total+fi.Size() > max {
+			skipped = append(skipped, fmt.Sprintf("%v: %v bytes would go over the max total size of %v bytes", rel, fi.Size(), max))
+			return nil
+		}
+		binary, err := isBinary(path)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%v: %v", rel, err))
+			return nil
+		}
+		if binary {
+			skipped = append(skipped, fmt.Sprintf("%v: binary files are not collected as they cannot be masked", rel))
+			return nil
+		}
+		dst := filepath.Join(outDir, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+			errs = append(errs, fmt.Errorf("unable to create %v: %w", filepath.Dir(dst), err))
+			return nil
+		}
+		if err := ddcio.CopyFile(path, dst); err != nil {
+			errs = append(errs, fmt.Errorf("unable to copy %v: %w", path, err))
+			return nil
+		}
+		if err := masking.MaskConfFile(dst); err != nil {
+			// never keep a file that may still have secrets
+			if rmErr := os.Remove(dst); rmErr != nil {
+				simplelog.Errorf("UNABLE TO REMOVE UNMASKED %v: %v", dst, rmErr)
+			}
+			errs = append(errs, fmt.Errorf("UNABLE TO MASK SECRETS in %v so it was not collected: %w", rel, err))
+			return nil
+		}
+		total += fi.Size()
+		simplelog.Debugf("collected and masked %v", rel)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to read conf dir %v: %w", confDir, err)
+	}
+	if len(skipped) > 0 {
+		simplelog.Warningf("skipped %v files of the conf dir %v, see %v", len(skipped), confDir, conf.SkippedFile)
+		loc := filepath.Join(outDir, conf.SkippedFile)
+		if err := os.WriteFile(loc, []byte(strings.Join(skipped, "\n")+"\n"), 0o600); err != nil {
+			errs = append(errs, fmt.Errorf("unable to write %v: %w", loc, err))
+		}
+	}
+	return errors.Join(errs...)
+}
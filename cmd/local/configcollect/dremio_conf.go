@@ -36,6 +36,9 @@ func RunCollectDremioConfig(c *conf.CollectConf, _ shutdown.CancelHook) error {
 	if err := masking.RemoveSecretsFromDremioConf(dremioConfDest); err != nil {
 		simplelog.Warningf("UNABLE TO MASK SECRETS in dremio.conf: %v", err)
 	}
+	if err := writeEffectiveConf(c); err != nil {
+		simplelog.Warningf("unable to write %v: %v", EffectiveConfFile, err)
+	}
 	err = ddcio.CopyFile(filepath.Join(c.DremioConfDir(), "dremio-env"), filepath.Join(c.ConfigurationOutDir(), "dremio-env"))
 	if err != nil {
 		simplelog.Warningf("unable to copy dremio-env: %v", err)
@@ -48,6 +51,9 @@ func RunCollectDremioConfig(c *conf.CollectConf, _ shutdown.CancelHook) error {
 	if err != nil {
 		simplelog.Warningf("unable to copy logback-access.xml: %v", err)
 	}
+	if err := collectConfDir(c); err != nil {
+		return err
+	}
 	simplelog.Debugf("... Collecting Configuration Information from %v COMPLETED", c.NodeName())
 
 	return nil
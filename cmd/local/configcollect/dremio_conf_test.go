@@ -183,4 +183,87 @@ node-name: %v
 	if !strings.Contains(string(text), "REMOVED_POTENTIAL_SECRET") {
 		t.Errorf("expected text '%v' to contain the REMOVED_POTENTIAL_SECRET but did not", string(text))
 	}
+	effective, err := os.ReadFile(filepath.Join(confDestination, configcollect.EffectiveConfFile))
+	if err != nil {
+		t.Fatalf("expected %v to be written: %v", configcollect.EffectiveConfFile, err)
+	}
+	if strings.Contains(string(effective), "hidemeplease") {
+		t.Errorf("expected the password to be masked in %v", string(effective))
+	}
+}
+
+func TestCollectsOtherConfFilesMasked(t *testing.T) {
+	outDir := filepath.Join(t.TempDir(), "ddc-conf")
+	srcConfDir := t.TempDir()
+	files := map[string]string{
+		"core-site.xml":               "<configuration><property><name>fs.s3a.secret.key</name><value>s3cr3t</value></property></configuration>",
+		"jaas.conf":                   "Client { PlainLoginModule required username=\"u\" password=\"jaaspw\"; };",
+		"custom.properties":           "db.password=proppw\ndb.user=dremio",
+		filepath.Join("ssl", "a.txt"): "nothing secret",
+		"keystore.jks":                "binary keystore",
+		"big.txt":                     strings.Repeat("x", 2*1024*1024),
+		"data.bin":                    "a\x00b",
+	}
+	for name, text := range files {
+		loc := filepath.Join(srcConfDir, name)
+		if err := os.MkdirAll(filepath.Dir(loc), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(loc, []byte(text), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	nodeName := "node1"
+	ddcYaml := filepath.Join(t.TempDir(), "ddc.yaml")
+	if err := os.WriteFile(ddcYaml, []byte(fmt.Sprintf(`
+dremio-log-dir: %v
+tmp-output-dir: %v
+dremio-conf-dir: %v
+node-name: %v
+conf-files-max-file-size-mb: 1
+`, filepath.Join("testdata", "logs"),
+		strings.ReplaceAll(outDir, "\\", "\\\\"),
+		strings.ReplaceAll(srcConfDir, "\\", "\\\\"),
+		nodeName)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+	c, err := conf.ReadConf(hook, make(map[string]string), ddcYaml, collects.StandardCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(c.ConfigurationOutDir(), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := configcollect.RunCollectDremioConfig(c, hook); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, secret := range map[string]string{"core-site.xml": "s3cr3t", "jaas.conf": "jaaspw", "custom.properties": "proppw"} {
+		b, err := os.ReadFile(filepath.Join(c.ConfigurationOutDir(), name))
+		if err != nil {
+			t.Fatalf("expected %v to be collected: %v", name, err)
+		}
+		if strings.Contains(string(b), secret) || !strings.Contains(string(b), "REMOVED_POTENTIAL_SECRET") {
+			t.Errorf("expected %v to be masked but was %v", name, string(b))
+		}
+	}
+	if _, err := os.Stat(filepath.Join(c.ConfigurationOutDir(), "ssl", "a.txt")); err != nil {
+		t.Errorf("expected files in sub folders to be collected: %v", err)
+	}
+	for _, name := range []string{"keystore.jks", "big.txt", "data.bin"} {
+		if _, err := os.Stat(filepath.Join(c.ConfigurationOutDir(), name)); err == nil {
+			t.Errorf("expected %v to be skipped", name)
+		}
+	}
+	b, err := os.ReadFile(filepath.Join(c.ConfigurationOutDir(), conf.SkippedFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"keystore.jks: excluded by conf-files-exclude", "big.txt: 2097152 bytes is over the max file size", "data.bin: binary files are not collected"} {
+		if !strings.Contains(string(b), expected) {
+			t.Errorf("expected '%v' in %v", expected, string(b))
+		}
+	}
 }
@@ -0,0 +1,156 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configcollect
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/hocon"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/masking"
+)
+
+// EffectiveConfFile is the summary of dremio.conf with substitutions resolved and the dremio defaults applied
+const EffectiveConfFile = "dremio-conf-effective.json"
+
+const removedSecret = "<REMOVED_POTENTIAL_SECRET>"
+
+// defaultPorts are the ports dremio listens on when dremio.conf does not change them
+var defaultPorts = map[string]interface{}{
+	"services.coordinator.web.port":             9047,
+	"services.coordinator.client-endpoint.port": 31010,
+	"services.fabric.port":                      45678,
+	"services.flight.port":                      32010,
+}
+
+// EffectiveConf is what is written to dremio-conf-effective.json, secrets are masked by their key path
+type EffectiveConf struct {
+	DremioHome    string                 `json:"dremioHome"`
+	Paths         map[string]interface{} `json:"paths"`
+	DistStoreType string                 `json:"distStoreType"`
+	RocksDBDir    string                 `json:"rocksDBDir"`
+	Roles         map[string]bool        `json:"roles"`
+	Ports         map[string]interface{} `json:"ports"`
+	SSL           map[string]interface{} `json:"ssl"`
+	Services      interface{}            `json:"services"`
+	Includes      []string               `json:"includes,omitempty"`
+	Unresolved    []string               `json:"unresolved,omitempty"`
+}
+
+// BuildEffectiveConf extracts the paths, services, ports and ssl settings of a parsed dremio.conf
+func BuildEffectiveConf(cfg *hocon.Config, dremioHome string) EffectiveConf {
+	masked := maskValue("", cfg.Root)
+	flat := make(map[string]interface{})
+	flatten("", masked, flat)
+	e := EffectiveConf{
+		DremioHome:    dremioHome,
+		Paths:         make(map[string]interface{}),
+		DistStoreType: conf.DistStoreType(conf.DremioDistPath(cfg, dremioHome)),
+		RocksDBDir:    conf.DremioDBPath(cfg, dremioHome),
+		Roles:         conf.DremioRoles(cfg, ""),
+		Ports:         make(map[string]interface{}),
+		SSL:           make(map[string]interface{}),
+		Includes:      cfg.Includes,
+		Unresolved:    cfg.Unresolved,
+	}
+	if paths, ok := masked.(map[string]interface{})["paths"].(map[string]interface{}); ok {
+		for k, v := range paths {
+			e.Paths[k] = v
+		}
+	}
+	e.Paths["local"] = conf.DremioLocalPath(cfg, dremioHome)
+	e.Paths["dist"] = conf.DremioDistPath(cfg, dremioHome)
+	e.Paths["db"] = e.RocksDBDir
+	if services, ok := masked.(map[string]interface{})["services"]; ok {
+		e.Services = services
+	}
+	for k, v := range defaultPorts {
+		e.Ports[k] = v
+	}
+	for path, v := range flat {
+		if strings.HasSuffix(strings.ToLower(path), "port") {
+			e.Ports[path] = v
+		}
+		for _, segment := range strings.Split(strings.ToLower(path), ".") {
+			if strings.Contains(segment, "ssl") {
+				e.SSL[path] = v
+				break
+			}
+		}
+	}
+	return e
+}
+
+// maskValue converts the value for json masking every scalar or array under a key path that looks like a secret,
+// values that came from substitutions such as environment variables are masked the same way
+func maskValue(path string, v *hocon.Value) interface{} {
+	if v.Kind == hocon.KindObject {
+		m := make(map[string]interface{}, len(v.Object))
+		for _, k := range v.Keys {
+			childPath := k
+			if path != "" {
+				childPath = path + "." + k
+			}
+			m[k] = maskValue(childPath, v.Object[k])
+		}
+		return m
+	}
+	if path != "" && masking.IsSecretKey(path) {
+		return removedSecret
+	}
+	return v.Interface()
+}
+
+func flatten(path string, v interface{}, out map[string]interface{}) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		out[path] = v
+		return
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		childPath := k
+		if path != "" {
+			childPath = path + "." + k
+		}
+		flatten(childPath, m[k], out)
+	}
+}
+
+// writeEffectiveConf parses dremio.conf of the conf dir and writes dremio-conf-effective.json to the out dir
+func writeEffectiveConf(c *conf.CollectConf) error {
+	cfg, err := conf.ParseDremioConf(c.DremioHome(), c.DremioConfDir())
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(BuildEffectiveConf(cfg, c.DremioHome()), "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to write %v: %w", EffectiveConfFile, err)
+	}
+	loc := filepath.Join(c.ConfigurationOutDir(), EffectiveConfFile)
+	if err := os.WriteFile(loc, b, 0o600); err != nil {
+		return fmt.Errorf("unable to write %v: %w", loc, err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configcollect_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/configcollect"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/hocon"
+)
+
+func TestBuildEffectiveConf(t *testing.T) {
+	t.Setenv("DDC_TEST_KEYSTORE_PASSWORD", "from-the-env")
+	cfg, err := hocon.Parse(`
+paths: {
+  local: ${DREMIO_HOME}"/data"
+  dist: "dremioS3:///my-bucket/dremio"
+}
+services: {
+  coordinator.enabled: false
+  executor.enabled: true
+  fabric.port: 45000
+  coordinator.web.ssl {
+    enabled: true
+    keyStorePassword: ${?DDC_TEST_KEYSTORE_PASSWORD}
+  }
+}
+`, map[string]string{"DREMIO_HOME": "/opt/dremio"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := configcollect.BuildEffectiveConf(cfg, "/opt/dremio")
+	if e.DistStoreType != "s3" {
+		t.Errorf("expected s3 but got %v", e.DistStoreType)
+	}
+	if e.RocksDBDir != "/opt/dremio/data/db" || e.Paths["local"] != "/opt/dremio/data" || e.Paths["dist"] != "dremioS3:///my-bucket/dremio" {
+		t.Errorf("unexpected paths %v rocksdb %v", e.Paths, e.RocksDBDir)
+	}
+	if e.Roles["coordinator"] || e.Roles["master"] || !e.Roles["executor"] {
+		t.Errorf("unexpected roles %v", e.Roles)
+	}
+	if !strings.Contains(marshal(t, e.Ports), `"services.fabric.port":45000`) {
+		t.Errorf("expected the configured fabric port in %v", marshal(t, e.Ports))
+	}
+	if !strings.Contains(marshal(t, e.Ports), `"services.coordinator.web.port":9047`) {
+		t.Errorf("expected the default web port in %v", marshal(t, e.Ports))
+	}
+	if e.SSL["services.coordinator.web.ssl.enabled"] != true {
+		t.Errorf("expected ssl to be enabled in %v", e.SSL)
+	}
+	text := marshal(t, e)
+	if strings.Contains(text, "from-the-env") || !strings.Contains(text, "REMOVED_POTENTIAL_SECRET") {
+		t.Errorf("expected the key store password to be masked in %v", text)
+	}
+
+	// without paths.dist dremio uses pdfs under paths.local
+	cfg, err = hocon.Parse("paths.db: /rocks", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e = configcollect.BuildEffectiveConf(cfg, "/opt/dremio")
+	if e.DistStoreType != "pdfs" || e.RocksDBDir != "/rocks" {
+		t.Errorf("expected pdfs and /rocks but got %v and %v", e.DistStoreType, e.RocksDBDir)
+	}
+}
+
+func marshal(t *testing.T, v interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
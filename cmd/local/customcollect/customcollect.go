@@ -0,0 +1,195 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// package customcollect runs the custom-collectors declared in ddc.yaml
+package customcollect
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/ddcio"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/masking"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+)
+
+// RunCollect runs a single custom collector, everything it collects goes into a folder named after it
+func RunCollect(c *conf.CollectConf, hook shutdown.CancelHook, cc conf.CustomCollector) error {
+	outDir := filepath.Join(c.CustomOutDir(), cc.Name)
+	if err := os.MkdirAll(outDir, 0o750); err != nil {
+		return fmt.Errorf("unable to create %v: %w", outDir, err)
+	}
+	if cc.Command != "" {
+		return runCommand(hook, cc, outDir)
+	}
+	return copyFiles(cc, outDir)
+}
+
+// timeoutHook limits a command to the timeout of the collector, it is still stopped with the collection
+type timeoutHook struct {
+	ctx context.Context
+}
+
+func (h timeoutHook) GetContext() context.Context {
+	return h.ctx
+}
+
+func runCommand(hook shutdown.CancelHook, cc conf.CustomCollector, outDir string) error {
+	ctx, cancel := context.WithTimeout(hook.GetContext(), time.Duration(cc.TimeoutSeconds)*time.Second)
+	defer cancel()
+	loc := filepath.Join(outDir, cc.OutputFile)
+	f, err := os.Create(filepath.Clean(loc))
+	if err != nil {
+		return fmt.Errorf("unable to create %v: %w", loc, err)
+	}
+	simplelog.Debugf("running custom collector %v: %v", cc.Name, cc.Command)
+	runErr := ddcio.Shell(timeoutHook{ctx: ctx}, f, cc.Command)
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("unable to close %v: %w", loc, err)
+	}
+	// the partial output of a failed command is still masked and kept
+	if err := maskFile(loc, cc); err != nil {
+		return err
+	}
+	if runErr != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("command of %v timed out after %v seconds: %w", cc.Name, cc.TimeoutSeconds, runErr)
+		}
+		return fmt.Errorf("command of %v failed: %w", cc.Name, runErr)
+	}
+	return nil
+}
+
+// copyFiles copies every file matching the globs keeping its full path under the out dir so files
+// with the same name in different folders do not overwrite each other
+func copyFiles(cc conf.CustomCollector, outDir string) error {
+	var matches []string
+	seen := make(map[string]bool)
+	for _, glob := range cc.Files {
+		found, err := filepath.Glob(glob)
+		if err != nil {
+			return fmt.Errorf("invalid glob %v: %w", glob, err)
+		}
+		for _, m := range found {
+			if !seen[m] {
+				seen[m] = true
+				matches = append(matches, m)
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("no files matched %v", strings.Join(cc.Files, ", "))
+	}
+	sort.Strings(matches)
+	var skipped []string
+	var errs []error
+	var total int64
+	for _, src := range matches {
+		fi, err := os.Stat(src)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to stat %v: %w", src, err))
+			continue
+		}
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		if cc.MaxFileSizeBytes > 0 && fi.Size() > cc.MaxFileSizeBytes {
+			skipped = append(skipped, fmt.Sprintf("%v: %v bytes is over the max file size of %v bytes", src, fi.Size(), cc.MaxFileSizeBytes))
+			continue
+		}
+		if cc.MaxTotalSizeBytes > 0 && total+fi.Size() > cc.MaxTotalSizeBytes {
+			skipped = append(skipped, fmt.Sprintf("%v: %v bytes would go over the max total size of %v bytes", src, fi.Size(), cc.MaxTotalSizeBytes))
+			continue
+		}
+		abs, err := filepath.Abs(src)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to find the absolute path of %v: %w", src, err))
+			continue
+		}
+		dst := filepath.Join(outDir, abs)
+		if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+			errs = append(errs, fmt.Errorf("unable to create %v: %w", filepath.Dir(dst), err))
+			continue
+		}
+		if err := ddcio.CopyFile(src, dst); err != nil {
+			errs = append(errs, fmt.Errorf("unable to copy %v: %w", src, err))
+			continue
+		}
+		total += fi.Size()
+		if err := maskFile(dst, cc); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(skipped) > 0 {
+		simplelog.Warningf("custom collector %v skipped %v files because of its size limits", cc.Name, len(skipped))
+		loc := filepath.Join(outDir, conf.SkippedFile)
+		if err := os.WriteFile(loc, []byte(strings.Join(skipped, "\n")+"\n"), 0o600); err != nil {
+			errs = append(errs, fmt.Errorf("unable to write %v: %w", loc, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// maskFile applies the masking rules of the collector to every line of the file
+func maskFile(loc string, cc conf.CustomCollector) error {
+	if len(cc.MaskPatterns) == 0 && !cc.MaskSecrets {
+		return nil
+	}
+	f, err := os.Open(filepath.Clean(loc))
+	if err != nil {
+		return fmt.Errorf("unable to open %v for masking: %w", loc, err)
+	}
+	var masked strings.Builder
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		masked.WriteString(MaskLine(scanner.Text(), cc))
+		masked.WriteString("\n")
+	}
+	scanErr := scanner.Err()
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("unable to close %v: %w", loc, err)
+	}
+	if scanErr != nil {
+		// never leave a file that could not be masked behind
+		if err := os.Remove(filepath.Clean(loc)); err != nil {
+			simplelog.Errorf("unable to remove unmasked file %v: %v", loc, err)
+		}
+		return fmt.Errorf("unable to mask %v, it was removed: %w", loc, scanErr)
+	}
+	if err := os.WriteFile(filepath.Clean(loc), []byte(masked.String()), 0o600); err != nil {
+		return fmt.Errorf("unable to write masked %v: %w", loc, err)
+	}
+	return nil
+}
+
+// MaskLine replaces the mask patterns and, when mask-secrets is set, the values of lines that look like secrets
+func MaskLine(line string, cc conf.CustomCollector) string {
+	for _, re := range cc.MaskPatterns {
+		line = re.ReplaceAllString(line, "<REMOVED_BY_MASK_PATTERN>")
+	}
+	if cc.MaskSecrets {
+		line = masking.MaskSecretLine(line)
+	}
+	return line
+}
@@ -0,0 +1,107 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package customcollect
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
+)
+
+func TestRunCommandMasksTheOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses bash")
+	}
+	outDir := t.TempDir()
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+	cc := conf.CustomCollector{
+		Name:           "vendor",
+		Command:        "echo 'status ok token=abc123'; echo 'db_password: hunter2'",
+		TimeoutSeconds: 10,
+		OutputFile:     "vendor.txt",
+		MaskPatterns:   []*regexp.Regexp{regexp.MustCompile(`token=\S+`)},
+		MaskSecrets:    true,
+	}
+	if err := runCommand(hook, cc, outDir); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(outDir, "vendor.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(b)
+	if strings.Contains(text, "abc123") || strings.Contains(text, "hunter2") {
+		t.Errorf("expected the secrets to be masked but was\n%v", text)
+	}
+	if !strings.Contains(text, "status ok <REMOVED_BY_MASK_PATTERN>") {
+		t.Errorf("expected the mask pattern to be replaced but was\n%v", text)
+	}
+}
+
+func TestRunCommandTimesOut(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses bash")
+	}
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+	cc := conf.CustomCollector{Name: "slow", Command: "echo started; sleep 10", TimeoutSeconds: 1, OutputFile: "slow.txt"}
+	err := runCommand(hook, cc, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "timed out after 1 seconds") {
+		t.Errorf("expected a timeout but got %v", err)
+	}
+}
+
+func TestCopyFilesHonorsTheSizeLimits(t *testing.T) {
+	srcDir := t.TempDir()
+	outDir := t.TempDir()
+	for name, size := range map[string]int{"a.conf": 10, "b.conf": 10, "big.conf": 100} {
+		if err := os.WriteFile(filepath.Join(srcDir, name), []byte(strings.Repeat("x", size)), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cc := conf.CustomCollector{Name: "site", Files: []string{filepath.Join(srcDir, "*.conf")}, MaxFileSizeBytes: 50, MaxTotalSizeBytes: 15}
+	if err := copyFiles(cc, outDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, srcDir, "a.conf")); err != nil {
+		t.Errorf("expected a.conf to be copied with its full path: %v", err)
+	}
+	for _, name := range []string{"b.conf", "big.conf"} {
+		if _, err := os.Stat(filepath.Join(outDir, srcDir, name)); err == nil {
+			t.Errorf("expected %v to be skipped", name)
+		}
+	}
+	skipped, err := os.ReadFile(filepath.Join(outDir, conf.SkippedFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(skipped), "big.conf: 100 bytes is over the max file size") || !strings.Contains(string(skipped), "b.conf: 10 bytes would go over the max total size") {
+		t.Errorf("unexpected skipped list\n%s", skipped)
+	}
+}
+
+func TestCopyFilesFailsWhenNothingMatches(t *testing.T) {
+	cc := conf.CustomCollector{Name: "none", Files: []string{filepath.Join(t.TempDir(), "*.missing")}}
+	if err := copyFiles(cc, t.TempDir()); err == nil || !strings.Contains(err.Error(), "no files matched") {
+		t.Errorf("expected an error when no file matches but got %v", err)
+	}
+}
@@ -96,6 +96,12 @@ func CopyDir(src, dst string) error {
 }
 
 func CopyFile(srcPath, dstPath string) error {
+	return CopyFileFrom(srcPath, dstPath, 0)
+}
+
+// CopyFileFrom copies what comes after offset in srcPath, such as the lines a log got since offset was its size.
+// The whole file is copied when it is shorter than offset because it was truncated
+func CopyFileFrom(srcPath, dstPath string, offset int64) error {
 	// Open the source file
 	srcFile, err := os.Open(path.Clean(srcPath))
 	if err != nil {
@@ -106,6 +112,11 @@ func CopyFile(srcPath, dstPath string) error {
 			simplelog.Warningf("unable to close %v :%v", path.Clean(srcPath), err)
 		}
 	}()
+	if fi, err := srcFile.Stat(); err == nil && offset > 0 && offset <= fi.Size() {
+		if _, err := srcFile.Seek(offset, io.SeekStart); err != nil {
+			return fmt.Errorf("unable to copy file %v: %w", path.Clean(srcPath), err)
+		}
+	}
 
 	// Create the destination file
 	dstFile, err := os.Create(path.Clean(dstPath))
@@ -15,10 +15,15 @@
 package ddcio_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/ddcio"
@@ -68,6 +73,36 @@ func TestCopyFile(t *testing.T) {
 	}
 }
 
+func TestCopyFileFrom(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "ddc.log")
+	if err := os.WriteFile(src, []byte("before\nafter\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(dir, "copy.log")
+	if err := ddcio.CopyFileFrom(src, dst, int64(len("before\n"))); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "after\n" {
+		t.Errorf("expected only what came after the offset but got %q", b)
+	}
+	// a truncated file is copied whole
+	if err := ddcio.CopyFileFrom(src, dst, 1000); err != nil {
+		t.Fatal(err)
+	}
+	b, err = os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "before\nafter\n" {
+		t.Errorf("expected the whole file but got %q", b)
+	}
+}
+
 func TestCopyDir(t *testing.T) {
 	srcDir, err := os.MkdirTemp("", "source-dir")
 	if err != nil {
@@ -169,3 +204,76 @@ func TestEnsureClose(t *testing.T) {
 		t.Errorf("expected error text''%v' was not captured in %v", expectedFile, out)
 	}
 }
+
+func TestParallelGzip(t *testing.T) {
+	var input bytes.Buffer
+	for i := 0; i < 50000; i++ {
+		fmt.Fprintf(&input, "line %v of the heap dump %v\n", i, i*7919%104729)
+	}
+	for _, tc := range []struct {
+		blockSize int
+		workers   int
+	}{{4096, 4}, {1 << 20, 2}, {1000, 1}} {
+		var out bytes.Buffer
+		if err := ddcio.ParallelGzip(&out, bytes.NewReader(input.Bytes()), tc.blockSize, tc.workers); err != nil {
+			t.Fatal(err)
+		}
+		zr, err := gzip.NewReader(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, input.Bytes()) {
+			t.Errorf("block size %v with %v workers: expected %v bytes back but got %v", tc.blockSize, tc.workers, input.Len(), len(got))
+		}
+	}
+	var empty bytes.Buffer
+	if err := ddcio.ParallelGzip(&empty, bytes.NewReader(nil), 4096, 2); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&empty)
+	if err != nil {
+		t.Fatalf("expected a valid gzip stream for an empty input: %v", err)
+	}
+	if got, err := io.ReadAll(zr); err != nil || len(got) != 0 {
+		t.Errorf("expected nothing back but got %v bytes: %v", len(got), err)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestParallelGzipWriteError(t *testing.T) {
+	input := bytes.Repeat([]byte("abc"), 100000)
+	if err := ddcio.ParallelGzip(failingWriter{}, bytes.NewReader(input), 1024, 3); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("expected the write error to be returned but got %v", err)
+	}
+}
+
+// zeros is an endless input that counts how much was read
+type zeros struct {
+	read atomic.Int64
+}
+
+func (z *zeros) Read(p []byte) (int, error) {
+	clear(p)
+	z.read.Add(int64(len(p)))
+	return len(p), nil
+}
+
+func TestParallelGzipStopsReadingOnWriteError(t *testing.T) {
+	src := &zeros{}
+	// the input never ends so only the write error stops the compression
+	if err := ddcio.ParallelGzip(failingWriter{}, src, 1024, 4); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("expected the write error to be returned but got %v", err)
+	}
+	if read := src.read.Load(); read > 64*1024 {
+		t.Errorf("expected reading to stop right after the write error but %v bytes were read", read)
+	}
+}
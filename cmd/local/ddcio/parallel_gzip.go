@@ -0,0 +1,133 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ddcio
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// gzipBlock is a block of the input and its compressed gzip member
+type gzipBlock struct {
+	in   []byte
+	out  bytes.Buffer
+	err  error
+	done chan struct{}
+}
+
+// ParallelGzip compresses src into dst as a multi member gzip stream. Blocks of blockSize are compressed by
+// workers goroutines and written in order, gzip readers including gunzip read the members as one stream.
+// At most 2*workers blocks are held in memory.
+func ParallelGzip(dst io.Writer, src io.Reader, blockSize, workers int) error {
+	if blockSize < 1 || workers < 1 {
+		return fmt.Errorf("invalid block size %v or workers %v", blockSize, workers)
+	}
+	work := make(chan *gzipBlock, workers)
+	ordered := make(chan *gzipBlock, workers)
+	// failed is closed by the writer on the first compress or write error so nothing more is read or compressed
+	failed := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range work {
+				select {
+				case <-failed:
+				default:
+					zw := gzip.NewWriter(&b.out)
+					if _, err := zw.Write(b.in); err != nil {
+						b.err = err
+					} else {
+						b.err = zw.Close()
+					}
+				}
+				b.in = nil
+				close(b.done)
+			}
+		}()
+	}
+	writeErr := make(chan error, 1)
+	go func() {
+		var err error
+		for b := range ordered {
+			if err != nil {
+				// keep draining so the reader is never blocked, the block may never have been compressed
+				continue
+			}
+			<-b.done
+			if b.err != nil {
+				err = fmt.Errorf("unable to compress block: %w", b.err)
+			} else if _, werr := dst.Write(b.out.Bytes()); werr != nil {
+				err = fmt.Errorf("unable to write compressed block: %w", werr)
+			}
+			if err != nil {
+				close(failed)
+			}
+		}
+		writeErr <- err
+	}()
+	send := func(b *gzipBlock) bool {
+		select {
+		case ordered <- b:
+		case <-failed:
+			return false
+		}
+		select {
+		case work <- b:
+			return true
+		case <-failed:
+			return false
+		}
+	}
+
+	var readErr error
+	blocks := 0
+read:
+	for {
+		select {
+		case <-failed:
+			break read
+		default:
+		}
+		buf := make([]byte, blockSize)
+		n, err := io.ReadFull(src, buf)
+		// an empty input still needs one member to be a valid gzip file
+		if n > 0 || (blocks == 0 && err != nil) {
+			if !send(&gzipBlock{in: buf[:n], done: make(chan struct{})}) {
+				break
+			}
+			blocks++
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			readErr = fmt.Errorf("unable to read block %v: %w", blocks, err)
+			break
+		}
+	}
+	close(work)
+	close(ordered)
+	wg.Wait()
+	if err := <-writeErr; err != nil {
+		return err
+	}
+	return readErr
+}
@@ -0,0 +1,128 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io"
+	"strings"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/logcollect"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/dryrun"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
+)
+
+// DryRunJob is what a single job would do
+type DryRunJob struct {
+	Name           string                   `json:"name"`
+	Enabled        bool                     `json:"enabled"`
+	Reads          []string                 `json:"reads"`
+	ResourceClass  string                   `json:"resourceClass,omitempty"`
+	DependsOn      []string                 `json:"dependsOn,omitempty"`
+	Files          []logcollect.PlannedFile `json:"files,omitempty"`
+	EstimatedBytes int64                    `json:"estimatedBytes"`
+	Error          string                   `json:"error,omitempty"`
+}
+
+// DryRunPlan is everything local-collect would do on this node without doing it
+type DryRunPlan struct {
+	NodeName       string `json:"nodeName"`
+	CollectionMode string `json:"collectionMode"`
+	TarballOutDir  string `json:"tarballOutDir"`
+	DremioPID      int    `json:"dremioPID"`
+	// DremioJVMs are the dremio jvms found on the node, the per jvm jobs are listed for each of them
+	DremioJVMs      []conf.DremioJVM       `json:"dremioJVMs,omitempty"`
+	EffectiveConfig map[string]interface{} `json:"effectiveConfig"`
+	EnabledJobs     []string               `json:"enabledJobs"`
+	DisabledJobs    []string               `json:"disabledJobs"`
+	Jobs            []DryRunJob            `json:"jobs"`
+	// EstimatedBytes is the size of the source files found, logs are gzipped when collected so the archive is usually smaller
+	EstimatedBytes int64 `json:"estimatedBytes"`
+}
+
+// BuildDryRunPlan resolves every job the same way a collection would but only lists and stats files
+func BuildDryRunPlan(c *conf.CollectConf, hook shutdown.Hook, collectionMode string) DryRunPlan {
+	plan := DryRunPlan{
+		NodeName:        c.NodeName(),
+		CollectionMode:  collectionMode,
+		TarballOutDir:   c.TarballOutDir(),
+		DremioPID:       c.DremioPID(),
+		DremioJVMs:      c.DremioJVMs(),
+		EffectiveConfig: c.EffectiveConfig(),
+		EnabledJobs:     []string{},
+		DisabledJobs:    []string{},
+	}
+	jobs, _ := nodeJobs(c, hook, nil)
+	for _, j := range jobs {
+		job := DryRunJob{
+			Name:          j.name,
+			Enabled:       j.enabled,
+			Reads:         j.reads,
+			ResourceClass: string(j.class),
+			DependsOn:     j.dependsOn,
+		}
+		if !j.enabled {
+			plan.DisabledJobs = append(plan.DisabledJobs, j.name)
+			plan.Jobs = append(plan.Jobs, job)
+			continue
+		}
+		plan.EnabledJobs = append(plan.EnabledJobs, j.name)
+		if j.plan != nil {
+			files, err := j.plan()
+			if err != nil {
+				job.Error = err.Error()
+			}
+			job.Files = files
+			for _, f := range files {
+				job.EstimatedBytes += f.Size
+			}
+		}
+		if j.estimate != nil {
+			size, err := j.estimate()
+			if err != nil {
+				job.Error = err.Error()
+			}
+			job.EstimatedBytes = size
+		}
+		plan.EstimatedBytes += job.EstimatedBytes
+		plan.Jobs = append(plan.Jobs, job)
+	}
+	return plan
+}
+
+// WriteDryRunPlan writes the plan as text for humans or json for review tooling
+func WriteDryRunPlan(w io.Writer, plan DryRunPlan, format string) error {
+	return dryrun.Write(w, plan, format, func(printf dryrun.Printf) {
+		printf("DRY RUN - nothing was collected\n\nnode:\t%v\ncollection mode:\t%v\ntarball out dir:\t%v\ndremio pid:\t%v\nestimated size:\t%v bytes\n\n", plan.NodeName, plan.CollectionMode, plan.TarballOutDir, plan.DremioPID, plan.EstimatedBytes)
+		dryrun.WriteConfig(printf, plan.EffectiveConfig)
+		printf("JOB\tENABLED\tCLASS\tAFTER\tEST. BYTES\tREADS\n")
+		for _, j := range plan.Jobs {
+			printf("%v\t%v\t%v\t%v\t%v\t%v\n", j.Name, j.Enabled, j.ResourceClass, strings.Join(j.DependsOn, ", "), j.EstimatedBytes, strings.Join(j.Reads, ", "))
+		}
+		printf("\n")
+		for _, j := range plan.Jobs {
+			if len(j.Files) == 0 && j.Error == "" {
+				continue
+			}
+			printf("%v files:\n", j.Name)
+			for _, f := range j.Files {
+				printf("  %v\t%v\n", f.Path, f.Size)
+			}
+			if j.Error != "" {
+				printf("  warning:\t%v\n", j.Error)
+			}
+		}
+	})
+}
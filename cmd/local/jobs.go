@@ -0,0 +1,593 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/apicollect"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/configcollect"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/customcollect"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/jvmcollect"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/logcollect"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/nodeinfocollect"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/snapshotcollect"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/threading"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+)
+
+// localJob is one unit of work of local-collect. Reads lists the commands, files and
+// REST endpoints the job touches so a dry run can report them without running anything.
+type localJob struct {
+	name    string
+	enabled bool
+	reads   []string
+	// class limits how many jobs using the same resource run at once
+	class threading.ResourceClass
+	// dependsOn are the jobs that have to finish first, disabled jobs are ignored
+	dependsOn []string
+	// outputs are the directories or file globs the job writes to, used to attribute bytes and files to the job
+	outputs []string
+	run     func() error
+	// plan lists the files the job would copy, only set for log jobs
+	plan func() ([]logcollect.PlannedFile, error)
+	// estimate is the size of what the job writes when it cannot be planned file by file
+	estimate func() (int64, error)
+	// perJVM jobs run once for every dremio jvm when there is more than one on the node
+	perJVM bool
+}
+
+// newLogBudget is shared by every log job, nil when no log size caps are configured
+func newLogBudget(c *conf.CollectConf) *logcollect.Budget {
+	if c.MaxLogsTotalBytes() == 0 && len(c.MaxLogTypeBytes()) == 0 {
+		return nil
+	}
+	return logcollect.NewBudget(c.MaxLogsTotalBytes(), c.MaxLogTypeBytes())
+}
+
+// newLogCollector follows the appenders of logback.xml and logback-access.xml, when they cannot be read the
+// default file names and archive layout of the log dir are used
+func newLogCollector(c *conf.CollectConf, budget *logcollect.Budget) *logcollect.Collector {
+	l := logcollect.NewLogCollectorWithWindow(
+		c.DremioLogDir(),
+		c.LogsOutDir(),
+		c.GcLogsDir(),
+		c.DremioGCFilePattern(),
+		c.QueriesOutDir(),
+		c.DremioQueriesJSONNumDays(),
+		c.DremioLogsNumDays(),
+		c.CollectionWindow(),
+	)
+	appenders, errs := logcollect.DiscoverAppenders(c.DremioConfDir(), c.DremioLogDir())
+	for _, err := range errs {
+		simplelog.Warningf("logback appender discovery: %v", err)
+	}
+	for _, a := range appenders {
+		simplelog.Debugf("found logback appender %v in %v with file '%v' and fileNamePattern '%v'", a.Name, a.Source, a.File, a.FileNamePattern)
+	}
+	l.SetAppenders(appenders)
+	l.SetBudget(budget)
+	return l
+}
+
+const (
+	jobQueriesJSON  = "QUERIES.JSON COLLECTION"
+	jobSystemTables = "SYSTEM TABLE COLLECTION"
+	jobTtop         = "TTOP COLLECTION"
+	jobJFR          = "JFR COLLECTION"
+	jobJStack       = "JSTACK COLLECTION"
+	jobHeapDump     = "HEAP DUMP COLLECTION"
+	// jobClassHistogram runs a full gc for every sample
+	jobClassHistogram = "CLASS HISTOGRAM COLLECTION"
+	// jobClassHistogramTrend samples next to the jstack loop for the whole window
+	jobClassHistogramTrend = "CLASS HISTOGRAM TREND COLLECTION"
+	// jobSnapshot takes its captures at the instants given by ddc
+	jobSnapshot = "INCIDENT SNAPSHOT"
+)
+
+// localJobs is every job local-collect knows about, jobs that are ready at the same time
+// start in this order. Disabled jobs are included so they can be recorded as skipped.
+// The log jobs share the budget, nil collects logs without a size cap.
+func localJobs(c *conf.CollectConf, hook shutdown.Hook, budget *logcollect.Budget) []localJob {
+	withConf := func(j func(c *conf.CollectConf, h shutdown.CancelHook) error) func() error {
+		return func() error { return j(c, hook) }
+	}
+	pid := c.DremioPID()
+	logDir := c.DremioLogDir()
+	nodeInfoOutputs := func(names ...string) []string {
+		var outputs []string
+		for _, n := range names {
+			outputs = append(outputs, filepath.Join(c.NodeInfoOutDir(), n))
+		}
+		return outputs
+	}
+	jvmOutputs := func(names ...string) []string {
+		var outputs []string
+		for _, n := range names {
+			outputs = append(outputs, filepath.Join(c.JVMOutDir(), n))
+		}
+		return outputs
+	}
+	jobs := []localJob{
+		// rest calls go first in case the token expires
+		{
+			name:    "WLM COLLECTION",
+			enabled: c.CollectWLM(),
+			reads:   []string{"GET /api/v3/wlm/queue", "GET /api/v3/wlm/rule"},
+			class:   threading.ResourceREST,
+			outputs: []string{c.WLMOutDir()},
+			run:     withConf(apicollect.RunCollectWLM),
+		},
+		{
+			name:    jobSystemTables,
+			enabled: c.CollectSystemTablesExport(),
+			reads:   []string{"POST /api/v3/sql", "GET /api/v3/job/{id}/results"},
+			class:   threading.ResourceREST,
+			outputs: []string{c.SystemTablesOutDir()},
+			run:     withConf(apicollect.RunCollectDremioSystemTables),
+		},
+	}
+	if !c.IsDremioCloud() {
+		logCollector := newLogCollector(c, budget)
+		collectQueriesJSON := c.CollectQueriesJSON() || c.NumberJobProfilesToCollect() > 0
+		if !c.CollectQueriesJSON() && c.NumberJobProfilesToCollect() > 0 {
+			simplelog.Warning("NOT Skipping collection of Queries JSON, because --number-job-profiles is greater than 0 and job profile download requires queries.json ...")
+		}
+		logOutputs := func(patterns ...string) []string {
+			var outputs []string
+			for _, p := range patterns {
+				outputs = append(outputs, filepath.Join(c.LogsOutDir(), p))
+			}
+			return outputs
+		}
+		logReads := func(names ...string) []string {
+			var reads []string
+			for _, n := range names {
+				reads = append(reads, fmt.Sprintf("%v/%v", logDir, n))
+			}
+			return reads
+		}
+		jobs = append(jobs, []localJob{
+			{
+				name:    "KV STORE COLLECTION",
+				enabled: c.CollectKVStoreReport(),
+				reads:   []string{"GET /apiv2/kvstore/report"},
+				class:   threading.ResourceREST,
+				outputs: []string{c.KVstoreOutDir()},
+				run:     withConf(apicollect.RunCollectKvReport),
+			},
+			{
+				name:    "DISK USAGE COLLECTION",
+				enabled: c.CollectDiskUsage(),
+				reads:   []string{"df -h", "du -sh /opt/dremio/data/db/*"},
+				class:   threading.ResourceCPU,
+				outputs: nodeInfoOutputs("diskusage.txt", "rocksdb_disk_allocation.txt"),
+				run:     withConf(nodeinfocollect.RunCollectDiskUsage),
+			},
+			{
+				name:    "DREMIO CONFIG COLLECTION",
+				perJVM:  true,
+				enabled: c.CollectDremioConfiguration(),
+				reads:   []string{c.DremioConfDir()},
+				class:   threading.ResourceCPU,
+				outputs: []string{c.ConfigurationOutDir()},
+				run:     withConf(configcollect.RunCollectDremioConfig),
+			},
+			{
+				name:    "OS CONFIG COLLECTION",
+				enabled: c.CollectOSConfig(),
+				reads:   []string{"/etc/os-release", "/proc", "/sys/block", "/sys/fs/cgroup", "/sys/kernel/mm/transparent_hugepage", fmt.Sprintf("/proc/%v/limits", pid)},
+				class:   threading.ResourceNone,
+				outputs: nodeInfoOutputs(nodeinfocollect.OSInfoTextFile, nodeinfocollect.OSInfoFile),
+				run:     func() error { return nodeinfocollect.RunCollectOSInfo(c) },
+			},
+			{
+				name:    jobQueriesJSON,
+				perJVM:  true,
+				enabled: collectQueriesJSON,
+				reads:   logReads("queries.json", "archive/queries.*"),
+				class:   threading.ResourceCPU,
+				outputs: []string{c.QueriesOutDir()},
+				run:     logCollector.RunCollectQueriesJSON,
+				plan:    logCollector.PlanQueriesJSON,
+			},
+			{
+				name:    "SERVER LOG COLLECTION",
+				perJVM:  true,
+				enabled: c.CollectServerLogs(),
+				reads:   logReads("server.log", "server.out", "archive/server.*"),
+				class:   threading.ResourceCPU,
+				outputs: logOutputs("server.log*", "server.out*", "server.[0-9]*"),
+				run:     logCollector.RunCollectDremioServerLog,
+				plan:    logCollector.PlanDremioServerLog,
+			},
+			{
+				name:    "GC LOG COLLECTION",
+				perJVM:  true,
+				enabled: c.CollectGCLogs(),
+				reads:   []string{fmt.Sprintf("%v/%v", c.GcLogsDir(), c.DremioGCFilePattern())},
+				class:   threading.ResourceCPU,
+				outputs: logOutputs(c.DremioGCFilePattern()),
+				run:     logCollector.RunCollectGcLogs,
+				plan:    logCollector.PlanGcLogs,
+			},
+			{
+				name:    "METADATA LOG COLLECTION",
+				perJVM:  true,
+				enabled: c.CollectMetaRefreshLogs(),
+				reads:   logReads("metadata_refresh.log", "archive/metadata_refresh.*"),
+				class:   threading.ResourceCPU,
+				outputs: logOutputs("metadata_refresh*"),
+				run:     logCollector.RunCollectMetadataRefreshLogs,
+				plan:    logCollector.PlanMetadataRefreshLogs,
+			},
+			{
+				name:    "REFLECTING LOG COLLECTION",
+				perJVM:  true,
+				enabled: c.CollectReflectionLogs(),
+				reads:   logReads("reflection.log", "archive/reflection.*"),
+				class:   threading.ResourceCPU,
+				outputs: logOutputs("reflection*"),
+				run:     logCollector.RunCollectReflectionLogs,
+				plan:    logCollector.PlanReflectionLogs,
+			},
+			{
+				name:    "VACUUM LOG COLLECTION",
+				perJVM:  true,
+				enabled: c.CollectVacuumLogs(),
+				reads:   logReads("vacuum.json", "archive/vacuum.*"),
+				class:   threading.ResourceCPU,
+				outputs: logOutputs("vacuum*"),
+				run:     logCollector.RunCollectVacuumLogs,
+				plan:    logCollector.PlanVacuumLogs,
+			},
+			{
+				name:    "ACCELERATION LOG COLLECTION",
+				perJVM:  true,
+				enabled: c.CollectAccelerationLogs(),
+				reads:   logReads("acceleration.log", "archive/acceleration.*"),
+				class:   threading.ResourceCPU,
+				outputs: logOutputs("acceleration*"),
+				run:     logCollector.RunCollectAccelerationLogs,
+				plan:    logCollector.PlanAccelerationLogs,
+			},
+			{
+				name:    "ACCESS LOG COLLECTION",
+				perJVM:  true,
+				enabled: c.CollectAccessLogs(),
+				reads:   logReads("access.log", "archive/access.*"),
+				class:   threading.ResourceCPU,
+				outputs: logOutputs("access*"),
+				run:     logCollector.RunCollectDremioAccessLogs,
+				plan:    logCollector.PlanDremioAccessLogs,
+			},
+			{
+				name:    "AUDIT LOG COLLECTION",
+				perJVM:  true,
+				enabled: c.CollectAuditLogs(),
+				reads:   logReads("audit.json", "archive/audit.*"),
+				class:   threading.ResourceCPU,
+				outputs: logOutputs("audit*"),
+				run:     logCollector.RunCollectDremioAuditLogs,
+				plan:    logCollector.PlanDremioAuditLogs,
+			},
+			{
+				name:    "EXTRA LOG COLLECTION",
+				perJVM:  true,
+				enabled: c.CollectExtraLogs(),
+				reads:   []string{filepath.Join(c.DremioConfDir(), "logback.xml"), filepath.Join(c.DremioConfDir(), "logback-access.xml")},
+				class:   threading.ResourceCPU,
+				outputs: logOutputs("extra"),
+				run:     logCollector.RunCollectExtraLogs,
+				plan:    logCollector.PlanExtraLogs,
+			},
+			{
+				name:    jobSnapshot,
+				perJVM:  true,
+				enabled: c.CollectSnapshot(),
+				reads:   snapshotReads(c),
+				// it mostly waits for its instants, the captures of an instant run at once
+				class:   threading.ResourceNone,
+				outputs: []string{c.SnapshotOutDir()},
+				run:     withConf(snapshotcollect.RunCollectSnapshot),
+			},
+			{
+				name:    "JVM FLAG COLLECTION",
+				perJVM:  true,
+				enabled: c.CollectJVMFlags(),
+				reads:   []string{"jps -v"},
+				class:   threading.ResourceJVMAttach,
+				outputs: nodeInfoOutputs("jvm_settings.txt"),
+				run:     withConf(jvmcollect.RunCollectJVMFlags),
+			},
+			{
+				name:    jobTtop,
+				perJVM:  true,
+				enabled: c.CollectTtop(),
+				reads:   []string{fmt.Sprintf("top -H -n %v -p %v -d %v -bw", ttopIterations(c), pid, c.DremioTtopFreqSeconds())},
+				class:   threading.ResourceNone,
+				outputs: []string{filepath.Join(c.TtopOutDir(), ttopFile)},
+				run:     withConf(RunTtopCollect),
+			},
+			{
+				name:    "JVM PERF COUNTERS COLLECTION",
+				perJVM:  true,
+				enabled: c.CollectJVMPerfCounters(),
+				reads:   []string{fmt.Sprintf("/tmp/hsperfdata_*/%v (every %v seconds for %v seconds)", pid, c.JVMPerfCountersFreqSeconds(), c.JVMPerfCountersTimeSeconds())},
+				class:   threading.ResourceNone,
+				outputs: nodeInfoOutputs(jvmcollect.PerfCountersFile),
+				run:     withConf(jvmcollect.RunCollectJVMPerfCounters),
+			},
+			{
+				name:    jobJFR,
+				perJVM:  true,
+				enabled: c.CollectJFR(),
+				reads:   []string{fmt.Sprintf("jcmd %v JFR.check/JFR.start/JFR.dump/JFR.stop (%v seconds)", pid, c.DremioJFRTimeSeconds())},
+				class:   threading.ResourceJVMAttach,
+				outputs: []string{filepath.Join(c.JFROutDir(), "*.jfr"), jvmcollect.JFRCheckFile(c)},
+				run:     withConf(jvmcollect.RunCollectJFR),
+			},
+			{
+				name:    jobJStack,
+				perJVM:  true,
+				enabled: c.CollectJStack(),
+				reads:   jstackReads(c),
+				class:   threading.ResourceJVMAttach,
+				outputs: jstackOutputs(c),
+				run:     withConf(jvmcollect.RunCollectJStacks),
+			},
+			{
+				name:    jobClassHistogramTrend,
+				perJVM:  true,
+				enabled: c.CollectJVMClassHistogramTrend(),
+				reads:   []string{fmt.Sprintf("jcmd %v GC.class_histogram (every %v seconds for %v seconds)", pid, c.JVMClassHistogramTrendFreqSeconds(), c.JVMClassHistogramTrendTimeSeconds())},
+				class:   threading.ResourceJVMAttach,
+				outputs: jvmOutputs(jvmcollect.ClassHistogramTrendPrefix+"*", jvmcollect.ClassHistogramTrendFile, jvmcollect.ClassHistogramTrendCSVFile),
+				run:     withConf(jvmcollect.RunCollectClassHistogramTrend),
+			},
+			{
+				name:    "NATIVE MEMORY COLLECTION",
+				perJVM:  true,
+				enabled: c.CollectJVMNativeMemory(),
+				reads:   []string{fmt.Sprintf("jcmd %v VM.native_memory summary", pid)},
+				class:   threading.ResourceJVMAttach,
+				outputs: jvmOutputs(jvmcollect.NativeMemoryFile),
+				run:     withConf(jvmcollect.RunCollectNativeMemory),
+			},
+			{
+				name:    "HEAP INFO COLLECTION",
+				perJVM:  true,
+				enabled: c.CollectJVMHeapInfo(),
+				reads:   []string{fmt.Sprintf("jcmd %v GC.heap_info", pid)},
+				class:   threading.ResourceJVMAttach,
+				outputs: jvmOutputs(jvmcollect.HeapInfoFile),
+				run:     withConf(jvmcollect.RunCollectHeapInfo),
+			},
+			{
+				name:    jobClassHistogram,
+				perJVM:  true,
+				enabled: c.CollectJVMClassHistogram(),
+				reads:   classHistogramReads(c),
+				class:   classHistogramClass(c),
+				// with the trend its samples are reused so every full gc is only run once
+				dependsOn: []string{jobClassHistogramTrend},
+				outputs:   jvmOutputs(jvmcollect.ClassHistogramPrefix + "*"),
+				run:       withConf(jvmcollect.RunCollectClassHistograms),
+			},
+			{
+				name:    "SYSTEM PROPERTIES COLLECTION",
+				perJVM:  true,
+				enabled: c.CollectJVMSystemProperties(),
+				reads:   []string{fmt.Sprintf("jcmd %v VM.system_properties", pid)},
+				class:   threading.ResourceJVMAttach,
+				outputs: jvmOutputs(jvmcollect.SystemPropertiesFile),
+				run:     withConf(jvmcollect.RunCollectSystemProperties),
+			},
+			{
+				name:    "VM INFO COLLECTION",
+				perJVM:  true,
+				enabled: c.CollectJVMInfo(),
+				reads:   []string{fmt.Sprintf("jcmd %v VM.info", pid)},
+				class:   threading.ResourceJVMAttach,
+				outputs: jvmOutputs(jvmcollect.VMInfoFile),
+				run:     withConf(jvmcollect.RunCollectVMInfo),
+			},
+			{
+				name:    "CODE CACHE COLLECTION",
+				perJVM:  true,
+				enabled: c.CollectJVMCodeCache(),
+				reads:   []string{fmt.Sprintf("jcmd %v Compiler.codecache", pid)},
+				class:   threading.ResourceJVMAttach,
+				outputs: jvmOutputs(jvmcollect.CodeCacheFile),
+				run:     withConf(jvmcollect.RunCollectCodeCache),
+			},
+			{
+				name:    "METASPACE COLLECTION",
+				perJVM:  true,
+				enabled: c.CollectJVMMetaspace(),
+				reads:   []string{fmt.Sprintf("jcmd %v VM.metaspace", pid)},
+				class:   threading.ResourceJVMAttach,
+				outputs: jvmOutputs(jvmcollect.MetaspaceFile),
+				run:     withConf(jvmcollect.RunCollectMetaspace),
+			},
+			{
+				name:    jobHeapDump,
+				perJVM:  true,
+				enabled: c.CaptureHeapDump(),
+				reads:   []string{fmt.Sprintf("jmap -dump:format=b %v", pid)},
+				class:   threading.ResourceJVMAttach,
+				// the dump pauses the JVM so it waits until the samplers are done to not skew them
+				dependsOn: []string{jobTtop, jobJFR, jobJStack, jobClassHistogram, jobClassHistogramTrend, jobSnapshot},
+				outputs:   []string{c.HeapDumpsOutDir()},
+				run:       func() error { return jvmcollect.RunCollectHeapDump(c, hook) },
+				// the dump is about the size of the used heap of the JVM
+				estimate: func() (int64, error) {
+					size, _, err := jvmcollect.EstimateHeapDumpBytes(hook, c.DremioPID())
+					return size, err
+				},
+			},
+		}...)
+		jobs = append(jobs, customJobs(c, hook)...)
+	}
+	// job profiles are picked from the queries.json and the job history system tables so they wait for both
+	return append(jobs,
+		localJob{
+			name:      "JOB PROFILES COLLECTION",
+			enabled:   c.NumberJobProfilesToCollect() > 0,
+			reads:     []string{"GET /apiv2/support/{jobid}/download"},
+			class:     threading.ResourceREST,
+			dependsOn: []string{jobQueriesJSON, jobSystemTables},
+			outputs:   []string{c.JobProfilesOutDir()},
+			run:       func() error { return apicollect.RunCollectJobProfiles(c, hook) },
+		},
+		localJob{
+			name:    "CLUSTER STATS COLLECTION",
+			enabled: true,
+			reads:   []string{fmt.Sprintf("jcmd %v VM.system_properties", pid), fmt.Sprintf("GET %v", c.DremioEndpoint())},
+			class:   threading.ResourceJVMAttach,
+			outputs: []string{filepath.Join(c.ClusterStatsOutDir(), "cluster-stats.json")},
+			run:     func() error { return runCollectClusterStats(c, hook) },
+		},
+	)
+}
+
+// nodeJobs are the localJobs of the node. When there is more than one dremio jvm on the node the per jvm
+// jobs run for each of them with the conf of ForJVM, named after the sub node folder, and the node level
+// jobs wait for all of them. The confs are every conf the jobs write with.
+func nodeJobs(c *conf.CollectConf, hook shutdown.Hook, budget *logcollect.Budget) ([]localJob, []*conf.CollectConf) {
+	jvms := c.CollectedDremioJVMs()
+	if len(jvms) < 2 {
+		return localJobs(c, hook, budget), []*conf.CollectConf{c}
+	}
+	confs := []*conf.CollectConf{c}
+	var jvmJobs []localJob
+	// names maps a per jvm job to its name for every jvm
+	names := make(map[string][]string)
+	for _, jvm := range jvms {
+		sub := c.ForJVM(hook, jvm)
+		confs = append(confs, sub)
+		rename := func(name string) string {
+			return fmt.Sprintf("%v [%v]", name, sub.SubNode())
+		}
+		for _, j := range localJobs(sub, hook, budget) {
+			if !j.perJVM {
+				continue
+			}
+			names[j.name] = append(names[j.name], rename(j.name))
+			j.name = rename(j.name)
+			var deps []string
+			for _, d := range j.dependsOn {
+				deps = append(deps, rename(d))
+			}
+			j.dependsOn = deps
+			jvmJobs = append(jvmJobs, j)
+		}
+	}
+	var jobs []localJob
+	for _, j := range localJobs(c, hook, budget) {
+		if j.perJVM {
+			continue
+		}
+		var deps []string
+		for _, d := range j.dependsOn {
+			if renamed, ok := names[d]; ok {
+				deps = append(deps, renamed...)
+				continue
+			}
+			deps = append(deps, d)
+		}
+		j.dependsOn = deps
+		jobs = append(jobs, j)
+	}
+	return append(jobs, jvmJobs...), confs
+}
+
+// customJobs has a job for every custom-collectors entry of ddc.yaml, entries that do not allow the
+// collection mode are disabled
+func customJobs(c *conf.CollectConf, hook shutdown.Hook) []localJob {
+	var jobs []localJob
+	for _, cc := range c.CustomCollectors() {
+		cc := cc
+		job := localJob{
+			name:    cc.JobName(),
+			enabled: cc.AllowsMode(c.CollectionModeLineage()...),
+			reads:   cc.Files,
+			class:   threading.ResourceCPU,
+			outputs: []string{filepath.Join(c.CustomOutDir(), cc.Name)},
+			run:     func() error { return customcollect.RunCollect(c, hook, cc) },
+		}
+		if cc.Command != "" {
+			job.reads = []string{fmt.Sprintf("%v (timeout %v seconds)", cc.Command, cc.TimeoutSeconds)}
+			job.class = threading.ResourceNone
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// ttopIterations is how many samples top takes
+func ttopIterations(c *conf.CollectConf) int {
+	if c.DremioTtopFreqSeconds() == 0 {
+		return 0
+	}
+	return c.DremioTtopTimeSeconds() / c.DremioTtopFreqSeconds()
+}
+
+// snapshotReads lists what every instant of the incident snapshot reads
+func snapshotReads(c *conf.CollectConf) []string {
+	reads := []string{fmt.Sprintf("jcmd %v Thread.print -l, GC.heap_info, /proc/%v/task/*/stat and hsperfdata (%v times every %v from %v)", c.DremioPID(), c.DremioPID(), c.SnapshotCount(), c.SnapshotInterval(), c.SnapshotAt().Format(time.RFC3339))}
+	if c.CollectSnapshotREST() {
+		for _, systable := range apicollect.SnapshotSystemTables {
+			reads = append(reads, "SELECT * FROM sys."+systable)
+		}
+	}
+	return reads
+}
+
+// jstackReads and jstackOutputs include the /proc files of the threads and the hot threads when they are sampled with the thread dumps
+func jstackReads(c *conf.CollectConf) []string {
+	reads := []string{fmt.Sprintf("jcmd %v Thread.print -l (every %v seconds for %v seconds)", c.DremioPID(), c.DremioJStackFreqSeconds(), c.DremioJStackTimeSeconds())}
+	if c.CollectHotThreads() {
+		reads = append(reads, fmt.Sprintf("/proc/%v/task/*/stat, comm and status (with every thread dump)", c.DremioPID()))
+	}
+	return reads
+}
+
+func jstackOutputs(c *conf.CollectConf) []string {
+	outputs := []string{c.ThreadDumpsOutDir()}
+	if c.CollectHotThreads() {
+		outputs = append(outputs, filepath.Join(c.TtopOutDir(), jvmcollect.HotThreadsFile), filepath.Join(c.TtopOutDir(), jvmcollect.HotThreadsTextFile))
+	}
+	return outputs
+}
+
+// classHistogramReads and classHistogramClass describe the class histograms, which only copy the samples of the trend when it runs
+func classHistogramReads(c *conf.CollectConf) []string {
+	if c.CollectJVMClassHistogramTrend() {
+		return []string{fmt.Sprintf("%v of the class histogram trend samples", c.JVMClassHistogramCount())}
+	}
+	return []string{fmt.Sprintf("jcmd %v GC.class_histogram (%v times every %v seconds)", c.DremioPID(), c.JVMClassHistogramCount(), c.JVMClassHistogramIntervalSeconds())}
+}
+
+func classHistogramClass(c *conf.CollectConf) threading.ResourceClass {
+	if c.CollectJVMClassHistogramTrend() {
+		return threading.ResourceNone
+	}
+	return threading.ResourceJVMAttach
+}
@@ -0,0 +1,278 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jvmcollect
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/hotspot"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+)
+
+// files of the class histogram trend in JVMOutDir
+const (
+	ClassHistogramTrendFile    = "class-histogram-trend.json"
+	ClassHistogramTrendCSVFile = "class-histogram-trend.csv"
+	// ClassHistogramTrendPrefix starts the file name of every sample of the trend, it differs from
+	// ClassHistogramPrefix so the samples do not collide with the separate class histograms
+	ClassHistogramTrendPrefix = "class-histogram-trend-"
+)
+
+// ClassHistogramEntry is one class of a GC.class_histogram
+type ClassHistogramEntry struct {
+	Class     string `json:"class"`
+	Instances int64  `json:"instances"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// ClassHistogramSample is a GC.class_histogram and when it was taken
+type ClassHistogramSample struct {
+	Time    time.Time
+	Entries []ClassHistogramEntry
+}
+
+// ClassGrowth is how much a class grew between the first and the last sample, a class missing
+// from a sample had no instances at that time
+type ClassGrowth struct {
+	Class           string `json:"class"`
+	FirstInstances  int64  `json:"firstInstances"`
+	LastInstances   int64  `json:"lastInstances"`
+	InstancesGrowth int64  `json:"instancesGrowth"`
+	FirstBytes      int64  `json:"firstBytes"`
+	LastBytes       int64  `json:"lastBytes"`
+	BytesGrowth     int64  `json:"bytesGrowth"`
+	// GrowingIntervals is in how many of the intervals between samples the bytes grew, a leak grows in most of them
+	GrowingIntervals int `json:"growingIntervals"`
+}
+
+// ClassHistogramTrend is the top classes by growth in bytes and in instances over the sampled window
+type ClassHistogramTrend struct {
+	PID            int           `json:"pid"`
+	Samples        []time.Time   `json:"samples"`
+	Intervals      int           `json:"intervals"`
+	TotalBytes     []int64       `json:"totalBytes"`
+	TopByBytes     []ClassGrowth `json:"topByBytes"`
+	TopByInstances []ClassGrowth `json:"topByInstances"`
+}
+
+// ParseClassHistogram reads the output of GC.class_histogram. The module after the class name is dropped
+// and classes with the same name from different class loaders are summed.
+func ParseClassHistogram(text string) ([]ClassHistogramEntry, error) {
+	index := make(map[string]int)
+	var entries []ClassHistogramEntry
+	for _, line := range strings.Split(text, "\n") {
+		// "   1:        123456       12345678  [B (java.base@11.0.2)"
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !strings.HasSuffix(fields[0], ":") {
+			continue
+		}
+		if _, err := strconv.Atoi(strings.TrimSuffix(fields[0], ":")); err != nil {
+			continue
+		}
+		instances, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid instance count in class histogram line '%v': %w", line, err)
+		}
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid byte count in class histogram line '%v': %w", line, err)
+		}
+		class := fields[3]
+		if i, ok := index[class]; ok {
+			entries[i].Instances += instances
+			entries[i].Bytes += size
+			continue
+		}
+		index[class] = len(entries)
+		entries = append(entries, ClassHistogramEntry{Class: class, Instances: instances, Bytes: size})
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no classes in the class histogram '%v'", strings.TrimSpace(text))
+	}
+	return entries, nil
+}
+
+// AnalyzeClassHistograms ranks the classes that grew the most from the first to the last sample,
+// classes that did not grow are left out
+func AnalyzeClassHistograms(samples []ClassHistogramSample, topN int) ClassHistogramTrend {
+	trend := ClassHistogramTrend{
+		TopByBytes:     []ClassGrowth{},
+		TopByInstances: []ClassGrowth{},
+	}
+	if len(samples) == 0 {
+		return trend
+	}
+	trend.Intervals = len(samples) - 1
+	byClass := make([]map[string]ClassHistogramEntry, len(samples))
+	var classes []string
+	seen := make(map[string]bool)
+	for i, s := range samples {
+		trend.Samples = append(trend.Samples, s.Time)
+		byClass[i] = make(map[string]ClassHistogramEntry, len(s.Entries))
+		var total int64
+		for _, e := range s.Entries {
+			byClass[i][e.Class] = e
+			total += e.Bytes
+			if !seen[e.Class] {
+				seen[e.Class] = true
+				classes = append(classes, e.Class)
+			}
+		}
+		trend.TotalBytes = append(trend.TotalBytes, total)
+	}
+	var growths []ClassGrowth
+	for _, class := range classes {
+		first, last := byClass[0][class], byClass[len(samples)-1][class]
+		g := ClassGrowth{
+			Class:           class,
+			FirstInstances:  first.Instances,
+			LastInstances:   last.Instances,
+			InstancesGrowth: last.Instances - first.Instances,
+			FirstBytes:      first.Bytes,
+			LastBytes:       last.Bytes,
+			BytesGrowth:     last.Bytes - first.Bytes,
+		}
+		for i := 1; i < len(samples); i++ {
+			if byClass[i][class].Bytes > byClass[i-1][class].Bytes {
+				g.GrowingIntervals++
+			}
+		}
+		growths = append(growths, g)
+	}
+	trend.TopByBytes = topGrowth(growths, topN, func(g ClassGrowth) int64 { return g.BytesGrowth })
+	trend.TopByInstances = topGrowth(growths, topN, func(g ClassGrowth) int64 { return g.InstancesGrowth })
+	return trend
+}
+
+func topGrowth(growths []ClassGrowth, topN int, growth func(ClassGrowth) int64) []ClassGrowth {
+	top := []ClassGrowth{}
+	for _, g := range growths {
+		if growth(g) > 0 {
+			top = append(top, g)
+		}
+	}
+	sort.SliceStable(top, func(a, b int) bool {
+		if growth(top[a]) != growth(top[b]) {
+			return growth(top[a]) > growth(top[b])
+		}
+		return top[a].Class < top[b].Class
+	})
+	if len(top) > topN {
+		top = top[:topN]
+	}
+	return top
+}
+
+// WriteClassHistogramCSV writes the time series of the top classes of the trend as time,class,instances,bytes rows
+func WriteClassHistogramCSV(w io.Writer, samples []ClassHistogramSample, trend ClassHistogramTrend) error {
+	var classes []string
+	seen := make(map[string]bool)
+	for _, top := range [][]ClassGrowth{trend.TopByBytes, trend.TopByInstances} {
+		for _, g := range top {
+			if !seen[g.Class] {
+				seen[g.Class] = true
+				classes = append(classes, g.Class)
+			}
+		}
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"time", "class", "instances", "bytes"}); err != nil {
+		return err
+	}
+	for _, s := range samples {
+		byClass := make(map[string]ClassHistogramEntry, len(s.Entries))
+		for _, e := range s.Entries {
+			byClass[e.Class] = e
+		}
+		for _, class := range classes {
+			e := byClass[class]
+			row := []string{s.Time.UTC().Format(time.RFC3339), class, strconv.FormatInt(e.Instances, 10), strconv.FormatInt(e.Bytes, 10)}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// RunCollectClassHistogramTrend takes a GC.class_histogram every JVMClassHistogramTrendFreqSeconds for
+// JVMClassHistogramTrendTimeSeconds and writes the classes that grew the most and their time series.
+// When heap dumps are too large to take this is the best evidence of a leak, every sample runs a full gc.
+func RunCollectClassHistogramTrend(c *conf.CollectConf, hook shutdown.CancelHook) error {
+	pid := c.DremioPID()
+	freq := max(c.JVMClassHistogramTrendFreqSeconds(), 1)
+	// one more sample than intervals so the first and the last sample span the whole window
+	count := c.JVMClassHistogramTrendTimeSeconds()/freq + 1
+	simplelog.Debugf("Running class histograms of pid %v every %v second(s) for a total of %v samples ...", pid, freq, count)
+	var samples []ClassHistogramSample
+	var stopErr error
+	for i := 0; i < count; i++ {
+		var w bytes.Buffer
+		if err := hotspot.Jcmd(hook, &w, pid, "GC.class_histogram"); err != nil {
+			if i == 0 {
+				return fmt.Errorf("unable to capture class histogram of pid %v: %w", pid, err)
+			}
+			// the jvm may have exited, keep what was sampled until then
+			stopErr = fmt.Errorf("stopped after %v of %v class histograms: %w", i, count, err)
+			break
+		}
+		now := time.Now()
+		if err := writeJVMFile(c, ClassHistogramTrendPrefix+now.Format("2006-01-02_15_04_05")+".txt", w.Bytes()); err != nil {
+			return err
+		}
+		entries, err := ParseClassHistogram(w.String())
+		if err != nil {
+			stopErr = fmt.Errorf("stopped after %v of %v class histograms: %w", i, count, err)
+			break
+		}
+		samples = append(samples, ClassHistogramSample{Time: now, Entries: entries})
+		if i == count-1 {
+			break
+		}
+		if !waitWithinDeadline(hook, time.Duration(freq)*time.Second) {
+			stopErr = fmt.Errorf("stopped after %v of %v class histograms: %w", i+1, count, context.DeadlineExceeded)
+			break
+		}
+	}
+	trend := AnalyzeClassHistograms(samples, c.JVMClassHistogramTrendTopN())
+	trend.PID = pid
+	b, err := json.MarshalIndent(trend, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal %v: %w", ClassHistogramTrendFile, err)
+	}
+	if err := writeJVMFile(c, ClassHistogramTrendFile, b); err != nil {
+		return err
+	}
+	var csvOut bytes.Buffer
+	if err := WriteClassHistogramCSV(&csvOut, samples, trend); err != nil {
+		return fmt.Errorf("unable to write %v: %w", ClassHistogramTrendCSVFile, err)
+	}
+	if err := writeJVMFile(c, ClassHistogramTrendCSVFile, csvOut.Bytes()); err != nil {
+		return err
+	}
+	return stopErr
+}
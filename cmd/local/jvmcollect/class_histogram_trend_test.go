@@ -0,0 +1,103 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jvmcollect
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+const classHistogramJDK11 = `12345:
+ num     #instances         #bytes  class name (module)
+-------------------------------------------------------
+   1:        100000       40000000  [B (java.base@11.0.20)
+   2:         50000        1200000  java.lang.String (java.base@11.0.20)
+   3:          1000          64000  com.dremio.Leak
+   4:            10            640  com.dremio.Leak
+Total        151010       41264640
+`
+
+func TestParseClassHistogram(t *testing.T) {
+	entries, err := ParseClassHistogram(classHistogramJDK11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 classes but got %v", entries)
+	}
+	expected := []ClassHistogramEntry{
+		{Class: "[B", Instances: 100000, Bytes: 40000000},
+		{Class: "java.lang.String", Instances: 50000, Bytes: 1200000},
+		// the same class from two class loaders is summed
+		{Class: "com.dremio.Leak", Instances: 1010, Bytes: 64640},
+	}
+	for i, e := range expected {
+		if entries[i] != e {
+			t.Errorf("expected %v but got %v", e, entries[i])
+		}
+	}
+	if _, err := ParseClassHistogram("12345:\nCommand executed successfully\n"); err == nil {
+		t.Error("expected an error for output without classes")
+	}
+}
+
+func TestAnalyzeClassHistograms(t *testing.T) {
+	start := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	sample := func(minute int, leakInstances, leakBytes, stringBytes int64, extra ...ClassHistogramEntry) ClassHistogramSample {
+		entries := []ClassHistogramEntry{
+			{Class: "com.dremio.Leak", Instances: leakInstances, Bytes: leakBytes},
+			{Class: "java.lang.String", Instances: 100, Bytes: stringBytes},
+		}
+		return ClassHistogramSample{Time: start.Add(time.Duration(minute) * time.Minute), Entries: append(entries, extra...)}
+	}
+	samples := []ClassHistogramSample{
+		sample(0, 10, 1000, 5000),
+		sample(1, 20, 2000, 9000),
+		sample(2, 30, 3000, 4000, ClassHistogramEntry{Class: "com.dremio.New", Instances: 500, Bytes: 800}),
+	}
+	trend := AnalyzeClassHistograms(samples, 1)
+	if trend.Intervals != 2 || len(trend.Samples) != 3 {
+		t.Errorf("expected 3 samples and 2 intervals but got %v and %v", len(trend.Samples), trend.Intervals)
+	}
+	if len(trend.TopByBytes) != 1 || trend.TopByBytes[0].Class != "com.dremio.Leak" || trend.TopByBytes[0].BytesGrowth != 2000 || trend.TopByBytes[0].GrowingIntervals != 2 {
+		t.Errorf("expected com.dremio.Leak to have grown the most bytes in both intervals but got %+v", trend.TopByBytes)
+	}
+	if len(trend.TopByInstances) != 1 || trend.TopByInstances[0].Class != "com.dremio.New" || trend.TopByInstances[0].FirstInstances != 0 {
+		t.Errorf("expected the new class to have grown the most instances but got %+v", trend.TopByInstances)
+	}
+	if trend.TotalBytes[0] != 6000 || trend.TotalBytes[2] != 7800 {
+		t.Errorf("unexpected total bytes %v", trend.TotalBytes)
+	}
+	// java.lang.String shrank overall so it is never a top class
+	for _, g := range AnalyzeClassHistograms(samples, 10).TopByBytes {
+		if g.Class == "java.lang.String" {
+			t.Errorf("expected classes that did not grow to be left out but got %+v", g)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := WriteClassHistogramCSV(&out, samples, trend); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 7 {
+		t.Fatalf("expected a header and 2 classes for 3 samples but got\n%v", out.String())
+	}
+	if lines[0] != "time,class,instances,bytes" || lines[1] != "2026-10-19T10:00:00Z,com.dremio.Leak,10,1000" || lines[2] != "2026-10-19T10:00:00Z,com.dremio.New,0,0" {
+		t.Errorf("unexpected csv\n%v", out.String())
+	}
+}
@@ -0,0 +1,67 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// package jvmcollect handles parsing of the jvm information
+package jvmcollect
+
+import (
+	"context"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+)
+
+// deadlineReserve is the time kept free before the collection deadline so jcmd can still dump and stop recordings
+const deadlineReserve = 30 * time.Second
+
+// waitWithinDeadline sleeps for the requested duration but wakes up early enough to leave
+// deadlineReserve before the deadline of the hook context. It returns false when the wait was shortened.
+func waitWithinDeadline(hook shutdown.CancelHook, d time.Duration) bool {
+	ctx := hook.GetContext()
+	complete := true
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline) - deadlineReserve
+		if remaining < d {
+			simplelog.Warningf("shortening wait from %v to %v because of the collection deadline", d, max(remaining, 0))
+			d = max(remaining, 0)
+			complete = false
+		}
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return complete
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// wrapUpHook carries a context that outlives the collection context
+type wrapUpHook struct {
+	ctx context.Context
+}
+
+func (h wrapUpHook) GetContext() context.Context {
+	return h.ctx
+}
+
+// wrapUp runs f after the collection context was cancelled so the jvm is not left with running work
+// such as a JFR recording. It gets deadlineReserve to complete.
+func wrapUp(hook shutdown.CancelHook, f func(h shutdown.CancelHook) error) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(hook.GetContext()), deadlineReserve)
+	defer cancel()
+	return f(wrapUpHook{ctx: ctx})
+}
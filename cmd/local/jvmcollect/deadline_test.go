@@ -0,0 +1,63 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jvmcollect
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
+)
+
+func TestWaitWithinDeadlineShortensWait(t *testing.T) {
+	hook := shutdown.NewHookWithDeadline(time.Now().Add(deadlineReserve + 100*time.Millisecond))
+	defer hook.Cleanup()
+	start := time.Now()
+	if waitWithinDeadline(hook, time.Minute) {
+		t.Error("expected the wait to be reported as shortened")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected the wait to be shortened but it took %v", elapsed)
+	}
+}
+
+func TestWaitWithinDeadlineWithoutDeadline(t *testing.T) {
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+	if !waitWithinDeadline(hook, 10*time.Millisecond) {
+		t.Error("expected the full wait without a deadline")
+	}
+}
+
+func TestWrapUpAfterStop(t *testing.T) {
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+	hook.Stop(true)
+	if waitWithinDeadline(hook, time.Minute) {
+		t.Error("expected the wait to end on stop")
+	}
+	err := wrapUp(hook, func(h shutdown.CancelHook) error {
+		if err := h.GetContext().Err(); err != nil {
+			t.Errorf("expected a live context to wrap up but got %v", err)
+		}
+		if _, ok := h.GetContext().Deadline(); !ok {
+			t.Error("expected the wrap up to have a deadline")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -18,58 +18,226 @@ package jvmcollect
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"time"
 
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/ddcio"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/dirs"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/hotspot"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
 )
 
+const (
+	// heapDumpCompressedRatio budgets the compressed dump at half of the raw dump, hprof files usually compress 3 to 5 times
+	heapDumpCompressedRatio = 2
+	// heapDumpMarginBytes is left free on top of what the dump needs
+	heapDumpMarginBytes = 512 * 1024 * 1024
+	// heapDumpBlockSize is the size of the blocks compressed in parallel
+	heapDumpBlockSize = 4 * 1024 * 1024
+)
+
+// maxHeapSizeFlag is the heap size of VM.flags such as -XX:MaxHeapSize=4294967296
+var maxHeapSizeFlag = regexp.MustCompile(`-XX:MaxHeapSize=(\d+)`)
+
 func RunCollectHeapDump(c *conf.CollectConf, hook shutdown.Hook) error {
 	simplelog.Debug("Capturing Java Heap Dump")
 	dremioPID := c.DremioPID()
 	baseName := fmt.Sprintf("%v.hprof", c.NodeName())
 
-	hprofFile := filepath.Join(c.OutputDir(), baseName)
-	hprofGzFile := fmt.Sprintf("%v.gz", hprofFile)
-	if err := os.Remove(path.Clean(hprofGzFile)); err != nil {
-		simplelog.Warningf("unable to remove hprof.gz file with error %v", err)
+	dumpDir := c.HeapDumpDir()
+	if fi, err := os.Stat(dumpDir); err != nil || !fi.IsDir() {
+		return fmt.Errorf("invalid heap dump dir %v: %w", dumpDir, err)
 	}
-	if err := os.Remove(path.Clean(hprofFile)); err != nil {
+	hprofFile := filepath.Join(dumpDir, baseName)
+	dest := filepath.Join(c.HeapDumpsOutDir(), baseName+".gz")
+	if err := os.Remove(path.Clean(hprofFile)); err != nil && !os.IsNotExist(err) {
 		simplelog.Warningf("unable to remove hprof file with error %v", err)
 	}
 	hook.Add(func() {
-		// make sure they're removed on ctrl+c so run them again in the shutdown hook
-		if err := os.Remove(path.Clean(hprofGzFile)); err != nil {
-			simplelog.Warningf("unable to remove hprof.gz file with error %v", err)
-		}
-		if err := os.Remove(path.Clean(hprofFile)); err != nil {
+		// make sure the raw dump is removed on ctrl+c so run it again in the shutdown hook
+		if err := os.Remove(path.Clean(hprofFile)); err != nil && !os.IsNotExist(err) {
 			simplelog.Warningf("unable to remove hprof file with error %v", err)
 		}
 	}, "removing heap dump files")
+
+	estimate, source, err := EstimateHeapDumpBytes(hook, dremioPID)
+	if err != nil {
+		return fmt.Errorf("not capturing a heap dump of pid %v as its size cannot be checked against the free space: %w", dremioPID, err)
+	}
+	simplelog.Infof("heap dump of pid %v is estimated at %v bytes from %v", dremioPID, estimate, source)
+	if err := CheckHeapDumpSpace(estimate, dumpDir, c.HeapDumpsOutDir(), dirFreeSpace); err != nil {
+		return fmt.Errorf("not capturing a heap dump of pid %v: %w", dremioPID, err)
+	}
+
 	var w bytes.Buffer
-	if err := ddcio.Shell(hook, &w, fmt.Sprintf("jmap -dump:format=b,file=%v %v", hprofFile, dremioPID)); err != nil {
+	if err := hotspot.HeapDump(hook, &w, dremioPID, hprofFile, c.HeapDumpLiveObjects()); err != nil {
 		return fmt.Errorf("unable to capture heap dump: %w", err)
 	}
 	simplelog.Debugf("heap dump output %v", w.String())
-	if err := ddcio.GzipFile(hprofFile, hprofGzFile); err != nil {
-		return fmt.Errorf("unable to gzip heap dump file: %w", err)
-	}
-	if err := os.Remove(path.Clean(hprofFile)); err != nil {
-		simplelog.Warningf("unable to remove old hprof file, must remove manually %v", err)
-	}
 	defer func() {
 		// run cleanup a second time to make sure it happens
-		if err := os.Remove(path.Clean(hprofFile)); err != nil {
+		if err := os.Remove(path.Clean(hprofFile)); err != nil && !os.IsNotExist(err) {
 			simplelog.Warningf("unable to remove old hprof file, must remove manually %v", err)
 		}
 	}()
-	dest := filepath.Join(c.HeapDumpsOutDir(), baseName+".gz")
-	if err := os.Rename(path.Clean(hprofGzFile), path.Clean(dest)); err != nil {
-		return fmt.Errorf("unable to move heap dump to %v: %w", dest, err)
+	if err := CompressHeapDump(hprofFile, dest, max(runtime.NumCPU()/2, 1)); err != nil {
+		if rmErr := os.Remove(path.Clean(dest)); rmErr != nil && !os.IsNotExist(rmErr) {
+			simplelog.Warningf("unable to remove partial heap dump %v: %v", dest, rmErr)
+		}
+		return fmt.Errorf("unable to compress heap dump file: %w", err)
+	}
+	return nil
+}
+
+// EstimateHeapDumpBytes is the used heap of the hsperfdata of the jvm, when there is no hsperfdata
+// it is the max heap size of VM.flags. The source of the estimate is returned too.
+func EstimateHeapDumpBytes(hook shutdown.CancelHook, pid int) (int64, string, error) {
+	loc, perfErr := hotspot.FindPerfData(pid)
+	if perfErr == nil {
+		var p *hotspot.PerfData
+		if p, perfErr = hotspot.ReadPerfData(loc); perfErr == nil {
+			var used int64
+			for _, g := range p.Sample(time.Now()).Generations {
+				used += g.UsedBytes
+			}
+			if used > 0 {
+				return used, "the used heap of " + loc, nil
+			}
+			perfErr = fmt.Errorf("no heap generations in %v", loc)
+		}
+	}
+	simplelog.Debugf("unable to read the used heap of pid %v from hsperfdata, using VM.flags: %v", pid, perfErr)
+	var w bytes.Buffer
+	if err := hotspot.Jcmd(hook, &w, pid, "VM.flags"); err != nil {
+		return 0, "", fmt.Errorf("unable to read the heap size from hsperfdata (%v) or VM.flags: %w", perfErr, err)
+	}
+	size, err := ParseMaxHeapSize(w.String())
+	if err != nil {
+		return 0, "", err
+	}
+	return size, "the max heap size of VM.flags", nil
+}
+
+// ParseMaxHeapSize reads -XX:MaxHeapSize from the output of VM.flags
+func ParseMaxHeapSize(vmFlags string) (int64, error) {
+	m := maxHeapSizeFlag.FindStringSubmatch(vmFlags)
+	if m == nil {
+		return 0, fmt.Errorf("no -XX:MaxHeapSize in VM.flags '%v'", vmFlags)
+	}
+	return strconv.ParseInt(m[1], 10, 64)
+}
+
+func dirFreeSpace(dir string) (uint64, error) {
+	return dirs.GetFreeSpaceOnFileSystem(dir)
+}
+
+// CheckHeapDumpSpace makes sure the raw dump of estimate bytes fits in dumpDir and the compressed dump fits in outDir.
+// The jvm writes the whole raw dump before it is compressed so the peak is about 1x the estimate. On one file system
+// that can free the blocks of the raw dump while it is compressed the compressed dump takes no extra space.
+func CheckHeapDumpSpace(estimate int64, dumpDir, outDir string, freeSpace func(string) (uint64, error)) error {
+	compressed := estimate / heapDumpCompressedRatio
+	required := map[string]int64{dumpDir: estimate + heapDumpMarginBytes}
+	if !sameFileSystem(dumpDir, outDir) {
+		required[outDir] = compressed + heapDumpMarginBytes
+	} else if !canPunchHoles(dumpDir) {
+		required[dumpDir] += compressed
+	}
+	for _, dir := range []string{dumpDir, outDir} {
+		need, ok := required[dir]
+		if !ok {
+			continue
+		}
+		free, err := freeSpace(dir)
+		if err != nil {
+			return err
+		}
+		if free < uint64(need) { // #nosec G115
+			return fmt.Errorf("a heap dump of about %v bytes needs %v bytes free on %v but only %v are free, set %v to a dir with more space", estimate, need, dir, free, conf.KeyHeapDumpDir)
+		}
+	}
+	return nil
+}
+
+// CheckCompressSpace makes sure the compressed dump of a raw dump of rawSize bytes fits in outDir once the raw dump
+// turns out to be read only. Its blocks cannot be freed then, which CheckHeapDumpSpace may have counted on.
+func CheckCompressSpace(rawSize int64, outDir string, freeSpace func(string) (uint64, error)) error {
+	need := rawSize/heapDumpCompressedRatio + heapDumpMarginBytes
+	free, err := freeSpace(outDir)
+	if err != nil {
+		return err
+	}
+	if free < uint64(need) { // #nosec G115
+		return fmt.Errorf("the read only heap dump of %v bytes cannot be freed while it is compressed and needs %v bytes free on %v but only %v are free, set %v to a dir with more space", rawSize, need, outDir, free, conf.KeyHeapDumpDir)
+	}
+	return nil
+}
+
+// holePunchingReader frees the blocks of the file it already read so a dump compressed on the
+// same file system never takes more than its own size
+type holePunchingReader struct {
+	f     *os.File
+	off   int64
+	punch bool
+}
+
+func (r *holePunchingReader) Read(p []byte) (int, error) {
+	n, err := r.f.Read(p)
+	if n > 0 && r.punch {
+		if perr := punchHole(r.f, r.off, int64(n)); perr != nil {
+			simplelog.Warningf("unable to free the blocks of %v while compressing it, it is removed when done: %v", r.f.Name(), perr)
+			r.punch = false
+		}
+	}
+	r.off += int64(n)
+	return n, err
+}
+
+// CompressHeapDump compresses the raw dump the jvm finished writing into a gzip file with workers goroutines, the
+// blocks of the raw dump are freed as they are read when the file system supports it and the raw dump is removed at
+// the end. The raw dump is never streamed, so the disk usage peaks at about its size. When the raw dump can only be
+// read the space for the compressed dump is checked again before compressing.
+func CompressHeapDump(src, dst string, workers int) error {
+	punch := true
+	in, err := os.OpenFile(path.Clean(src), os.O_RDWR, 0)
+	if err != nil {
+		// the dump belongs to the dremio user, reading it is enough to compress it
+		punch = false
+		if in, err = os.Open(path.Clean(src)); err != nil {
+			return err
+		}
+	}
+	defer in.Close()
+	if !punch {
+		info, err := in.Stat()
+		if err != nil {
+			return err
+		}
+		if err := CheckCompressSpace(info.Size(), filepath.Dir(path.Clean(dst)), dirFreeSpace); err != nil {
+			return err
+		}
+	}
+	out, err := os.Create(path.Clean(dst))
+	if err != nil {
+		return err
+	}
+	var r io.Reader = &holePunchingReader{f: in, punch: punch}
+	if err := ddcio.ParallelGzip(out, r, heapDumpBlockSize, workers); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("unable to close %v: %w", dst, err)
+	}
+	if err := os.Remove(path.Clean(src)); err != nil {
+		simplelog.Warningf("unable to remove old hprof file, must remove manually %v", err)
 	}
 	return nil
 }
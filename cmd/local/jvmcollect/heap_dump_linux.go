@@ -0,0 +1,59 @@
+//go:build linux
+// +build linux
+
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jvmcollect
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// punchHole frees the blocks of a range of the file without changing its size
+func punchHole(f *os.File, off, n int64) error {
+	return unix.Fallocate(int(f.Fd()), unix.FALLOC_FL_PUNCH_HOLE|unix.FALLOC_FL_KEEP_SIZE, off, n)
+}
+
+// canPunchHoles tries punching a hole in a scratch file of dir, not every file system supports it
+func canPunchHoles(dir string) bool {
+	f, err := os.CreateTemp(dir, ".ddc-punch-probe-*")
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+	if _, err := f.Write(make([]byte, 8192)); err != nil {
+		return false
+	}
+	return punchHole(f, 0, 4096) == nil
+}
+
+// sameFileSystem is true when both dirs are on the same device
+func sameFileSystem(a, b string) bool {
+	var sa, sb syscall.Stat_t
+	if err := syscall.Stat(filepath.Clean(a), &sa); err != nil {
+		return true
+	}
+	if err := syscall.Stat(filepath.Clean(b), &sb); err != nil {
+		return true
+	}
+	return sa.Dev == sb.Dev
+}
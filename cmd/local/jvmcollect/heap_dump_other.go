@@ -0,0 +1,37 @@
+//go:build !linux
+// +build !linux
+
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jvmcollect
+
+import (
+	"errors"
+	"os"
+)
+
+// punchHole is only supported on linux, the raw dump is removed once it is compressed
+func punchHole(_ *os.File, _, _ int64) error {
+	return errors.ErrUnsupported
+}
+
+func canPunchHoles(_ string) bool {
+	return false
+}
+
+// sameFileSystem cannot tell so it assumes both dirs share their free space
+func sameFileSystem(_, _ string) bool {
+	return true
+}
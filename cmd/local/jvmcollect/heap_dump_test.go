@@ -16,7 +16,10 @@
 package jvmcollect_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -120,3 +123,87 @@ dremio-pid: %v
 		t.Errorf("expected a non empty file for the hprof but we got one")
 	}
 }
+
+func TestParseMaxHeapSize(t *testing.T) {
+	flags := `12345:
+-XX:CICompilerCount=4 -XX:InitialHeapSize=264241152 -XX:MaxHeapSize=4294967296 -XX:+UseG1GC
+`
+	size, err := jvmcollect.ParseMaxHeapSize(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 4294967296 {
+		t.Errorf("expected 4294967296 but got %v", size)
+	}
+	if _, err := jvmcollect.ParseMaxHeapSize("-XX:+UseG1GC"); err == nil {
+		t.Error("expected an error without -XX:MaxHeapSize")
+	}
+}
+
+func TestCheckHeapDumpSpace(t *testing.T) {
+	dir := t.TempDir()
+	var gib int64 = 1024 * 1024 * 1024
+	free := func(free int64) func(string) (uint64, error) {
+		return func(string) (uint64, error) { return uint64(free), nil }
+	}
+	if err := jvmcollect.CheckHeapDumpSpace(4*gib, dir, dir, free(10*gib)); err != nil {
+		t.Errorf("expected 10GiB free to be enough for a 4GiB dump but got %v", err)
+	}
+	err := jvmcollect.CheckHeapDumpSpace(4*gib, dir, dir, free(4*gib))
+	if err == nil {
+		t.Fatal("expected 4GiB free to not be enough for a 4GiB dump")
+	}
+	if !strings.Contains(err.Error(), conf.KeyHeapDumpDir) {
+		t.Errorf("expected the error to point to %v but got %v", conf.KeyHeapDumpDir, err)
+	}
+}
+
+func TestCompressHeapDump(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "node1.hprof")
+	dst := filepath.Join(dir, "node1.hprof.gz")
+	data := bytes.Repeat([]byte("JAVA PROFILE 1.0.2 heap dump record "), 500000)
+	if err := os.WriteFile(src, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := jvmcollect.CompressHeapDump(src, dst, 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Errorf("expected the raw dump to be removed but got %v", err)
+	}
+	f, err := os.Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, data) {
+		t.Errorf("expected %v bytes back from the compressed dump but got %v", len(data), len(actual))
+	}
+}
+
+func TestCheckCompressSpace(t *testing.T) {
+	dir := t.TempDir()
+	var gib int64 = 1024 * 1024 * 1024
+	free := func(free int64) func(string) (uint64, error) {
+		return func(string) (uint64, error) { return uint64(free), nil }
+	}
+	if err := jvmcollect.CheckCompressSpace(4*gib, dir, free(3*gib)); err != nil {
+		t.Errorf("expected 3GiB free to be enough to compress a read only 4GiB dump but got %v", err)
+	}
+	err := jvmcollect.CheckCompressSpace(4*gib, dir, free(2*gib))
+	if err == nil {
+		t.Fatal("expected 2GiB free to not be enough to compress a read only 4GiB dump")
+	}
+	if !strings.Contains(err.Error(), conf.KeyHeapDumpDir) {
+		t.Errorf("expected the error to point to %v but got %v", conf.KeyHeapDumpDir, err)
+	}
+}
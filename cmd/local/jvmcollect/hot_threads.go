@@ -0,0 +1,382 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// package jvmcollect handles parsing of the jvm information
+package jvmcollect
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+)
+
+const (
+	// HotThreadsFile and HotThreadsTextFile are written to the ttop dir
+	HotThreadsFile     = "hot-threads.json"
+	HotThreadsTextFile = "hot-threads.txt"
+	// clockTicks is USER_HZ, the unit of utime and stime in /proc/<pid>/task/<tid>/stat. It is 100 on every linux
+	// architecture go supports
+	clockTicks = 100
+	// minHotThreadsInterval drops samples over intervals too short for the tick resolution of /proc
+	minHotThreadsInterval = 500 * time.Millisecond
+)
+
+// ThreadStat is the cpu time of a thread read from /proc/<pid>/task/<tid>
+type ThreadStat struct {
+	TID int
+	// NSTID is the tid inside the pid namespace of the jvm, which is the nid of its thread dumps. It differs
+	// from TID for a jvm in another container and 0 is the same as TID
+	NSTID int
+	Comm  string
+	State string
+	UTime int64
+	STime int64
+}
+
+// JavaThread is a thread of a thread dump with the native id that matches the tid in /proc
+type JavaThread struct {
+	Name  string
+	NID   int
+	State string
+	Stack string
+}
+
+// HotThread is the cpu use of a thread over one sample joined with its java thread
+type HotThread struct {
+	TID           int     `json:"tid"`
+	NID           string  `json:"nid"`
+	Name          string  `json:"name"`
+	Comm          string  `json:"comm"`
+	CPUPercent    float64 `json:"cpuPercent"`
+	UserPercent   float64 `json:"userPercent"`
+	SystemPercent float64 `json:"systemPercent"`
+	State         string  `json:"state,omitempty"`
+	Stack         string  `json:"stack,omitempty"`
+}
+
+// HotThreadsSample is the hottest threads over the interval that ends with a thread dump
+type HotThreadsSample struct {
+	Time              time.Time   `json:"time"`
+	IntervalSeconds   float64     `json:"intervalSeconds"`
+	ThreadDump        string      `json:"threadDump"`
+	ProcessCPUPercent float64     `json:"processCpuPercent"`
+	Threads           []HotThread `json:"threads"`
+}
+
+// HotThreads is the content of hot-threads.json
+type HotThreads struct {
+	PID     int                `json:"pid"`
+	TopN    int                `json:"topN"`
+	Samples []HotThreadsSample `json:"samples"`
+}
+
+// ReadThreadStats reads the stat and comm of every thread of pid under procDir, usually /proc
+func ReadThreadStats(procDir string, pid int) (map[int]ThreadStat, error) {
+	taskDir := filepath.Join(procDir, strconv.Itoa(pid), "task")
+	entries, err := os.ReadDir(taskDir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the threads of pid %v: %w", pid, err)
+	}
+	stats := make(map[int]ThreadStat, len(entries))
+	for _, e := range entries {
+		tid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(taskDir, e.Name(), "stat"))
+		if err != nil {
+			// the thread exited since the listing
+			continue
+		}
+		stat, err := ParseThreadStat(string(b))
+		if err != nil {
+			simplelog.Debugf("skipping thread %v of pid %v: %v", tid, pid, err)
+			continue
+		}
+		stat.TID = tid
+		if comm, err := os.ReadFile(filepath.Join(taskDir, e.Name(), "comm")); err == nil {
+			stat.Comm = strings.TrimSpace(string(comm))
+		}
+		stat.NSTID = tid
+		if status, err := os.ReadFile(filepath.Join(taskDir, e.Name(), "status")); err == nil {
+			if nsTID, ok := namespaceTID(string(status)); ok {
+				stat.NSTID = nsTID
+			}
+		}
+		stats[tid] = stat
+	}
+	return stats, nil
+}
+
+// namespaceTID is the last entry of NSpid in a /proc status, the id in the innermost pid namespace
+func namespaceTID(status string) (int, bool) {
+	for _, line := range strings.Split(status, "\n") {
+		value, ok := strings.CutPrefix(line, "NSpid:")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			return 0, false
+		}
+		tid, err := strconv.Atoi(fields[len(fields)-1])
+		return tid, err == nil
+	}
+	return 0, false
+}
+
+// ParseThreadStat reads the name, state, utime and stime of a /proc stat line. The name is in
+// parentheses and can itself contain spaces and parentheses so the fields are read after the last one
+func ParseThreadStat(stat string) (ThreadStat, error) {
+	open := strings.IndexByte(stat, '(')
+	end := strings.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return ThreadStat{}, fmt.Errorf("invalid stat '%v'", stat)
+	}
+	fields := strings.Fields(stat[end+1:])
+	// state is field 3 of proc(5), utime and stime are fields 14 and 15
+	if len(fields) < 13 {
+		return ThreadStat{}, fmt.Errorf("expected at least 15 fields in stat '%v'", stat)
+	}
+	utime, err := strconv.ParseInt(fields[11], 10, 64)
+	if err != nil {
+		return ThreadStat{}, fmt.Errorf("invalid utime in stat: %w", err)
+	}
+	stime, err := strconv.ParseInt(fields[12], 10, 64)
+	if err != nil {
+		return ThreadStat{}, fmt.Errorf("invalid stime in stat: %w", err)
+	}
+	tid, _ := strconv.Atoi(strings.TrimSpace(stat[:open]))
+	return ThreadStat{
+		TID:   tid,
+		Comm:  stat[open+1 : end],
+		State: fields[0],
+		UTime: utime,
+		STime: stime,
+	}, nil
+}
+
+// ParseThreadDump reads the threads of a Thread.print output by native id, nid is hex such as nid=0x1a2b
+// and decimal on newer jdks
+func ParseThreadDump(dump string) map[int]JavaThread {
+	threads := make(map[int]JavaThread)
+	var current *JavaThread
+	var stack []string
+	flush := func() {
+		if current != nil {
+			current.Stack = strings.Join(stack, "\n")
+			threads[current.NID] = *current
+		}
+		current, stack = nil, nil
+	}
+	scanner := bufio.NewScanner(strings.NewReader(dump))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "\"") {
+			flush()
+			nid, ok := threadNID(line)
+			if !ok {
+				continue
+			}
+			name := line[1:]
+			if end := strings.LastIndex(name, "\""); end >= 0 {
+				name = name[:end]
+			}
+			current = &JavaThread{Name: name, NID: nid}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			flush()
+			continue
+		}
+		if state, ok := strings.CutPrefix(trimmed, "java.lang.Thread.State: "); ok {
+			current.State = state
+			continue
+		}
+		stack = append(stack, trimmed)
+	}
+	flush()
+	return threads
+}
+
+func threadNID(header string) (int, bool) {
+	for _, f := range strings.Fields(header) {
+		v, ok := strings.CutPrefix(f, "nid=")
+		if !ok {
+			continue
+		}
+		var nid int64
+		var err error
+		if hex, isHex := strings.CutPrefix(v, "0x"); isHex {
+			nid, err = strconv.ParseInt(hex, 16, 64)
+		} else {
+			nid, err = strconv.ParseInt(v, 10, 64)
+		}
+		return int(nid), err == nil
+	}
+	return 0, false
+}
+
+// HotThreadsOf ranks the threads by their cpu use between prev and cur and joins the topN with the
+// threads of the dump by their tid in the namespace of the jvm, threads that are not in both snapshots are left out
+func HotThreadsOf(prev, cur map[int]ThreadStat, interval time.Duration, dump map[int]JavaThread, topN int) (processCPU float64, hot []HotThread) {
+	seconds := interval.Seconds()
+	if seconds <= 0 {
+		return 0, nil
+	}
+	percent := func(ticks int64) float64 {
+		return float64(ticks) / clockTicks / seconds * 100
+	}
+	for tid, c := range cur {
+		p, ok := prev[tid]
+		if !ok {
+			continue
+		}
+		user, sys := c.UTime-p.UTime, c.STime-p.STime
+		if user+sys <= 0 {
+			continue
+		}
+		processCPU += percent(user + sys)
+		nid := c.NSTID
+		if nid == 0 {
+			nid = tid
+		}
+		t := HotThread{
+			TID:           tid,
+			NID:           fmt.Sprintf("0x%x", nid),
+			Name:          c.Comm,
+			Comm:          c.Comm,
+			CPUPercent:    percent(user + sys),
+			UserPercent:   percent(user),
+			SystemPercent: percent(sys),
+		}
+		if j, ok := dump[nid]; ok {
+			t.Name, t.State, t.Stack = j.Name, j.State, j.Stack
+		}
+		hot = append(hot, t)
+	}
+	sort.Slice(hot, func(i, j int) bool {
+		if hot[i].CPUPercent == hot[j].CPUPercent {
+			return hot[i].TID < hot[j].TID
+		}
+		return hot[i].CPUPercent > hot[j].CPUPercent
+	})
+	if topN > 0 && len(hot) > topN {
+		hot = hot[:topN]
+	}
+	return processCPU, hot
+}
+
+// HotThreadsSampler keeps the last /proc snapshot of the jvm so every thread dump gets the cpu use of its threads
+// over the interval before it
+type HotThreadsSampler struct {
+	procDir string
+	pid     int
+	topN    int
+	last    map[int]ThreadStat
+	lastAt  time.Time
+	result  HotThreads
+}
+
+// NewHotThreadsSampler reads the first /proc snapshot of the threads of pid, the cpu of the first sample is measured from it
+func NewHotThreadsSampler(procDir string, pid, topN int) (*HotThreadsSampler, error) {
+	stats, err := ReadThreadStats(procDir, pid)
+	if err != nil {
+		return nil, err
+	}
+	return &HotThreadsSampler{
+		procDir: procDir,
+		pid:     pid,
+		topN:    topN,
+		last:    stats,
+		lastAt:  time.Now(),
+		result:  HotThreads{PID: pid, TopN: topN, Samples: []HotThreadsSample{}},
+	}, nil
+}
+
+// Sample reads /proc again right after a thread dump and records the hottest threads since the last sample
+func (s *HotThreadsSampler) Sample(at time.Time, dump, dumpFile string) error {
+	stats, err := ReadThreadStats(s.procDir, s.pid)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	interval := now.Sub(s.lastAt)
+	prev := s.last
+	s.last, s.lastAt = stats, now
+	if interval < minHotThreadsInterval {
+		simplelog.Debugf("skipping hot threads of %v, the interval of %v is too short", dumpFile, interval)
+		return nil
+	}
+	processCPU, hot := HotThreadsOf(prev, stats, interval, ParseThreadDump(dump), s.topN)
+	s.result.Samples = append(s.result.Samples, HotThreadsSample{
+		Time:              at,
+		IntervalSeconds:   interval.Seconds(),
+		ThreadDump:        dumpFile,
+		ProcessCPUPercent: processCPU,
+		Threads:           hot,
+	})
+	return nil
+}
+
+// Result is every sample taken so far
+func (s *HotThreadsSampler) Result() HotThreads {
+	return s.result
+}
+
+// Write saves hot-threads.json and hot-threads.txt to dir
+func (s *HotThreadsSampler) Write(dir string) error {
+	b, err := json.MarshalIndent(s.result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal hot threads: %w", err)
+	}
+	if err := os.WriteFile(filepath.Clean(filepath.Join(dir, HotThreadsFile)), b, 0o600); err != nil {
+		return fmt.Errorf("unable to write %v: %w", HotThreadsFile, err)
+	}
+	if err := os.WriteFile(filepath.Clean(filepath.Join(dir, HotThreadsTextFile)), []byte(FormatHotThreads(s.result)), 0o600); err != nil {
+		return fmt.Errorf("unable to write %v: %w", HotThreadsTextFile, err)
+	}
+	return nil
+}
+
+// FormatHotThreads is the text version of hot-threads.json
+func FormatHotThreads(h HotThreads) string {
+	var b strings.Builder
+	for _, s := range h.Samples {
+		fmt.Fprintf(&b, "%v - %.1fs - process cpu %.1f%% - %v\n", s.Time.Format(time.RFC3339), s.IntervalSeconds, s.ProcessCPUPercent, s.ThreadDump)
+		for _, t := range s.Threads {
+			fmt.Fprintf(&b, "  %6.1f%% (user %.1f%% sys %.1f%%) tid=%v nid=%v \"%v\" %v\n", t.CPUPercent, t.UserPercent, t.SystemPercent, t.TID, t.NID, t.Name, t.State)
+			if t.Stack != "" {
+				for _, frame := range strings.Split(t.Stack, "\n") {
+					fmt.Fprintf(&b, "          %v\n", frame)
+				}
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,174 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jvmcollect_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/jvmcollect"
+)
+
+const hotThreadsDump = `2024-03-17 09:00:00
+Full thread dump OpenJDK 64-Bit Server VM (17.0.9+9 mixed mode, sharing):
+
+"main" #1 prio=5 os_prio=0 cpu=120.50ms elapsed=30.10s tid=0x00007f0c4c017800 nid=0x3e9 waiting on condition  [0x00007f0c52bfe000]
+   java.lang.Thread.State: TIMED_WAITING (sleeping)
+	at java.lang.Thread.sleep(java.base@17.0.9/Native Method)
+	at com.dremio.Main.main(Main.java:10)
+
+   Locked ownable synchronizers:
+	- None
+
+"FABRIC-rpc-event-queue" #42 daemon prio=5 os_prio=0 cpu=9000.00ms elapsed=30.00s tid=0x00007f0c4c2a1000 nid=1002 runnable  [0x00007f0c30dfe000]
+   java.lang.Thread.State: RUNNABLE
+	at com.dremio.exec.Spin.run(Spin.java:42)
+
+"G1 Conc#0" os_prio=0 cpu=50.00ms elapsed=30.10s tid=0x00007f0c4c07a000 nid=0x3eb runnable
+`
+
+func TestParseThreadStat(t *testing.T) {
+	stat, err := jvmcollect.ParseThreadStat("1002 (rpc) event(1)) R 1 1000 1000 0 -1 4194368 1000 0 0 0 850 50 0 0 20 0 60 0 100 5000000 3000 18446744073709551615")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.TID != 1002 || stat.Comm != "rpc) event(1)" || stat.State != "R" || stat.UTime != 850 || stat.STime != 50 {
+		t.Errorf("unexpected stat %#v", stat)
+	}
+	if _, err := jvmcollect.ParseThreadStat("1002 (rpc) R 1"); err == nil {
+		t.Error("expected an error for a short stat")
+	}
+}
+
+func TestParseThreadDump(t *testing.T) {
+	threads := jvmcollect.ParseThreadDump(hotThreadsDump)
+	if len(threads) != 3 {
+		t.Fatalf("expected 3 threads but got %v", threads)
+	}
+	main := threads[0x3e9]
+	if main.Name != "main" || main.State != "TIMED_WAITING (sleeping)" {
+		t.Errorf("unexpected main thread %#v", main)
+	}
+	if !strings.Contains(main.Stack, "at com.dremio.Main.main(Main.java:10)") || strings.Contains(main.Stack, "Locked ownable") {
+		t.Errorf("expected only the frames of main but got '%v'", main.Stack)
+	}
+	// newer jdks print the nid in decimal
+	if rpc := threads[1002]; rpc.Name != "FABRIC-rpc-event-queue" || rpc.State != "RUNNABLE" {
+		t.Errorf("unexpected rpc thread %#v", rpc)
+	}
+	if gc := threads[0x3eb]; gc.Name != "G1 Conc#0" {
+		t.Errorf("unexpected gc thread %#v", gc)
+	}
+}
+
+func TestHotThreadsOf(t *testing.T) {
+	prev := map[int]jvmcollect.ThreadStat{
+		0x3e9: {TID: 0x3e9, Comm: "java", UTime: 10, STime: 0},
+		1002:  {TID: 1002, Comm: "FABRIC-rpc-even", UTime: 100, STime: 10},
+		0x3eb: {TID: 0x3eb, Comm: "G1 Conc#0", UTime: 5, STime: 0},
+		2000:  {TID: 2000, Comm: "exited", UTime: 5, STime: 0},
+	}
+	cur := map[int]jvmcollect.ThreadStat{
+		0x3e9: {TID: 0x3e9, Comm: "java", UTime: 10, STime: 0},
+		1002:  {TID: 1002, Comm: "FABRIC-rpc-even", UTime: 190, STime: 20},
+		0x3eb: {TID: 0x3eb, Comm: "G1 Conc#0", UTime: 25, STime: 5},
+		3000:  {TID: 3000, Comm: "new", UTime: 50, STime: 0},
+	}
+	processCPU, hot := jvmcollect.HotThreadsOf(prev, cur, time.Second, jvmcollect.ParseThreadDump(hotThreadsDump), 1)
+	if processCPU != 125 {
+		t.Errorf("expected 125%% process cpu but got %v", processCPU)
+	}
+	if len(hot) != 1 {
+		t.Fatalf("expected the top thread only but got %v", hot)
+	}
+	rpc := hot[0]
+	if rpc.TID != 1002 || rpc.NID != "0x3ea" || rpc.Name != "FABRIC-rpc-event-queue" || rpc.Comm != "FABRIC-rpc-even" {
+		t.Errorf("unexpected hot thread %#v", rpc)
+	}
+	if rpc.CPUPercent != 100 || rpc.UserPercent != 90 || rpc.SystemPercent != 10 {
+		t.Errorf("expected 100%% cpu with 90%% user and 10%% sys but got %#v", rpc)
+	}
+	if !strings.Contains(rpc.Stack, "com.dremio.exec.Spin.run") {
+		t.Errorf("expected the stack of the thread dump but got '%v'", rpc.Stack)
+	}
+	text := jvmcollect.FormatHotThreads(jvmcollect.HotThreads{Samples: []jvmcollect.HotThreadsSample{{Time: time.Now(), IntervalSeconds: 1, ProcessCPUPercent: processCPU, Threads: hot}}})
+	if !strings.Contains(text, `tid=1002 nid=0x3ea "FABRIC-rpc-event-queue" RUNNABLE`) {
+		t.Errorf("unexpected text '%v'", text)
+	}
+}
+
+func TestReadThreadStats(t *testing.T) {
+	procDir := t.TempDir()
+	task := filepath.Join(procDir, "42", "task", "43")
+	if err := os.MkdirAll(task, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(task, "stat"), []byte("43 (C2 CompilerThre) S 1 42 42 0 -1 4194368 0 0 0 0 7 3 0 0 20 0 60 0 100 0 0\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(task, "comm"), []byte("C2 CompilerThre\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := jvmcollect.ReadThreadStats(procDir, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := stats[43]; s.Comm != "C2 CompilerThre" || s.UTime != 7 || s.STime != 3 {
+		t.Errorf("unexpected stats %#v", stats)
+	}
+	if runtime.GOOS == "linux" {
+		stats, err := jvmcollect.ReadThreadStats("/proc", os.Getpid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := stats[os.Getpid()]; !ok {
+			t.Errorf("expected the main thread in %v", stats)
+		}
+	}
+}
+
+func TestHotThreadsOfAJVMInAnotherPidNamespace(t *testing.T) {
+	procDir := t.TempDir()
+	// the rpc thread is 5002 on the host and 1002 in the container, as in its thread dump
+	task := filepath.Join(procDir, "5000", "task", "5002")
+	if err := os.MkdirAll(task, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(task, "stat"), []byte("5002 (FABRIC-rpc-even) R 1 5000 5000 0 -1 4194368 0 0 0 0 100 10 0 0 20 0 60 0 100 0 0\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(task, "status"), []byte("Name:\tFABRIC-rpc-even\nPid:\t5002\nNSpid:\t5002\t1002\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	prev, err := jvmcollect.ReadThreadStats(procDir, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prev[5002].NSTID != 1002 {
+		t.Fatalf("expected the tid in the namespace of the jvm but got %#v", prev[5002])
+	}
+	cur := map[int]jvmcollect.ThreadStat{5002: {TID: 5002, NSTID: 1002, Comm: "FABRIC-rpc-even", UTime: 190, STime: 20}}
+	_, hot := jvmcollect.HotThreadsOf(prev, cur, time.Second, jvmcollect.ParseThreadDump(hotThreadsDump), 1)
+	if len(hot) != 1 {
+		t.Fatalf("expected one hot thread but got %v", hot)
+	}
+	if rpc := hot[0]; rpc.TID != 5002 || rpc.NID != "0x3ea" || rpc.Name != "FABRIC-rpc-event-queue" || !strings.Contains(rpc.Stack, "com.dremio.exec.Spin.run") {
+		t.Errorf("expected the thread of the dump joined by its namespace tid but got %#v", rpc)
+	}
+}
@@ -17,53 +17,241 @@ package jvmcollect
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
-	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/ddcio"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/hotspot"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/timewindow"
 )
 
+// DremioJFRName is the name of the recording started by ddc
+const DremioJFRName = "DREMIO_JFR"
+
+// jfrTimeFormat is the local time format accepted by the begin and end options of JFR.dump
+const jfrTimeFormat = "2006-01-02T15:04:05"
+
+var (
+	// jfrRecordingLine matches "Recording 1: name=1 maxsize=250.0MB (running)" and on java 8
+	// "Recording: recording=1 name="continuous" maxage=1d (running)"
+	jfrRecordingLine = regexp.MustCompile(`^Recording(?: (\d+))?: (?:recording=(\d+) )?name=("[^"]*"|\S+)`)
+	jfrStateSuffix   = regexp.MustCompile(`\((\w+)\)\s*$`)
+	unsafeFileChars  = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+)
+
+// JFRRecording is a recording listed by JFR.check
+type JFRRecording struct {
+	ID    int
+	Name  string
+	State string
+}
+
+// JFRCheckFile is the name of the JFR.check output in the jfr dir
+func JFRCheckFile(c *conf.CollectConf) string {
+	return filepath.Join(c.JFROutDir(), c.NodeName()+"-jfr-check.txt")
+}
+
 func RunCollectJFR(c *conf.CollectConf, hook shutdown.CancelHook) error {
 	var w bytes.Buffer
 	w = bytes.Buffer{}
-	if err := ddcio.Shell(hook, &w, fmt.Sprintf("jcmd %v VM.unlock_commercial_features", c.DremioPID())); err != nil {
+	if err := hotspot.Jcmd(hook, &w, c.DremioPID(), "VM.unlock_commercial_features"); err != nil {
 		simplelog.Warningf("Error trying to unlock commercial features %v. Note: newer versions of OpenJDK do not support the call VM.unlock_commercial_features. This is usually safe to ignore", err)
 	}
 
 	simplelog.Debugf("node: %v - jfr unlock commercial output - %v", c.NodeName(), w.String())
 
+	recordings, err := runJFRCheck(c, hook)
+	if err != nil {
+		simplelog.Warningf("unable to list the JFR recordings of node %v: %v", c.NodeName(), err)
+	}
+	if c.JFRDumpExisting() {
+		dumped, err := dumpExistingJFRs(c, hook, recordings)
+		if dumped > 0 {
+			simplelog.Infof("node: %v - dumped %v existing JFR recordings, not starting %v", c.NodeName(), dumped, DremioJFRName)
+			return err
+		}
+		if err != nil {
+			simplelog.Warningf("unable to dump the existing JFR recordings of node %v, starting %v: %v", c.NodeName(), DremioJFRName, err)
+		} else {
+			simplelog.Infof("node: %v - no existing JFR recording to dump, starting %v", c.NodeName(), DremioJFRName)
+		}
+	}
+
 	w = bytes.Buffer{}
 	// this is effectively a no op unless there is an existing recording running
-	if err := ddcio.Shell(hook, &w, fmt.Sprintf("jcmd %v JFR.stop name=\"DREMIO_JFR\"", c.DremioPID())); err != nil {
+	if err := hotspot.Jcmd(hook, &w, c.DremioPID(), fmt.Sprintf("JFR.stop name=\"%v\"", DremioJFRName)); err != nil {
 		simplelog.Debugf("attempting to stop existing JFR failed, but this is usually expected: '%v' -- output: '%v'", err, w.String())
 	}
-	if strings.Contains(w.String(), "Stopped recording \"DREMIO_JFR\"") {
-		simplelog.Warningf("stopped a JFR recording named \"DREMIO_JFR\"")
+	if strings.Contains(w.String(), fmt.Sprintf("Stopped recording \"%v\"", DremioJFRName)) {
+		simplelog.Warningf("stopped a JFR recording named \"%v\"", DremioJFRName)
 	}
 
+	settings, removeSettings, err := jfrSettings(c)
+	if err != nil {
+		return err
+	}
 	w = bytes.Buffer{}
-	if err := ddcio.Shell(hook, &w, fmt.Sprintf("jcmd %v JFR.start name=\"DREMIO_JFR\" settings=profile maxage=%vs  filename=%v/%v.jfr dumponexit=true", c.DremioPID(), c.DremioJFRTimeSeconds(), c.JFROutDir(), c.NodeName())); err != nil {
+	// the jvm reads the settings on JFR.start
+	err = hotspot.Jcmd(hook, &w, c.DremioPID(), JFRStartCommand(settings, c.JFRMaxAgeSeconds(), c.JFRMaxSizeMB(), filepath.Join(c.JFROutDir(), c.NodeName()+".jfr")))
+	removeSettings()
+	if err != nil {
 		return fmt.Errorf("unable to run JFR: %w", err)
 	}
 	simplelog.Debugf("node: %v - jfr start output - %v", c.NodeName(), w.String())
 	secondsWaiting := c.DremioJFRTimeSeconds()
-	time.Sleep(time.Duration(secondsWaiting) * time.Second)
+	completed := waitWithinDeadline(hook, time.Duration(secondsWaiting)*time.Second)
+	if ctx := hook.GetContext(); ctx.Err() != nil {
+		// stopping writes what was recorded so far to the file of the recording
+		simplelog.Warningf("node: %v - collection interrupted, stopping JFR %v", c.NodeName(), DremioJFRName)
+		if err := wrapUp(hook, func(h shutdown.CancelHook) error {
+			var w bytes.Buffer
+			return hotspot.Jcmd(h, &w, c.DremioPID(), fmt.Sprintf("JFR.stop name=\"%v\"", DremioJFRName))
+		}); err != nil {
+			return fmt.Errorf("unable to stop JFR after the collection was interrupted (%v): %w", context.Cause(ctx), err)
+		}
+		return fmt.Errorf("JFR recording was stopped early: %w", context.Cause(ctx))
+	}
 	// do not "optimize". the recording first needs to be stopped for all processes before collecting the data.
 	simplelog.Debugf("... stopping JFR %v", c.NodeName())
 	w = bytes.Buffer{}
-	if err := ddcio.Shell(hook, &w, fmt.Sprintf("jcmd %v JFR.dump name=\"DREMIO_JFR\"", c.DremioPID())); err != nil {
+	if err := hotspot.Jcmd(hook, &w, c.DremioPID(), fmt.Sprintf("JFR.dump name=\"%v\"", DremioJFRName)); err != nil {
 		return fmt.Errorf("unable to dump JFR: %w", err)
 	}
 	simplelog.Debugf("node: %v - jfr dump output %v", c.NodeName(), w.String())
 	w = bytes.Buffer{}
-	if err := ddcio.Shell(hook, &w, fmt.Sprintf("jcmd %v JFR.stop name=\"DREMIO_JFR\"", c.DremioPID())); err != nil {
+	if err := hotspot.Jcmd(hook, &w, c.DremioPID(), fmt.Sprintf("JFR.stop name=\"%v\"", DremioJFRName)); err != nil {
 		return fmt.Errorf("unable to dump JFR: %w", err)
 	}
 	simplelog.Debugf("node: %v - jfr stop output %v", c.NodeName(), w.String())
-
+	if !completed {
+		return fmt.Errorf("JFR recording was shortened: %w", context.DeadlineExceeded)
+	}
 	return nil
 }
+
+// JFRStartCommand is the JFR.start of the DREMIO_JFR recording, maxSizeMB of 0 is no size limit
+func JFRStartCommand(settings string, maxAgeSeconds, maxSizeMB int, file string) string {
+	var maxSize string
+	if maxSizeMB > 0 {
+		maxSize = fmt.Sprintf(" maxsize=%vm", maxSizeMB)
+	}
+	return fmt.Sprintf("JFR.start name=\"%v\" settings=%v maxage=%vs%v filename=%v dumponexit=true", DremioJFRName, settings, maxAgeSeconds, maxSize, file)
+}
+
+// jfrSettings writes the .jfc of jfr-settings-jfc to the /tmp of the jvm, readable by its user, and keeps a copy in
+// the archive, without it the configured settings name or path is used. remove deletes the file given to the jvm
+func jfrSettings(c *conf.CollectConf) (settings string, remove func(), err error) {
+	if c.JFRSettingsJFC() == "" {
+		return c.JFRSettings(), func() {}, nil
+	}
+	loc := filepath.Join(c.JFROutDir(), c.NodeName()+"-settings.jfc")
+	if err := os.WriteFile(filepath.Clean(loc), []byte(c.JFRSettingsJFC()), 0o600); err != nil {
+		return "", nil, fmt.Errorf("unable to write %v for %v: %w", loc, conf.KeyJFRSettingsJFC, err)
+	}
+	// the output dir is not readable by a jvm running as another user or in a container
+	settings, remove, err = hotspot.WriteTempFile(c.DremioPID(), "ddc-"+c.NodeName()+"-*.jfc", []byte(c.JFRSettingsJFC()))
+	if err != nil {
+		simplelog.Warningf("node: %v - unable to write %v for the jvm, passing %v: %v", c.NodeName(), conf.KeyJFRSettingsJFC, loc, err)
+		return loc, func() {}, nil
+	}
+	return settings, remove, nil
+}
+
+// runJFRCheck writes the JFR.check listing of the jvm to the jfr dir and returns the recordings
+func runJFRCheck(c *conf.CollectConf, hook shutdown.CancelHook) ([]JFRRecording, error) {
+	var w bytes.Buffer
+	if err := hotspot.Jcmd(hook, &w, c.DremioPID(), "JFR.check"); err != nil {
+		return nil, fmt.Errorf("unable to run JFR.check: %w", err)
+	}
+	if err := os.WriteFile(filepath.Clean(JFRCheckFile(c)), w.Bytes(), 0o600); err != nil {
+		return nil, fmt.Errorf("unable to write JFR.check output: %w", err)
+	}
+	return ParseJFRCheck(w.String()), nil
+}
+
+// ParseJFRCheck reads the recordings listed by JFR.check
+func ParseJFRCheck(out string) []JFRRecording {
+	var recordings []JFRRecording
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		m := jfrRecordingLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		id := m[1]
+		if id == "" {
+			id = m[2]
+		}
+		r := JFRRecording{Name: strings.Trim(m[3], `"`)}
+		r.ID, _ = strconv.Atoi(id)
+		if s := jfrStateSuffix.FindStringSubmatch(line); s != nil {
+			r.State = s[1]
+		}
+		recordings = append(recordings, r)
+	}
+	return recordings
+}
+
+// ExistingJFRDumpCommand is the JFR.dump of an existing recording, limited to the collection window when it is set
+// and otherwise to the last maxAgeSeconds. Zero values are no limit
+func ExistingJFRDumpCommand(r JFRRecording, file string, window timewindow.Window, maxAgeSeconds, maxSizeMB int) string {
+	cmd := fmt.Sprintf("JFR.dump name=\"%v\" filename=%v", r.Name, file)
+	if window.IsSet() {
+		if !window.From.IsZero() {
+			cmd += " begin=" + window.From.Local().Format(jfrTimeFormat)
+		}
+		if !window.To.IsZero() {
+			cmd += " end=" + window.To.Local().Format(jfrTimeFormat)
+		}
+	} else if maxAgeSeconds > 0 {
+		cmd += fmt.Sprintf(" maxage=%vs", maxAgeSeconds)
+	}
+	if maxSizeMB > 0 {
+		cmd += fmt.Sprintf(" maxsize=%vm", maxSizeMB)
+	}
+	return cmd
+}
+
+// dumpExistingJFRs dumps the recordings selected by jfr-existing-recordings, DREMIO_JFR is left out as
+// it is a recording of an earlier ddc run. It returns how many were dumped
+func dumpExistingJFRs(c *conf.CollectConf, hook shutdown.CancelHook, recordings []JFRRecording) (int, error) {
+	wanted := make(map[string]bool)
+	for _, name := range c.JFRExistingRecordings() {
+		wanted[name] = true
+	}
+	var dumped int
+	var errs []string
+	for _, r := range recordings {
+		if r.Name == DremioJFRName || (len(wanted) > 0 && !wanted[r.Name]) {
+			continue
+		}
+		file := filepath.Join(c.JFROutDir(), fmt.Sprintf("%v-%v-%v.jfr", c.NodeName(), r.ID, unsafeFileChars.ReplaceAllString(r.Name, "_")))
+		cmd := ExistingJFRDumpCommand(r, file, c.CollectionWindow(), c.JFRExistingMaxAgeSeconds(), c.JFRMaxSizeMB())
+		var w bytes.Buffer
+		err := hotspot.Jcmd(hook, &w, c.DremioPID(), cmd)
+		if err != nil && cmd != ExistingJFRDumpCommand(r, file, timewindow.Window{}, 0, 0) {
+			// begin, end, maxage and maxsize of JFR.dump need java 14 or later
+			simplelog.Warningf("node: %v - unable to dump JFR recording %v with '%v', dumping all of it: %v", c.NodeName(), r.Name, cmd, err)
+			w = bytes.Buffer{}
+			err = hotspot.Jcmd(hook, &w, c.DremioPID(), ExistingJFRDumpCommand(r, file, timewindow.Window{}, 0, 0))
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%v: %v", r.Name, err))
+			continue
+		}
+		simplelog.Debugf("node: %v - jfr dump of %v output %v", c.NodeName(), r.Name, w.String())
+		dumped++
+	}
+	if len(errs) > 0 {
+		return dumped, fmt.Errorf("unable to dump JFR recordings: %v", strings.Join(errs, ", "))
+	}
+	return dumped, nil
+}
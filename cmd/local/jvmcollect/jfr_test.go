@@ -19,6 +19,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
 	"testing"
@@ -29,6 +30,7 @@ import (
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/collects"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/timewindow"
 )
 
 func TestJFRCapture(t *testing.T) {
@@ -222,3 +224,48 @@ dremio-jfr-time-seconds: 2
 		t.Errorf("expected a non empty file for the hprof but we got one")
 	}
 }
+
+func TestParseJFRCheck(t *testing.T) {
+	out := `12345:
+Recording 1: name=1 maxsize=250.0MB (running)
+Recording 3: name=continuous maxage=1d (stopped)
+Recording: recording=4 name="java 8 recording" maxage=1d (running)
+`
+	expected := []jvmcollect.JFRRecording{
+		{ID: 1, Name: "1", State: "running"},
+		{ID: 3, Name: "continuous", State: "stopped"},
+		{ID: 4, Name: "java 8 recording", State: "running"},
+	}
+	actual := jvmcollect.ParseJFRCheck(out)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %#v but got %#v", expected, actual)
+	}
+	if recordings := jvmcollect.ParseJFRCheck("12345:\nNo available recordings.\n"); len(recordings) != 0 {
+		t.Errorf("expected no recordings but got %v", recordings)
+	}
+}
+
+func TestJFRStartCommand(t *testing.T) {
+	expected := `JFR.start name="DREMIO_JFR" settings=default maxage=60s filename=/tmp/jfr/node1.jfr dumponexit=true`
+	if actual := jvmcollect.JFRStartCommand("default", 60, 0, "/tmp/jfr/node1.jfr"); actual != expected {
+		t.Errorf("expected '%v' but got '%v'", expected, actual)
+	}
+	expected = `JFR.start name="DREMIO_JFR" settings=/tmp/jfr/node1-settings.jfc maxage=30s maxsize=100m filename=/tmp/jfr/node1.jfr dumponexit=true`
+	if actual := jvmcollect.JFRStartCommand("/tmp/jfr/node1-settings.jfc", 30, 100, "/tmp/jfr/node1.jfr"); actual != expected {
+		t.Errorf("expected '%v' but got '%v'", expected, actual)
+	}
+}
+
+func TestExistingJFRDumpCommand(t *testing.T) {
+	r := jvmcollect.JFRRecording{ID: 2, Name: "continuous"}
+	expected := `JFR.dump name="continuous" filename=/tmp/jfr/node1-2-continuous.jfr maxage=600s maxsize=200m`
+	if actual := jvmcollect.ExistingJFRDumpCommand(r, "/tmp/jfr/node1-2-continuous.jfr", timewindow.Window{}, 600, 200); actual != expected {
+		t.Errorf("expected '%v' but got '%v'", expected, actual)
+	}
+	from := time.Date(2024, 3, 17, 9, 0, 0, 0, time.Local)
+	window := timewindow.Window{From: from, To: from.Add(time.Hour)}
+	expected = `JFR.dump name="continuous" filename=/tmp/jfr/node1-2-continuous.jfr begin=2024-03-17T09:00:00 end=2024-03-17T10:00:00`
+	if actual := jvmcollect.ExistingJFRDumpCommand(r, "/tmp/jfr/node1-2-continuous.jfr", window, 600, 0); actual != expected {
+		t.Errorf("expected '%v' but got '%v'", expected, actual)
+	}
+}
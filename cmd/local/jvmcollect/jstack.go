@@ -17,13 +17,14 @@ package jvmcollect
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
-	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/ddcio"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/hotspot"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
 )
@@ -38,20 +39,40 @@ func RunCollectJStacksWithTimeService(c *conf.CollectConf, hook shutdown.CancelH
 	simplelog.Debug("Collecting Jstack ...")
 	threadDumpFreq := c.DremioJStackFreqSeconds()
 	iterations := c.DremioJStackTimeSeconds() / threadDumpFreq
+	var sampler *HotThreadsSampler
+	if c.CollectHotThreads() {
+		var err error
+		if sampler, err = NewHotThreadsSampler("/proc", c.DremioPID(), c.HotThreadsTopN()); err != nil {
+			simplelog.Warningf("not collecting hot threads: %v", err)
+		} else {
+			defer func() {
+				if err := sampler.Write(c.TtopOutDir()); err != nil {
+					simplelog.Warningf("unable to write hot threads of pid %v: %v", c.DremioPID(), err)
+				}
+			}()
+		}
+	}
 	simplelog.Debugf("Running Java thread dumps every %v second(s) for a total of %v iterations ...", threadDumpFreq, iterations)
 	for i := 0; i < iterations; i++ {
 		var w bytes.Buffer
-		if err := ddcio.Shell(hook, &w, fmt.Sprintf("jcmd %v Thread.print -l", c.DremioPID())); err != nil {
+		if err := hotspot.Jcmd(hook, &w, c.DremioPID(), "Thread.print -l"); err != nil {
 			simplelog.Warningf("unable to capture jstack of pid %v: %v", c.DremioPID(), err)
 		}
 		date := timer().Format("2006-01-02_15_04_05")
 		threadDumpFileName := filepath.Join(c.ThreadDumpsOutDir(), fmt.Sprintf("threadDump-%s-%s.txt", c.NodeName(), date))
+		if sampler != nil && w.Len() > 0 {
+			if err := sampler.Sample(timer(), w.String(), filepath.Base(threadDumpFileName)); err != nil {
+				simplelog.Warningf("unable to sample the thread cpu of pid %v: %v", c.DremioPID(), err)
+			}
+		}
 		if err := os.WriteFile(filepath.Clean(threadDumpFileName), w.Bytes(), 0o600); err != nil {
 			return fmt.Errorf("unable to write thread dump %v: %w", threadDumpFileName, err)
 		}
 		simplelog.Debugf("Saved %v", threadDumpFileName)
 		simplelog.Debugf("Waiting %v second(s) ...", threadDumpFreq)
-		time.Sleep(time.Duration(threadDumpFreq) * time.Second)
+		if !waitWithinDeadline(hook, time.Duration(threadDumpFreq)*time.Second) {
+			return fmt.Errorf("stopped after %v of %v thread dumps: %w", i+1, iterations, context.DeadlineExceeded)
+		}
 	}
 	return nil
 }
@@ -0,0 +1,196 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jvmcollect
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/hotspot"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/masking"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+)
+
+// files of the extended jvm diagnostics in JVMOutDir
+const (
+	NativeMemoryFile     = "native_memory.txt"
+	HeapInfoFile         = "heap_info.txt"
+	SystemPropertiesFile = "system_properties.txt"
+	VMInfoFile           = "vm_info.txt"
+	CodeCacheFile        = "codecache.txt"
+	MetaspaceFile        = "metaspace.txt"
+	// ClassHistogramPrefix starts the file name of every class histogram, the capture time follows
+	ClassHistogramPrefix = "class_histogram-"
+)
+
+// nmtDisabled is what the jvm answers to VM.native_memory without -XX:NativeMemoryTracking
+const nmtDisabled = "Native memory tracking is not enabled"
+
+// RunCollectNativeMemory writes VM.native_memory summary, a jvm without native memory tracking is skipped
+func RunCollectNativeMemory(c *conf.CollectConf, hook shutdown.CancelHook) error {
+	var w bytes.Buffer
+	err := hotspot.Jcmd(hook, &w, c.DremioPID(), "VM.native_memory summary")
+	var cmdErr *hotspot.CommandError
+	if strings.Contains(w.String(), nmtDisabled) || (errors.As(err, &cmdErr) && strings.Contains(cmdErr.Output, nmtDisabled)) {
+		simplelog.Infof("skipping native memory summary of pid %v as it runs without -XX:NativeMemoryTracking=summary", c.DremioPID())
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to run VM.native_memory summary on pid %v: %w", c.DremioPID(), err)
+	}
+	return writeJVMFile(c, NativeMemoryFile, w.Bytes())
+}
+
+// RunCollectHeapInfo writes GC.heap_info
+func RunCollectHeapInfo(c *conf.CollectConf, hook shutdown.CancelHook) error {
+	return runJcmdToFile(c, hook, "GC.heap_info", HeapInfoFile, nil)
+}
+
+// RunCollectSystemProperties writes VM.system_properties with the values of secret looking properties masked
+func RunCollectSystemProperties(c *conf.CollectConf, hook shutdown.CancelHook) error {
+	return runJcmdToFile(c, hook, "VM.system_properties", SystemPropertiesFile, masking.MaskProperties)
+}
+
+// RunCollectVMInfo writes VM.info, it has the jvm args and environment variables so secret looking lines are masked
+func RunCollectVMInfo(c *conf.CollectConf, hook shutdown.CancelHook) error {
+	return runJcmdToFile(c, hook, "VM.info", VMInfoFile, MaskVMInfo)
+}
+
+// RunCollectCodeCache writes Compiler.codecache
+func RunCollectCodeCache(c *conf.CollectConf, hook shutdown.CancelHook) error {
+	return runJcmdToFile(c, hook, "Compiler.codecache", CodeCacheFile, nil)
+}
+
+// RunCollectMetaspace writes VM.metaspace
+func RunCollectMetaspace(c *conf.CollectConf, hook shutdown.CancelHook) error {
+	return runJcmdToFile(c, hook, "VM.metaspace", MetaspaceFile, nil)
+}
+
+// RunCollectClassHistograms writes jvm-class-histogram-count GC.class_histogram samples
+// jvm-class-histogram-interval-seconds apart, every sample runs a full gc. When the class histogram
+// trend runs too its samples are reused instead so the full gcs are not doubled
+func RunCollectClassHistograms(c *conf.CollectConf, hook shutdown.CancelHook) error {
+	if c.CollectJVMClassHistogramTrend() {
+		return copyClassHistogramsFromTrend(c)
+	}
+	return RunCollectClassHistogramsWithTimeService(c, hook, time.Now)
+}
+
+// copyClassHistogramsFromTrend writes jvm-class-histogram-count of the samples of the trend, spread over
+// its window, as the class histograms. It runs after the trend
+func copyClassHistogramsFromTrend(c *conf.CollectConf) error {
+	samples, err := filepath.Glob(filepath.Join(c.JVMOutDir(), ClassHistogramTrendPrefix+"*.txt"))
+	if err != nil {
+		return fmt.Errorf("unable to list the class histogram trend samples: %w", err)
+	}
+	if len(samples) == 0 {
+		return errors.New("the class histogram trend took no samples to reuse")
+	}
+	// the names end with the time of the sample
+	sort.Strings(samples)
+	picked := pickSpread(samples, c.JVMClassHistogramCount())
+	simplelog.Debugf("reusing %v of the %v class histogram trend samples as the class histograms", len(picked), len(samples))
+	for _, sample := range picked {
+		b, err := os.ReadFile(filepath.Clean(sample))
+		if err != nil {
+			return fmt.Errorf("unable to read %v: %w", sample, err)
+		}
+		name := ClassHistogramPrefix + strings.TrimPrefix(filepath.Base(sample), ClassHistogramTrendPrefix)
+		if err := writeJVMFile(c, name, b); err != nil {
+			return err
+		}
+	}
+	if len(picked) < c.JVMClassHistogramCount() {
+		return fmt.Errorf("the class histogram trend only took %v of %v class histograms", len(picked), c.JVMClassHistogramCount())
+	}
+	return nil
+}
+
+// pickSpread picks count of the values evenly spread from the first to the last, all of them when there are fewer
+func pickSpread(values []string, count int) []string {
+	if count <= 0 {
+		return nil
+	}
+	if len(values) <= count {
+		return values
+	}
+	if count == 1 {
+		return values[:1]
+	}
+	picked := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		picked = append(picked, values[i*(len(values)-1)/(count-1)])
+	}
+	return picked
+}
+
+func RunCollectClassHistogramsWithTimeService(c *conf.CollectConf, hook shutdown.CancelHook, timer func() time.Time) error {
+	count := c.JVMClassHistogramCount()
+	interval := time.Duration(c.JVMClassHistogramIntervalSeconds()) * time.Second
+	simplelog.Debugf("Running %v class histograms every %v ...", count, interval)
+	for i := 0; i < count; i++ {
+		if i > 0 && !waitWithinDeadline(hook, interval) {
+			return fmt.Errorf("stopped after %v of %v class histograms: %w", i, count, context.DeadlineExceeded)
+		}
+		var w bytes.Buffer
+		if err := hotspot.Jcmd(hook, &w, c.DremioPID(), "GC.class_histogram"); err != nil {
+			return fmt.Errorf("unable to capture class histogram %v of %v of pid %v: %w", i+1, count, c.DremioPID(), err)
+		}
+		name := ClassHistogramPrefix + timer().Format("2006-01-02_15_04_05") + ".txt"
+		if err := writeJVMFile(c, name, w.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MaskVMInfo masks every line of VM.info that looks like it has a secret such as the jvm_args or an environment variable
+func MaskVMInfo(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = masking.MaskSecretLine(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func runJcmdToFile(c *conf.CollectConf, hook shutdown.CancelHook, command, name string, mask func(string) string) error {
+	var w bytes.Buffer
+	if err := hotspot.Jcmd(hook, &w, c.DremioPID(), command); err != nil {
+		return fmt.Errorf("unable to run %v on pid %v: %w", command, c.DremioPID(), err)
+	}
+	out := w.Bytes()
+	if mask != nil {
+		out = []byte(mask(w.String()))
+	}
+	return writeJVMFile(c, name, out)
+}
+
+func writeJVMFile(c *conf.CollectConf, name string, data []byte) error {
+	loc := filepath.Join(c.JVMOutDir(), name)
+	if err := os.WriteFile(filepath.Clean(loc), data, 0o600); err != nil {
+		return fmt.Errorf("unable to write %v: %w", loc, err)
+	}
+	simplelog.Debugf("Saved %v", loc)
+	return nil
+}
@@ -0,0 +1,72 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jvmcollect
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/masking"
+)
+
+func TestMaskVMInfo(t *testing.T) {
+	vmInfo := `jvm_args: -Xmx4g -Djavax.net.ssl.trustStorePassword=changeit
+java_command: com.dremio.dac.daemon.DremioDaemon
+Environment Variables:
+JAVA_HOME=/opt/java/openjdk
+AWS_SECRET_ACCESS_KEY=abc123
+`
+	masked := MaskVMInfo(vmInfo)
+	for _, secret := range []string{"changeit", "abc123"} {
+		if strings.Contains(masked, secret) {
+			t.Errorf("expected %v to be masked in\n%v", secret, masked)
+		}
+	}
+	for _, kept := range []string{"java_command: com.dremio.dac.daemon.DremioDaemon", "JAVA_HOME=/opt/java/openjdk"} {
+		if !strings.Contains(masked, kept) {
+			t.Errorf("expected %v to be kept in\n%v", kept, masked)
+		}
+	}
+}
+
+func TestMaskSystemProperties(t *testing.T) {
+	props := "12345:\n#Mon Oct 19 10:00:00 UTC 2026\njava.home=/opt/java/openjdk\njavax.net.ssl.keyStorePassword=changeit\n"
+	masked := masking.MaskProperties(props)
+	if strings.Contains(masked, "changeit") {
+		t.Errorf("expected the keystore password to be masked in\n%v", masked)
+	}
+	if !strings.Contains(masked, "java.home=/opt/java/openjdk") || !strings.HasPrefix(masked, "12345:") {
+		t.Errorf("expected the other lines to be kept in\n%v", masked)
+	}
+}
+
+func TestPickSpread(t *testing.T) {
+	values := []string{"a", "b", "c", "d", "e", "f", "g"}
+	for _, tc := range []struct {
+		count    int
+		expected string
+	}{
+		{3, "a,d,g"},
+		{2, "a,g"},
+		{1, "a"},
+		{7, "a,b,c,d,e,f,g"},
+		{10, "a,b,c,d,e,f,g"},
+		{0, ""},
+	} {
+		if actual := strings.Join(pickSpread(values, tc.count), ","); actual != tc.expected {
+			t.Errorf("count %v: expected %q but got %q", tc.count, tc.expected, actual)
+		}
+	}
+}
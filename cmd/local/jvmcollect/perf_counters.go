@@ -0,0 +1,98 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jvmcollect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/hotspot"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+)
+
+// PerfCountersFile is the node-info file with the sampled hsperfdata counters
+const PerfCountersFile = "jvm-perfcounters.json"
+
+// PerfCounters is the jvm found through its hsperfdata and the counters sampled over the collection window
+type PerfCounters struct {
+	PID         int                  `json:"pid"`
+	PerfData    string               `json:"perfData"`
+	MainClass   string               `json:"mainClass"`
+	Args        string               `json:"args,omitempty"`
+	JVMArgs     string               `json:"jvmArgs,omitempty"`
+	JavaVersion string               `json:"javaVersion,omitempty"`
+	VMName      string               `json:"vmName,omitempty"`
+	VMVersion   string               `json:"vmVersion,omitempty"`
+	Samples     []hotspot.PerfSample `json:"samples"`
+}
+
+// RunCollectJVMPerfCounters reads the hsperfdata of the dremio jvm every JVMPerfCountersFreqSeconds for
+// JVMPerfCountersTimeSeconds, this gives gc and heap trends without gc logs and without attaching to the jvm
+func RunCollectJVMPerfCounters(c *conf.CollectConf, hook shutdown.CancelHook) error {
+	pid := c.DremioPID()
+	loc, err := hotspot.FindPerfData(pid)
+	if err != nil {
+		return fmt.Errorf("unable to find hsperfdata of pid %v: %w", pid, err)
+	}
+	freq := max(c.JVMPerfCountersFreqSeconds(), 1)
+	// one more sample than intervals so the first and the last sample span the whole window
+	samples := c.JVMPerfCountersTimeSeconds()/freq + 1
+	simplelog.Debugf("sampling %v every %v second(s) for a total of %v samples ...", loc, freq, samples)
+	report := PerfCounters{PID: pid, PerfData: loc}
+	var stopErr error
+	for i := 0; i < samples; i++ {
+		p, err := hotspot.ReadPerfData(loc)
+		if err != nil {
+			if i == 0 {
+				return err
+			}
+			// the file is removed when the jvm exits, keep what was sampled until then
+			stopErr = fmt.Errorf("stopped after %v of %v samples: %w", i, samples, err)
+			break
+		}
+		if i == 0 {
+			report.MainClass = p.MainClass()
+			report.Args = p.Args()
+			report.JVMArgs = p.JVMArgs()
+			report.JavaVersion = p.String("java.property.java.version")
+			report.VMName = p.String("java.property.java.vm.name")
+			report.VMVersion = p.String("java.property.java.vm.version")
+		}
+		report.Samples = append(report.Samples, p.Sample(time.Now()))
+		if i == samples-1 {
+			break
+		}
+		if !waitWithinDeadline(hook, time.Duration(freq)*time.Second) {
+			stopErr = fmt.Errorf("stopped after %v of %v samples: %w", i+1, samples, context.DeadlineExceeded)
+			break
+		}
+	}
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal %v: %w", PerfCountersFile, err)
+	}
+	out := filepath.Join(c.NodeInfoOutDir(), PerfCountersFile)
+	if err := os.WriteFile(out, b, 0o600); err != nil {
+		return fmt.Errorf("unable to write %v: %w", out, err)
+	}
+	simplelog.Debugf("Saved %v", out)
+	return stopErr
+}
@@ -38,19 +38,16 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 
-	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/apicollect"
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
-	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/configcollect"
-	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/jvmcollect"
-	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/logcollect"
-	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/nodeinfocollect"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/archive"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/clusterstats"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/dirs"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/dryrun"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/hotspot"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/jobstats"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/masking"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
-	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/validation"
 
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/ddcio"
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/threading"
@@ -60,6 +57,9 @@ import (
 var (
 	ddcYamlLoc, collectionMode, pid string
 	patStdIn                        bool
+	maxCollectionTime               time.Duration
+	dryRun                          bool
+	dryRunJSON                      string
 )
 
 func createAllDirs(c *conf.CollectConf) error {
@@ -95,6 +95,14 @@ func createAllDirs(c *conf.CollectConf) error {
 		if err := os.MkdirAll(c.TtopOutDir(), perms); err != nil {
 			return fmt.Errorf("unable to create ttop directory: %w", err)
 		}
+		if err := os.MkdirAll(c.JVMOutDir(), perms); err != nil {
+			return fmt.Errorf("unable to create jvm directory: %w", err)
+		}
+		if c.CollectSnapshot() {
+			if err := os.MkdirAll(c.SnapshotOutDir(), perms); err != nil {
+				return fmt.Errorf("unable to create snapshot directory: %w", err)
+			}
+		}
 	}
 
 	if err := os.MkdirAll(c.ClusterStatsOutDir(), perms); err != nil {
@@ -118,210 +126,60 @@ func collect(c *conf.CollectConf, hook shutdown.Hook) error {
 			return fmt.Errorf("%w. Use a larger directory by using ddc --transfer-dir or if using ddc local-collect --tarball-out-dir", err)
 		}
 	}
-	if err := createAllDirs(c); err != nil {
-		return fmt.Errorf("unable to create directories: %w", err)
-	}
-
-	// we can probably remove this now that we have gone to single threaded, but keeping it for the delayed execution and logging for now
-	t, err := threading.NewThreadPool(1, 1, true, false)
-	if err != nil {
-		return fmt.Errorf("unable to spawn thread pool: %w", err)
-	}
-
-	wrapConfigJob := func(name string, j func(c *conf.CollectConf, h shutdown.CancelHook) error) threading.Job {
-		return threading.Job{
-			Name:    name,
-			Process: func() error { return j(c, hook) },
+	budget := newLogBudget(c)
+	jobs, confs := nodeJobs(c, hook, budget)
+	for _, jc := range confs {
+		if err := createAllDirs(jc); err != nil {
+			return fmt.Errorf("unable to create directories: %w", err)
 		}
 	}
 
-	wrapConfigJobWithFileRemovalTasks := func(name string, j func(c *conf.CollectConf, h shutdown.Hook) error) threading.Job {
-		return threading.Job{
-			Name:    name,
-			Process: func() error { return j(c, hook) },
-		}
-	}
+	// jobs run as soon as the jobs they depend on are done, limited per resource so the
+	// node being collected is not overloaded
+	sched := threading.NewScheduler(map[threading.ResourceClass]int{
+		threading.ResourceCPU:       c.MaxCPUJobs(),
+		threading.ResourceREST:      c.MaxRESTJobs(),
+		threading.ResourceJVMAttach: c.MaxJVMAttachJobs(),
+	}, true)
 
-	// rest call so we move it the front in case the token expires
-	if !c.CollectWLM() {
-		simplelog.Debug("Skipping Workload Manager report collection")
-	} else {
-		t.AddJob(wrapConfigJob("WLM COLLECTION", apicollect.RunCollectWLM))
-	}
+	// every job outcome is recorded so it can be reported in the summary.json
+	rec := jobstats.NewRecorder(hook.GetContext())
+	const skipReason = "disabled by configuration or missing prerequisites"
 
-	// rest call so we move it the front in case the token expires
-	if !c.CollectSystemTablesExport() {
-		simplelog.Debug("Skipping system tables collection")
-	} else {
-		t.AddJob(wrapConfigJob("SYSTEM TABLE COLLECTION", apicollect.RunCollectDremioSystemTables))
+	enabled := make(map[string]bool)
+	for _, j := range jobs {
+		enabled[j.name] = j.enabled
 	}
-
-	if !c.IsDremioCloud() {
-		// rest call so we move it the front in case the token expires
-		if !c.CollectKVStoreReport() {
-			simplelog.Debug("Skipping KV store report collection")
-		} else {
-			t.AddJob(wrapConfigJob("KV STORE COLLECTION", apicollect.RunCollectKvReport))
-		}
-
-		if !c.CollectDiskUsage() {
-			simplelog.Info("Skipping disk usage collection")
-		} else {
-			t.AddJob(wrapConfigJob("DISK USAGE COLLECTION", nodeinfocollect.RunCollectDiskUsage))
+	for _, j := range jobs {
+		if !j.enabled {
+			simplelog.Debugf("Skipping %v", j.name)
+			rec.Skip(j.name, skipReason)
+			continue
 		}
-
-		if !c.CollectDremioConfiguration() {
-			simplelog.Info("Skipping Dremio config collection")
-		} else {
-			t.AddJob(wrapConfigJob("DREMIO CONFIG COLLECTION", configcollect.RunCollectDremioConfig))
+		if len(j.outputs) == 0 {
+			return fmt.Errorf("job %v declares no outputs", j.name)
 		}
-
-		if !c.CollectOSConfig() {
-			simplelog.Info("Skipping OS config collection")
-		} else {
-			t.AddJob(wrapConfigJob("OS CONFIG COLLECTION", runCollectOSConfig))
-		}
-
-		// log collection
-		logCollector := logcollect.NewLogCollector(
-			c.DremioLogDir(),
-			c.LogsOutDir(),
-			c.GcLogsDir(),
-			c.DremioGCFilePattern(),
-			c.QueriesOutDir(),
-			c.DremioQueriesJSONNumDays(),
-			c.DremioLogsNumDays(),
-		)
-
-		if !c.CollectQueriesJSON() && c.NumberJobProfilesToCollect() == 0 {
-			simplelog.Debug("Skipping queries.json collection")
-		} else {
-			if !c.CollectQueriesJSON() {
-				simplelog.Warning("NOT Skipping collection of Queries JSON, because --number-job-profiles is greater than 0 and job profile download requires queries.json ...")
+		var deps []string
+		for _, d := range j.dependsOn {
+			if enabled[d] {
+				deps = append(deps, d)
 			}
-			t.AddJob(threading.Job{
-				Name:    "QUERIES.JSON COLLECTION",
-				Process: logCollector.RunCollectQueriesJSON,
-			})
-
-		}
-
-		if !c.CollectServerLogs() {
-			simplelog.Debug("Skipping server log collection")
-		} else {
-			t.AddJob(threading.Job{
-				Name:    "SERVER LOG COLLECTION",
-				Process: logCollector.RunCollectDremioServerLog,
-			})
-		}
-
-		if !c.CollectGCLogs() {
-			simplelog.Debug("Skipping gc log collection")
-		} else {
-			t.AddJob(threading.Job{
-				Name:    "GC LOG COLLECTION",
-				Process: logCollector.RunCollectGcLogs,
-			})
-		}
-
-		if !c.CollectMetaRefreshLogs() {
-			simplelog.Debug("Skipping metadata refresh log collection")
-		} else {
-			t.AddJob(threading.Job{
-				Name:    "METADATA LOG COLLECTION",
-				Process: logCollector.RunCollectMetadataRefreshLogs,
-			})
-		}
-
-		if !c.CollectReflectionLogs() {
-			simplelog.Debug("Skipping reflection log collection")
-		} else {
-			t.AddJob(threading.Job{
-				Name:    "REFLECTING LOG COLLECTION",
-				Process: logCollector.RunCollectReflectionLogs,
-			})
-		}
-
-		if !c.CollectVacuumLogs() {
-			simplelog.Debug("Skipping vacuum log collection")
-		} else {
-			t.AddJob(threading.Job{
-				Name:    "VACUUM LOG COLLECTION",
-				Process: logCollector.RunCollectVacuumLogs,
-			})
-		}
-
-		if !c.CollectAccelerationLogs() {
-			simplelog.Debug("Skipping acceleration log collection")
-		} else {
-			t.AddJob(threading.Job{
-				Name:    "ACCELERATION LOG COLLECTION",
-				Process: logCollector.RunCollectAccelerationLogs,
-			})
 		}
-
-		if !c.CollectAccessLogs() {
-			simplelog.Debug("Skipping access log collection")
-		} else {
-			t.AddJob(threading.Job{
-				Name:    "ACCESS LOG COLLECTION",
-				Process: logCollector.RunCollectDremioAccessLogs,
-			})
-		}
-
-		if !c.CollectAuditLogs() {
-			simplelog.Debug("Skipping audit log collection")
-		} else {
-			t.AddJob(threading.Job{
-				Name:    "AUDIT LOG COLLECTION",
-				Process: logCollector.RunCollectDremioAuditLogs,
-			})
-		}
-
-		if !c.CollectJVMFlags() {
-			simplelog.Debug("Skipping JVM Flags collection")
-		} else {
-			t.AddJob(wrapConfigJob("JVM FLAG COLLECTION", jvmcollect.RunCollectJVMFlags))
-		}
-
-		if !c.CollectTtop() {
-			simplelog.Debugf("Skipping ttop collection")
-		} else {
-			t.AddJob(wrapConfigJob("TTOP COLLECTION", RunTtopCollect))
+		if err := sched.AddTask(threading.Task{
+			Name:      j.name,
+			Class:     j.class,
+			DependsOn: deps,
+			Process:   rec.TrackOutputs(j.name, j.outputs, j.run),
+		}); err != nil {
+			return fmt.Errorf("unable to schedule %v: %w", j.name, err)
 		}
-		if !c.CollectJFR() {
-			simplelog.Debugf("Skipping Java Flight Recorder collection")
-		} else {
-			t.AddJob(wrapConfigJob("JFR COLLECTION", jvmcollect.RunCollectJFR))
-		}
-
-		if !c.CollectJStack() {
-			simplelog.Debugf("Skipping Java thread dumps collection")
-		} else {
-			t.AddJob(wrapConfigJob("JSTACK COLLECTION", jvmcollect.RunCollectJStacks))
-		}
-
-		if !c.CaptureHeapDump() {
-			simplelog.Debugf("Skipping Java heap dump collection")
-		} else {
-			t.AddJob(wrapConfigJobWithFileRemovalTasks("HEAP DUMP COLLECTION", jvmcollect.RunCollectHeapDump))
-		}
-	}
-
-	if err := t.ProcessAndWait(); err != nil {
-		simplelog.Errorf("thread pool has an error: %v", err)
 	}
-
-	// this has to happen after the queries.json collection so we don't have much choice and have to leave it here
-	if c.NumberJobProfilesToCollect() == 0 {
-		simplelog.Debugf("Skipping job profiles collection")
-	} else {
-		if err := apicollect.RunCollectJobProfiles(c, hook); err != nil {
-			simplelog.Errorf("during job profile collection there was an error: %v", err)
-		}
+	if err := sched.Run(); err != nil {
+		return fmt.Errorf("unable to run jobs: %w", err)
 	}
-	if err := runCollectClusterStats(c, hook); err != nil {
-		simplelog.Errorf("during unable to collect cluster stats like cluster ID: %v", err)
+	rec.AddTruncated(budget.Truncated()...)
+	if err := rec.WriteFile(c.NodeName(), filepath.Join(c.ClusterStatsOutDir(), jobstats.FileName)); err != nil {
+		simplelog.Errorf("unable to write job stats: %v", err)
 	}
 	return nil
 }
@@ -422,7 +280,7 @@ func parseVersionFromClassPath(classPath string) string {
 
 func getClassPath(hook shutdown.CancelHook, pid int) (string, error) {
 	var w bytes.Buffer
-	if err := ddcio.Shell(hook, &w, fmt.Sprintf("jcmd %v VM.system_properties", pid)); err != nil {
+	if err := hotspot.Jcmd(hook, &w, pid, "VM.system_properties"); err != nil {
 		return "", err
 	}
 	out := w.String()
@@ -465,6 +323,9 @@ func runCollectClusterStats(c *conf.CollectConf, hook shutdown.CancelHook) error
 	return os.WriteFile(filepath.Join(c.ClusterStatsOutDir(), "cluster-stats.json"), b, 0o600)
 }
 
+// ttopFile is the top -H output in the ttop dir, the hot threads of the jstack job are written next to it
+const ttopFile = "ttop.txt"
+
 func RunTtopCollect(c *conf.CollectConf, hook shutdown.CancelHook) error {
 	simplelog.Debug("Running top -H to get thread information")
 	duration := c.DremioTtopTimeSeconds() / c.DremioTtopFreqSeconds()
@@ -476,7 +337,7 @@ func RunTtopCollect(c *conf.CollectConf, hook shutdown.CancelHook) error {
 	if err != nil {
 		return fmt.Errorf("failed collecting top: %w", err)
 	}
-	loc := fmt.Sprintf("%v/ttop.txt", c.TtopOutDir())
+	loc := filepath.Join(c.TtopOutDir(), ttopFile)
 	if err := os.WriteFile(loc, w.Bytes(), 0o600); err != nil {
 		return fmt.Errorf("unable to write top out: %w", err)
 	}
@@ -484,144 +345,6 @@ func RunTtopCollect(c *conf.CollectConf, hook shutdown.CancelHook) error {
 	return nil
 }
 
-func runCollectOSConfig(c *conf.CollectConf, hook shutdown.CancelHook) error {
-	simplelog.Debug("Collecting OS Information")
-	osInfoFile := filepath.Join(c.NodeInfoOutDir(), "os_info.txt")
-	w, err := os.Create(filepath.Clean(osInfoFile))
-	if err != nil {
-		return fmt.Errorf("unable to create file %v: %w", filepath.Clean(osInfoFile), err)
-	}
-
-	simplelog.Debug("/etc/*-release")
-
-	_, err = w.Write([]byte("___\n>>> cat /etc/*-release\n"))
-	if err != nil {
-		simplelog.Warningf("unable to write release file header for os_info.txt: %v", err)
-	}
-
-	err = ddcio.Shell(hook, w, "cat /etc/*-release")
-	if err != nil {
-		simplelog.Warningf("unable to write release files for os_info.txt: %v", err)
-	}
-
-	_, err = w.Write([]byte("___\n>>> uname -r\n"))
-	if err != nil {
-		simplelog.Warningf("unable to write uname header for os_info.txt: %v", err)
-	}
-
-	err = ddcio.Shell(hook, w, "uname -r")
-	if err != nil {
-		simplelog.Warningf("unable to write uname -r for os_info.txt: %v", err)
-	}
-	_, err = w.Write([]byte("___\n>>> cat /etc/issue\n"))
-	if err != nil {
-		simplelog.Warningf("unable to write cat /etc/issue header for os_info.txt: %v", err)
-	}
-	err = ddcio.Shell(hook, w, "cat /etc/issue")
-	if err != nil {
-		simplelog.Warningf("unable to write /etc/issue for os_info.txt: %v", err)
-	}
-	_, err = w.Write([]byte("___\n>>> cat /proc/sys/kernel/hostname\n"))
-	if err != nil {
-		simplelog.Warningf("unable to write hostname for os_info.txt: %v", err)
-	}
-	err = ddcio.Shell(hook, w, "cat /proc/sys/kernel/hostname")
-	if err != nil {
-		simplelog.Warningf("unable to write hostname for os_info.txt: %v", err)
-	}
-	_, err = w.Write([]byte("___\n>>> cat /proc/meminfo\n"))
-	if err != nil {
-		simplelog.Warningf("unable to write /proc/meminfo header for os_info.txt: %v", err)
-	}
-	err = ddcio.Shell(hook, w, "cat /proc/meminfo")
-	if err != nil {
-		simplelog.Warningf("unable to write /proc/meminfo for os_info.txt: %v", err)
-	}
-	_, err = w.Write([]byte("___\n>>> lscpu\n"))
-	if err != nil {
-		simplelog.Warningf("unable to write lscpu header for os_info.txt: %v", err)
-	}
-	err = ddcio.Shell(hook, w, "lscpu")
-	if err != nil {
-		simplelog.Warningf("unable to write lscpu for os_info.txt: %v", err)
-	}
-	_, err = w.Write([]byte("___\n>>> mount\n"))
-	if err != nil {
-		simplelog.Warningf("unable to write mount header for os_info.txt: %v", err)
-	}
-	err = ddcio.Shell(hook, w, "mount")
-	if err != nil {
-		simplelog.Warningf("unable to write mount for os_info.txt: %v", err)
-	}
-	_, err = w.Write([]byte("___\n>>> lsblk\n"))
-	if err != nil {
-		simplelog.Warningf("unable to write lsblk header for os_info.txt: %v", err)
-	}
-	err = ddcio.Shell(hook, w, "lsblk")
-	if err != nil {
-		simplelog.Warningf("unable to write lsblk for os_info.txt: %v", err)
-	}
-	const s = `stat -fc %T /sys/fs/cgroup/`
-	_, err = w.Write([]byte(fmt.Sprintf("___\n>>> %v\n", s)))
-	if err != nil {
-		simplelog.Warningf("unable to write %s header for os_info.txt: %v", s, err)
-	}
-	err = ddcio.Shell(hook, w, s)
-	if err != nil {
-		simplelog.Warningf("unable to write %s for os_info.txt: %v", s, err)
-	}
-
-	// this only retrieves cgroupv2 files and will fail on cgroup1 and of course on prem
-	cgroupFiles := []string{
-		"memory.current",
-		"memory.swap.current",
-		"memory.pressure",
-		"cpu.pressure",
-		"io.pressure",
-	}
-	for _, cgroupFile := range cgroupFiles {
-		commandToExecute := fmt.Sprintf("cat /sys/fs/cgroup/%v", cgroupFile)
-		_, err = w.Write([]byte(fmt.Sprintf("___\n>>> %v\n", commandToExecute)))
-		if err != nil {
-			simplelog.Warningf("unable to write %s header for os_info.txt: %v", commandToExecute, err)
-		}
-		err = ddcio.Shell(hook, w, commandToExecute)
-		if err != nil {
-			simplelog.Warningf("unable to write %s for os_info.txt: %v", commandToExecute, err)
-		}
-	}
-
-	loadCommand := "cat /proc/loadavg"
-	_, err = w.Write([]byte(fmt.Sprintf("___\n>>> %v\n", loadCommand)))
-	if err != nil {
-		simplelog.Warningf("unable to write %s header for os_info.txt: %v", s, err)
-	}
-	err = ddcio.Shell(hook, w, loadCommand)
-	if err != nil {
-		simplelog.Warningf("unable to write %s for os_info.txt: %v", s, err)
-	}
-
-	if c.DremioPID() > 0 {
-		_, err = w.Write([]byte("___\n>>> ps eww\n"))
-		if err != nil {
-			simplelog.Warningf("unable to write ps eww header for os_info.txt: %v", err)
-		}
-		err = ddcio.Shell(hook, w, fmt.Sprintf("ps eww %v | grep dremio | awk '{$1=$2=$3=$4=\"\"; print $0}'", c.DremioPID()))
-		if err != nil {
-			simplelog.Warningf("unable to write ps eww output for os_info.txt: %v", err)
-		}
-	}
-	if err := w.Sync(); err != nil {
-		return fmt.Errorf("unable to sync the os_info.txt file: %w", err)
-	}
-	if err := w.Close(); err != nil {
-		return fmt.Errorf("unable to close the os_info.txt file: %w", err)
-	}
-	simplelog.Debugf("... Collecting OS Information from %v COMPLETED", c.NodeName())
-
-	return nil
-}
-
 var LocalCollectCmd = &cobra.Command{
 	Use:   "local-collect",
 	Short: "retrieves all the dremio logs and diagnostics for the local node and saves the results in a compatible format for Dremio support",
@@ -671,12 +394,23 @@ var LocalCollectCmd = &cobra.Command{
 
 func Execute(args []string, overrides map[string]string) (string, error) {
 	hook := shutdown.NewHook()
+	if maxCollectionTime > 0 {
+		// jobs still running at the deadline are stopped and whatever was collected is archived
+		hook = shutdown.NewHookWithDeadline(time.Now().Add(maxCollectionTime))
+	}
 	defer hook.Cleanup()
 	cSignal := make(chan os.Signal, 1)
 	signal.Notify(cSignal, os.Interrupt, syscall.SIGTERM)
 	killOnlyCleanup := func() {}
 	go func() {
-		<-cSignal
+		// the first CTRL+C stops the running jobs and archives what was collected, a second one or SIGTERM aborts
+		if sig := <-cSignal; sig == os.Interrupt {
+			msg := "interrupt received: stopping running jobs and archiving what was collected, interrupt again to abort"
+			fmt.Println(msg)
+			simplelog.Info(msg)
+			hook.Stop(true)
+			<-cSignal
+		}
 		simplelog.Infof("graceful shutdown initiated")
 		hook.Cleanup()
 		simplelog.Infof("removing tarball out folder if present")
@@ -687,6 +421,9 @@ func Execute(args []string, overrides map[string]string) (string, error) {
 	simplelog.Infof("ddc local-collect version: %v", versions.GetCLIVersion())
 	simplelog.Infof("args: %v", strings.Join(args, " "))
 	fmt.Println(strings.TrimSpace(versions.GetCLIVersion()))
+	if dryRun {
+		return executeDryRun(hook, overrides)
+	}
 	if pid != "" {
 		if _, err := os.Stat(pid); err != nil {
 			if !errors.Is(err, os.ErrNotExist) {
@@ -708,9 +445,6 @@ func Execute(args []string, overrides map[string]string) (string, error) {
 		}
 	}
 	startTime := time.Now().Unix()
-	if err := validation.ValidateCollectMode(collectionMode); err != nil {
-		return "", err
-	}
 
 	c, err := conf.ReadConf(hook, overrides, ddcYamlLoc, collectionMode)
 	if err != nil {
@@ -732,9 +466,29 @@ func Execute(args []string, overrides map[string]string) (string, error) {
 		return "", fmt.Errorf("unable to collect: %w", err)
 	}
 
+	tarballName, err := archiveCollection(c, 0)
+	if err != nil {
+		return "", err
+	}
+	select {
+	case <-hook.Stopped():
+		fmt.Println("INTERRUPTED - the archive only contains what was collected before the interrupt")
+	default:
+	}
+	endTime := time.Now().Unix()
+	fi, err := os.Stat(tarballName)
+	if err != nil {
+		// quickly just supplying tarball name and elapsed
+		return fmt.Sprintf("file %v - %v secs collection", tarballName, endTime-startTime), nil
+	}
+	return fmt.Sprintf("file %v - %v seconds for collection - size %v bytes", tarballName, endTime-startTime, fi.Size()), nil
+}
+
+// archiveCollection adds the ddc log from logFrom on to the output dir and archives it as <node>.tar.gz in the tarball out dir
+func archiveCollection(c *conf.CollectConf, logFrom int64) (string, error) {
 	logLoc := simplelog.GetLogLoc()
 	if logLoc != "" {
-		if err := ddcio.CopyFile(simplelog.GetLogLoc(), filepath.Join(c.OutputDir(), fmt.Sprintf("ddc-%v.log", c.NodeName()))); err != nil {
+		if err := ddcio.CopyFileFrom(logLoc, filepath.Join(c.OutputDir(), fmt.Sprintf("ddc-%v.log", c.NodeName())), logFrom); err != nil {
 			simplelog.Warningf("unable to copy log to archive: %v", err)
 		}
 	}
@@ -748,13 +502,48 @@ func Execute(args []string, overrides map[string]string) (string, error) {
 	}
 
 	simplelog.Infof("Archive %v complete", tarballName)
-	endTime := time.Now().Unix()
-	fi, err := os.Stat(tarballName)
+	return tarballName, nil
+}
+
+// executeDryRun resolves the configuration and prints what would be collected without collecting it
+func executeDryRun(hook shutdown.Hook, overrides map[string]string) (string, error) {
+	// the dry run flags are not configuration
+	delete(overrides, "dry-run")
+	delete(overrides, "dry-run-json")
+	// reading the configuration makes the tarball out dir when it is missing, so remove it again after
+	tarballOutDir := overrides[conf.KeyTarballOutDir]
+	var createdTarballOutDir bool
+	if tarballOutDir != "" {
+		if _, err := os.Stat(tarballOutDir); os.IsNotExist(err) {
+			createdTarballOutDir = true
+		}
+	}
+	if createdTarballOutDir {
+		defer func() {
+			if err := os.Remove(tarballOutDir); err != nil {
+				simplelog.Warningf("unable to remove %v created while reading the configuration: %v", tarballOutDir, err)
+			}
+		}()
+	}
+	c, err := conf.ReadConf(hook, overrides, ddcYamlLoc, collectionMode)
 	if err != nil {
-		// quickly just supplying tarball name and elapsed
-		return fmt.Sprintf("file %v - %v secs collection", tarballName, endTime-startTime), nil
+		return "", fmt.Errorf("unable to read configuration %w", err)
 	}
-	return fmt.Sprintf("file %v - %v seconds for collection - size %v bytes", tarballName, endTime-startTime, fi.Size()), nil
+	plan := BuildDryRunPlan(c, hook, collectionMode)
+	if err := WriteDryRunPlan(os.Stdout, plan, dryrun.FormatText); err != nil {
+		return "", err
+	}
+	if dryRunJSON != "" {
+		var b bytes.Buffer
+		if err := WriteDryRunPlan(&b, plan, dryrun.FormatJSON); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(filepath.Clean(dryRunJSON), b.Bytes(), 0o600); err != nil {
+			return "", fmt.Errorf("unable to write dry run plan to %v: %w", dryRunJSON, err)
+		}
+		return fmt.Sprintf("DRY RUN COMPLETE - plan written to %v", dryRunJSON), nil
+	}
+	return "DRY RUN COMPLETE", nil
 }
 
 func init() {
@@ -771,6 +560,13 @@ func init() {
 	LocalCollectCmd.Flags().Bool("allow-insecure-ssl", false, "When true allow insecure ssl certs when doing API calls")
 	LocalCollectCmd.Flags().BoolVar(&patStdIn, "pat-stdin", false, "allows one to pipe the pat to standard in")
 	LocalCollectCmd.Flags().Bool("disable-rest-api", false, "disable all REST API calls, this will disable job profile, WLM, and KVM reports")
+	LocalCollectCmd.Flags().DurationVar(&maxCollectionTime, conf.KeyMaxCollectionTime, 0, "max time for the collection (for example 30m), when reached running jobs are stopped and what was collected is archived. 0 means no limit")
+	LocalCollectCmd.Flags().String(conf.KeyFrom, "", "only collect logs, gc logs and queries.json from this time on, RFC3339 with the timezone such as 2024-01-15T23:40:00-05:00. Logs are trimmed to the lines inside of the window")
+	LocalCollectCmd.Flags().String(conf.KeyTo, "", "only collect logs, gc logs and queries.json up to this time, RFC3339 with the timezone such as 2024-01-16T00:10:00-05:00. Defaults to the time of the collection")
+	LocalCollectCmd.Flags().String(conf.KeySnapshotAt, "", "take an incident snapshot of thread dumps, thread cpu, heap and gc state at this time, RFC3339 such as 2024-01-15T23:40:00-05:00 or a duration from now such as 2m")
+	LocalCollectCmd.Flags().Int(conf.KeySnapshotClockOffsetMS, 0, "how many milliseconds the clock of this node is ahead of the clock the snapshot time was set with")
+	LocalCollectCmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the effective configuration, the jobs that would run, the files and endpoints they read and the estimated size without collecting anything")
+	LocalCollectCmd.Flags().StringVar(&dryRunJSON, "dry-run-json", "", "with --dry-run also write the plan as json to this file")
 	LocalCollectCmd.Flags().StringVar(&pid, "pid", "", "write a pid")
 	if err := LocalCollectCmd.Flags().MarkHidden("pid"); err != nil {
 		fmt.Printf("unable to mark flag hidden critical error %v", err)
@@ -783,5 +579,5 @@ func init() {
 	}
 	execLocDir := filepath.Dir(execLoc)
 	LocalCollectCmd.Flags().StringVar(&ddcYamlLoc, "ddc-yaml", filepath.Join(execLocDir, "ddc.yaml"), "location of ddc.yaml that will be transferred to remote nodes for collection configuration")
-	LocalCollectCmd.Flags().StringVar(&collectionMode, "collect", "light", "type of collection: 'light'- 2 days of logs (no top, jstack or jfr). 'standard' - includes jfr, top, 7 days of logs and 30 days of queries.json logs. 'standard+jstack' - all of 'standard' plus jstack. 'health-check' - all of 'standard' + WLM, KV Store Report, 25,000 Job Profiles")
+	LocalCollectCmd.Flags().StringVar(&collectionMode, "collect", "light", "type of collection: 'light'- 2 days of logs (no top, jstack or jfr). 'standard' - includes jfr, top, 7 days of logs and 30 days of queries.json logs. 'standard+jstack' - all of 'standard' plus jstack, a class histogram trend, native memory, heap info, vm info, code cache and metaspace. 'health-check' - all of 'standard' + WLM, KV Store Report, 25,000 Job Profiles. Custom modes declared in collection-modes of ddc.yaml are also accepted")
 }
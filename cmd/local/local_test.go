@@ -21,11 +21,13 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/threading"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/collects"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
 )
@@ -118,6 +120,61 @@ func TestCreateAllDirs(t *testing.T) {
 	}
 }
 
+func TestLocalJobsFormAValidGraph(t *testing.T) {
+	tmpDirForConf := filepath.Join(t.TempDir(), "ddc")
+	if err := os.Mkdir(tmpDirForConf, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	yamlLocation := writeConf(tmpDirForConf)
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+	// any pid turns on the jvm jobs and their outputs
+	overrides := map[string]string{conf.KeyDremioPid: strconv.Itoa(os.Getpid())}
+	c, err := conf.ReadConf(hook, overrides, yamlLocation, collects.StandardPlusJSTACKCollection)
+	if err != nil {
+		t.Fatalf("reading config %v", err)
+	}
+	sched := threading.NewScheduler(nil, false)
+	jobs := localJobs(c, hook, nil)
+	for _, j := range jobs {
+		if len(j.outputs) == 0 {
+			t.Errorf("expected %v to declare its outputs", j.name)
+		}
+		// job stats would credit the files of another job to a job whose output is a dir above them
+		for _, other := range jobs {
+			if other.name == j.name {
+				continue
+			}
+			for _, out := range j.outputs {
+				for _, otherOut := range other.outputs {
+					if strings.HasPrefix(otherOut, out+string(filepath.Separator)) {
+						t.Errorf("output %v of %v holds output %v of %v", out, j.name, otherOut, other.name)
+					}
+				}
+			}
+		}
+		if err := sched.AddTask(threading.Task{Name: j.name, Class: j.class, DependsOn: j.dependsOn, Process: j.run}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	order, err := sched.Order()
+	if err != nil {
+		t.Fatalf("invalid job graph: %v", err)
+	}
+	position := make(map[string]int)
+	for i, name := range order {
+		position[name] = i
+	}
+	if position["JOB PROFILES COLLECTION"] < position[jobQueriesJSON] {
+		t.Errorf("expected job profiles to run after queries.json: %v", order)
+	}
+	for _, sampler := range []string{jobTtop, jobJFR, jobJStack} {
+		if position[jobHeapDump] < position[sampler] {
+			t.Errorf("expected heap dump to run after %v: %v", sampler, order)
+		}
+	}
+}
+
 func TestCollectJVMFlags(t *testing.T) {
 	tmpDirForConf := filepath.Join(t.TempDir(), "ddc")
 	err := os.Mkdir(tmpDirForConf, 0o700)
@@ -0,0 +1,199 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logcollect
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/jobstats"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+)
+
+// DefaultLogType is the per log type budget used for every log type without its own
+const DefaultLogType = "default"
+
+// TruncationMarkerFile lists every log that was cut short or left out in the out dir it was collected to
+const TruncationMarkerFile = "ddc-truncated.txt"
+
+// Budget limits how many bytes of logs are collected per log type and in total. It is shared by the log
+// jobs running at the same time and counts the size of the source files, or of their lines inside of the
+// collection window when one is set, so the gzipped logs are smaller.
+// A nil budget has no limits.
+type Budget struct {
+	mu        sync.Mutex
+	total     int64
+	perType   map[string]int64
+	usedTotal int64
+	used      map[string]int64
+	truncated []jobstats.TruncatedFile
+}
+
+// NewBudget creates a budget, a limit of 0 means no limit and DefaultLogType applies to every log type
+// that is not in perTypeBytes
+func NewBudget(totalBytes int64, perTypeBytes map[string]int64) *Budget {
+	perType := make(map[string]int64)
+	for k, v := range perTypeBytes {
+		perType[k] = v
+	}
+	return &Budget{
+		total:   totalBytes,
+		perType: perType,
+		used:    make(map[string]int64),
+	}
+}
+
+func (b *Budget) limit(logType string) int64 {
+	if limit, ok := b.perType[logType]; ok {
+		return limit
+	}
+	return b.perType[DefaultLogType]
+}
+
+// take reserves up to size bytes for the log type, it returns how many bytes may be collected and
+// a description of the limit when it is less than size
+func (b *Budget) take(logType string, size int64) (int64, string) {
+	if b == nil {
+		return size, ""
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	allowed := size
+	reason := ""
+	if limit := b.limit(logType); limit > 0 {
+		if left := max(limit-b.used[logType], 0); left < allowed {
+			allowed = left
+			reason = fmt.Sprintf("%v budget of %v bytes", logType, limit)
+		}
+	}
+	if b.total > 0 {
+		if left := max(b.total-b.usedTotal, 0); left < allowed {
+			allowed = left
+			reason = fmt.Sprintf("total budget of %v bytes", b.total)
+		}
+	}
+	b.used[logType] += allowed
+	b.usedTotal += allowed
+	return allowed, reason
+}
+
+// release gives back bytes that were taken but not collected
+func (b *Budget) release(logType string, size int64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used[logType] -= size
+	b.usedTotal -= size
+}
+
+// record keeps the truncated file for the summary and appends it to the marker file of the out dir
+func (b *Budget) record(outDir string, t jobstats.TruncatedFile) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.truncated = append(b.truncated, t)
+	simplelog.Warningf("log budget reached: kept %v of %v bytes of %v, the %v was reached", t.KeptBytes, t.OriginalBytes, t.File, t.Budget)
+	loc := filepath.Join(outDir, TruncationMarkerFile)
+	f, err := os.OpenFile(filepath.Clean(loc), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		simplelog.Errorf("unable to write truncation marker %v: %v", loc, err)
+		return
+	}
+	if _, err := fmt.Fprintln(f, truncationMessage(t)); err != nil {
+		simplelog.Errorf("unable to write truncation marker %v: %v", loc, err)
+	}
+	if err := f.Close(); err != nil {
+		simplelog.Errorf("unable to close truncation marker %v: %v", loc, err)
+	}
+}
+
+func truncationMessage(t jobstats.TruncatedFile) string {
+	if t.KeptBytes == 0 {
+		return fmt.Sprintf("ddc: dropped all %v bytes of %v to stay within the %v", t.OriginalBytes, t.File, t.Budget)
+	}
+	return fmt.Sprintf("ddc: dropped the first %v of %v bytes of %v to stay within the %v", t.DroppedBytes, t.OriginalBytes, t.File, t.Budget)
+}
+
+// Truncated is every log that was cut short or left out so far
+func (b *Budget) Truncated() []jobstats.TruncatedFile {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	truncated := make([]jobstats.TruncatedFile, len(b.truncated))
+	copy(truncated, b.truncated)
+	return truncated
+}
+
+// newestFirst orders the files by mod time so the budget is spent on the newest logs,
+// files that cannot be read go last and fail when they are collected
+func newestFirst(files []string) []string {
+	modTimes := make(map[string]int64)
+	for _, f := range files {
+		if fi, err := os.Stat(filepath.Clean(f)); err == nil {
+			modTimes[f] = fi.ModTime().UnixNano()
+		}
+	}
+	sorted := append([]string{}, files...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return modTimes[sorted[i]] > modTimes[sorted[j]]
+	})
+	return sorted
+}
+
+// applyBudget takes the size of src from the budget. Offset is where to start copying to keep the newest data and
+// the marker goes in front of it, skip is true when nothing of the file fits. Gzipped files are collected whole or not at all.
+func (l *Collector) applyBudget(src, outDir, logType string) (offset int64, marker string, skip bool, err error) {
+	if l.budget == nil {
+		return 0, "", false, nil
+	}
+	fi, err := os.Stat(filepath.Clean(src))
+	if err != nil {
+		return 0, "", false, fmt.Errorf("unable to stat %v: %w", src, err)
+	}
+	offset, marker, skip = l.chargeBudget(src, fi.Size(), strings.HasSuffix(src, ".gz"), outDir, logType)
+	return offset, marker, skip, nil
+}
+
+// chargeBudget takes size bytes of src from the budget, wholeOnly files are collected whole or not at all
+func (l *Collector) chargeBudget(src string, size int64, wholeOnly bool, outDir, logType string) (offset int64, marker string, skip bool) {
+	keep, reason := l.budget.take(logType, size)
+	if keep == size {
+		return 0, "", false
+	}
+	if wholeOnly {
+		l.budget.release(logType, keep)
+		keep = 0
+	}
+	t := jobstats.TruncatedFile{
+		LogType:       logType,
+		File:          src,
+		OriginalBytes: size,
+		KeptBytes:     keep,
+		DroppedBytes:  size - keep,
+		Budget:        reason,
+	}
+	l.budget.record(outDir, t)
+	if keep == 0 {
+		return 0, "", true
+	}
+	return size - keep, truncationMessage(t) + "\n", false
+}
@@ -0,0 +1,152 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logcollect_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/logcollect"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/timewindow"
+)
+
+func TestServerLogBudgetKeepsTheNewestData(t *testing.T) {
+	logDir := t.TempDir()
+	outDir := t.TempDir()
+	today := time.Now().Format("2006-01-02")
+	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	var active strings.Builder
+	for i := 0; i < 100; i++ {
+		active.WriteString("2024-01-15 10:00:00,000 INFO line number " + strings.Repeat("x", i%7) + "\n")
+	}
+	active.WriteString("the newest line\n")
+	writeText(t, filepath.Join(logDir, "server.log"), active.String())
+	writeText(t, filepath.Join(logDir, "server.out"), "out\n")
+	newest := filepath.Join(logDir, "archive", "server."+today+".log")
+	oldest := filepath.Join(logDir, "archive", "server."+yesterday+".log")
+	writeText(t, newest, strings.Repeat("n", 100))
+	writeText(t, oldest, strings.Repeat("o", 100))
+	if err := os.Chtimes(oldest, time.Now().Add(-24*time.Hour), time.Now().Add(-24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	budget := logcollect.NewBudget(0, map[string]int64{"server": 1000})
+	c := logcollect.NewLogCollector(logDir, outDir, "", "", outDir, 2, 2)
+	c.SetBudget(budget)
+	if err := c.RunCollectDremioServerLog(); err != nil {
+		t.Fatal(err)
+	}
+	tail := readGzip(t, filepath.Join(outDir, "server.log.gz"))
+	if !strings.HasPrefix(tail, "ddc: dropped the first ") || !strings.HasSuffix(tail, "the newest line\n") {
+		t.Errorf("expected the tail of server.log after a truncation marker but was\n%v", tail)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, filepath.Base(newest)+".gz")); err == nil {
+		t.Error("expected the archives to be dropped once the active file used up the budget")
+	}
+	marker, err := os.ReadFile(filepath.Join(outDir, logcollect.TruncationMarkerFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(marker), oldest) || !strings.Contains(string(marker), "server budget of 1000 bytes") {
+		t.Errorf("expected the dropped archive in the marker but was\n%v", marker)
+	}
+	truncated := budget.Truncated()
+	// server.log, both archives and server.out
+	if len(truncated) != 4 {
+		t.Fatalf("expected 4 truncated files but got %#v", truncated)
+	}
+	if truncated[0].File != filepath.Join(logDir, "server.log") || truncated[0].KeptBytes != 1000 {
+		t.Errorf("expected server.log to keep 1000 bytes but was %#v", truncated[0])
+	}
+	if truncated[1].File != newest || truncated[2].File != oldest {
+		t.Errorf("expected the newest archive to be considered first but got %v then %v", truncated[1].File, truncated[2].File)
+	}
+}
+
+func TestTotalBudgetIsSharedAcrossLogTypes(t *testing.T) {
+	logDir := t.TempDir()
+	outDir := t.TempDir()
+	writeText(t, filepath.Join(logDir, "reflection.log"), strings.Repeat("r\n", 30))
+	writeText(t, filepath.Join(logDir, "vacuum.json"), strings.Repeat("v\n", 30))
+	if err := os.Mkdir(filepath.Join(logDir, "archive"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	budget := logcollect.NewBudget(80, nil)
+	c := logcollect.NewLogCollector(logDir, outDir, "", "", outDir, 2, 2)
+	c.SetBudget(budget)
+	if err := c.RunCollectReflectionLogs(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RunCollectVacuumLogs(); err != nil {
+		t.Fatal(err)
+	}
+	truncated := budget.Truncated()
+	if len(truncated) != 1 || truncated[0].LogType != "vacuum" || truncated[0].KeptBytes != 20 || truncated[0].Budget != "total budget of 80 bytes" {
+		t.Errorf("expected vacuum.json to keep the last 20 bytes of the total budget but got %#v", truncated)
+	}
+	if text := readGzip(t, filepath.Join(outDir, "reflection.log.gz")); text != strings.Repeat("r\n", 30) {
+		t.Errorf("expected reflection.log whole but was %q", text)
+	}
+}
+
+func TestBudgetIsChargedWithTheLinesInsideOfTheWindow(t *testing.T) {
+	logDir := t.TempDir()
+	outDir := t.TempDir()
+	incident := time.Now().Add(-2 * time.Hour).Truncate(time.Minute)
+	stamp := func(t time.Time) string { return t.Format("2006-01-02 15:04:05,000") }
+	var log strings.Builder
+	// a large log where the incident is in the middle, the tail is after the window
+	for i := 0; i < 500; i++ {
+		log.WriteString(stamp(incident.Add(-time.Hour)) + " [main] INFO before the incident\n")
+	}
+	for i := 0; i < 10; i++ {
+		log.WriteString(stamp(incident.Add(time.Duration(i)*time.Second)) + " [e1] ERROR incident line " + string(rune('a'+i)) + "\n")
+	}
+	for i := 0; i < 500; i++ {
+		log.WriteString(stamp(incident.Add(time.Hour)) + " [main] INFO after the incident\n")
+	}
+	writeText(t, filepath.Join(logDir, "server.log"), log.String())
+	writeText(t, filepath.Join(logDir, "server.out"), "out\n")
+	if err := os.Mkdir(filepath.Join(logDir, "archive"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	window := timewindow.Window{From: incident.Add(-time.Minute), To: incident.Add(time.Minute)}
+	lineSize := int64(len(stamp(incident) + " [e1] ERROR incident line a\n"))
+	// room for the newest 4 lines of the window only
+	budget := logcollect.NewBudget(0, map[string]int64{"server": 4*lineSize + 10})
+	c := logcollect.NewLogCollectorWithWindow(logDir, outDir, "", "", outDir, 2, 2, window)
+	c.SetBudget(budget)
+	if err := c.RunCollectDremioServerLog(); err != nil {
+		t.Fatal(err)
+	}
+	kept := readGzip(t, filepath.Join(outDir, "server.log.gz"))
+	if !strings.HasPrefix(kept, "ddc: dropped the first ") {
+		t.Errorf("expected a truncation marker but was\n%v", kept)
+	}
+	if !strings.HasSuffix(kept, "incident line j\n") || strings.Contains(kept, "after the incident") || strings.Contains(kept, "before the incident") {
+		t.Errorf("expected the newest lines of the window but was\n%v", kept)
+	}
+	if strings.Contains(kept, "incident line a\n") {
+		t.Errorf("expected the oldest lines of the window to be dropped but was\n%v", kept)
+	}
+	truncated := budget.Truncated()
+	if len(truncated) == 0 || truncated[0].OriginalBytes != 10*lineSize {
+		t.Fatalf("expected the budget to be charged with the %v bytes inside of the window but got %#v", 10*lineSize, truncated)
+	}
+}
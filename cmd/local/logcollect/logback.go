@@ -0,0 +1,481 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logcollect
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+)
+
+// Appender is a file appender read from logback.xml or logback-access.xml with its variables resolved
+type Appender struct {
+	Name string
+	// File is the active log file, blank when the rolling policy names the active file
+	File string
+	// FileNamePattern is the fileNamePattern of the rolling policy, blank when the file never rolls
+	FileNamePattern string
+	// Source is the logback file the appender was read from
+	Source string
+}
+
+// standardLogFiles are the logs collected by their own job, appenders writing anything else are extra logs
+var standardLogFiles = []string{"server.log", "metadata_refresh.log", "reflection.log", "vacuum.json", "acceleration.log", "access.log", "audit.json", "queries.json"}
+
+// LogType is the name of the log without extensions, server for server.log and queries for queries.json
+func (a Appender) LogType() string {
+	name := a.File
+	if name == "" {
+		name = a.FileNamePattern
+	}
+	base := filepath.Base(name)
+	if i := strings.IndexAny(base, ".%"); i > 0 {
+		return base[:i]
+	}
+	return base
+}
+
+// IsStandard is true when one of the built-in log jobs collects what the appender writes
+func (a Appender) IsStandard() bool {
+	for _, name := range standardLogFiles {
+		if a.matches(name, strings.TrimSuffix(name, filepath.Ext(name))) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches is true when the appender writes the file, appenders without an active file are matched by the log type
+func (a Appender) matches(unzippedFile, logPrefix string) bool {
+	if a.File != "" {
+		return filepath.Base(a.File) == unzippedFile
+	}
+	return a.LogType() == logPrefix
+}
+
+// DiscoverAppenders reads the file appenders of logback.xml and logback-access.xml in the conf dir,
+// ${dremio.log.path} resolves to the log dir. A missing logback-access.xml is not an error.
+func DiscoverAppenders(confDir, logDir string) ([]Appender, []error) {
+	vars := map[string]string{
+		"dremio.log.path": logDir,
+	}
+	var appenders []Appender
+	var errs []error
+	for _, name := range []string{"logback.xml", "logback-access.xml"} {
+		loc := filepath.Join(confDir, name)
+		f, err := os.Open(filepath.Clean(loc))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && name == "logback-access.xml" {
+				simplelog.Debugf("no %v found, access logs use the default naming", loc)
+				continue
+			}
+			errs = append(errs, fmt.Errorf("unable to read %v: %w", loc, err))
+			continue
+		}
+		found, parseErrs := ParseLogback(f, loc, vars)
+		if err := f.Close(); err != nil {
+			simplelog.Warningf("unable to close %v: %v", loc, err)
+		}
+		appenders = append(appenders, found...)
+		errs = append(errs, parseErrs...)
+	}
+	return appenders, errs
+}
+
+// ParseLogback finds every appender with a file or a rolling fileNamePattern including the ones inside of
+// <if> blocks. Variables are resolved from the <property> elements, vars and then the environment,
+// ${name:-default} is supported. Appenders with variables that cannot be resolved are returned as errors.
+func ParseLogback(r io.Reader, source string, vars map[string]string) ([]Appender, []error) {
+	props := make(map[string]string)
+	for k, v := range vars {
+		props[k] = v
+	}
+	type openAppender struct {
+		name    string
+		file    string
+		pattern string
+	}
+	var stack []*openAppender
+	var path []string
+	var text strings.Builder
+	var appenders []Appender
+	var errs []error
+	decoder := xml.NewDecoder(r)
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return appenders, append(errs, fmt.Errorf("unable to parse %v: %w", source, err))
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			text.Reset()
+			switch t.Name.Local {
+			case "appender":
+				stack = append(stack, &openAppender{name: attr(t, "name")})
+			case "property", "variable":
+				name, value := attr(t, "name"), attr(t, "value")
+				if _, ok := props[name]; name != "" && value != "" && !ok {
+					props[name] = value
+				}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			value := strings.TrimSpace(text.String())
+			text.Reset()
+			parent := ""
+			if len(path) > 1 {
+				parent = path[len(path)-2]
+			}
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+			if len(stack) == 0 {
+				continue
+			}
+			current := stack[len(stack)-1]
+			switch t.Name.Local {
+			case "file":
+				if parent == "appender" {
+					current.file = value
+				}
+			case "fileNamePattern", "FileNamePattern":
+				current.pattern = value
+			case "appender":
+				stack = stack[:len(stack)-1]
+				if current.file == "" && current.pattern == "" {
+					// console and socket appenders do not write files
+					continue
+				}
+				a, err := resolveAppender(current.name, current.file, current.pattern, source, props)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				appenders = append(appenders, a)
+			}
+		}
+	}
+	return appenders, errs
+}
+
+func resolveAppender(name, file, pattern, source string, props map[string]string) (Appender, error) {
+	a := Appender{Name: name, Source: source}
+	var err error
+	if file != "" {
+		if a.File, err = resolveVars(file, props); err != nil {
+			return a, fmt.Errorf("skipping appender %v in %v: %w", name, source, err)
+		}
+	}
+	if pattern != "" {
+		if a.FileNamePattern, err = resolveVars(pattern, props); err != nil {
+			return a, fmt.Errorf("skipping appender %v in %v: %w", name, source, err)
+		}
+	}
+	return a, nil
+}
+
+func attr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// resolveVars replaces ${name} and ${name:-default}, property values and defaults may use variables themselves.
+// Names that cannot be resolved are an error.
+func resolveVars(value string, props map[string]string) (string, error) {
+	return resolveVarsDepth(value, props, 0)
+}
+
+func resolveVarsDepth(value string, props map[string]string, depth int) (string, error) {
+	if depth > 10 {
+		return "", fmt.Errorf("variables in '%v' nest too deep", value)
+	}
+	var out strings.Builder
+	for i := 0; i < len(value); {
+		start := strings.Index(value[i:], "${")
+		if start < 0 {
+			out.WriteString(value[i:])
+			break
+		}
+		start += i
+		out.WriteString(value[i:start])
+		end := -1
+		level := 0
+		for j := start; j < len(value) && end < 0; j++ {
+			if strings.HasPrefix(value[j:], "${") {
+				level++
+				j++
+			} else if value[j] == '}' {
+				level--
+				if level == 0 {
+					end = j
+				}
+			}
+		}
+		if end < 0 {
+			return "", fmt.Errorf("unterminated variable in '%v'", value)
+		}
+		name := value[start+2 : end]
+		def, hasDefault := "", false
+		if k := strings.Index(name, ":-"); k >= 0 {
+			name, def, hasDefault = name[:k], name[k+2:], true
+		}
+		v, ok := props[name]
+		if !ok {
+			v, ok = os.LookupEnv(name)
+		}
+		if !ok && !hasDefault {
+			return "", fmt.Errorf("unable to resolve %v in '%v'", name, value)
+		}
+		if !ok {
+			v = def
+		}
+		resolved, err := resolveVarsDepth(v, props, depth+1)
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(resolved)
+		i = end + 1
+	}
+	return out.String(), nil
+}
+
+// rollover periods ordered from the longest to the shortest
+const (
+	periodYear = iota
+	periodMonth
+	periodDay
+	periodHour
+	periodMinute
+)
+
+func addPeriod(t time.Time, period int) time.Time {
+	switch period {
+	case periodMonth:
+		return t.AddDate(0, 1, 0)
+	case periodDay:
+		return t.AddDate(0, 0, 1)
+	case periodHour:
+		return t.Add(time.Hour)
+	case periodMinute:
+		return t.Add(time.Minute)
+	default:
+		return t.AddDate(1, 0, 0)
+	}
+}
+
+// rollingPattern is a compiled logback fileNamePattern
+type rollingPattern struct {
+	// dir is the part of the pattern before the first conversion word, it is walked to find the archives
+	dir string
+	re  *regexp.Regexp
+	// dateGroup is the submatch of the primary %d, 0 when the pattern has no date and files are picked by mod time
+	dateGroup int
+	layout    string
+	loc       *time.Location
+	period    int
+}
+
+var conversionWord = regexp.MustCompile(`%(d|i)(\{[^}]*\})?`)
+
+// compileRollingPattern turns every %d{...} into a date and %i into an index, the first %d without
+// the aux option is the one the file rolls over on
+func compileRollingPattern(pattern string) (*rollingPattern, error) {
+	first := strings.Index(pattern, "%")
+	if first < 0 {
+		return nil, fmt.Errorf("fileNamePattern '%v' has no %%d or %%i", pattern)
+	}
+	rp := &rollingPattern{dir: filepath.Dir(pattern[:first] + "x"), loc: time.Local}
+	var re strings.Builder
+	re.WriteString("^")
+	last := 0
+	group := 0
+	for _, m := range conversionWord.FindAllStringSubmatchIndex(pattern, -1) {
+		re.WriteString(regexp.QuoteMeta(pattern[last:m[0]]))
+		last = m[1]
+		group++
+		if pattern[m[2]:m[3]] == "i" {
+			re.WriteString(`(\d+)`)
+			continue
+		}
+		format := "yyyy-MM-dd"
+		aux := false
+		loc := time.Local
+		if m[4] >= 0 {
+			options := strings.Split(pattern[m[4]+1:m[5]-1], ",")
+			if f := strings.TrimSpace(options[0]); f != "" {
+				format = f
+			}
+			for _, o := range options[1:] {
+				o = strings.TrimSpace(o)
+				if o == "aux" {
+					aux = true
+					continue
+				}
+				l, err := time.LoadLocation(o)
+				if err != nil {
+					return nil, fmt.Errorf("unknown timezone %v in fileNamePattern '%v': %w", o, pattern, err)
+				}
+				loc = l
+			}
+		}
+		layout, dateRe, period, err := javaDateFormat(format)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported fileNamePattern '%v': %w", pattern, err)
+		}
+		re.WriteString("(" + dateRe + ")")
+		if !aux && rp.dateGroup == 0 {
+			rp.dateGroup, rp.layout, rp.loc, rp.period = group, layout, loc, period
+		}
+	}
+	// the archive is compressed after the rollover, until then it has no .gz or .zip
+	tail := strings.TrimSuffix(strings.TrimSuffix(pattern[last:], ".gz"), ".zip")
+	re.WriteString(regexp.QuoteMeta(tail))
+	re.WriteString(`(\.gz|\.zip)?$`)
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return nil, fmt.Errorf("unable to compile fileNamePattern '%v': %w", pattern, err)
+	}
+	rp.re = compiled
+	return rp, nil
+}
+
+// javaDateFormat converts the SimpleDateFormat letters used in fileNamePatterns to a go layout, a regexp
+// and the shortest period in the format which is how often the file rolls over
+func javaDateFormat(format string) (layout, dateRe string, period int, err error) {
+	var l, r strings.Builder
+	period = periodYear
+	shorter := func(p int) {
+		if p > period {
+			period = p
+		}
+	}
+	for i := 0; i < len(format); {
+		c := format[i]
+		if c == '\'' {
+			end := strings.IndexByte(format[i+1:], '\'')
+			if end < 0 {
+				return "", "", 0, fmt.Errorf("unterminated quote in %v", format)
+			}
+			literal := format[i+1 : i+1+end]
+			l.WriteString(literal)
+			r.WriteString(regexp.QuoteMeta(literal))
+			i += end + 2
+			continue
+		}
+		j := i
+		for j < len(format) && format[j] == c {
+			j++
+		}
+		run := format[i:j]
+		i = j
+		switch run {
+		case "yyyy":
+			l.WriteString("2006")
+			r.WriteString(`\d{4}`)
+		case "yy":
+			l.WriteString("06")
+			r.WriteString(`\d{2}`)
+		case "MM":
+			l.WriteString("01")
+			r.WriteString(`\d{2}`)
+			shorter(periodMonth)
+		case "dd":
+			l.WriteString("02")
+			r.WriteString(`\d{2}`)
+			shorter(periodDay)
+		case "HH":
+			l.WriteString("15")
+			r.WriteString(`\d{2}`)
+			shorter(periodHour)
+		case "mm":
+			l.WriteString("04")
+			r.WriteString(`\d{2}`)
+			shorter(periodMinute)
+		default:
+			if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+				return "", "", 0, fmt.Errorf("date format '%v' in %v is not supported", run, format)
+			}
+			l.WriteString(run)
+			r.WriteString(regexp.QuoteMeta(run))
+		}
+	}
+	return l.String(), r.String(), period, nil
+}
+
+// findRollingArchives returns the files matching the fileNamePattern that hold anything written between start and end.
+// A file rolled over on a date holds the whole period of the date, files of patterns without a date are picked by
+// their mod time. The active file is never returned.
+func findRollingArchives(pattern, activeFile string, start, end time.Time) ([]string, error) {
+	rp, err := compileRollingPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	err = filepath.WalkDir(rp.dir, func(loc string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || loc == activeFile {
+			return nil
+		}
+		m := rp.re.FindStringSubmatch(loc)
+		if m == nil {
+			return nil
+		}
+		if rp.dateGroup == 0 {
+			fi, err := d.Info()
+			if err != nil {
+				return fmt.Errorf("unable to stat %v: %w", loc, err)
+			}
+			if !fi.ModTime().Before(start) {
+				matches = append(matches, loc)
+			}
+			return nil
+		}
+		periodStart, err := time.ParseInLocation(rp.layout, m[rp.dateGroup], rp.loc)
+		if err != nil {
+			simplelog.Warningf("skipping %v, the date does not match the fileNamePattern %v: %v", loc, pattern, err)
+			return nil
+		}
+		if !periodStart.After(end) && addPeriod(periodStart, rp.period).After(start) {
+			matches = append(matches, loc)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list archives of %v: %w", pattern, err)
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
@@ -0,0 +1,182 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logcollect_test
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/logcollect"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/timewindow"
+)
+
+const testLogback = `<?xml version="1.0" encoding="UTF-8" ?>
+<configuration>
+  <property name="archive.dir" value="${dremio.log.path}/archive"/>
+  <appender name="console" class="ch.qos.logback.core.ConsoleAppender">
+    <encoder><pattern>%date{ISO8601} [%thread] %-5level %logger{36} - %msg%n</pattern></encoder>
+  </appender>
+  <if condition='isDefined("dremio.log.path")'>
+    <then>
+      <appender name="text" class="ch.qos.logback.core.rolling.RollingFileAppender">
+        <file>${dremio.log.path}/server.log</file>
+        <rollingPolicy class="ch.qos.logback.core.rolling.SizeAndTimeBasedRollingPolicy">
+          <fileNamePattern>${archive.dir}/server.%d{yyyy-MM-dd}.%i.log.gz</fileNamePattern>
+          <maxHistory>30</maxHistory>
+          <maxFileSize>100MB</maxFileSize>
+        </rollingPolicy>
+      </appender>
+      <appender name="tracing" class="ch.qos.logback.core.rolling.RollingFileAppender">
+        <rollingPolicy class="ch.qos.logback.core.rolling.TimeBasedRollingPolicy">
+          <fileNamePattern>${TRACING_DIR:-${dremio.log.path}/tracing}/tracing.%d{yyyy-MM-dd_HH}.log</fileNamePattern>
+        </rollingPolicy>
+      </appender>
+      <appender name="unresolved" class="ch.qos.logback.core.FileAppender">
+        <file>${DDC_TEST_NOT_SET}/other.log</file>
+      </appender>
+    </then>
+  </if>
+</configuration>
+`
+
+func TestParseLogback(t *testing.T) {
+	appenders, errs := logcollect.ParseLogback(strings.NewReader(testLogback), "logback.xml", map[string]string{"dremio.log.path": "/var/log/dremio"})
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "DDC_TEST_NOT_SET") {
+		t.Errorf("expected one error for the unresolved appender but got %v", errs)
+	}
+	expected := []logcollect.Appender{
+		{Name: "text", File: "/var/log/dremio/server.log", FileNamePattern: "/var/log/dremio/archive/server.%d{yyyy-MM-dd}.%i.log.gz", Source: "logback.xml"},
+		{Name: "tracing", FileNamePattern: "/var/log/dremio/tracing/tracing.%d{yyyy-MM-dd_HH}.log", Source: "logback.xml"},
+	}
+	if len(appenders) != len(expected) {
+		t.Fatalf("expected %#v but got %#v", expected, appenders)
+	}
+	for i := range expected {
+		if appenders[i] != expected[i] {
+			t.Errorf("expected %#v but got %#v", expected[i], appenders[i])
+		}
+	}
+	if !appenders[0].IsStandard() || appenders[0].LogType() != "server" {
+		t.Errorf("expected the text appender to be the standard server log but was %v", appenders[0].LogType())
+	}
+	if appenders[1].IsStandard() || appenders[1].LogType() != "tracing" {
+		t.Errorf("expected the tracing appender to be an extra log but was %v", appenders[1].LogType())
+	}
+}
+
+func listFiles(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func writeText(t *testing.T, loc, text string) {
+	if err := os.MkdirAll(filepath.Dir(loc), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(loc, []byte(text), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectSizeAndTimeRollingArchives(t *testing.T) {
+	logDir := t.TempDir()
+	outDir := t.TempDir()
+	today := time.Now().Format("2006-01-02")
+	old := time.Now().AddDate(0, 0, -10).Format("2006-01-02")
+	writeText(t, filepath.Join(logDir, "server.log"), "active\n")
+	writeText(t, filepath.Join(logDir, "server.out"), "out\n")
+	if err := os.Mkdir(filepath.Join(logDir, "archive"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	writeGzip(t, filepath.Join(logDir, "archive", "server."+today+".0.log.gz"), "first\n")
+	writeGzip(t, filepath.Join(logDir, "archive", "server."+today+".1.log.gz"), "second\n")
+	// rolled over but not compressed yet
+	writeText(t, filepath.Join(logDir, "archive", "server."+today+".2.log"), "third\n")
+	writeGzip(t, filepath.Join(logDir, "archive", "server."+old+".0.log.gz"), "old\n")
+
+	appenders, errs := logcollect.ParseLogback(strings.NewReader(testLogback), "logback.xml", map[string]string{"dremio.log.path": logDir})
+	if len(errs) != 1 {
+		t.Fatalf("expected only the unresolved appender to fail but got %v", errs)
+	}
+	c := logcollect.NewLogCollector(logDir, outDir, "", "", outDir, 2, 2)
+	c.SetAppenders(appenders)
+	if err := c.RunCollectDremioServerLog(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"server." + today + ".0.log.gz",
+		"server." + today + ".1.log.gz",
+		"server." + today + ".2.log.gz",
+		"server.log.gz",
+		"server.out",
+	}
+	if actual := listFiles(t, outDir); strings.Join(actual, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+	if text := readGzip(t, filepath.Join(outDir, "server."+today+".2.log.gz")); text != "third\n" {
+		t.Errorf("expected the uncompressed archive to be gzipped but read %q", text)
+	}
+}
+
+func TestCollectExtraHourlyAppenderInWindow(t *testing.T) {
+	logDir := t.TempDir()
+	outDir := t.TempDir()
+	now := time.Now()
+	hour := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, time.Local)
+	archive := func(hoursAgo int) string {
+		return "tracing." + hour.Add(-time.Duration(hoursAgo)*time.Hour).Format("2006-01-02_15") + ".log"
+	}
+	for _, h := range []int{1, 2, 3, 5} {
+		writeText(t, filepath.Join(logDir, "tracing", archive(h)), "trace\n")
+	}
+	window := timewindow.Window{From: hour.Add(-3*time.Hour + 10*time.Minute), To: hour.Add(-2*time.Hour + 10*time.Minute)}
+	c := logcollect.NewLogCollectorWithWindow(logDir, outDir, "", "", outDir, 2, 2, window)
+	c.SetAppenders([]logcollect.Appender{
+		{Name: "text", File: filepath.Join(logDir, "server.log")},
+		{Name: "tracing", FileNamePattern: filepath.Join(logDir, "tracing", "tracing.%d{yyyy-MM-dd_HH}.log")},
+	})
+	if extra := c.ExtraAppenders(); len(extra) != 1 || extra[0].Name != "tracing" {
+		t.Fatalf("expected only tracing to be an extra appender but got %v", extra)
+	}
+	if err := c.RunCollectExtraLogs(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{archive(3) + ".gz", archive(2) + ".gz"}
+	sort.Strings(expected)
+	if actual := listFiles(t, filepath.Join(outDir, "extra", "tracing")); strings.Join(actual, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+	planned, err := c.PlanExtraLogs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(planned) != 2 {
+		t.Errorf("expected the plan to list the 2 archives in the window but got %v", planned)
+	}
+}
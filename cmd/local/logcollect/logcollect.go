@@ -26,6 +26,7 @@ import (
 
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/ddcio"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/timewindow"
 )
 
 type Collector struct {
@@ -36,9 +37,19 @@ type Collector struct {
 	queriesOutDir            string
 	dremioLogsNumDays        int
 	dremioQueriesJSONNumDays int
+	// window when set replaces the number of days and trims the collected logs to the lines inside of it
+	window timewindow.Window
+	// appenders are read from logback.xml, logs without an appender use the default naming in the log dir
+	appenders []Appender
+	// budget limits the bytes collected per log type and in total, nil is no limit
+	budget *Budget
 }
 
 func NewLogCollector(dremioLogDir, logsOutDir, gcLogsDir, dremioGCFilePattern, queriesOutDir string, dremioQueriesJSONNumDays, dremioLogsNumDays int) *Collector {
+	return NewLogCollectorWithWindow(dremioLogDir, logsOutDir, gcLogsDir, dremioGCFilePattern, queriesOutDir, dremioQueriesJSONNumDays, dremioLogsNumDays, timewindow.Window{})
+}
+
+func NewLogCollectorWithWindow(dremioLogDir, logsOutDir, gcLogsDir, dremioGCFilePattern, queriesOutDir string, dremioQueriesJSONNumDays, dremioLogsNumDays int, window timewindow.Window) *Collector {
 	return &Collector{
 		dremioLogDir:             dremioLogDir,
 		logsOutDir:               logsOutDir,
@@ -47,9 +58,38 @@ func NewLogCollector(dremioLogDir, logsOutDir, gcLogsDir, dremioGCFilePattern, q
 		dremioGCFilePattern:      dremioGCFilePattern,
 		queriesOutDir:            queriesOutDir,
 		gcLogsDir:                gcLogsDir,
+		window:                   window,
 	}
 }
 
+// SetAppenders makes the collection follow the files and rolling patterns of the logback appenders
+func (l *Collector) SetAppenders(appenders []Appender) {
+	l.appenders = appenders
+}
+
+// SetBudget limits the bytes collected, the budget can be shared with other collectors
+func (l *Collector) SetBudget(budget *Budget) {
+	l.budget = budget
+}
+
+// appender is the appender writing the log, false when logback.xml does not configure one
+func (l *Collector) appender(unzippedFile, logPrefix string) (Appender, bool) {
+	for _, a := range l.appenders {
+		if a.matches(unzippedFile, logPrefix) {
+			return a, true
+		}
+	}
+	return Appender{}, false
+}
+
+// activeLog is the file the log is currently written to
+func (l *Collector) activeLog(srcLogDir, unzippedFile, logPrefix string) string {
+	if a, ok := l.appender(unzippedFile, logPrefix); ok && a.File != "" {
+		return a.File
+	}
+	return path.Join(srcLogDir, unzippedFile)
+}
+
 func (l *Collector) RunCollectDremioServerLog() error {
 	simplelog.Debug("Collecting Dremio Server logs ...")
 	var errs []error
@@ -59,8 +99,16 @@ func (l *Collector) RunCollectDremioServerLog() error {
 	simplelog.Debug("... collecting server.out")
 	src := path.Join(l.dremioLogDir, "server.out")
 	dest := path.Join(l.logsOutDir, "server.out")
-	if err := ddcio.CopyFile(path.Clean(src), path.Clean(dest)); err != nil {
-		errs = append(errs, fmt.Errorf("unable to copy %v to %v: %w", src, dest, err))
+	if offset, marker, skip, err := l.applyBudget(src, l.logsOutDir, "server"); err != nil {
+		errs = append(errs, err)
+	} else if offset > 0 {
+		if err := l.copyLog(src, dest, offset, marker, false); err != nil {
+			errs = append(errs, fmt.Errorf("unable to copy the tail of %v to %v: %w", src, dest, err))
+		}
+	} else if !skip {
+		if err := ddcio.CopyFile(path.Clean(src), path.Clean(dest)); err != nil {
+			errs = append(errs, fmt.Errorf("unable to copy %v to %v: %w", src, dest, err))
+		}
 	}
 	if len(errs) > 1 {
 		return fmt.Errorf("several errors while copying dremio server logs: %w", errors.Join(errs...))
@@ -77,12 +125,62 @@ func (l *Collector) RunCollectGcLogs() error {
 	} else {
 		simplelog.Debug("Collecting GC logs ...")
 	}
+	matches, errs, err := l.findGcLogs()
+	if err != nil {
+		return err
+	}
+	for _, srcPath := range newestFirst(matches) {
+		fileName := filepath.Base(srcPath)
+		destPath := filepath.Join(l.logsOutDir, fileName)
+		if l.window.IsSet() && l.budget != nil {
+			if err := l.copyWindowWithinBudget(srcPath, destPath, l.logsOutDir, "gc", false); err != nil {
+				errs = append(errs, fmt.Errorf("error trimming file %s: %w", fileName, err))
+			}
+			continue
+		}
+		offset, marker, skip, err := l.applyBudget(srcPath, l.logsOutDir, "gc")
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if skip {
+			continue
+		}
+		if l.window.IsSet() || offset > 0 {
+			if err := l.copyLog(srcPath, destPath, offset, marker, false); err != nil {
+				errs = append(errs, fmt.Errorf("error trimming file %s: %w", fileName, err))
+			}
+			continue
+		}
+		if err := ddcio.CopyFile(path.Clean(srcPath), path.Clean(destPath)); err != nil {
+			errs = append(errs, fmt.Errorf("error copying file %s: %w", fileName, err))
+			continue
+		}
+		simplelog.Debugf("Copied file %s to %s", srcPath, destPath)
+	}
+	if len(errs) > 1 {
+		return fmt.Errorf("several errors while copying dremio server logs: %w", errors.Join(errs...))
+	} else if (len(errs)) == 1 {
+		return errs[0]
+	}
+	simplelog.Debug("... collecting GC logs COMPLETED")
+
+	return nil
+}
+
+// findGcLogs returns the gc logs matching the gc file pattern that are inside the day window
+func (l *Collector) findGcLogs() ([]string, []error, error) {
 	files, err := os.ReadDir(path.Clean(l.gcLogsDir))
 	if err != nil {
-		return fmt.Errorf("error reading directory: %w", err)
+		return nil, nil, fmt.Errorf("error reading directory: %w", err)
 	}
 	now := time.Now()
 	logAgeLimit := now.AddDate(0, 0, -l.dremioLogsNumDays)
+	if l.window.IsSet() {
+		// a gc log last written before the window starts cannot have anything in it
+		logAgeLimit = l.window.From
+	}
+	var matches []string
 	var errs []error
 	for _, file := range files {
 		if file.IsDir() {
@@ -104,24 +202,12 @@ func (l *Collector) RunCollectGcLogs() error {
 				simplelog.Debugf("skipping file %v due to having mode time of %v when logage is %v and current time of collection at %v resulting in all logs being skipped older than %v", srcPath, f.ModTime(), l.dremioLogsNumDays, now, logAgeLimit)
 				continue
 			}
-			destPath := filepath.Join(l.logsOutDir, file.Name())
-			if err := ddcio.CopyFile(path.Clean(srcPath), path.Clean(destPath)); err != nil {
-				errs = append(errs, fmt.Errorf("error copying file %s: %w", file.Name(), err))
-				continue
-			}
-			simplelog.Debugf("Copied file %s to %s", srcPath, destPath)
+			matches = append(matches, srcPath)
 		} else {
 			simplelog.Debugf("skipping file %v in gc log folder: '%v' did not match gc pattern: '%v'", file.Name(), l.gcLogsDir, l.dremioGCFilePattern)
 		}
 	}
-	if len(errs) > 1 {
-		return fmt.Errorf("several errors while copying dremio server logs: %w", errors.Join(errs...))
-	} else if (len(errs)) == 1 {
-		return errs[0]
-	}
-	simplelog.Debug("... collecting GC logs COMPLETED")
-
-	return nil
+	return matches, errs, nil
 }
 
 func (l *Collector) RunCollectMetadataRefreshLogs() error {
@@ -194,69 +280,199 @@ func (l *Collector) RunCollectQueriesJSON() error {
 	return nil
 }
 
-func (l *Collector) exportArchivedLogs(srcLogDir string, unzippedFile string, logPrefix string, archiveDays int) error {
+// ExtraAppenders are the appenders in logback.xml writing logs none of the log jobs collect
+func (l *Collector) ExtraAppenders() []Appender {
+	var extra []Appender
+	for _, a := range l.appenders {
+		if !a.IsStandard() {
+			extra = append(extra, a)
+		}
+	}
+	return extra
+}
+
+// RunCollectExtraLogs collects the logs of every extra appender into its own folder named after the appender
+func (l *Collector) RunCollectExtraLogs() error {
+	simplelog.Debug("Collecting logs of extra logback appenders ...")
 	var errs []error
-	src := path.Join(srcLogDir, unzippedFile)
+	for _, a := range l.ExtraAppenders() {
+		outDir := l.extraLogsOutDir(a)
+		if err := os.MkdirAll(outDir, 0o700); err != nil {
+			errs = append(errs, fmt.Errorf("unable to create %v: %w", outDir, err))
+			continue
+		}
+		if a.File != "" {
+			if err := l.collectLogFile(a.File, outDir, a.LogType()); err != nil {
+				errs = append(errs, fmt.Errorf("copying of log file %v of appender %v failed: %w", a.File, a.Name, err))
+			}
+		}
+		if a.FileNamePattern == "" {
+			continue
+		}
+		archived, err := l.rollingArchives(a, l.dremioLogsNumDays)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to find the archives of appender %v: %w", a.Name, err))
+			continue
+		}
+		for _, src := range newestFirst(archived) {
+			if err := l.collectLogFile(src, outDir, a.LogType()); err != nil {
+				errs = append(errs, fmt.Errorf("unable to archive file %v of appender %v: %w", src, a.Name, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("unable to collect extra logs: %w", errors.Join(errs...))
+	}
+	simplelog.Debug("... collecting logs of extra logback appenders COMPLETED")
+	return nil
+}
+
+func (l *Collector) extraLogsOutDir(a Appender) string {
+	return filepath.Join(l.logsOutDir, "extra", filepath.Base(a.Name))
+}
+
+func (l *Collector) exportArchivedLogs(srcLogDir string, unzippedFile string, logPrefix string, archiveDays int) error {
 	var outDir string
 	if logPrefix == "queries" {
 		outDir = l.queriesOutDir
 	} else {
 		outDir = l.logsOutDir
 	}
-	unzippedFileDest := path.Join(outDir, unzippedFile)
-	// we must copy before archival to avoid races around the archiving features of logging (which also use gzip)
-	if err := ddcio.CopyFile(path.Clean(src), path.Clean(unzippedFileDest)); err != nil {
+	var errs []error
+	src := l.activeLog(srcLogDir, unzippedFile, logPrefix)
+	if err := l.collectLogFile(src, outDir, logPrefix); err != nil {
 		errs = append(errs, fmt.Errorf("copying of log file %v failed: %w", unzippedFile, err))
-	} else {
-		// if this is successful go ahead and gzip it
-		if err := ddcio.GzipFile(path.Clean(unzippedFileDest), path.Clean(unzippedFileDest+".gz")); err != nil {
-			errs = append(errs, fmt.Errorf("archiving of log file %v failed: %w", unzippedFile, err))
-		} else {
-			// if we've successfully gzipped the file we can safely delete the source
-			if err := os.Remove(path.Clean(unzippedFileDest)); err != nil {
-				errs = append(errs, fmt.Errorf("cleanup of old log file %v failed: %w", unzippedFile, err))
-			}
+	}
+	archived, err := l.archivedLogs(srcLogDir, unzippedFile, logPrefix, archiveDays)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	// the active file goes first and then the newest archives so a budget keeps the newest data
+	for _, src := range newestFirst(archived) {
+		simplelog.Debugf("Copying archive file %v", filepath.Base(src))
+		if err := l.collectLogFile(src, outDir, logPrefix); err != nil {
+			errs = append(errs, fmt.Errorf("unable to archive file %v: %w", src, err))
 		}
 	}
+	if len(errs) > 1 {
+		return fmt.Errorf("multiple errors archiving: %w", errors.Join(errs...))
+	} else if len(errs) == 1 {
+		return errs[0]
+	}
+	return nil
+}
 
-	today := time.Now()
+// collectLogFile writes the log gzipped to the out dir, when a collection window is set only the lines inside
+// of it are written and when the budget is reached only the tail of the log, or of its lines in the window, is written
+func (l *Collector) collectLogFile(src, outDir, logType string) error {
+	dst := filepath.Join(outDir, filepath.Base(src))
+	if l.window.IsSet() && l.budget != nil {
+		if !strings.HasSuffix(dst, ".gz") {
+			dst += ".gz"
+		}
+		return l.copyWindowWithinBudget(src, dst, outDir, logType, true)
+	}
+	offset, marker, skip, err := l.applyBudget(src, outDir, logType)
+	if err != nil {
+		return err
+	}
+	if skip {
+		return nil
+	}
+	if l.window.IsSet() || offset > 0 {
+		if !strings.HasSuffix(dst, ".gz") {
+			dst += ".gz"
+		}
+		return l.copyLog(src, dst, offset, marker, true)
+	}
+	// we must copy before archival to avoid races around the archiving features of logging (which also use gzip)
+	if err := ddcio.CopyFile(path.Clean(src), path.Clean(dst)); err != nil {
+		return fmt.Errorf("unable to copy file %v to %v: %w", src, dst, err)
+	}
+	if strings.HasSuffix(dst, ".gz") {
+		return nil
+	}
+	// go ahead and archive the file since it's not already
+	if err := ddcio.GzipFile(path.Clean(dst), path.Clean(dst+".gz")); err != nil {
+		return fmt.Errorf("unable to gzip file %v: %w", dst, err)
+	}
+	// if we've successfully gzipped the file we can safely delete the copy
+	if err := os.Remove(path.Clean(dst)); err != nil {
+		return fmt.Errorf("cleanup of old log file %v failed: %w", dst, err)
+	}
+	return nil
+}
+
+// archivedLogs picks the archives by the collection window when one is set, otherwise by the number of days
+func (l *Collector) archivedLogs(srcLogDir, unzippedFile, logPrefix string, archiveDays int) ([]string, error) {
+	if a, ok := l.appender(unzippedFile, logPrefix); ok && a.FileNamePattern != "" {
+		archived, err := l.rollingArchives(a, archiveDays)
+		if err == nil {
+			return archived, nil
+		}
+		simplelog.Warningf("falling back to %v/archive for %v logs: %v", srcLogDir, logPrefix, err)
+	}
+	if !l.window.IsSet() {
+		return findArchivedLogs(srcLogDir, logPrefix, archiveDays, time.Now())
+	}
+	now := time.Now()
+	end := l.window.To
+	if end.IsZero() {
+		end = now
+	}
+	// archives are named after the day they were rolled over on in the timezone of the node
+	if days := l.window.Days(now, time.Local); days > 0 {
+		archiveDays = days - 1
+	}
+	return findArchivedLogs(srcLogDir, logPrefix, archiveDays, end.In(time.Local))
+}
+
+// rollingArchives picks the archives of the appender by its fileNamePattern for the collection window,
+// without a window everything since the start of the first day of the number of days is picked
+func (l *Collector) rollingArchives(a Appender, archiveDays int) ([]string, error) {
+	now := time.Now()
+	y, m, d := now.AddDate(0, 0, -archiveDays).Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	end := now
+	if l.window.IsSet() {
+		if !l.window.From.IsZero() {
+			start = l.window.From
+		}
+		if !l.window.To.IsZero() {
+			end = l.window.To
+		}
+	}
+	return findRollingArchives(a.FileNamePattern, a.File, start, end)
+}
+
+// findArchivedLogs returns the rolled over logs in the archive folder for every day in the window
+func findArchivedLogs(srcLogDir, logPrefix string, archiveDays int, today time.Time) ([]string, error) {
 	files, err := os.ReadDir(filepath.Join(srcLogDir, "archive"))
 	if err != nil {
-		// no archives to read go ahead and exit as there is nothing to do
-		return fmt.Errorf("unable to read archive folder: %w", err)
+		return nil, fmt.Errorf("unable to read archive folder: %w", err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	var matches []string
+	for _, name := range matchArchivedNames(names, logPrefix, archiveDays, today) {
+		matches = append(matches, filepath.Join(srcLogDir, "archive", name))
 	}
+	return matches, nil
+}
+
+// matchArchivedNames keeps the names that were rolled over on one of the days in the window
+func matchArchivedNames(names []string, logPrefix string, archiveDays int, today time.Time) []string {
+	var matches []string
 	for i := 0; i <= archiveDays; i++ {
 		processingDate := today.AddDate(0, 0, -i).Format("2006-01-02")
 		// now search files for a match
-		for _, f := range files {
-			if strings.HasPrefix(f.Name(), fmt.Sprintf("%v.%v", logPrefix, processingDate)) {
-				simplelog.Debugf("Copying archive file for %v:%v", processingDate, f.Name())
-				src := filepath.Join(srcLogDir, "archive", f.Name())
-				dst := filepath.Join(outDir, f.Name())
-
-				// we must copy before archival to avoid races around the archiving features of logging (which also use gzip)
-				if err := ddcio.CopyFile(path.Clean(src), path.Clean(dst)); err != nil {
-					errs = append(errs, fmt.Errorf("unable to move file %v to %v: %w", src, dst, err))
-					continue
-				}
-				if !strings.HasSuffix(f.Name(), ".gz") {
-					// go ahead and archive the file since it's not already
-					if err := ddcio.GzipFile(path.Clean(dst), path.Clean(dst+".gz")); err != nil {
-						errs = append(errs, fmt.Errorf("unable to archive file %v to %v: %w", src, dst, err))
-						continue
-					}
-					// if we've successfully gzipped the file we can safely delete the source (the continue above will guard against executing this)
-					if err := os.Remove(path.Clean(dst)); err != nil {
-						errs = append(errs, fmt.Errorf("cleanup of old log file %v failed: %w", unzippedFile, err))
-					}
-				}
+		for _, name := range names {
+			if strings.HasPrefix(name, fmt.Sprintf("%v.%v", logPrefix, processingDate)) {
+				matches = append(matches, name)
 			}
 		}
 	}
-	if len(errs) > 1 {
-		return fmt.Errorf("multiple errors archiving: %w", errors.Join(errs...))
-	} else if len(errs) == 1 {
-		return errs[0]
-	}
-	return nil
+	return matches
 }
@@ -0,0 +1,168 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logcollect
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PlannedFile is a log file that a collection would copy, used by dry runs
+type PlannedFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+func (l *Collector) PlanDremioServerLog() ([]PlannedFile, error) {
+	files, err := l.planArchivedLogs("server.log", "server", l.dremioLogsNumDays)
+	return append(files, statFiles(path.Join(l.dremioLogDir, "server.out"))...), err
+}
+
+func (l *Collector) PlanGcLogs() ([]PlannedFile, error) {
+	if l.gcLogsDir == "" {
+		return nil, errors.New("no gc log directory is configured set dremio-gclogs-dir in ddc.yaml")
+	}
+	matches, _, err := l.findGcLogs()
+	if err != nil {
+		return nil, err
+	}
+	return statFiles(matches...), nil
+}
+
+func (l *Collector) PlanMetadataRefreshLogs() ([]PlannedFile, error) {
+	return l.planArchivedLogs("metadata_refresh.log", "metadata_refresh", l.dremioLogsNumDays)
+}
+
+func (l *Collector) PlanReflectionLogs() ([]PlannedFile, error) {
+	return l.planArchivedLogs("reflection.log", "reflection", l.dremioLogsNumDays)
+}
+
+func (l *Collector) PlanVacuumLogs() ([]PlannedFile, error) {
+	return l.planArchivedLogs("vacuum.json", "vacuum", l.dremioLogsNumDays)
+}
+
+func (l *Collector) PlanDremioAccessLogs() ([]PlannedFile, error) {
+	return l.planArchivedLogs("access.log", "access", l.dremioLogsNumDays)
+}
+
+func (l *Collector) PlanDremioAuditLogs() ([]PlannedFile, error) {
+	return l.planArchivedLogs("audit.json", "audit", l.dremioLogsNumDays)
+}
+
+func (l *Collector) PlanAccelerationLogs() ([]PlannedFile, error) {
+	return l.planArchivedLogs("acceleration.log", "acceleration", l.dremioLogsNumDays)
+}
+
+func (l *Collector) PlanQueriesJSON() ([]PlannedFile, error) {
+	return l.planArchivedLogs("queries.json", "queries", l.dremioQueriesJSONNumDays)
+}
+
+func (l *Collector) PlanExtraLogs() ([]PlannedFile, error) {
+	var files []PlannedFile
+	var errs []error
+	for _, a := range l.ExtraAppenders() {
+		if a.File != "" {
+			files = append(files, statFiles(a.File)...)
+		}
+		if a.FileNamePattern == "" {
+			continue
+		}
+		archived, err := l.rollingArchives(a, l.dremioLogsNumDays)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		files = append(files, statFiles(archived...)...)
+	}
+	return files, errors.Join(errs...)
+}
+
+// planArchivedLogs lists the same files exportArchivedLogs would copy without copying them
+func (l *Collector) planArchivedLogs(unzippedFile, logPrefix string, archiveDays int) ([]PlannedFile, error) {
+	files := statFiles(l.activeLog(l.dremioLogDir, unzippedFile, logPrefix))
+	archived, err := l.archivedLogs(l.dremioLogDir, unzippedFile, logPrefix, archiveDays)
+	if err != nil {
+		return files, err
+	}
+	return append(files, statFiles(archived...)...), nil
+}
+
+// statFiles skips files that are not present, the same as a collection would not find them
+func statFiles(locs ...string) []PlannedFile {
+	var files []PlannedFile
+	for _, loc := range locs {
+		fi, err := os.Stat(filepath.Clean(loc))
+		if err != nil || fi.IsDir() {
+			continue
+		}
+		files = append(files, PlannedFile{Path: loc, Size: fi.Size()})
+	}
+	return files
+}
+
+// MatchListing picks the files a collection would copy out of a listing of the log dir and its
+// archive folder, this is used when the files cannot be read directly such as on a remote node
+func MatchListing(listing []PlannedFile, logDir, archivePrefix string, archiveDays int, today time.Time, activeFiles ...string) []PlannedFile {
+	var matches []PlannedFile
+	archived := make(map[string]PlannedFile)
+	var archivedNames []string
+	for _, f := range listing {
+		dir, name := path.Split(f.Path)
+		dir = path.Clean(dir)
+		if dir == path.Clean(logDir) {
+			for _, active := range activeFiles {
+				if name == active {
+					matches = append(matches, f)
+				}
+			}
+		} else if dir == path.Join(logDir, "archive") {
+			archived[name] = f
+			archivedNames = append(archivedNames, name)
+		}
+	}
+	for _, name := range matchArchivedNames(archivedNames, archivePrefix, archiveDays, today) {
+		matches = append(matches, archived[name])
+	}
+	return matches
+}
+
+// ParseLsListing reads the output of ls -ln for one or more directories, only regular files are kept
+func ParseLsListing(lines []string, defaultDir string) []PlannedFile {
+	var files []PlannedFile
+	dir := defaultDir
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasSuffix(line, ":") && !strings.Contains(line, " ") {
+			dir = strings.TrimSuffix(line, ":")
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 9 || !strings.HasPrefix(fields[0], "-") {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			continue
+		}
+		name := strings.Join(fields[8:], " ")
+		files = append(files, PlannedFile{Path: path.Join(dir, name), Size: size})
+	}
+	return files
+}
@@ -0,0 +1,79 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logcollect_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/logcollect"
+)
+
+func TestPlanDremioServerLog(t *testing.T) {
+	logDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(logDir, "archive"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	today := time.Now().Format("2006-01-02")
+	old := time.Now().AddDate(0, 0, -10).Format("2006-01-02")
+	for name, size := range map[string]int{
+		"server.log": 10,
+		"server.out": 3,
+		filepath.Join("archive", "server."+today+".0.log.gz"): 7,
+		filepath.Join("archive", "server."+old+".0.log.gz"):   100,
+	} {
+		if err := os.WriteFile(filepath.Join(logDir, name), make([]byte, size), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	collector := logcollect.NewLogCollector(logDir, t.TempDir(), "", "", t.TempDir(), 2, 2)
+	files, err := collector.PlanDremioServerLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+	if len(files) != 3 || total != 20 {
+		t.Errorf("expected server.log, server.out and today's archive (20 bytes) but got %v bytes in %#v", total, files)
+	}
+}
+
+func TestParseLsListingAndMatch(t *testing.T) {
+	today := time.Now()
+	lines := []string{
+		"/logs:",
+		"total 4",
+		"-rw-r--r-- 1 0 0 42 Oct 18 10:00 queries.json",
+		"drwxr-xr-x 2 0 0 4096 Oct 18 10:00 archive",
+		"",
+		"/logs/archive:",
+		"-rw-r--r-- 1 0 0 8 Oct 18 10:00 queries." + today.Format("2006-01-02") + ".0.json.gz",
+	}
+	listing := logcollect.ParseLsListing(lines, "/logs")
+	if len(listing) != 2 {
+		t.Fatalf("expected 2 files but got %#v", listing)
+	}
+	matches := logcollect.MatchListing(listing, "/logs", "queries", 1, today, "queries.json")
+	if len(matches) != 2 || matches[1].Path != "/logs/archive/queries."+today.Format("2006-01-02")+".0.json.gz" {
+		t.Errorf("unexpected matches %#v", matches)
+	}
+	if matches := logcollect.MatchListing(listing, "/logs", "server", 1, today, "server.log"); len(matches) != 0 {
+		t.Errorf("expected no server log matches but got %#v", matches)
+	}
+}
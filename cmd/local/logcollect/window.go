@@ -0,0 +1,157 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logcollect
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/ddcio"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/timewindow"
+)
+
+// trimFile writes the lines of src inside of the collection window to dst, gzipped sources are read
+// transparently and gzipOut compresses the result
+func (l *Collector) trimFile(src, dst string, gzipOut bool) error {
+	return l.copyLog(src, dst, 0, "", gzipOut)
+}
+
+// copyWindowWithinBudget trims src to the collection window before the budget is applied, so the budget is charged
+// with the bytes inside of the window and the tail that is kept is the newest part of the window. The sizes in the
+// truncation marker are those of the lines inside of the window.
+func (l *Collector) copyWindowWithinBudget(src, dst, outDir, logType string, gzipOut bool) error {
+	tmp, err := os.CreateTemp(outDir, ".ddc-window-*")
+	if err != nil {
+		return fmt.Errorf("unable to create temp file for %v: %w", src, err)
+	}
+	tmpName := tmp.Name()
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to close %v: %w", tmpName, err)
+	}
+	defer func() {
+		if err := os.Remove(tmpName); err != nil && !os.IsNotExist(err) {
+			simplelog.Warningf("unable to remove %v: %v", tmpName, err)
+		}
+	}()
+	// trimmed uncompressed so the budget can cut it at any line
+	if err := l.copyLog(src, tmpName, 0, "", false); err != nil {
+		return err
+	}
+	fi, err := os.Stat(tmpName)
+	if err != nil {
+		// nothing of src was inside of the window
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("unable to stat %v: %w", tmpName, err)
+	}
+	offset, marker, skip := l.chargeBudget(src, fi.Size(), false, outDir, logType)
+	if skip {
+		return nil
+	}
+	return l.copyTail(tmpName, dst, offset, marker, gzipOut)
+}
+
+// copyTail is copyLog without the collection window, for files that were already trimmed
+func (l *Collector) copyTail(src, dst string, offset int64, marker string, gzipOut bool) error {
+	whole := *l
+	whole.window = timewindow.Window{}
+	return whole.copyLog(src, dst, offset, marker, gzipOut)
+}
+
+// copyLog writes src to dst starting at the first full line after offset with the marker in front, when a collection
+// window is set only the lines inside of it are written. Offsets are only supported for uncompressed sources.
+func (l *Collector) copyLog(src, dst string, offset int64, marker string, gzipOut bool) error {
+	srcFile, err := os.Open(filepath.Clean(src))
+	if err != nil {
+		return fmt.Errorf("unable to open %v: %w", src, err)
+	}
+	defer ddcio.EnsureClose(src, srcFile.Close)
+	var r io.Reader = srcFile
+	if strings.HasSuffix(src, ".gz") {
+		gzReader, err := gzip.NewReader(srcFile)
+		if err != nil {
+			return fmt.Errorf("unable to read gzip %v: %w", src, err)
+		}
+		defer ddcio.EnsureClose(src, gzReader.Close)
+		r = gzReader
+	} else if offset > 0 {
+		if _, err := srcFile.Seek(offset, io.SeekStart); err != nil {
+			return fmt.Errorf("unable to seek to %v in %v: %w", offset, src, err)
+		}
+		buffered := bufio.NewReader(srcFile)
+		// the offset is likely in the middle of a line
+		if _, err := buffered.ReadString('\n'); err != nil && err != io.EOF {
+			return fmt.Errorf("unable to read %v: %w", src, err)
+		}
+		r = buffered
+	}
+	dstFile, err := os.Create(filepath.Clean(dst))
+	if err != nil {
+		return fmt.Errorf("unable to create %v: %w", dst, err)
+	}
+	var w io.Writer = dstFile
+	var gzWriter *gzip.Writer
+	if gzipOut {
+		gzWriter = gzip.NewWriter(dstFile)
+		w = gzWriter
+	}
+	if _, err := io.WriteString(w, marker); err != nil {
+		_ = dstFile.Close()
+		return fmt.Errorf("unable to write %v: %w", dst, err)
+	}
+	var stats timewindow.Stats
+	if l.window.IsSet() {
+		stats, err = timewindow.Trim(r, w, l.window, time.Local)
+		if err != nil {
+			_ = dstFile.Close()
+			return fmt.Errorf("unable to trim %v to %v: %w", src, l.window, err)
+		}
+	} else if _, err := io.Copy(w, r); err != nil {
+		_ = dstFile.Close()
+		return fmt.Errorf("unable to copy %v to %v: %w", src, dst, err)
+	}
+	if gzWriter != nil {
+		if err := gzWriter.Close(); err != nil {
+			_ = dstFile.Close()
+			return fmt.Errorf("unable to finish gzip %v: %w", dst, err)
+		}
+	}
+	if err := dstFile.Close(); err != nil {
+		return fmt.Errorf("unable to close %v: %w", dst, err)
+	}
+	if !l.window.IsSet() {
+		return nil
+	}
+	if !stats.Timestamped {
+		simplelog.Warningf("no timestamps found in %v, it was collected whole", src)
+	}
+	if stats.LinesKept == 0 {
+		simplelog.Debugf("nothing in %v is inside of %v, it is not collected", src, l.window)
+		if err := os.Remove(filepath.Clean(dst)); err != nil {
+			return fmt.Errorf("unable to remove empty %v: %w", dst, err)
+		}
+		return nil
+	}
+	simplelog.Debugf("kept %v of %v lines of %v inside of %v", stats.LinesKept, stats.LinesRead, src, l.window)
+	return nil
+}
@@ -0,0 +1,120 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logcollect_test
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/logcollect"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/timewindow"
+)
+
+func writeGzip(t *testing.T, loc, text string) {
+	f, err := os.Create(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := gzip.NewWriter(f)
+	if _, err := w.Write([]byte(text)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readGzip(t *testing.T, loc string) string {
+	f, err := os.Open(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestCollectServerLogInWindow(t *testing.T) {
+	// a 20 minute incident over midnight touches yesterday's archive and today's log
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	yesterday := midnight.AddDate(0, 0, -1)
+	stamp := func(t time.Time) string { return t.Format("2006-01-02 15:04:05,000") }
+	logDir := t.TempDir()
+	outDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(logDir, "archive"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	writeGzip(t, filepath.Join(logDir, "archive", "server."+yesterday.Format("2006-01-02")+".0.log.gz"), strings.Join([]string{
+		stamp(midnight.Add(-time.Hour)) + " [main] INFO  an hour before",
+		stamp(midnight.Add(-5*time.Minute)) + " [e1] ERROR boom",
+		"java.lang.IllegalStateException: boom",
+		"\tat com.dremio.Foo.bar(Foo.java:1)",
+		"",
+	}, "\n"))
+	// two days ago is outside of the window and must not be read
+	writeGzip(t, filepath.Join(logDir, "archive", "server."+yesterday.AddDate(0, 0, -1).Format("2006-01-02")+".0.log.gz"), stamp(yesterday.Add(-time.Hour))+" [main] INFO old\n")
+	if err := os.WriteFile(filepath.Join(logDir, "server.log"), []byte(strings.Join([]string{
+		stamp(midnight.Add(5*time.Minute)) + " [e1] INFO  recovered",
+		stamp(midnight.Add(time.Hour)) + " [e1] INFO  an hour after",
+		"",
+	}, "\n")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(logDir, "server.out"), []byte("started\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	window := timewindow.Window{From: midnight.Add(-10 * time.Minute), To: midnight.Add(10 * time.Minute)}
+	collector := logcollect.NewLogCollectorWithWindow(logDir, outDir, "", "", t.TempDir(), 2, 2, window)
+	if err := collector.RunCollectDremioServerLog(); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	expectedNames := []string{"server." + yesterday.Format("2006-01-02") + ".0.log.gz", "server.log.gz", "server.out"}
+	if strings.Join(names, ",") != strings.Join(expectedNames, ",") {
+		t.Fatalf("expected %v but got %v", expectedNames, names)
+	}
+	archived := readGzip(t, filepath.Join(outDir, expectedNames[0]))
+	expected := stamp(midnight.Add(-5*time.Minute)) + " [e1] ERROR boom\njava.lang.IllegalStateException: boom\n\tat com.dremio.Foo.bar(Foo.java:1)\n"
+	if archived != expected {
+		t.Errorf("expected\n%q\nbut got\n%q", expected, archived)
+	}
+	active := readGzip(t, filepath.Join(outDir, "server.log.gz"))
+	if active != stamp(midnight.Add(5*time.Minute))+" [e1] INFO  recovered\n" {
+		t.Errorf("unexpected server.log %q", active)
+	}
+}
@@ -0,0 +1,346 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodeinfocollect
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Unlimited is used by the limits of CgroupSummary for "max" and the near 2^63 values cgroup v1 reports when there is no limit
+const Unlimited int64 = -1
+
+// v1 reports an unset memory limit as the largest page aligned int64, anything above this is treated as unlimited
+const cgroupV1NoLimit = int64(1) << 62
+
+// CgroupInfo is the cgroup membership of the process and the files of its cgroups
+type CgroupInfo struct {
+	// Version is v1, v2, hybrid or none
+	Version string `json:"version"`
+	// Memberships are the lines of /proc/<pid>/cgroup
+	Memberships []CgroupMembership `json:"memberships,omitempty"`
+	// Summary holds the limits and counters of both hierarchies under the same names
+	Summary *CgroupSummary `json:"summary,omitempty"`
+	// Files are the interesting files of every cgroup of the process keyed by their path under /sys/fs/cgroup
+	Files map[string]string `json:"files,omitempty"`
+}
+
+// CgroupMembership is one line of /proc/<pid>/cgroup, an empty controller list is the v2 hierarchy
+type CgroupMembership struct {
+	HierarchyID int      `json:"hierarchyId"`
+	Controllers []string `json:"controllers,omitempty"`
+	Path        string   `json:"path"`
+}
+
+// CgroupSummary is the container limits of the process whatever the cgroup version.
+// Limits are Unlimited when not set and missing when the controller is not available,
+// v1 values are converted to the v2 units: swap excludes memory and throttling is in microseconds
+type CgroupSummary struct {
+	// Path is the cgroup of the process in the memory hierarchy
+	Path string `json:"path,omitempty"`
+
+	MemoryLimitBytes    *int64  `json:"memoryLimitBytes,omitempty"`
+	MemoryUsageBytes    *uint64 `json:"memoryUsageBytes,omitempty"`
+	MemoryMaxUsageBytes *uint64 `json:"memoryMaxUsageBytes,omitempty"`
+	SwapLimitBytes      *int64  `json:"swapLimitBytes,omitempty"`
+	SwapUsageBytes      *uint64 `json:"swapUsageBytes,omitempty"`
+	// MemoryLimitHits counts how often the usage hit the limit, memory.failcnt on v1 and the max event on v2
+	MemoryLimitHits *uint64 `json:"memoryLimitHits,omitempty"`
+	OOMEvents       *uint64 `json:"oomEvents,omitempty"`
+	OOMKills        *uint64 `json:"oomKills,omitempty"`
+	UnderOOM        bool    `json:"underOom,omitempty"`
+
+	CPUQuotaMicros  *int64  `json:"cpuQuotaMicros,omitempty"`
+	CPUPeriodMicros *uint64 `json:"cpuPeriodMicros,omitempty"`
+	// CPULimit is the quota in cores, 0 when there is no quota
+	CPULimit         float64 `json:"cpuLimit,omitempty"`
+	CPUShares        *uint64 `json:"cpuShares,omitempty"`
+	CPUWeight        *uint64 `json:"cpuWeight,omitempty"`
+	CPUSet           string  `json:"cpuSet,omitempty"`
+	NrPeriods        *uint64 `json:"nrPeriods,omitempty"`
+	NrThrottled      *uint64 `json:"nrThrottled,omitempty"`
+	ThrottledMicros  *uint64 `json:"throttledMicros,omitempty"`
+	ThrottledPercent float64 `json:"throttledPercent,omitempty"`
+
+	PidsMax     *int64  `json:"pidsMax,omitempty"`
+	PidsCurrent *uint64 `json:"pidsCurrent,omitempty"`
+}
+
+// cgroupV1Files are read from every v1 controller the process is a member of
+var cgroupV1Files = map[string][]string{
+	"memory":  {"memory.limit_in_bytes", "memory.usage_in_bytes", "memory.max_usage_in_bytes", "memory.memsw.limit_in_bytes", "memory.memsw.usage_in_bytes", "memory.failcnt", "memory.oom_control", "memory.stat"},
+	"cpu":     {"cpu.cfs_quota_us", "cpu.cfs_period_us", "cpu.shares", "cpu.stat"},
+	"cpuacct": {"cpuacct.usage"},
+	"cpuset":  {"cpuset.cpus", "cpuset.mems"},
+	"pids":    {"pids.max", "pids.current"},
+	"blkio":   {"blkio.throttle.io_service_bytes"},
+}
+
+// cgroupV2Files are read from the v2 cgroup of the process
+var cgroupV2Files = []string{
+	"cgroup.controllers",
+	"memory.current", "memory.peak", "memory.max", "memory.high", "memory.swap.current", "memory.swap.max", "memory.events", "memory.stat", "memory.pressure",
+	"cpu.max", "cpu.weight", "cpu.stat", "cpu.pressure",
+	"cpuset.cpus.effective",
+	"io.max", "io.pressure",
+	"pids.max", "pids.current",
+}
+
+func (r *OSReader) cgroup(proc string) CgroupInfo {
+	info := CgroupInfo{Version: "none"}
+	switch {
+	case r.exists("/sys/fs/cgroup/cgroup.controllers"):
+		info.Version = "v2"
+	case r.exists("/sys/fs/cgroup/unified/cgroup.controllers"):
+		info.Version = "hybrid"
+	case r.exists("/sys/fs/cgroup"):
+		info.Version = "v1"
+	}
+	s, ok := r.read(proc + "/cgroup")
+	if !ok {
+		return info
+	}
+	info.Memberships = ParseCgroupMemberships(s)
+	files := make(map[string]string)
+	v1 := make(map[string]string)
+	v2 := make(map[string]string)
+	var v1Path, v2Path string
+	for _, m := range info.Memberships {
+		if len(m.Controllers) == 0 {
+			base := "/sys/fs/cgroup"
+			if info.Version == "hybrid" {
+				base = "/sys/fs/cgroup/unified"
+			}
+			v2Path = m.Path
+			r.readCgroupFiles(files, v2, r.cgroupDir(base, m.Path), cgroupV2Files)
+			continue
+		}
+		for _, controller := range m.Controllers {
+			names, ok := cgroupV1Files[controller]
+			if !ok {
+				continue
+			}
+			if controller == "memory" {
+				v1Path = m.Path
+			}
+			// co-mounted controllers such as cpu,cpuacct are found under the joined name and usually a symlink per controller
+			base := "/sys/fs/cgroup/" + controller
+			if !r.exists(base) {
+				base = "/sys/fs/cgroup/" + strings.Join(m.Controllers, ",")
+			}
+			r.readCgroupFiles(files, v1, r.cgroupDir(base, m.Path), names)
+		}
+	}
+	if len(files) > 0 {
+		info.Files = files
+	}
+	if len(v1) > 0 || len(v2) > 0 {
+		// on hybrid hosts the controllers are bound to v1 and the unified hierarchy only tracks membership
+		summary := SummarizeCgroupV2(v2)
+		summary.Path = v2Path
+		if len(v1) > 0 {
+			summary = SummarizeCgroupV1(v1)
+			summary.Path = v1Path
+		}
+		info.Summary = &summary
+	}
+	return info
+}
+
+// cgroupDir resolves the cgroup path of the process, inside a container the cgroup namespace
+// usually mounts the own cgroup at the root so a path that does not exist falls back to the mount
+func (r *OSReader) cgroupDir(base, p string) string {
+	dir := filepath.Join(base, p)
+	if p != "/" && r.exists(dir) {
+		return dir
+	}
+	return base
+}
+
+// readCgroupFiles adds the files found in dir to files by path and to values by name
+func (r *OSReader) readCgroupFiles(files, values map[string]string, dir string, names []string) {
+	for _, name := range names {
+		p := filepath.Join(dir, name)
+		if s, ok := r.read(p); ok {
+			s = strings.TrimSpace(s)
+			files[strings.TrimPrefix(p, "/sys/fs/cgroup/")] = s
+			values[name] = s
+		}
+	}
+}
+
+// SummarizeCgroupV1 builds the summary from v1 file contents keyed by file name
+func SummarizeCgroupV1(values map[string]string) CgroupSummary {
+	var s CgroupSummary
+	s.MemoryLimitBytes = v1Limit(values["memory.limit_in_bytes"])
+	s.MemoryUsageBytes = parseCounter(values["memory.usage_in_bytes"])
+	s.MemoryMaxUsageBytes = parseCounter(values["memory.max_usage_in_bytes"])
+	// memsw is memory plus swap
+	if memsw := v1Limit(values["memory.memsw.limit_in_bytes"]); memsw != nil && s.MemoryLimitBytes != nil {
+		swap := Unlimited
+		if *memsw != Unlimited && *s.MemoryLimitBytes != Unlimited {
+			swap = *memsw - *s.MemoryLimitBytes
+		}
+		s.SwapLimitBytes = &swap
+	}
+	if memsw := parseCounter(values["memory.memsw.usage_in_bytes"]); memsw != nil && s.MemoryUsageBytes != nil && *memsw >= *s.MemoryUsageBytes {
+		swap := *memsw - *s.MemoryUsageBytes
+		s.SwapUsageBytes = &swap
+	}
+	s.MemoryLimitHits = parseCounter(values["memory.failcnt"])
+	oom := parseFlatKeyed(values["memory.oom_control"])
+	s.OOMKills = oom["oom_kill"]
+	s.UnderOOM = oom["under_oom"] != nil && *oom["under_oom"] > 0
+
+	if quota, err := strconv.ParseInt(values["cpu.cfs_quota_us"], 10, 64); err == nil {
+		if quota < 0 {
+			quota = Unlimited
+		}
+		s.CPUQuotaMicros = &quota
+	}
+	s.CPUPeriodMicros = parseCounter(values["cpu.cfs_period_us"])
+	s.CPUShares = parseCounter(values["cpu.shares"])
+	s.CPUSet = values["cpuset.cpus"]
+	stat := parseFlatKeyed(values["cpu.stat"])
+	s.NrPeriods = stat["nr_periods"]
+	s.NrThrottled = stat["nr_throttled"]
+	if ns := stat["throttled_time"]; ns != nil {
+		us := *ns / 1000
+		s.ThrottledMicros = &us
+	}
+
+	s.PidsMax = v2Limit(values["pids.max"])
+	s.PidsCurrent = parseCounter(values["pids.current"])
+	s.derive()
+	return s
+}
+
+// SummarizeCgroupV2 builds the summary from v2 file contents keyed by file name
+func SummarizeCgroupV2(values map[string]string) CgroupSummary {
+	var s CgroupSummary
+	s.MemoryLimitBytes = v2Limit(values["memory.max"])
+	s.MemoryUsageBytes = parseCounter(values["memory.current"])
+	s.MemoryMaxUsageBytes = parseCounter(values["memory.peak"])
+	s.SwapLimitBytes = v2Limit(values["memory.swap.max"])
+	s.SwapUsageBytes = parseCounter(values["memory.swap.current"])
+	events := parseFlatKeyed(values["memory.events"])
+	s.MemoryLimitHits = events["max"]
+	s.OOMEvents = events["oom"]
+	s.OOMKills = events["oom_kill"]
+
+	// cpu.max is "$MAX $PERIOD"
+	if fields := strings.Fields(values["cpu.max"]); len(fields) == 2 {
+		s.CPUQuotaMicros = v2Limit(fields[0])
+		s.CPUPeriodMicros = parseCounter(fields[1])
+	}
+	s.CPUWeight = parseCounter(values["cpu.weight"])
+	s.CPUSet = values["cpuset.cpus.effective"]
+	stat := parseFlatKeyed(values["cpu.stat"])
+	s.NrPeriods = stat["nr_periods"]
+	s.NrThrottled = stat["nr_throttled"]
+	s.ThrottledMicros = stat["throttled_usec"]
+
+	s.PidsMax = v2Limit(values["pids.max"])
+	s.PidsCurrent = parseCounter(values["pids.current"])
+	s.derive()
+	return s
+}
+
+// derive fills in the values computed from the others
+func (s *CgroupSummary) derive() {
+	if s.CPUQuotaMicros != nil && *s.CPUQuotaMicros > 0 && s.CPUPeriodMicros != nil && *s.CPUPeriodMicros > 0 {
+		s.CPULimit = float64(*s.CPUQuotaMicros) / float64(*s.CPUPeriodMicros)
+	}
+	if s.NrPeriods != nil && *s.NrPeriods > 0 && s.NrThrottled != nil {
+		s.ThrottledPercent = float64(*s.NrThrottled) * 100 / float64(*s.NrPeriods)
+	}
+}
+
+func parseCounter(s string) *uint64 {
+	v, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// v2Limit parses a limit that is a number or "max"
+func v2Limit(s string) *int64 {
+	s = strings.TrimSpace(s)
+	if s == "max" {
+		v := Unlimited
+		return &v
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// v1Limit parses a v1 memory limit where no limit is a value close to 2^63
+func v1Limit(s string) *int64 {
+	v := v2Limit(s)
+	if v != nil && (*v >= cgroupV1NoLimit || *v < 0) {
+		u := Unlimited
+		return &u
+	}
+	return v
+}
+
+// parseFlatKeyed parses the "key value" lines of files such as cpu.stat and memory.events
+func parseFlatKeyed(text string) map[string]*uint64 {
+	out := make(map[string]*uint64)
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if v := parseCounter(fields[1]); v != nil {
+			out[fields[0]] = v
+		}
+	}
+	return out
+}
+
+// ParseCgroupMemberships parses /proc/<pid>/cgroup
+func ParseCgroupMemberships(text string) []CgroupMembership {
+	var memberships []CgroupMembership
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		id, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
+		}
+		m := CgroupMembership{HierarchyID: id, Path: parts[2]}
+		for _, c := range strings.Split(parts[1], ",") {
+			// named hierarchies such as name=systemd have no files we want
+			if c != "" && !strings.HasPrefix(c, "name=") {
+				m.Controllers = append(m.Controllers, c)
+			}
+		}
+		if parts[1] != "" && len(m.Controllers) == 0 {
+			continue
+		}
+		sort.Strings(m.Controllers)
+		memberships = append(memberships, m)
+	}
+	return memberships
+}
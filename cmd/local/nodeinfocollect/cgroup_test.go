@@ -0,0 +1,123 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodeinfocollect_test
+
+import (
+	"testing"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/nodeinfocollect"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/helpers"
+)
+
+func TestSummarizeCgroupV1(t *testing.T) {
+	s := nodeinfocollect.SummarizeCgroupV1(map[string]string{
+		"memory.limit_in_bytes":       "4294967296",
+		"memory.usage_in_bytes":       "1073741824",
+		"memory.max_usage_in_bytes":   "2147483648",
+		"memory.memsw.limit_in_bytes": "9223372036854771712",
+		"memory.memsw.usage_in_bytes": "1073745920",
+		"memory.failcnt":              "17",
+		"memory.oom_control":          "oom_kill_disable 0\nunder_oom 0\noom_kill 2",
+		"cpu.cfs_quota_us":            "150000",
+		"cpu.cfs_period_us":           "100000",
+		"cpu.shares":                  "1024",
+		"cpu.stat":                    "nr_periods 200\nnr_throttled 50\nthrottled_time 3000000",
+		"pids.max":                    "max",
+		"pids.current":                "312",
+	})
+	if s.MemoryLimitBytes == nil || *s.MemoryLimitBytes != 4294967296 {
+		t.Errorf("unexpected memory limit %v", s.MemoryLimitBytes)
+	}
+	if s.SwapLimitBytes == nil || *s.SwapLimitBytes != nodeinfocollect.Unlimited {
+		t.Errorf("expected unlimited swap but got %v", s.SwapLimitBytes)
+	}
+	if s.SwapUsageBytes == nil || *s.SwapUsageBytes != 4096 {
+		t.Errorf("expected swap usage without the memory usage but got %v", s.SwapUsageBytes)
+	}
+	if s.OOMKills == nil || *s.OOMKills != 2 || s.UnderOOM || s.MemoryLimitHits == nil || *s.MemoryLimitHits != 17 {
+		t.Errorf("unexpected oom values %v %v %v", s.OOMKills, s.UnderOOM, s.MemoryLimitHits)
+	}
+	if s.CPULimit != 1.5 || s.CPUShares == nil || *s.CPUShares != 1024 {
+		t.Errorf("unexpected cpu limit %v shares %v", s.CPULimit, s.CPUShares)
+	}
+	if s.ThrottledMicros == nil || *s.ThrottledMicros != 3000 || s.ThrottledPercent != 25 {
+		t.Errorf("unexpected throttling %v %v", s.ThrottledMicros, s.ThrottledPercent)
+	}
+	if s.PidsMax == nil || *s.PidsMax != nodeinfocollect.Unlimited || s.PidsCurrent == nil || *s.PidsCurrent != 312 {
+		t.Errorf("unexpected pids %v %v", s.PidsMax, s.PidsCurrent)
+	}
+}
+
+func TestSummarizeCgroupV2(t *testing.T) {
+	s := nodeinfocollect.SummarizeCgroupV2(map[string]string{
+		"memory.max":          "max",
+		"memory.current":      "52428800",
+		"memory.swap.max":     "0",
+		"memory.swap.current": "0",
+		"memory.events":       "low 0\nhigh 0\nmax 4\noom 1\noom_kill 1\noom_group_kill 0",
+		"cpu.max":             "max 100000",
+		"cpu.weight":          "100",
+		"cpu.stat":            "usage_usec 1000\nnr_periods 0\nnr_throttled 0\nthrottled_usec 0",
+		"pids.max":            "4096",
+	})
+	if s.MemoryLimitBytes == nil || *s.MemoryLimitBytes != nodeinfocollect.Unlimited {
+		t.Errorf("expected unlimited memory but got %v", s.MemoryLimitBytes)
+	}
+	if s.SwapLimitBytes == nil || *s.SwapLimitBytes != 0 {
+		t.Errorf("expected no swap but got %v", s.SwapLimitBytes)
+	}
+	if s.OOMEvents == nil || *s.OOMEvents != 1 || s.OOMKills == nil || *s.OOMKills != 1 || s.MemoryLimitHits == nil || *s.MemoryLimitHits != 4 {
+		t.Errorf("unexpected memory events %v %v %v", s.OOMEvents, s.OOMKills, s.MemoryLimitHits)
+	}
+	if s.CPUQuotaMicros == nil || *s.CPUQuotaMicros != nodeinfocollect.Unlimited || s.CPULimit != 0 {
+		t.Errorf("expected no cpu quota but got %v %v", s.CPUQuotaMicros, s.CPULimit)
+	}
+	if s.ThrottledPercent != 0 || s.NrPeriods == nil {
+		t.Errorf("unexpected throttling %v %v", s.NrPeriods, s.ThrottledPercent)
+	}
+	if s.PidsMax == nil || *s.PidsMax != 4096 || s.PidsCurrent != nil {
+		t.Errorf("unexpected pids %v %v", s.PidsMax, s.PidsCurrent)
+	}
+}
+
+func TestOSReaderCgroupHybridUsesV1Controllers(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"proc/5/cgroup": "4:memory:/system.slice/dremio.service\n3:cpu,cpuacct:/system.slice/dremio.service\n0::/system.slice/dremio.service\n",
+		"sys/fs/cgroup/unified/cgroup.controllers":                               "\n",
+		"sys/fs/cgroup/unified/system.slice/dremio.service/pids.current":         "90\n",
+		"sys/fs/cgroup/memory/system.slice/dremio.service/memory.limit_in_bytes": "8589934592\n",
+		"sys/fs/cgroup/cpu,cpuacct/system.slice/dremio.service/cpu.cfs_quota_us": "-1\n",
+	})
+	info := nodeinfocollect.NewOSReader(helpers.NewFakeFileSystem(), root).Collect(5)
+	if info.Cgroup.Version != "hybrid" {
+		t.Fatalf("expected hybrid but got %v", info.Cgroup.Version)
+	}
+	s := info.Cgroup.Summary
+	if s == nil {
+		t.Fatal("expected a summary")
+	}
+	if s.Path != "/system.slice/dremio.service" {
+		t.Errorf("unexpected path %v", s.Path)
+	}
+	if s.MemoryLimitBytes == nil || *s.MemoryLimitBytes != 8589934592 {
+		t.Errorf("unexpected memory limit %v", s.MemoryLimitBytes)
+	}
+	if s.CPUQuotaMicros == nil || *s.CPUQuotaMicros != nodeinfocollect.Unlimited {
+		t.Errorf("unexpected cpu quota %v", s.CPUQuotaMicros)
+	}
+	if info.Cgroup.Files["unified/system.slice/dremio.service/pids.current"] != "90" {
+		t.Errorf("expected the unified files to be collected %v", info.Cgroup.Files)
+	}
+}
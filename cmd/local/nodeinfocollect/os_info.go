@@ -0,0 +1,514 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodeinfocollect
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/helpers"
+)
+
+// OSInfoFile is the machine readable output of the os collector
+const OSInfoFile = "os.json"
+
+// OSInfoTextFile is the human readable output of the os collector
+const OSInfoTextFile = "os_info.txt"
+
+// OSInfo is everything read from /proc, /sys and /etc about the host and the dremio process
+type OSInfo struct {
+	Hostname      string               `json:"hostname,omitempty"`
+	Kernel        string               `json:"kernel,omitempty"`
+	KernelVersion string               `json:"kernelVersion,omitempty"`
+	Release       map[string]string    `json:"release,omitempty"`
+	CPU           CPUInfo              `json:"cpu"`
+	MemInfo       map[string]uint64    `json:"memInfoBytes,omitempty"`
+	LoadAvg       *LoadAvg             `json:"loadAvg,omitempty"`
+	VMStat        map[string]uint64    `json:"vmstat,omitempty"`
+	Swappiness    *int                 `json:"swappiness,omitempty"`
+	THP           TransparentHugePages `json:"transparentHugePages"`
+	Mounts        []Mount              `json:"mounts,omitempty"`
+	BlockDevices  []BlockDevice        `json:"blockDevices,omitempty"`
+	DiskStats     []DiskStat           `json:"diskStats,omitempty"`
+	Cgroup        CgroupInfo           `json:"cgroup"`
+	PID           int                  `json:"pid,omitempty"`
+	Cmdline       []string             `json:"cmdline,omitempty"`
+	Limits        []Limit              `json:"limits,omitempty"`
+	// Errors lists every source that could not be read, the rest of the collection carries on without it
+	Errors []string `json:"errors,omitempty"`
+}
+
+// CPUInfo summarizes /proc/cpuinfo the way lscpu does
+type CPUInfo struct {
+	ModelName      string   `json:"modelName,omitempty"`
+	Vendor         string   `json:"vendor,omitempty"`
+	LogicalCPUs    int      `json:"logicalCpus"`
+	Sockets        int      `json:"sockets,omitempty"`
+	CoresPerSocket int      `json:"coresPerSocket,omitempty"`
+	MHz            float64  `json:"mhz,omitempty"`
+	CacheSize      string   `json:"cacheSize,omitempty"`
+	Flags          []string `json:"flags,omitempty"`
+}
+
+// LoadAvg is /proc/loadavg
+type LoadAvg struct {
+	One       float64 `json:"one"`
+	Five      float64 `json:"five"`
+	Fifteen   float64 `json:"fifteen"`
+	Running   int     `json:"running"`
+	Processes int     `json:"processes"`
+}
+
+// TransparentHugePages holds the selected THP modes
+type TransparentHugePages struct {
+	Enabled string `json:"enabled,omitempty"`
+	Defrag  string `json:"defrag,omitempty"`
+}
+
+// Mount is one line of /proc/mounts
+type Mount struct {
+	Device     string `json:"device"`
+	MountPoint string `json:"mountPoint"`
+	FSType     string `json:"fsType"`
+	Options    string `json:"options"`
+}
+
+// BlockDevice is a device of /sys/block
+type BlockDevice struct {
+	Name       string `json:"name"`
+	SizeBytes  uint64 `json:"sizeBytes"`
+	Rotational bool   `json:"rotational"`
+	Removable  bool   `json:"removable"`
+	Scheduler  string `json:"scheduler,omitempty"`
+}
+
+// DiskStat is one line of /proc/diskstats
+type DiskStat struct {
+	Major           int    `json:"major"`
+	Minor           int    `json:"minor"`
+	Name            string `json:"name"`
+	ReadsCompleted  uint64 `json:"readsCompleted"`
+	SectorsRead     uint64 `json:"sectorsRead"`
+	ReadTimeMs      uint64 `json:"readTimeMs"`
+	WritesCompleted uint64 `json:"writesCompleted"`
+	SectorsWritten  uint64 `json:"sectorsWritten"`
+	WriteTimeMs     uint64 `json:"writeTimeMs"`
+	IOInProgress    uint64 `json:"ioInProgress"`
+	IOTimeMs        uint64 `json:"ioTimeMs"`
+}
+
+// Limit is a line of /proc/<pid>/limits
+type Limit struct {
+	Name  string `json:"name"`
+	Soft  string `json:"soft"`
+	Hard  string `json:"hard"`
+	Units string `json:"units,omitempty"`
+}
+
+// OSReader reads the os information below a root dir, which is / outside of tests
+type OSReader struct {
+	fs   helpers.Filesystem
+	root string
+	errs []string
+}
+
+// NewOSReader reads through fs with every path prefixed by root
+func NewOSReader(fs helpers.Filesystem, root string) *OSReader {
+	if root == "" {
+		root = "/"
+	}
+	return &OSReader{fs: fs, root: root}
+}
+
+func (r *OSReader) path(p string) string {
+	return filepath.Join(r.root, p)
+}
+
+// read returns the content of p, failures other than a missing file are recorded in the errors
+func (r *OSReader) read(p string) (string, bool) {
+	b, err := r.fs.ReadFile(r.path(p))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			r.errs = append(r.errs, fmt.Sprintf("unable to read %v: %v", p, err))
+		}
+		return "", false
+	}
+	return string(b), true
+}
+
+func (r *OSReader) readTrimmed(p string) string {
+	s, _ := r.read(p)
+	return strings.TrimSpace(s)
+}
+
+func (r *OSReader) exists(p string) bool {
+	_, err := r.fs.Stat(r.path(p))
+	return err == nil
+}
+
+// Collect reads everything, pid 0 skips the process specific parts and uses the cgroups of the collector
+func (r *OSReader) Collect(pid int) OSInfo {
+	r.errs = nil
+	info := OSInfo{
+		Hostname:      r.readTrimmed("/proc/sys/kernel/hostname"),
+		Kernel:        r.readTrimmed("/proc/sys/kernel/osrelease"),
+		KernelVersion: r.readTrimmed("/proc/sys/kernel/version"),
+		Release:       r.release(),
+		PID:           pid,
+	}
+	if s, ok := r.read("/proc/cpuinfo"); ok {
+		info.CPU = ParseCPUInfo(s)
+	}
+	if s, ok := r.read("/proc/meminfo"); ok {
+		info.MemInfo = ParseMemInfo(s)
+	}
+	if s, ok := r.read("/proc/loadavg"); ok {
+		info.LoadAvg = ParseLoadAvg(s)
+	}
+	if s, ok := r.read("/proc/vmstat"); ok {
+		info.VMStat = parseKeyValues(s)
+	}
+	if s := r.readTrimmed("/proc/sys/vm/swappiness"); s != "" {
+		if v, err := strconv.Atoi(s); err == nil {
+			info.Swappiness = &v
+		}
+	}
+	info.THP = TransparentHugePages{
+		Enabled: selectedMode(r.readTrimmed("/sys/kernel/mm/transparent_hugepage/enabled")),
+		Defrag:  selectedMode(r.readTrimmed("/sys/kernel/mm/transparent_hugepage/defrag")),
+	}
+	if s, ok := r.read("/proc/mounts"); ok {
+		info.Mounts = ParseMounts(s)
+	}
+	info.BlockDevices = r.blockDevices()
+	if s, ok := r.read("/proc/diskstats"); ok {
+		info.DiskStats = ParseDiskStats(s)
+	}
+	proc := "/proc/self"
+	if pid > 0 {
+		proc = fmt.Sprintf("/proc/%d", pid)
+		if s, ok := r.read(proc + "/cmdline"); ok {
+			info.Cmdline = strings.FieldsFunc(s, func(c rune) bool { return c == 0 })
+		}
+		if s, ok := r.read(proc + "/limits"); ok {
+			info.Limits = ParseLimits(s)
+		}
+	}
+	info.Cgroup = r.cgroup(proc)
+	info.Errors = r.errs
+	return info
+}
+
+// release reads /etc/os-release and falls back to the other release files of older distributions
+func (r *OSReader) release() map[string]string {
+	for _, p := range []string{"/etc/os-release", "/usr/lib/os-release"} {
+		if s, ok := r.read(p); ok {
+			return ParseOSRelease(s)
+		}
+	}
+	for _, p := range []string{"/etc/redhat-release", "/etc/centos-release", "/etc/system-release", "/etc/lsb-release"} {
+		if s := r.readTrimmed(p); s != "" {
+			return map[string]string{"PRETTY_NAME": strings.SplitN(s, "\n", 2)[0]}
+		}
+	}
+	return nil
+}
+
+func (r *OSReader) blockDevices() []BlockDevice {
+	entries, err := r.fs.ReadDir(r.path("/sys/block"))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			r.errs = append(r.errs, fmt.Sprintf("unable to read /sys/block: %v", err))
+		}
+		return nil
+	}
+	var devices []BlockDevice
+	for _, e := range entries {
+		name := e.Name()
+		// loop and ram devices are noise on most hosts
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") {
+			continue
+		}
+		dir := "/sys/block/" + name
+		d := BlockDevice{
+			Name:       name,
+			Rotational: r.readTrimmed(dir+"/queue/rotational") == "1",
+			Removable:  r.readTrimmed(dir+"/removable") == "1",
+			Scheduler:  selectedMode(r.readTrimmed(dir + "/queue/scheduler")),
+		}
+		// size is always in 512 byte sectors whatever the block size of the device
+		if sectors, err := strconv.ParseUint(r.readTrimmed(dir+"/size"), 10, 64); err == nil {
+			d.SizeBytes = sectors * 512
+		}
+		devices = append(devices, d)
+	}
+	return devices
+}
+
+// ParseCPUInfo summarizes the processors of /proc/cpuinfo
+func ParseCPUInfo(text string) CPUInfo {
+	var info CPUInfo
+	sockets := make(map[string]bool)
+	cores := make(map[string]bool)
+	var physicalID string
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		switch key {
+		case "processor":
+			info.LogicalCPUs++
+			physicalID = ""
+		case "model name":
+			info.ModelName = value
+		case "vendor_id":
+			info.Vendor = value
+		case "cpu MHz":
+			if v, err := strconv.ParseFloat(value, 64); err == nil && v > info.MHz {
+				info.MHz = v
+			}
+		case "cache size":
+			info.CacheSize = value
+		case "physical id":
+			physicalID = value
+			sockets[value] = true
+		case "core id":
+			cores[physicalID+"/"+value] = true
+		case "flags", "Features":
+			if info.Flags == nil {
+				info.Flags = strings.Fields(value)
+			}
+		}
+	}
+	info.Sockets = len(sockets)
+	if info.Sockets > 0 {
+		info.CoresPerSocket = len(cores) / info.Sockets
+	}
+	return info
+}
+
+// ParseMemInfo returns the values of /proc/meminfo in bytes, values without a unit such as HugePages_Total are kept as is
+func ParseMemInfo(text string) map[string]uint64 {
+	out := make(map[string]uint64)
+	for _, line := range strings.Split(text, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) > 1 && fields[1] == "kB" {
+			v *= 1024
+		}
+		out[strings.TrimSpace(key)] = v
+	}
+	return out
+}
+
+// ParseLoadAvg parses /proc/loadavg, nil when the format is not understood
+func ParseLoadAvg(text string) *LoadAvg {
+	fields := strings.Fields(text)
+	if len(fields) < 4 {
+		return nil
+	}
+	var l LoadAvg
+	var err error
+	if l.One, err = strconv.ParseFloat(fields[0], 64); err != nil {
+		return nil
+	}
+	if l.Five, err = strconv.ParseFloat(fields[1], 64); err != nil {
+		return nil
+	}
+	if l.Fifteen, err = strconv.ParseFloat(fields[2], 64); err != nil {
+		return nil
+	}
+	running, total, _ := strings.Cut(fields[3], "/")
+	l.Running, _ = strconv.Atoi(running)
+	l.Processes, _ = strconv.Atoi(total)
+	return &l
+}
+
+// parseKeyValues parses files of "name value" lines such as /proc/vmstat
+func parseKeyValues(text string) map[string]uint64 {
+	out := make(map[string]uint64)
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			out[fields[0]] = v
+		}
+	}
+	return out
+}
+
+// selectedMode returns the bracketed entry of sysfs choices like "always [madvise] never",
+// a value without brackets is returned as is
+func selectedMode(s string) string {
+	start := strings.Index(s, "[")
+	end := strings.Index(s, "]")
+	if start >= 0 && end > start {
+		return s[start+1 : end]
+	}
+	return s
+}
+
+// ParseOSRelease parses the KEY=value lines of os-release
+func ParseOSRelease(text string) map[string]string {
+	out := make(map[string]string)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else {
+			value = strings.Trim(value, `"'`)
+		}
+		out[key] = value
+	}
+	return out
+}
+
+// unescapeMount decodes the octal escapes the kernel uses for spaces and tabs in /proc/mounts
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// ParseMounts parses /proc/mounts
+func ParseMounts(text string) []Mount {
+	var mounts []Mount
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		mounts = append(mounts, Mount{
+			Device:     unescapeMount(fields[0]),
+			MountPoint: unescapeMount(fields[1]),
+			FSType:     fields[2],
+			Options:    fields[3],
+		})
+	}
+	return mounts
+}
+
+// ParseDiskStats parses /proc/diskstats skipping loop and ram devices
+func ParseDiskStats(text string) []DiskStat {
+	var stats []DiskStat
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 14 {
+			continue
+		}
+		name := fields[2]
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") {
+			continue
+		}
+		n := func(i int) uint64 {
+			v, _ := strconv.ParseUint(fields[i], 10, 64)
+			return v
+		}
+		major, _ := strconv.Atoi(fields[0])
+		minor, _ := strconv.Atoi(fields[1])
+		stats = append(stats, DiskStat{
+			Major:           major,
+			Minor:           minor,
+			Name:            name,
+			ReadsCompleted:  n(3),
+			SectorsRead:     n(5),
+			ReadTimeMs:      n(6),
+			WritesCompleted: n(7),
+			SectorsWritten:  n(9),
+			WriteTimeMs:     n(10),
+			IOInProgress:    n(11),
+			IOTimeMs:        n(12),
+		})
+	}
+	return stats
+}
+
+// ParseLimits parses the fixed width table of /proc/<pid>/limits
+func ParseLimits(text string) []Limit {
+	lines := strings.Split(text, "\n")
+	if len(lines) == 0 {
+		return nil
+	}
+	header := lines[0]
+	softAt := strings.Index(header, "Soft Limit")
+	hardAt := strings.Index(header, "Hard Limit")
+	unitsAt := strings.Index(header, "Units")
+	if softAt < 0 || hardAt < softAt || unitsAt < hardAt {
+		return nil
+	}
+	column := func(line string, from, to int) string {
+		if from >= len(line) {
+			return ""
+		}
+		if to < 0 || to > len(line) {
+			to = len(line)
+		}
+		return strings.TrimSpace(line[from:to])
+	}
+	var limits []Limit
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		limits = append(limits, Limit{
+			Name:  column(line, 0, softAt),
+			Soft:  column(line, softAt, hardAt),
+			Hard:  column(line, hardAt, unitsAt),
+			Units: column(line, unitsAt, -1),
+		})
+	}
+	return limits
+}
@@ -0,0 +1,174 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodeinfocollect_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/nodeinfocollect"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/helpers"
+)
+
+const limits = `Limit                     Soft Limit           Hard Limit           Units     
+Max cpu time              unlimited            unlimited            seconds   
+Max open files            65536                65536                files     
+Max processes             4096                 unlimited            processes 
+`
+
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		loc := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(loc), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(loc, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestOSReaderCgroupV2(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"etc/os-release":                             "NAME=\"Ubuntu\"\nVERSION_ID=\"22.04\"\nPRETTY_NAME=\"Ubuntu 22.04.4 LTS\"\n",
+		"proc/sys/kernel/hostname":                   "dremio-master-0\n",
+		"proc/sys/kernel/osrelease":                  "5.15.0-1057-aws\n",
+		"proc/sys/vm/swappiness":                     "60\n",
+		"proc/loadavg":                               "0.52 0.58 0.59 3/467 1234\n",
+		"proc/meminfo":                               "MemTotal:       16318948 kB\nMemFree:          246304 kB\nHugePages_Total:       0\n",
+		"proc/vmstat":                                "nr_free_pages 61576\npgmajfault 12\n",
+		"proc/cpuinfo":                               "processor\t: 0\nvendor_id\t: GenuineIntel\nmodel name\t: Intel(R) Xeon(R)\ncpu MHz\t\t: 2500.000\nphysical id\t: 0\ncore id\t\t: 0\nflags\t\t: fpu avx2\n\nprocessor\t: 1\nvendor_id\t: GenuineIntel\nmodel name\t: Intel(R) Xeon(R)\ncpu MHz\t\t: 2500.000\nphysical id\t: 0\ncore id\t\t: 1\nflags\t\t: fpu avx2\n",
+		"proc/mounts":                                "overlay / overlay rw,relatime 0 0\n/dev/nvme1n1 /opt/dremio/data\\040dir ext4 rw 0 0\n",
+		"proc/diskstats":                             "   7       0 loop0 1 0 2 0 0 0 0 0 0 0 0 0 0 0 0 0 0\n 259       0 nvme0n1 1000 10 20000 300 500 20 8000 400 0 600 700 0 0 0 0 0 0\n",
+		"proc/42/cmdline":                            "java\x00-Xmx4g\x00com.dremio.dac.daemon.DremioDaemon\x00",
+		"proc/42/limits":                             limits,
+		"proc/42/cgroup":                             "0::/\n",
+		"sys/block/nvme0n1/size":                     "209715200\n",
+		"sys/block/nvme0n1/removable":                "0\n",
+		"sys/block/nvme0n1/queue/rotational":         "0\n",
+		"sys/block/nvme0n1/queue/scheduler":          "[none] mq-deadline\n",
+		"sys/block/loop0/size":                       "0\n",
+		"sys/kernel/mm/transparent_hugepage/enabled": "always [madvise] never\n",
+		"sys/kernel/mm/transparent_hugepage/defrag":  "always defer defer+madvise [madvise] never\n",
+		"sys/fs/cgroup/cgroup.controllers":           "cpuset cpu io memory pids\n",
+		"sys/fs/cgroup/memory.max":                   "8589934592\n",
+		"sys/fs/cgroup/cpu.max":                      "200000 100000\n",
+	})
+	info := nodeinfocollect.NewOSReader(helpers.NewFakeFileSystem(), root).Collect(42)
+	if info.Hostname != "dremio-master-0" || info.Kernel != "5.15.0-1057-aws" {
+		t.Errorf("unexpected hostname %q and kernel %q", info.Hostname, info.Kernel)
+	}
+	if info.Release["PRETTY_NAME"] != "Ubuntu 22.04.4 LTS" {
+		t.Errorf("unexpected release %v", info.Release)
+	}
+	if info.CPU.LogicalCPUs != 2 || info.CPU.Sockets != 1 || info.CPU.CoresPerSocket != 2 || info.CPU.ModelName != "Intel(R) Xeon(R)" {
+		t.Errorf("unexpected cpu %+v", info.CPU)
+	}
+	if info.MemInfo["MemTotal"] != 16318948*1024 || info.MemInfo["HugePages_Total"] != 0 {
+		t.Errorf("unexpected meminfo %v", info.MemInfo)
+	}
+	if info.VMStat["pgmajfault"] != 12 {
+		t.Errorf("unexpected vmstat %v", info.VMStat)
+	}
+	if info.Swappiness == nil || *info.Swappiness != 60 {
+		t.Errorf("unexpected swappiness %v", info.Swappiness)
+	}
+	if info.THP.Enabled != "madvise" || info.THP.Defrag != "madvise" {
+		t.Errorf("unexpected thp %+v", info.THP)
+	}
+	if info.LoadAvg == nil || info.LoadAvg.Five != 0.58 || info.LoadAvg.Processes != 467 {
+		t.Errorf("unexpected loadavg %+v", info.LoadAvg)
+	}
+	if len(info.Mounts) != 2 || info.Mounts[1].MountPoint != "/opt/dremio/data dir" {
+		t.Errorf("unexpected mounts %+v", info.Mounts)
+	}
+	if len(info.BlockDevices) != 1 || info.BlockDevices[0].SizeBytes != 209715200*512 || info.BlockDevices[0].Rotational || info.BlockDevices[0].Scheduler != "none" {
+		t.Errorf("unexpected block devices %+v", info.BlockDevices)
+	}
+	if len(info.DiskStats) != 1 || info.DiskStats[0].Name != "nvme0n1" || info.DiskStats[0].SectorsWritten != 8000 || info.DiskStats[0].IOTimeMs != 600 {
+		t.Errorf("unexpected disk stats %+v", info.DiskStats)
+	}
+	if strings.Join(info.Cmdline, " ") != "java -Xmx4g com.dremio.dac.daemon.DremioDaemon" {
+		t.Errorf("unexpected cmdline %v", info.Cmdline)
+	}
+	if len(info.Limits) != 3 || info.Limits[1].Name != "Max open files" || info.Limits[1].Soft != "65536" || info.Limits[2].Hard != "unlimited" || info.Limits[2].Units != "processes" {
+		t.Errorf("unexpected limits %+v", info.Limits)
+	}
+	if info.Cgroup.Version != "v2" {
+		t.Errorf("expected v2 but got %v", info.Cgroup.Version)
+	}
+	if info.Cgroup.Files["memory.max"] != "8589934592" || info.Cgroup.Files["cpu.max"] != "200000 100000" {
+		t.Errorf("unexpected cgroup files %v", info.Cgroup.Files)
+	}
+	if s := info.Cgroup.Summary; s == nil || s.CPULimit != 2 || s.Path != "/" {
+		t.Errorf("unexpected cgroup summary %+v", s)
+	}
+	if len(info.Errors) > 0 {
+		t.Errorf("unexpected errors %v", info.Errors)
+	}
+
+	var b bytes.Buffer
+	if err := nodeinfocollect.WriteOSInfoText(&b, info); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{">>> mount\n", ">>> lsblk\n", "/dev/nvme1n1 on /opt/dremio/data dir type ext4 (rw)", "Max open files", ">>> cat /sys/fs/cgroup/memory.max\n8589934592"} {
+		if !strings.Contains(b.String(), expected) {
+			t.Errorf("expected %q in\n%v", expected, b.String())
+		}
+	}
+}
+
+func TestOSReaderCgroupV1(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"proc/7/cgroup": "12:pids:/kubepods/pod1/abc\n4:cpu,cpuacct:/kubepods/pod1/abc\n3:memory:/kubepods/pod1/abc\n1:name=systemd:/kubepods/pod1/abc\n",
+		"sys/fs/cgroup/memory/memory.limit_in_bytes":    "4294967296\n",
+		"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_quota_us":    "150000\n",
+		"sys/fs/cgroup/pids/kubepods/pod1/abc/pids.max": "max\n",
+	})
+	info := nodeinfocollect.NewOSReader(helpers.NewFakeFileSystem(), root).Collect(7)
+	if info.Cgroup.Version != "v1" {
+		t.Errorf("expected v1 but got %v", info.Cgroup.Version)
+	}
+	if len(info.Cgroup.Memberships) != 3 {
+		t.Errorf("expected the named hierarchy to be skipped %+v", info.Cgroup.Memberships)
+	}
+	// inside of a container the own cgroup is mounted at the controller root
+	if info.Cgroup.Files["memory/memory.limit_in_bytes"] != "4294967296" {
+		t.Errorf("unexpected memory files %v", info.Cgroup.Files)
+	}
+	if info.Cgroup.Files["cpu,cpuacct/cpu.cfs_quota_us"] != "150000" {
+		t.Errorf("unexpected cpu files %v", info.Cgroup.Files)
+	}
+	if info.Cgroup.Files["pids/kubepods/pod1/abc/pids.max"] != "max" {
+		t.Errorf("unexpected pids files %v", info.Cgroup.Files)
+	}
+}
+
+func TestOSReaderMissingFiles(t *testing.T) {
+	info := nodeinfocollect.NewOSReader(helpers.NewFakeFileSystem(), t.TempDir()).Collect(99)
+	if info.Cgroup.Version != "none" || len(info.Errors) != 0 || info.LoadAvg != nil {
+		t.Errorf("expected an empty result without errors for a minimal image but got %+v", info)
+	}
+	var b bytes.Buffer
+	if err := nodeinfocollect.WriteOSInfoText(&b, info); err != nil {
+		t.Fatal(err)
+	}
+}
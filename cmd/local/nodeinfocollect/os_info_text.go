@@ -0,0 +1,224 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodeinfocollect
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/helpers"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+)
+
+// RunCollectOSInfo reads /proc, /sys and /etc without shelling out and writes os.json and os_info.txt
+func RunCollectOSInfo(c *conf.CollectConf) error {
+	simplelog.Debug("Collecting OS Information")
+	info := NewOSReader(helpers.NewRealFileSystem(), "/").Collect(c.DremioPID())
+	for _, e := range info.Errors {
+		simplelog.Warningf("os info: %v", e)
+	}
+	b, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal %v: %w", OSInfoFile, err)
+	}
+	jsonLoc := filepath.Join(c.NodeInfoOutDir(), OSInfoFile)
+	if err := os.WriteFile(jsonLoc, b, 0o600); err != nil {
+		return fmt.Errorf("unable to write %v: %w", jsonLoc, err)
+	}
+	textLoc := filepath.Join(c.NodeInfoOutDir(), OSInfoTextFile)
+	w, err := os.Create(filepath.Clean(textLoc))
+	if err != nil {
+		return fmt.Errorf("unable to create file %v: %w", textLoc, err)
+	}
+	if err := WriteOSInfoText(w, info); err != nil {
+		_ = w.Close()
+		return fmt.Errorf("unable to write %v: %w", textLoc, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("unable to close the %v file: %w", textLoc, err)
+	}
+	simplelog.Debugf("... Collecting OS Information from %v COMPLETED", c.NodeName())
+	return nil
+}
+
+// WriteOSInfoText writes the human readable report, every section starts with the ___ and >>> header lines
+// that os_info.txt always had so the sections of older collections line up
+func WriteOSInfoText(w io.Writer, info OSInfo) error {
+	var b strings.Builder
+	section := func(name string) {
+		fmt.Fprintf(&b, "___\n>>> %v\n", name)
+	}
+
+	section("os release")
+	for _, k := range sortedMapKeys(info.Release) {
+		fmt.Fprintf(&b, "%v=%v\n", k, info.Release[k])
+	}
+	section("kernel")
+	fmt.Fprintf(&b, "%v %v\n", info.Kernel, info.KernelVersion)
+	section("hostname")
+	fmt.Fprintln(&b, info.Hostname)
+
+	section("lscpu")
+	tw := tabwriter.NewWriter(&b, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "CPU(s):\t%v\n", info.CPU.LogicalCPUs)
+	fmt.Fprintf(tw, "Vendor ID:\t%v\n", info.CPU.Vendor)
+	fmt.Fprintf(tw, "Model name:\t%v\n", info.CPU.ModelName)
+	fmt.Fprintf(tw, "Socket(s):\t%v\n", info.CPU.Sockets)
+	fmt.Fprintf(tw, "Core(s) per socket:\t%v\n", info.CPU.CoresPerSocket)
+	fmt.Fprintf(tw, "CPU MHz:\t%.3f\n", info.CPU.MHz)
+	fmt.Fprintf(tw, "Cache size:\t%v\n", info.CPU.CacheSize)
+	fmt.Fprintf(tw, "Flags:\t%v\n", strings.Join(info.CPU.Flags, " "))
+	_ = tw.Flush()
+
+	section("meminfo")
+	for _, k := range sortedMapKeys(info.MemInfo) {
+		fmt.Fprintf(&b, "%v: %v\n", k, info.MemInfo[k])
+	}
+
+	section("loadavg")
+	if l := info.LoadAvg; l != nil {
+		fmt.Fprintf(&b, "%.2f %.2f %.2f %v/%v\n", l.One, l.Five, l.Fifteen, l.Running, l.Processes)
+	}
+
+	section("vm")
+	if info.Swappiness != nil {
+		fmt.Fprintf(&b, "swappiness: %v\n", *info.Swappiness)
+	}
+	fmt.Fprintf(&b, "transparent_hugepage enabled: %v\n", info.THP.Enabled)
+	fmt.Fprintf(&b, "transparent_hugepage defrag: %v\n", info.THP.Defrag)
+	for _, k := range sortedMapKeys(info.VMStat) {
+		fmt.Fprintf(&b, "%v %v\n", k, info.VMStat[k])
+	}
+
+	section("mount")
+	for _, m := range info.Mounts {
+		fmt.Fprintf(&b, "%v on %v type %v (%v)\n", m.Device, m.MountPoint, m.FSType, m.Options)
+	}
+
+	section("lsblk")
+	tw = tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSIZE\tROTA\tRM\tSCHEDULER")
+	for _, d := range info.BlockDevices {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n", d.Name, d.SizeBytes, boolFlag(d.Rotational), boolFlag(d.Removable), d.Scheduler)
+	}
+	_ = tw.Flush()
+
+	section("diskstats")
+	tw = tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tREADS\tSECTORS READ\tREAD MS\tWRITES\tSECTORS WRITTEN\tWRITE MS\tIN PROGRESS\tIO MS")
+	for _, d := range info.DiskStats {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", d.Name, d.ReadsCompleted, d.SectorsRead, d.ReadTimeMs, d.WritesCompleted, d.SectorsWritten, d.WriteTimeMs, d.IOInProgress, d.IOTimeMs)
+	}
+	_ = tw.Flush()
+
+	section("cgroup " + info.Cgroup.Version)
+	for _, m := range info.Cgroup.Memberships {
+		fmt.Fprintf(&b, "%v:%v:%v\n", m.HierarchyID, strings.Join(m.Controllers, ","), m.Path)
+	}
+	if cs := info.Cgroup.Summary; cs != nil {
+		section("cgroup summary")
+		writeCgroupSummary(&b, cs)
+	}
+	for _, k := range sortedMapKeys(info.Cgroup.Files) {
+		fmt.Fprintf(&b, "___\n>>> cat /sys/fs/cgroup/%v\n%v\n", k, info.Cgroup.Files[k])
+	}
+
+	if info.PID > 0 {
+		section(fmt.Sprintf("limits %v", info.PID))
+		tw = tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "LIMIT\tSOFT\tHARD\tUNITS")
+		for _, l := range info.Limits {
+			fmt.Fprintf(tw, "%v\t%v\t%v\t%v\n", l.Name, l.Soft, l.Hard, l.Units)
+		}
+		_ = tw.Flush()
+		section(fmt.Sprintf("cmdline %v", info.PID))
+		fmt.Fprintln(&b, strings.Join(info.Cmdline, " "))
+	}
+
+	if len(info.Errors) > 0 {
+		section("errors")
+		for _, e := range info.Errors {
+			fmt.Fprintln(&b, e)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeCgroupSummary(w io.Writer, s *CgroupSummary) {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "path:\t%v\n", s.Path)
+	fmt.Fprintf(tw, "memory limit:\t%v\n", limitText(s.MemoryLimitBytes))
+	fmt.Fprintf(tw, "memory usage:\t%v\n", counterText(s.MemoryUsageBytes))
+	fmt.Fprintf(tw, "memory max usage:\t%v\n", counterText(s.MemoryMaxUsageBytes))
+	fmt.Fprintf(tw, "swap limit:\t%v\n", limitText(s.SwapLimitBytes))
+	fmt.Fprintf(tw, "swap usage:\t%v\n", counterText(s.SwapUsageBytes))
+	fmt.Fprintf(tw, "memory limit hits:\t%v\n", counterText(s.MemoryLimitHits))
+	fmt.Fprintf(tw, "oom events:\t%v\n", counterText(s.OOMEvents))
+	fmt.Fprintf(tw, "oom kills:\t%v\n", counterText(s.OOMKills))
+	fmt.Fprintf(tw, "under oom:\t%v\n", s.UnderOOM)
+	fmt.Fprintf(tw, "cpu quota us:\t%v\n", limitText(s.CPUQuotaMicros))
+	fmt.Fprintf(tw, "cpu period us:\t%v\n", counterText(s.CPUPeriodMicros))
+	fmt.Fprintf(tw, "cpu limit cores:\t%.2f\n", s.CPULimit)
+	fmt.Fprintf(tw, "cpu shares:\t%v\n", counterText(s.CPUShares))
+	fmt.Fprintf(tw, "cpu weight:\t%v\n", counterText(s.CPUWeight))
+	fmt.Fprintf(tw, "cpuset:\t%v\n", s.CPUSet)
+	fmt.Fprintf(tw, "throttled periods:\t%v of %v (%.2f%%)\n", counterText(s.NrThrottled), counterText(s.NrPeriods), s.ThrottledPercent)
+	fmt.Fprintf(tw, "throttled us:\t%v\n", counterText(s.ThrottledMicros))
+	fmt.Fprintf(tw, "pids max:\t%v\n", limitText(s.PidsMax))
+	fmt.Fprintf(tw, "pids current:\t%v\n", counterText(s.PidsCurrent))
+	_ = tw.Flush()
+}
+
+func limitText(v *int64) string {
+	switch {
+	case v == nil:
+		return "n/a"
+	case *v == Unlimited:
+		return "unlimited"
+	default:
+		return fmt.Sprint(*v)
+	}
+}
+
+func counterText(v *uint64) string {
+	if v == nil {
+		return "n/a"
+	}
+	return fmt.Sprint(*v)
+}
+
+func boolFlag(v bool) string {
+	if v {
+		return "1"
+	}
+	return "0"
+}
+
+func sortedMapKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,214 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// package snapshotcollect takes the captures of an incident snapshot at the instants agreed with every other node
+package snapshotcollect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/apicollect"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/jvmcollect"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/hotspot"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/snapshot"
+)
+
+// ResultFile is written to the snapshot dir with the planned and actual time of every capture
+const ResultFile = "snapshot.json"
+
+// hotThreadsLead is how long before each instant the cpu of the threads starts to be measured
+const hotThreadsLead = time.Second
+
+// RunCollectSnapshot waits for every instant of the snapshot and takes a thread dump with the cpu of the threads,
+// the heap info, the gc counters and on coordinators the threads and memory system tables at once
+func RunCollectSnapshot(c *conf.CollectConf, hook shutdown.CancelHook) error {
+	instants := snapshot.Schedule(c.SnapshotAt(), c.SnapshotCount(), c.SnapshotInterval(), c.SnapshotClockOffset())
+	result := snapshot.Result{
+		Node:              c.NodeName(),
+		PID:               c.DremioPID(),
+		At:                c.SnapshotAt(),
+		Count:             c.SnapshotCount(),
+		IntervalSeconds:   int(c.SnapshotInterval().Seconds()),
+		ClockOffsetMillis: c.SnapshotClockOffset().Milliseconds(),
+		Instants:          []snapshot.Instant{},
+	}
+	simplelog.Infof("incident snapshot of pid %v: %v captures every %v from %v on the clock of this node", c.DremioPID(), len(instants), c.SnapshotInterval(), instants[0].Format(time.RFC3339Nano))
+	var stopErr error
+	for i, planned := range instants {
+		inst, ok := captureInstant(c, hook, i, planned)
+		if !ok {
+			stopErr = fmt.Errorf("snapshot stopped after %v of %v captures: %w", i, len(instants), context.Cause(hook.GetContext()))
+			break
+		}
+		if inst.LateMillis > 1000 {
+			simplelog.Warningf("snapshot capture %v started %vms after its planned time", i, inst.LateMillis)
+		}
+		result.Instants = append(result.Instants, inst)
+	}
+	b, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal %v: %w", ResultFile, err)
+	}
+	loc := filepath.Join(c.SnapshotOutDir(), ResultFile)
+	if err := os.WriteFile(filepath.Clean(loc), b, 0o600); err != nil {
+		return fmt.Errorf("unable to write %v: %w", loc, err)
+	}
+	return stopErr
+}
+
+// captureInstant runs the captures of one instant in parallel so they are as close as possible to it,
+// it returns false when the collection was stopped before the instant
+func captureInstant(c *conf.CollectConf, hook shutdown.CancelHook, index int, planned time.Time) (snapshot.Instant, bool) {
+	inst := snapshot.Instant{Index: index, Planned: planned}
+	if !sleepUntil(hook, planned.Add(-hotThreadsLead)) {
+		return inst, false
+	}
+	sampler, samplerErr := jvmcollect.NewHotThreadsSampler("/proc", c.DremioPID(), c.HotThreadsTopN())
+	if !sleepUntil(hook, planned) {
+		return inst, false
+	}
+	inst.Started = time.Now()
+	inst.LateMillis = inst.Started.Sub(planned).Milliseconds()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	record := func(file string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			simplelog.Warningf("snapshot capture %v: %v", index, err)
+			inst.Errors = append(inst.Errors, err.Error())
+			return
+		}
+		inst.Files = append(inst.Files, file)
+	}
+	run := func(f func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f()
+		}()
+	}
+	name := func(suffix string) string {
+		return fmt.Sprintf("snapshot-%v-%v", index, suffix)
+	}
+	run(func() {
+		var w bytes.Buffer
+		if err := hotspot.Jcmd(hook, &w, c.DremioPID(), "Thread.print -l"); err != nil {
+			record("", fmt.Errorf("unable to capture thread dump: %w", err))
+			return
+		}
+		dumpFile := name("thread-dump.txt")
+		record(dumpFile, writeFile(c, dumpFile, w.Bytes()))
+		if samplerErr != nil {
+			record("", fmt.Errorf("unable to sample the thread cpu: %w", samplerErr))
+			return
+		}
+		if err := sampler.Sample(inst.Started, w.String(), dumpFile); err != nil {
+			record("", fmt.Errorf("unable to sample the thread cpu: %w", err))
+			return
+		}
+		hot := sampler.Result()
+		if len(hot.Samples) == 0 {
+			record("", fmt.Errorf("no thread cpu sample, the capture started %vms late", inst.LateMillis))
+			return
+		}
+		b, err := json.MarshalIndent(hot.Samples[0], "", "  ")
+		if err != nil {
+			record("", fmt.Errorf("unable to marshal hot threads: %w", err))
+			return
+		}
+		record(name(jvmcollect.HotThreadsFile), writeFile(c, name(jvmcollect.HotThreadsFile), b))
+		record(name(jvmcollect.HotThreadsTextFile), writeFile(c, name(jvmcollect.HotThreadsTextFile), []byte(jvmcollect.FormatHotThreads(hot))))
+	})
+	run(func() {
+		var w bytes.Buffer
+		if err := hotspot.Jcmd(hook, &w, c.DremioPID(), "GC.heap_info"); err != nil {
+			record("", fmt.Errorf("unable to capture heap info: %w", err))
+			return
+		}
+		record(name(jvmcollect.HeapInfoFile), writeFile(c, name(jvmcollect.HeapInfoFile), w.Bytes()))
+	})
+	run(func() {
+		b, err := gcState(c.DremioPID())
+		if err != nil {
+			record("", err)
+			return
+		}
+		record(name("gc.json"), writeFile(c, name("gc.json"), b))
+	})
+	if c.CollectSnapshotREST() {
+		for _, systable := range apicollect.SnapshotSystemTables {
+			run(func() {
+				ctx, cancel := context.WithTimeout(hook.GetContext(), time.Duration(c.CollectSystemTablesTimeoutSeconds())*time.Second)
+				defer cancel()
+				file := name(fmt.Sprintf("sys.%v.json", systable))
+				err := apicollect.QuerySystemTableToFile(ctx, c, hook, systable, filepath.Join(c.SnapshotOutDir(), file))
+				record(file, err)
+			})
+		}
+	}
+	wg.Wait()
+	inst.Finished = time.Now()
+	sort.Strings(inst.Files)
+	return inst, true
+}
+
+// gcState is the hsperfdata sample of the heap generations and gc counters of pid
+func gcState(pid int) ([]byte, error) {
+	loc, err := hotspot.FindPerfData(pid)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find hsperfdata of pid %v: %w", pid, err)
+	}
+	p, err := hotspot.ReadPerfData(loc)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(p.Sample(time.Now()), "", "  ")
+}
+
+func writeFile(c *conf.CollectConf, name string, data []byte) error {
+	loc := filepath.Join(c.SnapshotOutDir(), name)
+	if err := os.WriteFile(filepath.Clean(loc), data, 0o600); err != nil {
+		return fmt.Errorf("unable to write %v: %w", loc, err)
+	}
+	return nil
+}
+
+// sleepUntil returns false when the collection is stopped before t
+func sleepUntil(hook shutdown.CancelHook, t time.Time) bool {
+	d := time.Until(t)
+	if d <= 0 {
+		return hook.GetContext().Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-hook.GetContext().Done():
+		return false
+	}
+}
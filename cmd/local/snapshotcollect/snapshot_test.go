@@ -0,0 +1,134 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshotcollect_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/snapshotcollect"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/collects"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/snapshot"
+)
+
+func TestRunCollectSnapshot(t *testing.T) {
+	tmpOutDir := filepath.Join(t.TempDir(), "ddcout")
+	ddcYaml := filepath.Join(t.TempDir(), "ddc.yaml")
+	at := time.Now().Add(1500 * time.Millisecond)
+	// this process is not a jvm so only the captures that do not attach are expected to work
+	ddcYamlString := fmt.Sprintf(`
+dremio-log-dir: %v
+dremio-conf-dir: %v
+tarball-out-dir: %v
+node-name: node1
+dremio-pid: %v
+snapshot-at: %v
+snapshot-count: 2
+snapshot-interval-seconds: 1
+snapshot-clock-offset-ms: 250
+`, dremioDir(t, "server.log"), dremioDir(t, "dremio.conf"), strings.ReplaceAll(tmpOutDir, "\\", "\\\\"), os.Getpid(), at.Format(time.RFC3339Nano))
+	if err := os.WriteFile(ddcYaml, []byte(ddcYamlString), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+	c, err := conf.ReadConf(hook, map[string]string{}, ddcYaml, collects.StandardCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.CollectSnapshot() {
+		t.Fatal("expected the snapshot to be enabled")
+	}
+	if err := os.MkdirAll(c.SnapshotOutDir(), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := snapshotcollect.RunCollectSnapshot(c, hook); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(c.SnapshotOutDir(), snapshotcollect.ResultFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result snapshot.Result
+	if err := json.Unmarshal(b, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Instants) != 2 || result.ClockOffsetMillis != 250 {
+		t.Fatalf("expected 2 captures with a 250ms offset but got %#v", result)
+	}
+	for i, inst := range result.Instants {
+		expected := at.Add(time.Duration(i)*time.Second + 250*time.Millisecond)
+		if !inst.Planned.Equal(expected) {
+			t.Errorf("expected capture %v planned at %v but got %v", i, expected, inst.Planned)
+		}
+		if inst.Started.Before(expected) {
+			t.Errorf("expected capture %v to start after %v but it started at %v", i, expected, inst.Started)
+		}
+		if len(inst.Errors) == 0 {
+			t.Errorf("expected the thread dump of a process that is not a jvm to fail for capture %v", i)
+		}
+	}
+}
+
+func TestRunCollectSnapshotStopped(t *testing.T) {
+	tmpOutDir := filepath.Join(t.TempDir(), "ddcout")
+	ddcYaml := filepath.Join(t.TempDir(), "ddc.yaml")
+	ddcYamlString := fmt.Sprintf(`
+dremio-log-dir: %v
+dremio-conf-dir: %v
+tarball-out-dir: %v
+node-name: node1
+dremio-pid: %v
+snapshot-at: 1h
+`, dremioDir(t, "server.log"), dremioDir(t, "dremio.conf"), strings.ReplaceAll(tmpOutDir, "\\", "\\\\"), os.Getpid())
+	if err := os.WriteFile(ddcYaml, []byte(ddcYamlString), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+	c, err := conf.ReadConf(hook, map[string]string{}, ddcYaml, collects.StandardCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(c.SnapshotOutDir(), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		hook.Stop(true)
+	}()
+	if err := snapshotcollect.RunCollectSnapshot(c, hook); err == nil {
+		t.Error("expected an error when the collection is stopped before the snapshot")
+	}
+	if _, err := os.Stat(filepath.Join(c.SnapshotOutDir(), snapshotcollect.ResultFile)); err != nil {
+		t.Errorf("expected %v to be written when stopped: %v", snapshotcollect.ResultFile, err)
+	}
+}
+
+// dremioDir is a dir with an empty file as the log and conf dirs only need to be valid
+func dremioDir(t *testing.T, file string) string {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, file), []byte{}, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return strings.ReplaceAll(dir, "\\", "\\\\")
+}
@@ -0,0 +1,213 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package threading
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+)
+
+// ResourceClass groups tasks that compete for the same resource
+type ResourceClass string
+
+const (
+	// ResourceNone tasks are light and are never limited
+	ResourceNone ResourceClass = ""
+	// ResourceCPU tasks copy and compress files
+	ResourceCPU ResourceClass = "cpu"
+	// ResourceREST tasks call the Dremio REST API
+	ResourceREST ResourceClass = "rest"
+	// ResourceJVMAttach tasks attach to the Dremio JVM through the attach api or with jcmd, jps or jmap
+	ResourceJVMAttach ResourceClass = "jvm-attach"
+)
+
+// Task is a node in the scheduler graph. DependsOn only orders tasks, a task still runs
+// when one of its dependencies fails and is expected to handle the missing input itself.
+type Task struct {
+	Name      string
+	Class     ResourceClass
+	DependsOn []string
+	Process   func() error
+}
+
+// Scheduler runs tasks as soon as their dependencies are done while keeping the number
+// of running tasks of each resource class under its limit
+type Scheduler struct {
+	limits map[ResourceClass]int
+	tasks  []Task
+	index  map[string]int
+	output bool
+}
+
+// NewScheduler takes the concurrency limit of each resource class, classes without a limit are not limited
+func NewScheduler(limits map[ResourceClass]int, output bool) *Scheduler {
+	return &Scheduler{
+		limits: limits,
+		index:  make(map[string]int),
+		output: output,
+	}
+}
+
+// AddTask adds a task to the graph, task names must be unique
+func (s *Scheduler) AddTask(task Task) error {
+	if _, ok := s.index[task.Name]; ok {
+		return fmt.Errorf("task %v was already added", task.Name)
+	}
+	s.index[task.Name] = len(s.tasks)
+	s.tasks = append(s.tasks, task)
+	return nil
+}
+
+// Order validates the graph and returns the task names in an order that respects every dependency,
+// tasks that are ready at the same time keep the order they were added in
+func (s *Scheduler) Order() ([]string, error) {
+	pending, dependents, err := s.graph()
+	if err != nil {
+		return nil, err
+	}
+	var order []string
+	var ready []int
+	for i := range s.tasks {
+		if pending[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		order = append(order, s.tasks[i].Name)
+		for _, d := range dependents[i] {
+			pending[d]--
+			if pending[d] == 0 {
+				ready = insertSorted(ready, d)
+			}
+		}
+	}
+	if len(order) != len(s.tasks) {
+		var stuck []string
+		for i, t := range s.tasks {
+			if pending[i] > 0 {
+				stuck = append(stuck, t.Name)
+			}
+		}
+		return nil, fmt.Errorf("dependency cycle between tasks: %v", strings.Join(stuck, ", "))
+	}
+	return order, nil
+}
+
+// graph counts the dependencies of every task and finds the tasks waiting on each task
+func (s *Scheduler) graph() (pending []int, dependents [][]int, err error) {
+	pending = make([]int, len(s.tasks))
+	dependents = make([][]int, len(s.tasks))
+	for i, t := range s.tasks {
+		for _, dep := range t.DependsOn {
+			d, ok := s.index[dep]
+			if !ok {
+				return nil, nil, fmt.Errorf("task %v depends on unknown task %v", t.Name, dep)
+			}
+			if d == i {
+				return nil, nil, fmt.Errorf("task %v depends on itself", t.Name)
+			}
+			pending[i]++
+			dependents[d] = append(dependents[d], i)
+		}
+	}
+	return pending, dependents, nil
+}
+
+func insertSorted(ready []int, i int) []int {
+	pos := len(ready)
+	for j, r := range ready {
+		if r > i {
+			pos = j
+			break
+		}
+	}
+	ready = append(ready, 0)
+	copy(ready[pos+1:], ready[pos:])
+	ready[pos] = i
+	return ready
+}
+
+// Run blocks until every task has run. Task failures are logged the same way the ThreadPool logs them,
+// an error is only returned when the graph is invalid in which case nothing is run.
+func (s *Scheduler) Run() error {
+	if _, err := s.Order(); err != nil {
+		return err
+	}
+	pending, dependents, err := s.graph()
+	if err != nil {
+		return err
+	}
+	running := make(map[ResourceClass]int)
+	done := make(chan int)
+	var ready []int
+	for i := range s.tasks {
+		if pending[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	finished := 0
+	for finished < len(s.tasks) {
+		// start everything that is ready and has room in its class, in the order the tasks were added
+		var waiting []int
+		for _, i := range ready {
+			class := s.tasks[i].Class
+			if limit, ok := s.limits[class]; ok && limit > 0 && running[class] >= limit {
+				waiting = append(waiting, i)
+				continue
+			}
+			running[class]++
+			go func(i int) {
+				s.runTask(s.tasks[i])
+				done <- i
+			}(i)
+		}
+		ready = waiting
+		i := <-done
+		finished++
+		running[s.tasks[i].Class]--
+		for _, d := range dependents[i] {
+			pending[d]--
+			if pending[d] == 0 {
+				ready = insertSorted(ready, d)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) runTask(task Task) {
+	msg := fmt.Sprintf("JOB START - %v", task.Name)
+	if s.output {
+		fmt.Println(msg)
+	}
+	simplelog.Info(msg)
+	if err := task.Process(); err != nil {
+		msg := fmt.Sprintf("JOB FAILED - %v - %v", task.Name, err)
+		if s.output {
+			fmt.Println(msg)
+		}
+		simplelog.Error(msg)
+		return
+	}
+	msg = fmt.Sprintf("JOB COMPLETE - %v", task.Name)
+	if s.output {
+		fmt.Println(msg)
+	}
+	simplelog.Info(msg)
+}
@@ -0,0 +1,186 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package threading_test
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/threading"
+)
+
+func TestSchedulerRunsDependenciesFirst(t *testing.T) {
+	var mut sync.Mutex
+	var finished []string
+	record := func(name string, d time.Duration) func() error {
+		return func() error {
+			time.Sleep(d)
+			mut.Lock()
+			defer mut.Unlock()
+			finished = append(finished, name)
+			return nil
+		}
+	}
+	s := threading.NewScheduler(nil, false)
+	tasks := []threading.Task{
+		{Name: "JOB PROFILES", Class: threading.ResourceREST, DependsOn: []string{"QUERIES.JSON", "SYSTEM TABLES"}, Process: record("JOB PROFILES", 0)},
+		{Name: "QUERIES.JSON", Class: threading.ResourceCPU, Process: record("QUERIES.JSON", 50*time.Millisecond)},
+		{Name: "SYSTEM TABLES", Class: threading.ResourceREST, Process: record("SYSTEM TABLES", 10*time.Millisecond)},
+	}
+	for _, task := range tasks {
+		if err := s.AddTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Run(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"SYSTEM TABLES", "QUERIES.JSON", "JOB PROFILES"}
+	if !reflect.DeepEqual(expected, finished) {
+		t.Errorf("expected %v but got %v", expected, finished)
+	}
+}
+
+func TestSchedulerRunsDependentsWhenDependencyFails(t *testing.T) {
+	s := threading.NewScheduler(nil, false)
+	var ran bool
+	if err := s.AddTask(threading.Task{Name: "a", Process: func() error { return errors.New("failed") }}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddTask(threading.Task{Name: "b", DependsOn: []string{"a"}, Process: func() error {
+		ran = true
+		return nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if !ran {
+		t.Error("expected b to run after a failed")
+	}
+}
+
+func TestSchedulerHonorsClassLimits(t *testing.T) {
+	limits := map[threading.ResourceClass]int{
+		threading.ResourceCPU:       2,
+		threading.ResourceJVMAttach: 1,
+	}
+	s := threading.NewScheduler(limits, false)
+	var running, maxRunning [3]int32
+	classes := []threading.ResourceClass{threading.ResourceCPU, threading.ResourceJVMAttach, threading.ResourceNone}
+	for i := 0; i < 12; i++ {
+		c := i % 3
+		task := threading.Task{
+			Name:  string(rune('a' + i)),
+			Class: classes[c],
+			Process: func() error {
+				n := atomic.AddInt32(&running[c], 1)
+				for {
+					m := atomic.LoadInt32(&maxRunning[c])
+					if n <= m || atomic.CompareAndSwapInt32(&maxRunning[c], m, n) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				atomic.AddInt32(&running[c], -1)
+				return nil
+			},
+		}
+		if err := s.AddTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if maxRunning[0] != 2 {
+		t.Errorf("expected at most 2 cpu tasks at once but got %v", maxRunning[0])
+	}
+	if maxRunning[1] != 1 {
+		t.Errorf("expected at most 1 jvm attach task at once but got %v", maxRunning[1])
+	}
+	if maxRunning[2] != 4 {
+		t.Errorf("expected all 4 unlimited tasks at once but got %v", maxRunning[2])
+	}
+}
+
+func TestSchedulerRejectsInvalidGraphs(t *testing.T) {
+	noop := func() error { return nil }
+	s := threading.NewScheduler(nil, false)
+	if err := s.AddTask(threading.Task{Name: "a", Process: noop}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddTask(threading.Task{Name: "a", Process: noop}); err == nil {
+		t.Error("expected an error adding a duplicate task")
+	}
+
+	s = threading.NewScheduler(nil, false)
+	var ran bool
+	if err := s.AddTask(threading.Task{Name: "a", DependsOn: []string{"missing"}, Process: func() error {
+		ran = true
+		return nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Run(); err == nil {
+		t.Error("expected an error for an unknown dependency")
+	}
+	if ran {
+		t.Error("expected nothing to run with an invalid graph")
+	}
+
+	s = threading.NewScheduler(nil, false)
+	for _, task := range []threading.Task{
+		{Name: "a", DependsOn: []string{"c"}, Process: noop},
+		{Name: "b", DependsOn: []string{"a"}, Process: noop},
+		{Name: "c", DependsOn: []string{"b"}, Process: noop},
+		{Name: "d", Process: noop},
+	} {
+		if err := s.AddTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.Order(); err == nil || err.Error() != "dependency cycle between tasks: a, b, c" {
+		t.Errorf("expected a cycle error but got %v", err)
+	}
+}
+
+func TestSchedulerOrder(t *testing.T) {
+	noop := func() error { return nil }
+	s := threading.NewScheduler(nil, false)
+	for _, task := range []threading.Task{
+		{Name: "heap dump", DependsOn: []string{"jfr", "jstack"}, Process: noop},
+		{Name: "wlm", Process: noop},
+		{Name: "jstack", Process: noop},
+		{Name: "jfr", Process: noop},
+	} {
+		if err := s.AddTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	order, err := s.Order()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"wlm", "jstack", "jfr", "heap dump"}
+	if !reflect.DeepEqual(expected, order) {
+		t.Errorf("expected %v but got %v", expected, order)
+	}
+}
@@ -0,0 +1,339 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/watch"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/versions"
+)
+
+// captureInProgressDir is where a capture is collected inside of the watch dir before it is archived
+const captureInProgressDir = "capture-in-progress"
+
+var watchDDCYamlLoc, watchCollectionMode string
+
+var WatchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "watches the local dremio node and takes a local-collect capture when a watch rule of ddc.yaml matches",
+	Long:  `Watches the local dremio node and takes a local-collect capture when a watch rule of ddc.yaml matches. Rules match a regular expression on the new lines of server.log or the gc logs, a gc pause above a threshold or the heap still being full after a garbage collection. Each capture is a normal local-collect tarball with thread dumps, heap info, JFR and the logs before the trigger, the oldest captures are removed once watch-max-captures is reached. Runs until interrupted`,
+	Run: func(cobraCmd *cobra.Command, args []string) {
+		overrides := make(map[string]string)
+		// if a cli flag was set go ahead and use those values to override the yaml configuration
+		cobraCmd.Flags().Visit(func(flag *pflag.Flag) {
+			if flag.Name == conf.KeyDremioPatToken {
+				// we do not want to log the token
+				simplelog.Debugf("overriding yaml with cli flag %v and value 'REDACTED'", flag.Name)
+			} else {
+				simplelog.Debugf("overriding yaml with cli flag %v and value %q", flag.Name, flag.Value.String())
+			}
+			overrides[flag.Name] = flag.Value.String()
+		})
+		msg, err := ExecuteWatch(args, overrides)
+		if err != nil {
+			fmt.Printf("\nCRITICAL ERROR: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(msg)
+	},
+}
+
+// ExecuteWatch polls the logs and the heap of the local dremio until interrupted and takes a capture when a rule matches
+func ExecuteWatch(args []string, overrides map[string]string) (string, error) {
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+	cSignal := make(chan os.Signal, 1)
+	signal.Notify(cSignal, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		// the first CTRL+C stops watching and archives a capture in progress, a second one or SIGTERM aborts
+		if sig := <-cSignal; sig == os.Interrupt {
+			msg := "interrupt received: stopping the watch, interrupt again to abort"
+			fmt.Println(msg)
+			simplelog.Info(msg)
+			hook.Stop(true)
+			<-cSignal
+		}
+		simplelog.Infof("graceful shutdown initiated")
+		hook.Cleanup()
+		os.Exit(1)
+	}()
+	simplelog.Infof("ddc watch version: %v", versions.GetCLIVersion())
+	simplelog.Infof("args: %v", strings.Join(args, " "))
+	fmt.Println(strings.TrimSpace(versions.GetCLIVersion()))
+
+	// the configuration is read again for every capture, this read only needs somewhere empty for the tarball
+	setupDir, err := os.MkdirTemp("", "ddc-watch-")
+	if err != nil {
+		return "", fmt.Errorf("unable to create a temporary directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(setupDir); err != nil {
+			simplelog.Warningf("unable to remove %v: %v", setupDir, err)
+		}
+	}()
+	setupOverrides := copyOverrides(overrides)
+	setupOverrides[conf.KeyTarballOutDir] = setupDir
+	c, err := conf.ReadConf(hook, setupOverrides, watchDDCYamlLoc, watchCollectionMode)
+	if err != nil {
+		return "", fmt.Errorf("unable to read configuration %w", err)
+	}
+	rules := c.WatchRules()
+	if len(rules) == 0 {
+		return "", fmt.Errorf("there is nothing to watch, add %v to %v", conf.KeyWatchRules, watchDDCYamlLoc)
+	}
+	if err := os.MkdirAll(c.WatchDir(), 0o750); err != nil {
+		return "", fmt.Errorf("unable to create watch directory %v: %w", c.WatchDir(), err)
+	}
+	var heap watch.HeapReader
+	if c.DremioPIDDetection() {
+		// dremio is found again when it restarts, after an OOM for example
+		heap = watch.PerfDataReader(c.DremioPID(), func() (int, error) {
+			return conf.DetectDremioPID(hook)
+		})
+	} else if c.DremioPID() > 0 {
+		heap = watch.PerfDataReader(c.DremioPID(), nil)
+	} else {
+		for _, r := range rules {
+			if r.HeapPercent > 0 {
+				simplelog.Warningf("no dremio jvm was found so rule %v that checks the heap will never match", r.Name)
+			}
+		}
+	}
+	w := watch.NewWatcher(rules, filepath.Join(c.DremioLogDir(), "server.log"), filepath.Join(c.GcLogsDir(), c.DremioGCFilePattern()), heap)
+	msg := fmt.Sprintf("watching %v with %v rules, captures are written to %v", c.DremioLogDir(), len(rules), c.WatchDir())
+	fmt.Println(msg)
+	simplelog.Info(msg)
+
+	start := time.Now()
+	captures, err := runWatch(hook, w.Poll, c.WatchPollInterval(), func(triggers []watch.Trigger) bool {
+		tarball, err := takeWatchCapture(hook, overrides, c, triggers)
+		if err != nil {
+			simplelog.Errorf("unable to take the capture for rule %v: %v", triggers[0].Rule, err)
+			fmt.Printf("unable to take the capture for rule %v: %v\n", triggers[0].Rule, err)
+		} else {
+			fmt.Printf("capture %v taken for rule %v: %v\n", tarball, triggers[0].Rule, triggers[0].Reason)
+		}
+		removed, err := watch.Rotate(c.WatchDir(), c.WatchMaxCaptures())
+		if err != nil {
+			simplelog.Warningf("unable to rotate the captures: %v", err)
+		}
+		for _, r := range removed {
+			simplelog.Infof("removed the old capture %v to keep %v", r, c.WatchMaxCaptures())
+		}
+		return err == nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("stopped watching after %v and %v captures in %v: %w", time.Since(start).Round(time.Second), captures, c.WatchDir(), err)
+	}
+	return fmt.Sprintf("watched for %v and took %v captures in %v", time.Since(start).Round(time.Second), captures, c.WatchDir()), nil
+}
+
+// runWatch polls every interval until the hook is stopped and returns how many captures succeeded. A capture runs in
+// the background so the rules, the heap after a garbage collection too, are still checked while it runs, what matches
+// in the meantime is captured once it ends. It fails when the heap of dremio cannot be read anymore
+func runWatch(hook shutdown.Hook, poll func(time.Time) ([]watch.Trigger, error), interval time.Duration, capture func([]watch.Trigger) bool) (int, error) {
+	var captures int
+	var lastErr string
+	var pending []watch.Trigger
+	capturing := false
+	done := make(chan bool, 1)
+	startCapture := func(triggers []watch.Trigger) {
+		capturing = true
+		go func() {
+			done <- capture(triggers)
+		}()
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := time.Now(); ; now = time.Now() {
+		triggers, err := poll(now)
+		if err != nil && err.Error() != lastErr {
+			// the same problem is only logged once so it does not fill the log every poll
+			simplelog.Warningf("watch: %v", err)
+		}
+		if err != nil {
+			lastErr = err.Error()
+		} else {
+			lastErr = ""
+		}
+		if errors.Is(err, watch.ErrDremioGone) {
+			// the heap rules would never match again
+			if capturing && <-done {
+				captures++
+			}
+			return captures, err
+		}
+		if len(triggers) > 0 {
+			if capturing {
+				simplelog.Infof("%v matched during a capture, it is captured next", triggers)
+				pending = append(pending, triggers...)
+			} else {
+				startCapture(triggers)
+			}
+		}
+		select {
+		case <-hook.Stopped():
+			// the capture in progress is stopped by the hook too and archives what it has
+			if capturing && <-done {
+				captures++
+			}
+			return captures, nil
+		case ok := <-done:
+			capturing = false
+			if ok {
+				captures++
+			}
+			select {
+			case <-hook.Stopped():
+			default:
+				if len(pending) > 0 {
+					startCapture(pending)
+					pending = nil
+				}
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// takeWatchCapture runs a local-collect for the triggers and moves its tarball to the watch dir
+func takeWatchCapture(watchHook shutdown.Hook, overrides map[string]string, c *conf.CollectConf, triggers []watch.Trigger) (string, error) {
+	simplelog.Infof("taking a capture for %v", triggers)
+	// the ddc log of the watch keeps growing, a capture only keeps what was logged while it ran
+	var logFrom int64
+	if fi, err := os.Stat(simplelog.GetLogLoc()); err == nil {
+		logFrom = fi.Size()
+	}
+	staging := filepath.Join(c.WatchDir(), captureInProgressDir)
+	if err := os.RemoveAll(staging); err != nil {
+		return "", fmt.Errorf("unable to clear %v: %w", staging, err)
+	}
+	defer func() {
+		if err := os.RemoveAll(staging); err != nil {
+			simplelog.Warningf("unable to remove %v: %v", staging, err)
+		}
+	}()
+	// every capture has its own hook so what a collection leaves to clean up is done when it ends
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-watchHook.Stopped():
+			hook.Stop(true)
+		case <-done:
+		}
+	}()
+	captureOverrides := watchCaptureOverrides(overrides, staging, triggers[0].At.Add(-c.WatchLogTail()), c.WatchCaptureSeconds(), watch.Collects(triggers))
+	captureConf, err := conf.ReadConf(hook, captureOverrides, watchDDCYamlLoc, watchCollectionMode)
+	if err != nil {
+		return "", fmt.Errorf("unable to read configuration %w", err)
+	}
+	if err := collect(captureConf, hook); err != nil {
+		return "", fmt.Errorf("unable to collect: %w", err)
+	}
+	if err := watch.WriteTriggers(captureConf.OutputDir(), triggers); err != nil {
+		simplelog.Warningf("unable to record why the capture was taken: %v", err)
+	}
+	tarball, err := archiveCollection(captureConf, logFrom)
+	if err != nil {
+		return "", err
+	}
+	dest := filepath.Join(c.WatchDir(), watch.CaptureName(captureConf.NodeName(), triggers))
+	if err := os.Rename(tarball, dest); err != nil {
+		return "", fmt.Errorf("unable to move the capture to %v: %w", dest, err)
+	}
+	select {
+	case <-hook.Stopped():
+		return dest, errors.New("interrupted, the capture only contains what was collected before the interrupt")
+	default:
+	}
+	return dest, nil
+}
+
+// watchCaptureOverrides turns the collections of a capture on or off on top of the overrides of ddc watch.
+// The logs are limited to the window ending at the capture and thread dumps and JFR last captureSeconds
+func watchCaptureOverrides(overrides map[string]string, staging string, from time.Time, captureSeconds int, collects []string) map[string]string {
+	o := copyOverrides(overrides)
+	o[conf.KeyTarballOutDir] = staging
+	o[conf.KeyFrom] = from.Format(time.RFC3339)
+	delete(o, conf.KeyTo)
+	o[conf.KeySnapshotAt] = ""
+	o[conf.KeyCaptureHeapDump] = "false"
+	enable := func(what string) string {
+		for _, c := range collects {
+			if c == what {
+				return "true"
+			}
+		}
+		return "false"
+	}
+	seconds := strconv.Itoa(captureSeconds)
+	o[conf.KeyCollectJStack] = enable(conf.WatchCaptureJStack)
+	o[conf.KeyDremioJStackTimeSeconds] = seconds
+	o[conf.KeyCollectJVMHeapInfo] = enable(conf.WatchCaptureHeapInfo)
+	// the recordings already running in dremio have what led to the trigger, a new one is only started without them
+	o[conf.KeyCollectJFR] = enable(conf.WatchCaptureJFR)
+	o[conf.KeyJFRDumpExisting] = "true"
+	o[conf.KeyDremioJFRTimeSeconds] = seconds
+	logs := enable(conf.WatchCaptureLogs)
+	for _, k := range []string{conf.KeyCollectServerLogs, conf.KeyCollectGCLogs, conf.KeyCollectMetaRefreshLog, conf.KeyCollectReflectionLog, conf.KeyCollectAccelerationLog, conf.KeyCollectQueriesJSON} {
+		o[k] = logs
+	}
+	return o
+}
+
+func copyOverrides(overrides map[string]string) map[string]string {
+	o := make(map[string]string, len(overrides))
+	for k, v := range overrides {
+		o[k] = v
+	}
+	return o
+}
+
+func init() {
+	WatchCmd.Flags().CountP("verbose", "v", "Logging verbosity")
+	WatchCmd.Flags().String(conf.KeyDremioPatToken, "", "Dremio Personal Access Token (PAT) used by the captures for the rest api")
+	WatchCmd.Flags().Bool("allow-insecure-ssl", false, "When true allow insecure ssl certs when doing API calls")
+	WatchCmd.Flags().Bool("disable-rest-api", false, "disable all REST API calls of the captures")
+	WatchCmd.Flags().Bool(conf.KeyDisableFreeSpaceCheck, false, "disables the free space check of the captures")
+	WatchCmd.Flags().String(conf.KeyWatchDir, "/tmp/ddc-watch", "directory where the captures are kept")
+	WatchCmd.Flags().Int(conf.KeyWatchMaxCaptures, 5, "number of captures kept, the oldest are removed")
+	WatchCmd.Flags().Int(conf.KeyWatchPollSeconds, 5, "how often the logs and the heap are read")
+	execLoc, err := os.Executable()
+	if err != nil {
+		fmt.Printf("unable to find ddc, critical error %v", err)
+		os.Exit(1)
+	}
+	execLocDir := filepath.Dir(execLoc)
+	WatchCmd.Flags().StringVar(&watchDDCYamlLoc, "ddc-yaml", filepath.Join(execLocDir, "ddc.yaml"), "location of ddc.yaml with the watch-rules and the configuration of the captures")
+	WatchCmd.Flags().StringVar(&watchCollectionMode, "collect", "light", "collection mode the captures start from, the watch-rules then turn thread dumps, heap info, JFR and the logs on or off")
+}
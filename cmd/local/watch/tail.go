@@ -0,0 +1,149 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watch
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxTailBytes caps what is read of a file in one poll, the rest is read by the next polls
+const maxTailBytes = 64 * 1024 * 1024
+
+// Tailer reads the lines appended to the files matching a glob since the last read. A file replaced by
+// a new one, such as after a rotation, is read from its start and a renamed file is followed
+type Tailer struct {
+	glob    string
+	files   map[string]*tailedFile
+	started bool
+}
+
+type tailedFile struct {
+	info   os.FileInfo
+	offset int64
+}
+
+// Line is a complete line of a tailed file
+type Line struct {
+	File string
+	Text string
+}
+
+// NewTailer tails the files matching glob, compressed files are skipped
+func NewTailer(glob string) *Tailer {
+	return &Tailer{glob: glob, files: make(map[string]*tailedFile)}
+}
+
+// Lines returns the complete lines appended since the last call, the first call only notes where the files end
+// so what was logged before the watch started does not match
+func (t *Tailer) Lines() ([]Line, error) {
+	matches, err := filepath.Glob(t.glob)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log glob %v: %w", t.glob, err)
+	}
+	sort.Strings(matches)
+	files := make(map[string]*tailedFile)
+	var lines []Line
+	var errs []error
+	for _, path := range matches {
+		if strings.HasSuffix(path, ".gz") {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		f := t.find(path, info)
+		switch {
+		case f == nil && !t.started:
+			f = &tailedFile{offset: info.Size()}
+		case f == nil || info.Size() < f.offset:
+			// a new or truncated file
+			f = &tailedFile{}
+		}
+		f.info = info
+		files[path] = f
+		read, err := readLines(path, f.offset, info.Size())
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		f.offset += read.bytes
+		for _, l := range read.lines {
+			lines = append(lines, Line{File: filepath.Base(path), Text: l})
+		}
+	}
+	t.files = files
+	t.started = true
+	return lines, errors.Join(errs...)
+}
+
+// find is what was read of the file, following it when it was renamed
+func (t *Tailer) find(path string, info os.FileInfo) *tailedFile {
+	if f, ok := t.files[path]; ok && os.SameFile(f.info, info) {
+		return f
+	}
+	for _, f := range t.files {
+		if os.SameFile(f.info, info) {
+			return f
+		}
+	}
+	return nil
+}
+
+type readResult struct {
+	lines []string
+	bytes int64
+}
+
+// readLines reads the complete lines from offset, a line still being written is left for the next read
+func readLines(path string, offset, size int64) (readResult, error) {
+	var r readResult
+	if size <= offset {
+		return r, nil
+	}
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return r, fmt.Errorf("unable to read %v: %w", path, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return r, fmt.Errorf("unable to read %v: %w", path, err)
+	}
+	reader := bufio.NewReader(io.LimitReader(f, min(size-offset, maxTailBytes)))
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				if r.bytes == 0 && size-offset >= maxTailBytes {
+					// a line longer than a whole read would never complete so it is skipped
+					r.bytes = maxTailBytes
+				}
+				return r, nil
+			}
+			return r, fmt.Errorf("unable to read %v: %w", path, err)
+		}
+		r.bytes += int64(len(line))
+		r.lines = append(r.lines, strings.TrimRight(line, "\r\n"))
+	}
+}
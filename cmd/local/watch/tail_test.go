@@ -0,0 +1,109 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func appendTo(t *testing.T, path, text string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(text); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func texts(lines []Line) string {
+	var s []string
+	for _, l := range lines {
+		s = append(s, l.Text)
+	}
+	return strings.Join(s, "|")
+}
+
+func TestTailerOnlyReadsNewCompleteLines(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "server.log")
+	appendTo(t, log, "before the watch\n")
+	tailer := NewTailer(log)
+	lines, err := tailer.Lines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 0 {
+		t.Errorf("expected the first read to skip the existing lines but got %v", texts(lines))
+	}
+	appendTo(t, log, "first\nsecond\nhalf")
+	lines, err = tailer.Lines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if texts(lines) != "first|second" || lines[0].File != "server.log" {
+		t.Errorf("expected the complete new lines but got %v", texts(lines))
+	}
+	appendTo(t, log, " a line\n")
+	lines, err = tailer.Lines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if texts(lines) != "half a line" {
+		t.Errorf("expected the finished line but got %v", texts(lines))
+	}
+}
+
+func TestTailerFollowsRotation(t *testing.T) {
+	dir := t.TempDir()
+	gc := filepath.Join(dir, "gc.log")
+	appendTo(t, gc, "old\n")
+	tailer := NewTailer(filepath.Join(dir, "gc*.log*"))
+	if _, err := tailer.Lines(); err != nil {
+		t.Fatal(err)
+	}
+	appendTo(t, gc, "before rotation\n")
+	// the jvm renames the current log and starts a new one
+	if err := os.Rename(gc, gc+".0"); err != nil {
+		t.Fatal(err)
+	}
+	appendTo(t, gc, "after rotation\n")
+	appendTo(t, filepath.Join(dir, "gc.log.1.gz"), "compressed\n")
+	lines, err := tailer.Lines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if texts(lines) != "after rotation|before rotation" {
+		t.Errorf("expected the renamed file to be followed and the new one read from its start but got %v", texts(lines))
+	}
+	// a truncated file is read from its start
+	if err := os.WriteFile(gc, []byte("truncated\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	lines, err = tailer.Lines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if texts(lines) != "truncated" {
+		t.Errorf("expected the truncated file to be read again but got %v", texts(lines))
+	}
+}
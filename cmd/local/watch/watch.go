@@ -0,0 +1,322 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// watch package decides when ddc watch takes a capture, from the lines appended to server.log and the gc logs
+// and the heap read from the hsperfdata file of dremio
+package watch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/hotspot"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+)
+
+// TriggerFile is written in every capture with the rules that took it
+const TriggerFile = "watch-trigger.json"
+
+// CapturePrefix starts the name of every capture, only those are rotated so other tarballs in the watch dir are left alone
+const CapturePrefix = "ddc-watch-"
+
+// maxReasonLength keeps a long log line from filling the trigger
+const maxReasonLength = 500
+
+var (
+	// unified logging, such as GC(12) Pause Young (Normal) (G1 Evacuation Pause) 100M->50M(200M) 12.345ms
+	unifiedPause = regexp.MustCompile(`\bPause\b.*\s(\d+(?:\.\d+)?)ms\s*$`)
+	// java 8, such as [GC pause (G1 Evacuation Pause) (young), 0.0123456 secs] or [Full GC (Allocation Failure) ..., 2.34 secs]
+	legacyPause = regexp.MustCompile(`\[(?:GC|Full GC)\b.*?, (\d+(?:\.\d+)?) secs\]`)
+)
+
+// ParseGCPause is the length of the stop the world pause logged by the gc log line
+func ParseGCPause(line string) (time.Duration, bool) {
+	if m := unifiedPause.FindStringSubmatch(line); m != nil {
+		ms, err := strconv.ParseFloat(m[1], 64)
+		return time.Duration(ms * float64(time.Millisecond)), err == nil
+	}
+	if m := legacyPause.FindStringSubmatch(line); m != nil {
+		secs, err := strconv.ParseFloat(m[1], 64)
+		return time.Duration(secs * float64(time.Second)), err == nil
+	}
+	return 0, false
+}
+
+// HeapOccupancy is how full the old generation is in percent, with the number of garbage collections so far
+func HeapOccupancy(s hotspot.PerfSample) (percent float64, collections int64, ok bool) {
+	for _, c := range s.Collectors {
+		collections += c.Invocations
+	}
+	for _, g := range s.Generations {
+		if g.Name == "old" && g.MaxCapacityBytes > 0 {
+			return float64(g.UsedBytes) * 100 / float64(g.MaxCapacityBytes), collections, true
+		}
+	}
+	return 0, collections, false
+}
+
+// HeapReader reads the hsperfdata counters of the jvm
+type HeapReader func() (hotspot.PerfSample, error)
+
+// ErrDremioGone is returned by the heap reader when the hsperfdata of dremio cannot be read anymore and there is no
+// way to find dremio again, the heap rules would never match again
+var ErrDremioGone = errors.New("the heap of dremio cannot be read anymore")
+
+// PerfDataReader reads the hsperfdata file of pid. When it cannot be read, as after dremio crashed or restarted, find
+// looks for the pid of dremio again. Without find the reader fails with ErrDremioGone
+func PerfDataReader(pid int, find func() (int, error)) HeapReader {
+	return pidReader(pid, find, readPerfData)
+}
+
+func readPerfData(pid int) (hotspot.PerfSample, error) {
+	loc, err := hotspot.FindPerfData(pid)
+	if err != nil {
+		return hotspot.PerfSample{}, err
+	}
+	p, err := hotspot.ReadPerfData(loc)
+	if err != nil {
+		return hotspot.PerfSample{}, err
+	}
+	return p.Sample(time.Now()), nil
+}
+
+func pidReader(pid int, find func() (int, error), read func(int) (hotspot.PerfSample, error)) HeapReader {
+	return func() (hotspot.PerfSample, error) {
+		s, err := read(pid)
+		if err == nil {
+			return s, nil
+		}
+		if find == nil {
+			return hotspot.PerfSample{}, fmt.Errorf("%w, pid %v: %w", ErrDremioGone, pid, err)
+		}
+		found, findErr := find()
+		if findErr != nil {
+			return hotspot.PerfSample{}, fmt.Errorf("pid %v: %w and dremio was not found again: %w", pid, err, findErr)
+		}
+		if found == pid {
+			return hotspot.PerfSample{}, fmt.Errorf("pid %v: %w", pid, err)
+		}
+		simplelog.Infof("dremio pid %v is gone, reading the heap of dremio pid %v", pid, found)
+		pid = found
+		return read(pid)
+	}
+}
+
+// Trigger is a rule that matched
+type Trigger struct {
+	Rule   string    `json:"rule"`
+	At     time.Time `json:"at"`
+	Reason string    `json:"reason"`
+	// Captures are what the rule collects
+	Captures []string `json:"captures"`
+}
+
+// Watcher checks the rules against what changed since the last poll
+type Watcher struct {
+	rules     []conf.WatchRule
+	serverLog *Tailer
+	gcLogs    *Tailer
+	heap      HeapReader
+	// collections is the gc count of the last heap read, the heap is only checked after a garbage collection
+	collections int64
+	lastFired   map[string]time.Time
+}
+
+// NewWatcher watches serverLog and the files matching gcLogGlob, heap is nil when there is no jvm to read
+func NewWatcher(rules []conf.WatchRule, serverLog, gcLogGlob string, heap HeapReader) *Watcher {
+	return &Watcher{
+		rules:       rules,
+		serverLog:   NewTailer(serverLog),
+		gcLogs:      NewTailer(gcLogGlob),
+		heap:        heap,
+		collections: -1,
+		lastFired:   make(map[string]time.Time),
+	}
+}
+
+// Poll returns the rules that matched since the last poll and are not cooling down, those start their cooldown.
+// The first poll only notes where the logs end. Problems reading a source are returned with the triggers of the others
+func (w *Watcher) Poll(now time.Time) ([]Trigger, error) {
+	var errs []error
+	serverLines, err := w.serverLog.Lines()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	gcLines, err := w.gcLogs.Lines()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	heapPercent, heapChecked, err := w.readHeap()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	var triggers []Trigger
+	for _, r := range w.rules {
+		if last, ok := w.lastFired[r.Name]; ok && now.Sub(last) < r.Cooldown {
+			continue
+		}
+		reason, matched := "", false
+		switch {
+		case r.LogPattern != nil:
+			lines := serverLines
+			if r.Log == conf.WatchLogGC {
+				lines = gcLines
+			}
+			reason, matched = matchLog(r.LogPattern, lines)
+		case r.GCPause > 0:
+			reason, matched = matchPause(r.GCPause, gcLines)
+		case r.HeapPercent > 0:
+			if heapChecked && heapPercent >= r.HeapPercent {
+				reason, matched = fmt.Sprintf("old generation %.1f%% full after a garbage collection", heapPercent), true
+			}
+		}
+		if !matched {
+			continue
+		}
+		w.lastFired[r.Name] = now
+		triggers = append(triggers, Trigger{Rule: r.Name, At: now, Reason: truncate(reason), Captures: r.Captures})
+	}
+	return triggers, errors.Join(errs...)
+}
+
+// readHeap is the old generation occupancy when there was a garbage collection since the last read
+func (w *Watcher) readHeap() (float64, bool, error) {
+	if w.heap == nil {
+		return 0, false, nil
+	}
+	s, err := w.heap()
+	if err != nil {
+		return 0, false, fmt.Errorf("unable to read the heap: %w", err)
+	}
+	percent, collections, ok := HeapOccupancy(s)
+	if !ok {
+		return 0, false, errors.New("unable to read the heap: no old generation in the hsperfdata")
+	}
+	previous := w.collections
+	w.collections = collections
+	return percent, previous >= 0 && collections > previous, nil
+}
+
+func matchLog(re *regexp.Regexp, lines []Line) (string, bool) {
+	for _, l := range lines {
+		if re.MatchString(l.Text) {
+			return fmt.Sprintf("%v matched: %v", l.File, l.Text), true
+		}
+	}
+	return "", false
+}
+
+// matchPause finds the longest pause at least threshold long
+func matchPause(threshold time.Duration, lines []Line) (string, bool) {
+	var longest time.Duration
+	var reason string
+	for _, l := range lines {
+		if pause, ok := ParseGCPause(l.Text); ok && pause >= threshold && pause > longest {
+			longest = pause
+			reason = fmt.Sprintf("gc pause of %v in %v: %v", pause, l.File, l.Text)
+		}
+	}
+	return reason, longest > 0
+}
+
+func truncate(reason string) string {
+	if len(reason) <= maxReasonLength {
+		return reason
+	}
+	return reason[:maxReasonLength] + "..."
+}
+
+// Collects is every capture asked for by the triggers
+func Collects(triggers []Trigger) []string {
+	var all []string
+	for _, c := range conf.WatchCaptures {
+		for _, t := range triggers {
+			if slices.Contains(t.Captures, c) {
+				all = append(all, c)
+				break
+			}
+		}
+	}
+	return all
+}
+
+// CaptureName is the tarball name of the capture taken for the triggers
+func CaptureName(node string, triggers []Trigger) string {
+	return fmt.Sprintf("%v%v-%v-%v.tar.gz", CapturePrefix, node, triggers[0].At.UTC().Format("20060102T150405Z"), triggers[0].Rule)
+}
+
+// WriteTriggers records why the capture was taken in dir
+func WriteTriggers(dir string, triggers []Trigger) error {
+	b, err := json.MarshalIndent(triggers, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal the triggers: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, TriggerFile), b, 0o600); err != nil {
+		return fmt.Errorf("unable to write the triggers: %w", err)
+	}
+	return nil
+}
+
+// Rotate removes the oldest captures of dir so at most keep are left and returns the removed ones, files not named by
+// CaptureName are never removed
+func Rotate(dir string, keep int) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the captures in %v: %w", dir, err)
+	}
+	type capture struct {
+		name    string
+		modTime time.Time
+	}
+	var captures []capture
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), CapturePrefix) || !strings.HasSuffix(e.Name(), ".tar.gz") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		captures = append(captures, capture{e.Name(), info.ModTime()})
+	}
+	if len(captures) <= keep {
+		return nil, nil
+	}
+	sort.Slice(captures, func(i, j int) bool {
+		if captures[i].modTime.Equal(captures[j].modTime) {
+			return captures[i].name < captures[j].name
+		}
+		return captures[i].modTime.Before(captures[j].modTime)
+	})
+	var removed []string
+	var errs []error
+	for _, c := range captures[:len(captures)-keep] {
+		if err := os.Remove(filepath.Join(dir, c.name)); err != nil {
+			errs = append(errs, fmt.Errorf("unable to remove the old capture %v: %w", c.name, err))
+			continue
+		}
+		removed = append(removed, c.name)
+	}
+	return removed, errors.Join(errs...)
+}
@@ -0,0 +1,247 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/hotspot"
+)
+
+func TestParseGCPause(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected time.Duration
+		ok       bool
+	}{
+		{"[2024-01-15T23:40:00.123+0000][info][gc] GC(12) Pause Young (Normal) (G1 Evacuation Pause) 100M->50M(200M) 12.345ms", 12345 * time.Microsecond, true},
+		{"[12.3s][info][gc] GC(40) Pause Full (G1 Compaction Pause) 900M->850M(1024M) 2345.000ms", 2345 * time.Millisecond, true},
+		{"2024-01-15T23:40:00.123+0000: 12.345: [GC pause (G1 Evacuation Pause) (young), 0.0123456 secs]", 12345600 * time.Nanosecond, true},
+		{"2024-01-15T23:40:00.123+0000: 12.345: [Full GC (Allocation Failure)  1000M->900M(1024M), 2.5 secs]", 2500 * time.Millisecond, true},
+		{"[info][gc] GC(13) Concurrent Mark Cycle 123.456ms", 0, false},
+		{"[info][gc,phases] GC(12)   Pre Evacuate Collection Set: 0.1ms", 0, false},
+		{"2024-01-15T23:40:00.123+0000: [CMS-concurrent-mark: 1.234/1.500 secs]", 0, false},
+	}
+	for _, tt := range tests {
+		pause, ok := ParseGCPause(tt.line)
+		if ok != tt.ok || pause.Round(time.Microsecond) != tt.expected.Round(time.Microsecond) {
+			t.Errorf("expected %v %v for %q but got %v %v", tt.expected, tt.ok, tt.line, pause, ok)
+		}
+	}
+}
+
+func heapSample(collections, used int64) hotspot.PerfSample {
+	return hotspot.PerfSample{
+		Collectors:  []hotspot.Collector{{Name: "G1 young", Invocations: collections}, {Name: "G1 old", Invocations: 1}},
+		Generations: []hotspot.Generation{{Name: "new", UsedBytes: 10, MaxCapacityBytes: 1000}, {Name: "old", UsedBytes: used, MaxCapacityBytes: 1000}},
+	}
+}
+
+func TestWatcherPoll(t *testing.T) {
+	dir := t.TempDir()
+	serverLog := filepath.Join(dir, "server.log")
+	gcLog := filepath.Join(dir, "gc.log")
+	appendTo(t, serverLog, "java.lang.OutOfMemoryError: logged before the watch\n")
+	appendTo(t, gcLog, "")
+	samples := []hotspot.PerfSample{heapSample(5, 950), heapSample(5, 960), heapSample(6, 950), heapSample(7, 500)}
+	heap := func() (hotspot.PerfSample, error) {
+		s := samples[0]
+		samples = samples[1:]
+		return s, nil
+	}
+	rules := []conf.WatchRule{
+		{Name: "oom", LogPattern: regexp.MustCompile("OutOfMemoryError"), Log: conf.WatchLogServer, Cooldown: time.Minute, Captures: []string{conf.WatchCaptureLogs}},
+		{Name: "long-gc", GCPause: time.Second, Cooldown: time.Minute, Captures: []string{conf.WatchCaptureJStack}},
+		{Name: "full-heap", HeapPercent: 90, Captures: []string{conf.WatchCaptureHeapInfo, conf.WatchCaptureLogs}},
+	}
+	w := NewWatcher(rules, serverLog, filepath.Join(dir, "gc*.log"), heap)
+	start := time.Date(2024, 1, 15, 23, 40, 0, 0, time.UTC)
+
+	triggers, err := w.Poll(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triggers) != 0 {
+		t.Errorf("expected the first poll to only note where the logs end but got %v", triggers)
+	}
+
+	// a full heap without a garbage collection in between is not checked
+	appendTo(t, gcLog, "[info][gc] GC(5) Pause Young (Normal) (G1 Evacuation Pause) 100M->50M(200M) 12.345ms\n")
+	triggers, err = w.Poll(start.Add(5 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triggers) != 0 {
+		t.Errorf("expected nothing to match but got %v", triggers)
+	}
+
+	appendTo(t, serverLog, "java.lang.OutOfMemoryError: Java heap space\n")
+	appendTo(t, gcLog, "[info][gc] GC(6) Pause Full (G1 Compaction Pause) 900M->850M(1024M) 1500.000ms\n[info][gc] GC(7) Pause Full (G1 Compaction Pause) 900M->850M(1024M) 2500.000ms\n")
+	triggers, err = w.Poll(start.Add(10 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triggers) != 3 {
+		t.Fatalf("expected every rule to match but got %v", triggers)
+	}
+	if !strings.Contains(triggers[0].Reason, "server.log matched: java.lang.OutOfMemoryError") {
+		t.Errorf("unexpected log reason %v", triggers[0].Reason)
+	}
+	if !strings.Contains(triggers[1].Reason, "gc pause of 2.5s") {
+		t.Errorf("expected the longest pause but was %v", triggers[1].Reason)
+	}
+	if triggers[2].Reason != "old generation 95.0% full after a garbage collection" {
+		t.Errorf("unexpected heap reason %v", triggers[2].Reason)
+	}
+	if got := Collects(triggers); strings.Join(got, ",") != "jstack,heap-info,logs" {
+		t.Errorf("expected every capture of the triggers once but got %v", got)
+	}
+	if name := CaptureName("node1", triggers); name != "ddc-watch-node1-20240115T234010Z-oom.tar.gz" {
+		t.Errorf("unexpected capture name %v", name)
+	}
+
+	// the rules with a cooldown are ignored until it passed
+	appendTo(t, serverLog, "java.lang.OutOfMemoryError: Java heap space\n")
+	triggers, err = w.Poll(start.Add(20 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triggers) != 0 {
+		t.Errorf("expected the rules to be cooling down and the heap to be fine but got %v", triggers)
+	}
+	appendTo(t, serverLog, "java.lang.OutOfMemoryError: Java heap space\n")
+	samples = []hotspot.PerfSample{heapSample(7, 500)}
+	triggers, err = w.Poll(start.Add(80 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triggers) != 1 || triggers[0].Rule != "oom" {
+		t.Errorf("expected the log rule to match again after its cooldown but got %v", triggers)
+	}
+}
+
+func TestWatcherPollReportsHeapErrors(t *testing.T) {
+	dir := t.TempDir()
+	rules := []conf.WatchRule{{Name: "oom", LogPattern: regexp.MustCompile("OutOfMemoryError"), Log: conf.WatchLogServer}}
+	serverLog := filepath.Join(dir, "server.log")
+	w := NewWatcher(rules, serverLog, filepath.Join(dir, "gc*.log"), func() (hotspot.PerfSample, error) {
+		return hotspot.PerfSample{}, errors.New("dremio is gone")
+	})
+	if _, err := w.Poll(time.Now()); err == nil || !strings.Contains(err.Error(), "dremio is gone") {
+		t.Errorf("expected the heap error but got %v", err)
+	}
+	appendTo(t, serverLog, "java.lang.OutOfMemoryError: Java heap space\n")
+	triggers, err := w.Poll(time.Now())
+	if err == nil {
+		t.Error("expected the heap error again")
+	}
+	if len(triggers) != 1 {
+		t.Errorf("expected the log rule to still match but got %v", triggers)
+	}
+}
+
+func TestWriteTriggers(t *testing.T) {
+	dir := t.TempDir()
+	at := time.Date(2024, 1, 15, 23, 40, 0, 0, time.UTC)
+	if err := WriteTriggers(dir, []Trigger{{Rule: "oom", At: at, Reason: "server.log matched: OutOfMemoryError", Captures: []string{"logs"}}}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, TriggerFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var triggers []Trigger
+	if err := json.Unmarshal(b, &triggers); err != nil {
+		t.Fatal(err)
+	}
+	if len(triggers) != 1 || triggers[0].Rule != "oom" || !triggers[0].At.Equal(at) {
+		t.Errorf("unexpected triggers %v", triggers)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for i, name := range []string{"backup.tar.gz", "ddc-watch-node1-20240115T234000Z-oom.tar.gz", "ddc-watch-node1-20240115T235000Z-oom.tar.gz", "ddc-watch-node1-20240116T000000Z-long-gc.tar.gz"} {
+		path := filepath.Join(dir, name)
+		appendTo(t, path, "capture")
+		mod := now.Add(time.Duration(i-4) * time.Minute)
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "capture-in-progress"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := Rotate(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0] != "ddc-watch-node1-20240115T234000Z-oom.tar.gz" {
+		t.Errorf("expected the oldest capture to be removed but was %v", removed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "backup.tar.gz")); err != nil {
+		t.Errorf("expected the older tarball that is not a capture to be left alone but got %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Errorf("expected 2 captures, the other tarball and the capture in progress to be left but got %v", len(entries))
+	}
+	if removed, err := Rotate(dir, 2); err != nil || len(removed) != 0 {
+		t.Errorf("expected nothing else to be removed but got %v %v", removed, err)
+	}
+}
+
+func TestPerfDataReaderFindsDremioAgain(t *testing.T) {
+	read := func(pid int) (hotspot.PerfSample, error) {
+		if pid != 2 {
+			return hotspot.PerfSample{}, fmt.Errorf("no hsperfdata for pid %v", pid)
+		}
+		return hotspot.PerfSample{Generations: []hotspot.Generation{{Name: "old", UsedBytes: 50, MaxCapacityBytes: 100}}}, nil
+	}
+	// dremio restarted as pid 2
+	heap := pidReader(1, func() (int, error) { return 2, nil }, read)
+	for i := 0; i < 2; i++ {
+		s, err := heap()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if percent, _, ok := HeapOccupancy(s); !ok || percent != 50 {
+			t.Errorf("expected the heap of the new pid but got %v %v", percent, ok)
+		}
+	}
+	// dremio is still down
+	heap = pidReader(1, func() (int, error) { return 0, errors.New("no dremio") }, read)
+	if _, err := heap(); err == nil || errors.Is(err, ErrDremioGone) {
+		t.Errorf("expected the heap to be read again on the next poll but got %v", err)
+	}
+	// nothing finds dremio again
+	heap = pidReader(1, nil, read)
+	if _, err := heap(); !errors.Is(err, ErrDremioGone) {
+		t.Errorf("expected %v but got %v", ErrDremioGone, err)
+	}
+}
@@ -0,0 +1,237 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/watch"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/collects"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
+)
+
+func TestWatchCaptureOverrides(t *testing.T) {
+	from := time.Date(2024, 1, 15, 23, 30, 0, 0, time.UTC)
+	base := map[string]string{conf.KeyTo: "2024-01-16T00:00:00Z", conf.KeyDisableRESTAPI: "true"}
+	o := watchCaptureOverrides(base, "/tmp/ddc-watch/capture-in-progress", from, 20, []string{conf.WatchCaptureJStack, conf.WatchCaptureJFR})
+	expected := map[string]string{
+		conf.KeyTarballOutDir:           "/tmp/ddc-watch/capture-in-progress",
+		conf.KeyFrom:                    "2024-01-15T23:30:00Z",
+		conf.KeyDisableRESTAPI:          "true",
+		conf.KeyCollectJStack:           "true",
+		conf.KeyDremioJStackTimeSeconds: "20",
+		conf.KeyCollectJFR:              "true",
+		conf.KeyJFRDumpExisting:         "true",
+		conf.KeyCollectJVMHeapInfo:      "false",
+		conf.KeyCollectServerLogs:       "false",
+		conf.KeyCollectGCLogs:           "false",
+		conf.KeyCaptureHeapDump:         "false",
+	}
+	for k, v := range expected {
+		if o[k] != v {
+			t.Errorf("expected %v to be %q but was %q", k, v, o[k])
+		}
+	}
+	if _, ok := o[conf.KeyTo]; ok {
+		t.Error("expected the window to end at the capture")
+	}
+	if _, ok := base[conf.KeyTarballOutDir]; ok {
+		t.Error("expected the overrides of ddc watch to be left alone")
+	}
+}
+
+func tarballEntries(t *testing.T, loc string) map[string]string {
+	t.Helper()
+	f, err := os.Open(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return entries
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[filepath.ToSlash(h.Name)] = string(b)
+	}
+}
+
+func TestTakeWatchCapture(t *testing.T) {
+	dir := t.TempDir()
+	logDir := filepath.Join(dir, "logs")
+	confDir := filepath.Join(dir, "conf")
+	watchDir := filepath.Join(dir, "watch")
+	for _, d := range []string{logDir, confDir} {
+		if err := os.Mkdir(d, 0o700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	serverLog := fmt.Sprintf("%v INFO  old line from before the tail\n%v ERROR java.lang.OutOfMemoryError: Java heap space\n",
+		now.Add(-time.Hour).Format("2006-01-02 15:04:05,000"), now.Add(-time.Minute).Format("2006-01-02 15:04:05,000"))
+	if err := os.WriteFile(filepath.Join(logDir, "server.log"), []byte(serverLog), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(confDir, "dremio.conf"), []byte("paths.local: /tmp\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	yaml := fmt.Sprintf(`
+dremio-log-dir: %v
+dremio-conf-dir: %v
+dremio-pid-detection: false
+disable-rest-api: true
+disable-free-space-check: true
+node-name: node1
+watch-dir: %v
+watch-log-tail-seconds: 600
+collect-os-config: false
+collect-disk-usage: false
+collect-dremio-configuration: false
+watch-rules:
+  - name: oom
+    log-pattern: OutOfMemoryError
+    capture: [logs]
+`, logDir, confDir, watchDir)
+	yamlLoc := filepath.Join(dir, "ddc.yaml")
+	if err := os.WriteFile(yamlLoc, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	watchDDCYamlLoc, watchCollectionMode = yamlLoc, collects.QuickCollection
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+	c, err := conf.ReadConf(hook, map[string]string{conf.KeyTarballOutDir: filepath.Join(dir, "setup")}, yamlLoc, collects.QuickCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(c.WatchDir(), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	triggers := []watch.Trigger{{Rule: "oom", At: now, Reason: "server.log matched: OutOfMemoryError", Captures: []string{conf.WatchCaptureLogs}}}
+	tarball, err := takeWatchCapture(hook, map[string]string{}, c, triggers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tarball != filepath.Join(watchDir, watch.CaptureName("node1", triggers)) {
+		t.Errorf("unexpected capture location %v", tarball)
+	}
+	if _, err := os.Stat(filepath.Join(watchDir, captureInProgressDir)); !os.IsNotExist(err) {
+		t.Errorf("expected the capture in progress to be removed but got %v", err)
+	}
+	entries := tarballEntries(t, tarball)
+	trigger := entries[watch.TriggerFile]
+	gz, err := gzip.NewReader(strings.NewReader(entries["logs/node1/server.log.gz"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := string(b)
+	if !strings.Contains(trigger, `"rule": "oom"`) {
+		t.Errorf("expected the trigger in the capture but got %q", trigger)
+	}
+	if !strings.Contains(log, "OutOfMemoryError") || strings.Contains(log, "old line") {
+		t.Errorf("expected only the tail of server.log but got %q", log)
+	}
+}
+
+func TestRunWatchKeepsPollingDuringACapture(t *testing.T) {
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+	var polls atomic.Int32
+	poll := func(now time.Time) ([]watch.Trigger, error) {
+		switch polls.Add(1) {
+		case 1:
+			return []watch.Trigger{{Rule: "oom", At: now}}, nil
+		case 3:
+			return []watch.Trigger{{Rule: "heap", At: now}}, nil
+		}
+		return nil, nil
+	}
+	release := make(chan struct{})
+	captured := make(chan []watch.Trigger, 2)
+	capture := func(triggers []watch.Trigger) bool {
+		captured <- triggers
+		if triggers[0].Rule == "oom" {
+			<-release
+		}
+		return true
+	}
+	result := make(chan int, 1)
+	go func() {
+		captures, err := runWatch(hook, poll, time.Millisecond, capture)
+		if err != nil {
+			t.Error(err)
+		}
+		result <- captures
+	}()
+	if first := <-captured; first[0].Rule != "oom" {
+		t.Fatalf("expected the oom capture first but got %v", first)
+	}
+	// the heap rule is still checked while the oom capture runs
+	for polls.Load() < 5 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	if second := <-captured; len(second) != 1 || second[0].Rule != "heap" {
+		t.Errorf("expected what matched during the capture to be captured next but got %v", second)
+	}
+	hook.Stop(true)
+	if captures := <-result; captures != 2 {
+		t.Errorf("expected 2 captures but got %v", captures)
+	}
+}
+
+func TestRunWatchStopsWhenDremioIsGone(t *testing.T) {
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+	poll := func(time.Time) ([]watch.Trigger, error) {
+		return nil, fmt.Errorf("unable to read the heap: %w", watch.ErrDremioGone)
+	}
+	captures, err := runWatch(hook, poll, time.Millisecond, func([]watch.Trigger) bool { return true })
+	if !errors.Is(err, watch.ErrDremioGone) {
+		t.Errorf("expected the watch to stop with %v but got %v", watch.ErrDremioGone, err)
+	}
+	if captures != 0 {
+		t.Errorf("expected no captures but got %v", captures)
+	}
+}
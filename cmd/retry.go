@@ -0,0 +1,201 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// cmd package contains all the command line flag and initialization logic for commands
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/collection"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/helpers"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/kubernetes"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/ssh"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/collects"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/consoleprint"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/dirs"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/masking"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/timewindow"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/versions"
+	"github.com/spf13/cobra"
+)
+
+var (
+	retryFrom          string
+	retryOutputLoc     string
+	retryDisablePrompt bool
+)
+
+var RetryCmd = &cobra.Command{
+	Use:   "retry",
+	Short: "Re-collect only the nodes that failed in a previous collection",
+	Long: `Re-collect only the nodes that failed in a previous collection using the same collection mode and transport,
+the result is merged with the data of the previous archive into a new archive
+examples:
+
+	ddc retry --from diag.tgz
+`,
+	Run: func(_ *cobra.Command, _ []string) {
+		if err := Retry(retryFrom, retryOutputLoc); err != nil {
+			consoleprint.ErrorPrint(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+// RetryOutputLoc is the default location of the archive produced by a retry, it is placed next to the previous archive
+func RetryOutputLoc(previousArchive string) string {
+	return strings.TrimSuffix(previousArchive, filepath.Ext(previousArchive)) + "-retry.tgz"
+}
+
+// Retry reads the summary from either a ddc archive or a summary.json and collects the failed nodes again
+func Retry(from, output string) error {
+	previous, err := collection.ReadSummary(from)
+	if err != nil {
+		return err
+	}
+	if len(previous.NodeResults) == 0 {
+		return fmt.Errorf("no node results found in %v, it was likely created by an older version of ddc and cannot be retried", from)
+	}
+	failed := previous.FailedNodes()
+	if len(failed) == 0 {
+		fmt.Printf("all nodes in %v completed, nothing to retry\n", from)
+		return nil
+	}
+	params := previous.Parameters
+	archiveLoc := from
+	if strings.HasSuffix(from, ".json") {
+		archiveLoc = params.OutputLoc
+	}
+	if archiveLoc == "" {
+		return fmt.Errorf("no archive location recorded in %v, pass the archive to --from instead", from)
+	}
+	archiveLoc, err = filepath.Abs(archiveLoc)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(archiveLoc); err != nil {
+		return fmt.Errorf("previous archive %v is needed to merge the retried nodes: %w", archiveLoc, err)
+	}
+	if output == "" {
+		output = RetryOutputLoc(archiveLoc)
+	}
+	absOutputLoc, err := filepath.Abs(output)
+	if err != nil {
+		return err
+	}
+	if absOutputLoc == archiveLoc {
+		return errors.New("--output-file must be different from the previous archive")
+	}
+	params.OutputLoc = absOutputLoc
+
+	var maxTime time.Duration
+	if params.MaxCollectionTime != "" {
+		maxTime, err = time.ParseDuration(params.MaxCollectionTime)
+		if err != nil {
+			return fmt.Errorf("invalid max collection time %q in %v: %w", params.MaxCollectionTime, from, err)
+		}
+	}
+	window, err := timewindow.Parse(params.From, params.To, time.Now())
+	if err != nil {
+		return fmt.Errorf("invalid collection window in %v: %w", from, err)
+	}
+	hook, deadline := newCollectionHook(maxTime)
+	defer hook.Cleanup()
+	handleInterrupt(hook)
+	if retryDisablePrompt {
+		consoleprint.EnableStatusOutput()
+	}
+	simplelog.Info(versions.GetCLIVersion())
+	simplelog.Infof("retrying nodes %v from %v with collection mode %v", strings.Join(failed, ", "), from, params.CollectionMode)
+
+	confData, mode, err := ValidateAndReadYaml(params.DDCYamlLoc, params.CollectionMode)
+	if err != nil {
+		return fmt.Errorf("CRITICAL ERROR: unable to parse %v: %w", params.DDCYamlLoc, err)
+	}
+	if !params.DisableFreeSpaceCheck {
+		if err := dirs.CheckFreeSpace(filepath.Dir(absOutputLoc), params.MinFreeSpaceGB); err != nil {
+			return fmt.Errorf("%w, therefore use --output-file to output the tarball to somewhere with more space", err)
+		}
+	}
+	dremioPAT := confData[conf.KeyDremioPatToken].(string)
+	if mode.Base == collects.HealthCheckCollection && dremioPAT == "" {
+		pat, err := masking.PromptForPAT()
+		if err != nil {
+			return fmt.Errorf("unable to get PAT: %w", err)
+		}
+		dremioPAT = pat
+	}
+	if !retryDisablePrompt {
+		stop := startTicker()
+		hook.AddUIStop(stop)
+	}
+	collectionArgs := collection.Args{
+		OutputLoc:             absOutputLoc,
+		DDCfs:                 helpers.NewRealFileSystem(),
+		DremioPAT:             dremioPAT,
+		TransferDir:           params.TransferDir,
+		DDCYamlLoc:            params.DDCYamlLoc,
+		Enabled:               previous.CollectionsEnabled,
+		Disabled:              previous.CollectionsDisabled,
+		DisableFreeSpaceCheck: params.DisableFreeSpaceCheck,
+		MinFreeSpaceGB:        params.MinFreeSpaceGB,
+		CollectionMode:        params.CollectionMode,
+		TransferThreads:       params.TransferThreads,
+		Parameters:            params,
+		Window:                window,
+		Deadline:              deadline,
+		PreviousCollection: &collection.PreviousCollection{
+			ArchiveLoc: archiveLoc,
+			Summary:    previous,
+		},
+	}
+	sshArgs := ssh.Args{
+		SSHKeyLoc:      params.SSHKeyLoc,
+		SSHUser:        params.SSHUser,
+		SudoUser:       params.SudoUser,
+		CoordinatorStr: strings.Join(previous.Coordinators, ","),
+		ExecutorStr:    strings.Join(previous.Executors, ","),
+	}
+	kubeArgs := kubernetes.KubeArgs{
+		Namespace:     params.Namespace,
+		LabelSelector: params.LabelSelector,
+		K8SContext:    params.K8SContext,
+	}
+	if err := RemoteCollect(collectionArgs, sshArgs, kubeArgs, params.FallbackEnabled, hook); err != nil {
+		consoleprint.UpdateResult(err.Error())
+	}
+	// we put the error in result so just return nil
+	if !retryDisablePrompt {
+		consoleprint.PrintState()
+	}
+	return nil
+}
+
+func init() {
+	RetryCmd.Flags().StringVar(&retryFrom, "from", "", "previous ddc archive or summary.json to retry the failed nodes of")
+	RetryCmd.Flags().StringVar(&retryOutputLoc, "output-file", "", "name and location of the merged diagnostic tarball (default is next to the previous archive with a -retry suffix)")
+	RetryCmd.Flags().BoolVar(&retryDisablePrompt, "disable-prompt", false, "disables the prompt ui")
+	if err := RetryCmd.MarkFlagRequired("from"); err != nil {
+		fmt.Printf("unable to mark flag required critical error %v", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,72 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// cmd package contains all the command line flag and initialization logic for commands
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/collection"
+)
+
+func writeTestSummary(t *testing.T, summaryInfo collection.SummaryInfo) string {
+	t.Helper()
+	text, err := summaryInfo.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc := filepath.Join(t.TempDir(), "summary.json")
+	if err := os.WriteFile(loc, []byte(text), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestRetryWithOldSummary(t *testing.T) {
+	loc := writeTestSummary(t, collection.SummaryInfo{})
+	err := Retry(loc, "")
+	if err == nil || !strings.Contains(err.Error(), "older version of ddc") {
+		t.Errorf("expected older version error but got %v", err)
+	}
+}
+
+func TestRetryNothingFailed(t *testing.T) {
+	loc := writeTestSummary(t, collection.SummaryInfo{
+		NodeResults: []collection.NodeResult{{Host: "coord1", Status: collection.NodeStatusCompleted}},
+	})
+	if err := Retry(loc, ""); err != nil {
+		t.Errorf("expected no error when nothing failed but got %v", err)
+	}
+}
+
+func TestRetryMissingArchive(t *testing.T) {
+	loc := writeTestSummary(t, collection.SummaryInfo{
+		NodeResults: []collection.NodeResult{{Host: "exec1", Status: collection.NodeStatusFailed}},
+		Parameters:  collection.CollectionParameters{OutputLoc: filepath.Join(t.TempDir(), "missing.tgz")},
+	})
+	err := Retry(loc, "")
+	if err == nil || !strings.Contains(err.Error(), "is needed to merge the retried nodes") {
+		t.Errorf("expected missing archive error but got %v", err)
+	}
+}
+
+func TestRetryOutputLoc(t *testing.T) {
+	if actual := RetryOutputLoc("/tmp/diag.tgz"); actual != "/tmp/diag-retry.tgz" {
+		t.Errorf("expected /tmp/diag-retry.tgz but got %v", actual)
+	}
+}
@@ -16,6 +16,7 @@
 package cmd
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -35,14 +36,17 @@ import (
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/kubectl"
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/kubernetes"
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/ssh"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/summary"
 	version "github.com/dremio/dremio-diagnostic-collector/v3/cmd/version"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/collects"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/consoleprint"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/dirs"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/dryrun"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/masking"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
-	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/validation"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/snapshot"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/timewindow"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/versions"
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
@@ -77,6 +81,12 @@ var (
 	pid                   string
 	transferThreads       int
 	manualPATPrompt       bool
+	maxCollectionTime     time.Duration
+	dryRun                bool
+	dryRunJSON            string
+	windowFrom            string
+	windowTo              string
+	snapshotAtFlag        string
 )
 
 // var isEmbeddedK8s bool
@@ -149,7 +159,7 @@ func RemoteCollect(collectionArgs collection.Args, sshArgs ssh.Args, kubeArgs ku
 		0,
 	)
 	consoleprint.UpdateCollectionMode(collectionArgs.CollectionMode)
-	outputDir, err := filepath.Abs(filepath.Dir(outputLoc))
+	outputDir, err := filepath.Abs(filepath.Dir(collectionArgs.OutputLoc))
 	// This is where the SSH or K8s collection is determined. We create an instance of the interface based on this
 	// which then determines whether the commands are routed to the SSH or K8s commands
 	if err != nil {
@@ -157,6 +167,59 @@ func RemoteCollect(collectionArgs collection.Args, sshArgs ssh.Args, kubeArgs ku
 	}
 	cs := helpers.NewHCCopyStrategy(collectionArgs.DDCfs, &helpers.RealTimeService{}, outputDir)
 	hook.AddFinalSteps(cs.Close, "running cleanup on copy strategy")
+	collectorStrategy, clusterCollect, err := newCollectorStrategy(collectionArgs, sshArgs, kubeArgs, fallbackEnabled, hook, cs)
+	if err != nil {
+		return err
+	}
+	collectionArgs.Parameters.Transport = collectorStrategy.Name()
+
+	// Launch the collection
+	err = collection.Execute(collectorStrategy,
+		cs,
+		collectionArgs,
+		hook,
+		clusterCollect,
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// DryRunCollect resolves the transport and nodes and writes the plan of what a collection
+// would do, ddc is not copied to the nodes and nothing is collected
+func DryRunCollect(w io.Writer, collectionArgs collection.Args, sshArgs ssh.Args, kubeArgs kubernetes.KubeArgs, fallbackEnabled bool, hook shutdown.Hook, confData map[string]interface{}, jsonLoc string) error {
+	collectorStrategy, _, err := newCollectorStrategy(collectionArgs, sshArgs, kubeArgs, fallbackEnabled, hook, nil)
+	if err != nil {
+		return err
+	}
+	var clusterCollection []string
+	if !fallbackEnabled && kubeArgs.Namespace != "" {
+		clusterCollection = collection.ClusterK8sPlan(kubeArgs.Namespace)
+	}
+	plan, err := collection.BuildDryRunPlan(collectorStrategy, collectionArgs, confData, clusterCollection)
+	if err != nil {
+		return fmt.Errorf("unable to build dry run plan: %w", err)
+	}
+	if err := collection.WriteDryRunPlan(w, plan, dryrun.FormatText); err != nil {
+		return err
+	}
+	if jsonLoc != "" {
+		var b bytes.Buffer
+		if err := collection.WriteDryRunPlan(&b, plan, dryrun.FormatJSON); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Clean(jsonLoc), b.Bytes(), 0o600); err != nil {
+			return fmt.Errorf("unable to write dry run plan to %v: %w", jsonLoc, err)
+		}
+	}
+	return nil
+}
+
+// newCollectorStrategy picks the transport and the cluster level collection that goes with it
+func newCollectorStrategy(collectionArgs collection.Args, sshArgs ssh.Args, kubeArgs kubernetes.KubeArgs, fallbackEnabled bool, hook shutdown.Hook, cs collection.CopyStrategy) (collection.Collector, func(), error) {
+	patSet := collectionArgs.DremioPAT != ""
+	var err error
 	clusterCollect := func() {}
 	var collectorStrategy collection.Collector
 	if fallbackEnabled {
@@ -178,9 +241,9 @@ func RemoteCollect(collectionArgs collection.Args, sshArgs ssh.Args, kubeArgs ku
 		consoleprint.UpdateCollectionArgs(fmt.Sprintf("namespace: '%v', label selector: '%v'", kubeArgs.Namespace, kubeArgs.LabelSelector))
 		collectorStrategy, err = kubernetes.NewK8sAPI(kubeArgs, hook)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
-		if !disableKubeCtl {
+		if !collectionArgs.Parameters.DisableKubectl {
 			potentialStrategy, err := kubectl.NewKubectlK8sActions(hook, kubeArgs.Namespace, kubeArgs.K8SContext)
 			if err != nil {
 				simplelog.Warningf("kubectl not available failling back to kubeapi: %v", err)
@@ -202,7 +265,7 @@ func RemoteCollect(collectionArgs collection.Args, sshArgs ssh.Args, kubeArgs ku
 		)
 
 		clusterCollect = func() {
-			clientSet, _, err := kubernetes.GetClientset(k8sContext)
+			clientSet, _, err := kubernetes.GetClientset(kubeArgs.K8SContext)
 			if err != nil {
 				simplelog.Errorf("when getting Kubernetes info, the following error was returned: %v", err)
 				return
@@ -223,35 +286,37 @@ func RemoteCollect(collectionArgs collection.Args, sshArgs ssh.Args, kubeArgs ku
 			fmt.Println("")
 			helpErr := RootCmd.Help()
 			if helpErr != nil {
-				return fmt.Errorf("unable to print help: %w", helpErr)
+				return nil, nil, fmt.Errorf("unable to print help: %w", helpErr)
 			}
-			return fmt.Errorf("invalid command flag detected: %w", err)
+			return nil, nil, fmt.Errorf("invalid command flag detected: %w", err)
 		}
 		simplelog.Info("using SSH based collection")
 		consoleprint.UpdateCollectionArgs(fmt.Sprintf("login: %v, user: %v, coordinator: %v, executor: %v, key: %v", sshArgs.SSHUser, sshArgs.SudoUser, sshArgs.CoordinatorStr, sshArgs.ExecutorStr, sshArgs.SSHKeyLoc))
 		collectorStrategy = ssh.NewCmdSSHActions(sshArgs, hook)
 	}
-
-	// Launch the collection
-	err = collection.Execute(collectorStrategy,
-		cs,
-		collectionArgs,
-		hook,
-		clusterCollect,
-	)
-	if err != nil {
-		return err
-	}
-	return nil
+	return collectorStrategy, clusterCollect, nil
 }
 
-func ValidateAndReadYaml(ddcYaml, collectionMode string) (map[string]interface{}, error) {
+// ValidateAndReadYaml parses ddc.yaml and resolves the collection mode, which is either built-in or declared in ddc.yaml
+func ValidateAndReadYaml(ddcYaml, collectionMode string) (map[string]interface{}, conf.ResolvedMode, error) {
 	emptyOverrides := make(map[string]string)
 	confData, err := conf.ParseConfig(ddcYaml, emptyOverrides)
 	if err != nil {
-		return make(map[string]interface{}), err
+		return make(map[string]interface{}), conf.ResolvedMode{}, err
+	}
+	// fail before any node is contacted instead of on every node
+	modes, err := conf.ParseCollectionModes(confData, ddcYaml)
+	if err != nil {
+		return make(map[string]interface{}), conf.ResolvedMode{}, err
+	}
+	mode, err := conf.ApplyCollectionMode(confData, modes, collectionMode, emptyOverrides)
+	if err != nil {
+		return make(map[string]interface{}), conf.ResolvedMode{}, err
+	}
+	conf.SetViperDefaults(confData, "", 0, mode.Base)
+	if _, err := conf.ParseCustomCollectors(confData[conf.KeyCustomCollectors], conf.CollectionModeNames(modes)); err != nil {
+		return make(map[string]interface{}), conf.ResolvedMode{}, err
 	}
-	conf.SetViperDefaults(confData, "", 0, collectionMode)
 	simplelog.Infof("parsed configuration for %v follows", ddcYaml)
 	for k, v := range confData {
 		if k == conf.KeyDremioPatToken && v != "" {
@@ -263,7 +328,7 @@ func ValidateAndReadYaml(ddcYaml, collectionMode string) (map[string]interface{}
 
 	// set defaults so we get an accurate reading of if these will be enabled or not
 	conf.SetViperDefaults(confData, "", 0, collects.StandardCollection)
-	return confData, nil
+	return confData, mode, nil
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -272,17 +337,22 @@ func Execute(args []string) error {
 	foundCmd, _, err := RootCmd.Find(args[1:])
 	// default cmd if no cmd is given
 	if err == nil && foundCmd.Use == RootCmd.Use && !errors.Is(foundCmd.Flags().Parse(args[1:]), pflag.ErrHelp) {
-		hook := shutdown.NewHook()
+		window, err := timewindow.Parse(windowFrom, windowTo, time.Now())
+		if err != nil {
+			return err
+		}
+		snapshotAt, err := snapshot.ParseAt(snapshotAtFlag, time.Now())
+		if err != nil {
+			return err
+		}
+		if !snapshotAt.IsZero() && !snapshotAt.After(time.Now()) {
+			msg := fmt.Sprintf("the snapshot time %v has passed, the nodes will take the snapshot as soon as they start", snapshotAt.Format(time.RFC3339))
+			simplelog.Warning(msg)
+			consoleprint.WarningPrint(msg)
+		}
+		hook, deadline := newCollectionHook(maxCollectionTime)
 		defer hook.Cleanup()
-		c := make(chan os.Signal, 1)
-		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-		go func() {
-			<-c
-			simplelog.Info("CTRL+C interrupt starting graceful shutdown")
-			consoleprint.UpdateResult("CANCELLING")
-			hook.Interrupt()
-			os.Exit(1)
-		}()
+		handleInterrupt(hook)
 		if disablePrompt {
 			consoleprint.EnableStatusOutput()
 		}
@@ -409,11 +479,12 @@ func Execute(args []string) error {
 
 		simplelog.Info(versions.GetCLIVersion())
 		simplelog.Infof("cli command: %v", strings.Join(args, " "))
-		confData, err := ValidateAndReadYaml(ddcYamlLoc, collectionMode)
+		confData, mode, err := ValidateAndReadYaml(ddcYamlLoc, collectionMode)
 		if err != nil {
 			return fmt.Errorf("CRITICAL ERROR: unable to parse %v: %w", ddcYamlLoc, err)
 		}
-		if !disableFreeSpaceCheck {
+		// a dry run writes nothing so the free space is not needed
+		if !disableFreeSpaceCheck && !dryRun {
 			abs, err := filepath.Abs(outputLoc)
 			if err != nil {
 				return err
@@ -440,11 +511,7 @@ func Execute(args []string) error {
 				dremioPAT = strings.TrimSpace(string(b[:]))
 			}
 		}
-		if err := validation.ValidateCollectMode(collectionMode); err != nil {
-			return err
-		}
-
-		if manualPATPrompt || (collectionMode == collects.HealthCheckCollection && dremioPAT == "") {
+		if !dryRun && (manualPATPrompt || (mode.Base == collects.HealthCheckCollection && dremioPAT == "")) {
 			pat, err := masking.PromptForPAT()
 			if err != nil {
 				return fmt.Errorf("unable to get PAT: %w", err)
@@ -517,10 +584,14 @@ func Execute(args []string) error {
 				}
 			}
 		}
-		if !disablePrompt {
+		if !disablePrompt && !dryRun {
 			stop := startTicker()
 			hook.AddUIStop(stop)
 		}
+		absOutputLoc, err := filepath.Abs(outputLoc)
+		if err != nil {
+			return err
+		}
 		collectionArgs := collection.Args{
 			OutputLoc:             filepath.Clean(outputLoc),
 			DDCfs:                 helpers.NewRealFileSystem(),
@@ -533,6 +604,30 @@ func Execute(args []string) error {
 			MinFreeSpaceGB:        minFreeSpaceGB,
 			CollectionMode:        collectionMode,
 			TransferThreads:       transferThreads,
+			Window:                window,
+			SnapshotAt:            snapshotAt,
+			Deadline:              deadline,
+			Parameters: collection.CollectionParameters{
+				CollectionMode:        collectionMode,
+				OutputLoc:             absOutputLoc,
+				DDCYamlLoc:            ddcYamlLoc,
+				TransferDir:           transferDir,
+				TransferThreads:       transferThreads,
+				DisableFreeSpaceCheck: disableFreeSpaceCheck,
+				MinFreeSpaceGB:        minFreeSpaceGB,
+				MaxCollectionTime:     formatMaxCollectionTime(maxCollectionTime),
+				From:                  window.FromString(),
+				To:                    window.ToString(),
+				SnapshotAt:            formatSnapshotAt(snapshotAt),
+				FallbackEnabled:       enableFallback,
+				Namespace:             namespace,
+				LabelSelector:         labelSelector,
+				K8SContext:            k8sContext,
+				DisableKubectl:        disableKubeCtl,
+				SSHUser:               sshUser,
+				SSHKeyLoc:             sshKeyLoc,
+				SudoUser:              sudoUser,
+			},
 		}
 		sshArgs := ssh.Args{
 			SSHKeyLoc:      sshKeyLoc,
@@ -546,6 +641,9 @@ func Execute(args []string) error {
 			LabelSelector: labelSelector,
 			K8SContext:    k8sContext,
 		}
+		if dryRun {
+			return DryRunCollect(os.Stdout, collectionArgs, sshArgs, kubeArgs, enableFallback, hook, confData, dryRunJSON)
+		}
 		if err := RemoteCollect(collectionArgs, sshArgs, kubeArgs, enableFallback, hook); err != nil {
 			consoleprint.UpdateResult(err.Error())
 		}
@@ -561,6 +659,39 @@ func Execute(args []string) error {
 	return nil
 }
 
+// newCollectionHook creates the shutdown hook, when maxCollectionTime is set the returned deadline is
+// given to the nodes and the hook context only expires after the transfer grace period so tarballs still
+// being copied at the deadline are not lost
+func newCollectionHook(maxCollectionTime time.Duration) (shutdown.Hook, time.Time) {
+	if maxCollectionTime > 0 {
+		deadline := time.Now().Add(maxCollectionTime)
+		return shutdown.NewHookWithDeadline(deadline.Add(collection.TransferGracePeriod)), deadline
+	}
+	return shutdown.NewHook(), time.Time{}
+}
+
+// handleInterrupt handles CTRL+C in two stages. The first stops new work and asks the running
+// local-collects to archive what they have so it can still be transferred. A second CTRL+C
+// or a SIGTERM runs the shutdown hook and exits.
+func handleInterrupt(hook shutdown.Hook) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if sig := <-c; sig == os.Interrupt {
+			msg := "CTRL+C interrupt: stopping and archiving what was collected, press CTRL+C again to abort"
+			simplelog.Info(msg)
+			consoleprint.AddWarningToConsole(msg)
+			consoleprint.UpdateResult("STOPPING - CTRL+C AGAIN TO ABORT")
+			hook.Stop(false)
+			<-c
+		}
+		simplelog.Info("CTRL+C interrupt starting graceful shutdown")
+		consoleprint.UpdateResult("CANCELLING")
+		hook.Interrupt()
+		os.Exit(1)
+	}()
+}
+
 type unableToGetHomeDir struct {
 	Err error
 }
@@ -595,7 +726,7 @@ func init() {
 	RootCmd.Flags().StringVarP(&labelSelector, "label-selector", "l", "role=dremio-cluster-pod", "K8S ONLY: select which pods to collect: follows kubernetes label syntax see https://kubernetes.io/docs/concepts/overview/working-with-objects/labels/#label-selectors")
 
 	// shared flags
-	RootCmd.Flags().StringVar(&collectionMode, "collect", "light", "type of collection: 'light'- 2 days of logs (no top or jfr). 'standard' - includes jfr, top, 7 days of logs and 30 days of queries.json logs. 'standard+jstack' - all of 'standard' plus jstack. 'health-check' - all of 'standard' + WLM, KV Store Report, 25,000 Job Profiles")
+	RootCmd.Flags().StringVar(&collectionMode, "collect", "light", "type of collection: 'light'- 2 days of logs (no top or jfr). 'standard' - includes jfr, top, 7 days of logs and 30 days of queries.json logs. 'standard+jstack' - all of 'standard' plus jstack, a class histogram trend, native memory, heap info, vm info, code cache and metaspace. 'health-check' - all of 'standard' + WLM, KV Store Report, 25,000 Job Profiles. Custom modes declared in collection-modes of ddc.yaml are also accepted")
 	RootCmd.Flags().BoolVar(&disableFreeSpaceCheck, conf.KeyDisableFreeSpaceCheck, false, "disables the free space check for the --transfer-dir")
 	RootCmd.Flags().BoolVar(&disablePrompt, "disable-prompt", false, "disables the prompt ui")
 	RootCmd.Flags().BoolVarP(&disableKubeCtl, "disable-kubectl", "d", false, "uses the embedded k8s api client and skips the use of kubectl for transfers and copying")
@@ -607,6 +738,12 @@ func init() {
 		os.Exit(1)
 	}
 	RootCmd.Flags().IntVar(&transferThreads, "transfer-threads", 2, "number of threads to transfer tarballs")
+	RootCmd.Flags().DurationVar(&maxCollectionTime, conf.KeyMaxCollectionTime, 0, "max time for the whole collection (for example 2h), when reached nodes are stopped, transfers already running get 5 more minutes, and what was collected is archived with incomplete nodes marked in the summary. 0 means no limit")
+	RootCmd.Flags().StringVar(&windowFrom, conf.KeyFrom, "", "only collect logs, gc logs and queries.json from this time on, RFC3339 with the timezone such as 2024-01-15T23:40:00-05:00. Logs are trimmed to the lines inside of the window")
+	RootCmd.Flags().StringVar(&windowTo, conf.KeyTo, "", "only collect logs, gc logs and queries.json up to this time, RFC3339 with the timezone such as 2024-01-16T00:10:00-05:00. Defaults to the time of the collection")
+	RootCmd.Flags().StringVar(&snapshotAtFlag, conf.KeySnapshotAt, "", "take an incident snapshot of thread dumps, thread cpu, heap and gc state on every node at the same instants, RFC3339 such as 2024-01-15T23:40:00-05:00 or a duration from now such as 2m. Allow enough time for ddc to be copied to the nodes")
+	RootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the nodes, commands, effective configuration, enabled and disabled collections, matching logs and estimated size without copying ddc to the nodes or collecting anything")
+	RootCmd.Flags().StringVar(&dryRunJSON, "dry-run-json", "", "with --dry-run also write the plan as json to this file")
 	var defaultMaxFreeSpace uint64 = 40
 	RootCmd.Flags().Uint64Var(&minFreeSpaceGB, "min-free-space-gb", defaultMaxFreeSpace, "min free space needed in GB for the process to run")
 	RootCmd.Flags().StringVar(&transferDir, "transfer-dir", fmt.Sprintf("/tmp/ddc-%v", time.Now().Format("20060102150405")), "directory to use for communication between the local-collect command and this one")
@@ -621,8 +758,27 @@ func init() {
 
 	// init
 	RootCmd.AddCommand(local.LocalCollectCmd)
+	RootCmd.AddCommand(local.WatchCmd)
 	RootCmd.AddCommand(version.VersionCmd)
 	RootCmd.AddCommand(awselogs.AWSELogsCmd)
+	RootCmd.AddCommand(summary.SummaryCmd)
+	RootCmd.AddCommand(RetryCmd)
+}
+
+// formatMaxCollectionTime is blank when there is no limit so it is left out of the summary
+func formatMaxCollectionTime(maxCollectionTime time.Duration) string {
+	if maxCollectionTime <= 0 {
+		return ""
+	}
+	return maxCollectionTime.String()
+}
+
+// formatSnapshotAt is the snapshot time for the summary, empty when there is no snapshot
+func formatSnapshotAt(at time.Time) string {
+	if at.IsZero() {
+		return ""
+	}
+	return at.Format(time.RFC3339Nano)
 }
 
 func validateSSHParameters(sshArgs ssh.Args) error {
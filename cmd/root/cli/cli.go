@@ -58,9 +58,21 @@ func NewCli(hook shutdown.CancelHook) CmdExecutor {
 	}
 }
 
+// NewDetachedCli runs every command in its own process group so a CTRL+C in the terminal only reaches ddc,
+// which then interrupts it with InterruptProcess. Commands that can prompt on the terminal, such as ssh and
+// kubectl, have to use NewCli
+func NewDetachedCli(hook shutdown.CancelHook) CmdExecutor {
+	return &cli{
+		hook:     hook,
+		detached: true,
+	}
+}
+
 // cli
 type cli struct {
 	hook shutdown.CancelHook
+	// detached commands run in their own process group
+	detached bool
 }
 
 // ExecuteAndStreamOutput runs a system command and streams the output (stdout)
@@ -78,6 +90,9 @@ func (c *cli) ExecuteAndStreamOutput(mask bool, outputHandler OutputHandler, pat
 	// Log the command that's about to be run
 	logArgs(mask, args)
 	cmd := exec.CommandContext(c.hook.GetContext(), args[0], args[1:]...)
+	if c.detached {
+		detachFromTerminalSignals(cmd)
+	}
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return UnableToStartErr{Err: err, Cmd: strings.Join(args, " ")}
@@ -146,6 +161,9 @@ func (c *cli) Execute(mask bool, args ...string) (string, error) {
 	// Log the command that's about to be run
 	logArgs(mask, args)
 	cmd := exec.Command(args[0], args[1:]...)
+	if c.detached {
+		detachFromTerminalSignals(cmd)
+	}
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return string(output), UnableToStartErr{Err: err, Cmd: strings.Join(args, " ")}
@@ -16,10 +16,13 @@ package cli_test
 
 import (
 	"fmt"
+	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/cli"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/output"
@@ -162,3 +165,51 @@ func TestExecute_WhenCommandIsInvalid(t *testing.T) {
 		t.Errorf("Expected error message to contain '%s', but it was %v", expectedErr, err)
 	}
 }
+
+func TestInterruptProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("CTRL+BREAK needs a console process group")
+	}
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := cli.InterruptProcess(cmd.Process.Pid); err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	if err := cmd.Wait(); err == nil {
+		t.Error("expected the interrupted process to exit with an error")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected the process to stop on interrupt but it took %v", elapsed)
+	}
+}
+
+func TestOnlyTheDetachedCliLeavesTheProcessGroup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process groups are compared with ps")
+	}
+	// the process group of the child and of the test
+	script := fmt.Sprintf("ps -o pgid= -p $$; ps -o pgid= -p %v", os.Getpid())
+	for _, tc := range []struct {
+		name      string
+		cli       cli.CmdExecutor
+		sameGroup bool
+	}{
+		{"cli", cli.NewCli(hook), true},
+		{"detached cli", cli.NewDetachedCli(hook), false},
+	} {
+		out, err := tc.cli.Execute(false, "sh", "-c", script)
+		if err != nil {
+			t.Fatalf("%v: %v %v", tc.name, err, out)
+		}
+		groups := strings.Fields(out)
+		if len(groups) != 2 {
+			t.Fatalf("%v: unexpected ps output %q", tc.name, out)
+		}
+		if same := groups[0] == groups[1]; same != tc.sameGroup {
+			t.Errorf("%v: expected the child to be in the process group of ddc to be %v but got groups %v", tc.name, tc.sameGroup, groups)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+//go:build windows
+// +build windows
+
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// detachFromTerminalSignals puts the child in its own process group so that a CTRL+C in the
+// console is only received by ddc, which then decides how to stop the child
+func detachFromTerminalSignals(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: windows.CREATE_NEW_PROCESS_GROUP}
+}
+
+// InterruptProcess asks a child of NewDetachedCli to stop as if CTRL+C was pressed.
+// CTRL+C cannot be sent to another process group on Windows so CTRL+BREAK is sent to the group of the
+// child, Go programs receive both as os.Interrupt.
+func InterruptProcess(pid int) error {
+	return windows.GenerateConsoleCtrlEvent(windows.CTRL_BREAK_EVENT, uint32(pid)) // #nosec G115
+}
@@ -16,11 +16,13 @@
 package collection
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/consoleprint"
@@ -112,6 +114,50 @@ func extractJobProgressText(line string) (status string, statusUX string, messag
 	return
 }
 
+// localCollectBudgetRatio is the share of the remaining collection time given to local-collect,
+// the rest is kept for transferring and archiving the tarballs
+const localCollectBudgetRatio = 0.8
+
+// LocalCollectBudget is how long local-collect may run given the time left before the deadline
+func LocalCollectBudget(remaining time.Duration) time.Duration {
+	return time.Duration(float64(remaining) * localCollectBudgetRatio).Truncate(time.Second)
+}
+
+// LocalCollectArgs is the local-collect command line run on the host, mask is true
+// when the PAT is passed on standard in so it must be masked in the logs
+func LocalCollectArgs(c HostCaptureConfiguration, pathToDDC string, skipRESTCollect, disableFreeSpaceCheck bool, minFreeSpaceGB uint64) (args []string, mask bool, err error) {
+	pidFile := path.Join(c.TransferDir, "ddc.pid")
+	args = []string{pathToDDC, "local-collect", fmt.Sprintf("--%v", conf.KeyTarballOutDir), c.TransferDir, fmt.Sprintf("--%v", conf.KeyCollectionMode), c.CollectionMode, fmt.Sprintf("--%v", conf.KeyMinFreeSpaceGB), fmt.Sprintf("%v", minFreeSpaceGB), "--pid", pidFile}
+	if disableFreeSpaceCheck {
+		args = append(args, fmt.Sprintf("--%v", conf.KeyDisableFreeSpaceCheck))
+	}
+	if !c.Deadline.IsZero() {
+		budget := LocalCollectBudget(time.Until(c.Deadline))
+		if budget <= 0 {
+			return nil, false, fmt.Errorf("host %v not collected: %w", c.Host, context.DeadlineExceeded)
+		}
+		args = append(args, fmt.Sprintf("--%v", conf.KeyMaxCollectionTime), budget.String())
+	}
+	if from := c.Window.FromString(); from != "" {
+		args = append(args, fmt.Sprintf("--%v", conf.KeyFrom), from)
+	}
+	if to := c.Window.ToString(); to != "" {
+		args = append(args, fmt.Sprintf("--%v", conf.KeyTo), to)
+	}
+	if !c.SnapshotAt.IsZero() {
+		args = append(args, fmt.Sprintf("--%v", conf.KeySnapshotAt), c.SnapshotAt.UTC().Format(time.RFC3339Nano), fmt.Sprintf("--%v", conf.KeySnapshotClockOffsetMS), fmt.Sprintf("%v", c.SnapshotClockOffset.Milliseconds()))
+	}
+	if skipRESTCollect {
+		// if skipRESTCollect is set blank the pat
+		args = append(args, fmt.Sprintf("--%v", conf.KeyDisableRESTAPI))
+	} else if c.DremioPAT != "" {
+		// if the dremio PAT is set, set the pat-stdin value so we can pass it in via that mechanism
+		args = append(args, "--pat-stdin")
+		mask = true
+	}
+	return args, mask, nil
+}
+
 // valid status list
 
 // Capture collects diagnostics, conf files and log files from the target hosts. Failures are permissive and
@@ -256,27 +302,19 @@ func StartCapture(c HostCaptureConfiguration, localDDCPath, localDDCYamlPath str
 	consoleprint.UpdateNodeState(nodeState)
 	simplelog.HostLog(host, fmt.Sprintf("%#v", nodeState))
 
+	if isStopped(c.Stopped) {
+		return fmt.Errorf("host %v not collected: %w", host, shutdown.ErrStopped)
+	}
 	// execute local-collect with a tarball-out-dir flag it must match our transfer-dir flag
-	var mask bool // to mask PAT token in logs
 	pidFile := path.Join(c.TransferDir, "ddc.pid")
 	c.Collector.SetHostPid(c.Host, pidFile)
-	localCollectArgs := []string{pathToDDC, "local-collect", fmt.Sprintf("--%v", conf.KeyTarballOutDir), c.TransferDir, fmt.Sprintf("--%v", conf.KeyCollectionMode), c.CollectionMode, fmt.Sprintf("--%v", conf.KeyMinFreeSpaceGB), fmt.Sprintf("%v", minFreeSpaceGB), "--pid", pidFile}
-	if disableFreeSpaceCheck {
-		localCollectArgs = append(localCollectArgs, fmt.Sprintf("--%v", conf.KeyDisableFreeSpaceCheck))
-	}
-	if skipRESTCollect {
-		// if skipRESTCollect is set blank the pat
-		localCollectArgs = append(localCollectArgs, fmt.Sprintf("--%v", conf.KeyDisableRESTAPI))
-	} else if dremioPAT != "" {
-		// if the dremio PAT is set, set the pat-stdin value so we can pass it in via that mechanism
-		localCollectArgs = append(localCollectArgs, "--pat-stdin")
-		mask = true
-	} else {
-		mask = false
+	localCollectArgs, mask, err := LocalCollectArgs(c, pathToDDC, skipRESTCollect, disableFreeSpaceCheck, minFreeSpaceGB)
+	if err != nil {
+		return err
 	}
 
 	var allHostLog []string
-	err := c.Collector.HostExecuteAndStream(mask, c.Host, func(line string) {
+	err = c.Collector.HostExecuteAndStream(mask, c.Host, func(line string) {
 		if strings.HasPrefix(line, "JOB START") {
 			status, statusUX := extractJobText(line)
 			nodeState := consoleprint.NodeState{
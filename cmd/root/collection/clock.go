@@ -0,0 +1,39 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/snapshot"
+)
+
+// MeasureClockOffset reads the clock of host with date and returns how far it is ahead of the local clock,
+// the uncertainty is half of the round trip plus the resolution of the remote clock
+func MeasureClockOffset(c Collector, host string) (offset, uncertainty time.Duration, err error) {
+	before := time.Now()
+	out, err := c.HostExecute(false, host, "date", "+%s.%N")
+	after := time.Now()
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to read the clock of host %v: %w", host, err)
+	}
+	remote, resolution, err := snapshot.ParseRemoteClock(out)
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to read the clock of host %v: %w", host, err)
+	}
+	offset, uncertainty = snapshot.ClockOffset(before, after, remote)
+	return offset, uncertainty + resolution, nil
+}
@@ -0,0 +1,73 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// clockCollector answers date with a clock that is ahead of the local one
+type clockCollector struct {
+	dryRunCollector
+	ahead time.Duration
+	err   error
+}
+
+func (c *clockCollector) HostExecute(_ bool, _ string, _ ...string) (string, error) {
+	if c.err != nil {
+		return "", c.err
+	}
+	now := time.Now().Add(c.ahead)
+	return fmt.Sprintf("%d.%09d\n", now.Unix(), now.Nanosecond()), nil
+}
+
+func TestMeasureClockOffset(t *testing.T) {
+	offset, uncertainty, err := MeasureClockOffset(&clockCollector{ahead: 3 * time.Second}, "node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := offset - 3*time.Second; diff > 100*time.Millisecond || diff < -100*time.Millisecond {
+		t.Errorf("expected an offset of about 3s but was %v", offset)
+	}
+	if uncertainty < 0 || uncertainty > 100*time.Millisecond {
+		t.Errorf("expected a small uncertainty but was %v", uncertainty)
+	}
+}
+
+func TestMeasureClockOffsetFails(t *testing.T) {
+	if _, _, err := MeasureClockOffset(&clockCollector{err: errors.New("no date")}, "node1"); err == nil {
+		t.Error("expected an error when date fails")
+	}
+}
+
+func TestMeasureSnapshotClockOnlyWithASnapshot(t *testing.T) {
+	c := HostCaptureConfiguration{Host: "node1", Collector: &clockCollector{ahead: -2 * time.Second}}
+	var result NodeResult
+	measureSnapshotClock(&c, &result)
+	if result.ClockOffsetMillis != nil {
+		t.Errorf("expected no offset without a snapshot but was %v", *result.ClockOffsetMillis)
+	}
+	c.SnapshotAt = time.Now().Add(time.Minute)
+	measureSnapshotClock(&c, &result)
+	if result.ClockOffsetMillis == nil || *result.ClockOffsetMillis > -1900 || *result.ClockOffsetMillis < -2100 {
+		t.Errorf("expected an offset of about -2000ms but was %v", result.ClockOffsetMillis)
+	}
+	if c.SnapshotClockOffset.Milliseconds() != *result.ClockOffsetMillis {
+		t.Errorf("expected the offset to be passed to local-collect but was %v", c.SnapshotClockOffset)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"io"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/helpers"
@@ -37,8 +38,19 @@ import (
 
 var clusterRequestTimeout = 120
 
+// clusterK8sResources are the kubernetes resources captured for the whole cluster
+var clusterK8sResources = []string{"nodes", "sc", "pvc", "pv", "service", "endpoints", "pods", "deployments", "statefulsets", "daemonset", "replicaset", "cronjob", "job", "events", "ingress", "limitrange", "resourcequota", "hpa", "pdb", "pc"}
+
+// ClusterK8sPlan describes what the cluster level kubernetes collection reads
+func ClusterK8sPlan(namespace string) []string {
+	return []string{
+		fmt.Sprintf("kubernetes resources in namespace %v: %v", namespace, strings.Join(clusterK8sResources, ", ")),
+		fmt.Sprintf("container logs of every pod in namespace %v", namespace),
+	}
+}
+
 func ClusterK8sExecute(hook shutdown.CancelHook, namespace string, c *k8sapi.Clientset, cs CopyStrategy, ddfs helpers.Filesystem) error {
-	cmds := []string{"nodes", "sc", "pvc", "pv", "service", "endpoints", "pods", "deployments", "statefulsets", "daemonset", "replicaset", "cronjob", "job", "events", "ingress", "limitrange", "resourcequota", "hpa", "pdb", "pc"}
+	cmds := clusterK8sResources
 	path, err := cs.CreatePath("kubernetes", "", "")
 	if err != nil {
 		simplelog.Errorf("trying to construct cluster config path %v with error %v", path, err)
@@ -16,6 +16,7 @@
 package collection
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -28,14 +29,17 @@ import (
 	"sync"
 	"time"
 
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/cli"
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/ddcbinary"
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/helpers"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/archive"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/clusterstats"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/consoleprint"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/jobstats"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/timewindow"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/versions"
 )
 
@@ -58,6 +62,7 @@ type Collector interface {
 	Name() string
 	SetHostPid(host, pidFile string)
 	CleanupRemote() error
+	InterruptRemote() error
 }
 
 type Args struct {
@@ -73,8 +78,21 @@ type Args struct {
 	MinFreeSpaceGB        uint64
 	CollectionMode        string
 	TransferThreads       int
+	Parameters            CollectionParameters
+	PreviousCollection    *PreviousCollection
+	// Window when set limits the logs to an absolute time range
+	Window timewindow.Window
+	// SnapshotAt when set is when every node takes the first capture of the incident snapshot
+	SnapshotAt time.Time
+	// Deadline when set is the --max-collection-time limit, nodes still collecting when it is reached
+	// are given up on while the transfers already running get TransferGracePeriod to finish
+	Deadline time.Time
 }
 
+// TransferGracePeriod is how long past the deadline the transfers and the final archive may take,
+// the shutdown hook context should only expire once it is over
+const TransferGracePeriod = 5 * time.Minute
+
 type HostCaptureConfiguration struct {
 	IsCoordinator  bool
 	Collector      Collector
@@ -84,6 +102,35 @@ type HostCaptureConfiguration struct {
 	DremioPAT      string
 	TransferDir    string
 	CollectionMode string
+	// Deadline when set limits how long local-collect is allowed to run
+	Deadline time.Time
+	// Stopped is closed on the first CTRL+C, no new local-collect is started after that
+	Stopped <-chan struct{}
+	// Window when set is passed to local-collect as --from and --to
+	Window timewindow.Window
+	// SnapshotAt when set is passed to local-collect with SnapshotClockOffset, the clock of the host minus the clock of ddc
+	SnapshotAt          time.Time
+	SnapshotClockOffset time.Duration
+}
+
+// measureSnapshotClock records the clock offset of the host so its snapshot fires at the same instant as
+// the other nodes and its times can be aligned, without it the clock of the host is trusted
+func measureSnapshotClock(c *HostCaptureConfiguration, result *NodeResult) {
+	if c.SnapshotAt.IsZero() {
+		return
+	}
+	offset, uncertainty, err := MeasureClockOffset(c.Collector, c.Host)
+	if err != nil {
+		msg := fmt.Sprintf("the snapshot of host %v is not corrected for its clock: %v", c.Host, err)
+		simplelog.Warning(msg)
+		consoleprint.AddWarningToConsole(msg)
+		return
+	}
+	simplelog.HostLog(c.Host, fmt.Sprintf("clock offset %v +/- %v", offset, uncertainty))
+	c.SnapshotClockOffset = offset
+	offsetMillis := offset.Milliseconds()
+	result.ClockOffsetMillis = &offsetMillis
+	result.ClockOffsetUncertaintyMillis = uncertainty.Milliseconds()
 }
 
 func FilterCoordinators(coordinators []string) []string {
@@ -150,6 +197,15 @@ func Execute(c Collector, s CopyStrategy, collectionArgs Args, hook shutdown.Hoo
 	minFreeSpaceGB := collectionArgs.MinFreeSpaceGB
 	collectionMode := collectionArgs.CollectionMode
 	transferThreads := collectionArgs.TransferThreads
+	ctx := hook.GetContext()
+	deadline := collectionArgs.Deadline
+	// never fires when there is no deadline
+	var deadlineC <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		deadlineC = timer.C
+	}
 	var err error
 	tmpInstallDir := filepath.Join(outputLocDir, fmt.Sprintf("ddcex-output-%v", time.Now().Unix()))
 	err = os.Mkdir(tmpInstallDir, 0o700)
@@ -165,6 +221,11 @@ func Execute(c Collector, s CopyStrategy, collectionArgs Args, hook shutdown.Hoo
 	if err != nil {
 		return fmt.Errorf("making ddc binary failed: %w", err)
 	}
+	// the nodes only receive ddc.yaml so the modes of a collection-modes-file are copied into it
+	ddcYamlFilePath, err = conf.InlineCollectionModes(ddcYamlFilePath, tmpInstallDir)
+	if err != nil {
+		return err
+	}
 
 	coordinators, err := c.GetCoordinators()
 	if err != nil {
@@ -177,6 +238,25 @@ func Execute(c Collector, s CopyStrategy, collectionArgs Args, hook shutdown.Hoo
 	}
 	executors := FilterExecutors(executorsRaw, coordinators)
 
+	previous := collectionArgs.PreviousCollection
+	if previous != nil {
+		retryHosts := previous.Summary.FailedNodes()
+		coordinators = FilterHosts(coordinators, retryHosts)
+		executors = FilterHosts(executors, retryHosts)
+		for _, h := range retryHosts {
+			if !slices.Contains(coordinators, h) && !slices.Contains(executors, h) {
+				msg := fmt.Sprintf("failed node %v is no longer found, it will stay marked as failed", h)
+				simplelog.Warning(msg)
+				consoleprint.AddWarningToConsole(msg)
+			}
+		}
+		if err := SeedFromArchive(previous.ArchiveLoc, s.GetTmpDir()); err != nil {
+			return fmt.Errorf("unable to merge previous collection %v: %w", previous.ArchiveLoc, err)
+		}
+		// cluster level data was already captured by the previous collection
+		clusterCollection = func() {}
+	}
+
 	totalNodes := len(executors) + len(coordinators)
 	if totalNodes == 0 {
 		return fmt.Errorf("no hosts found nothing to collect: %v", c.HelpText())
@@ -218,11 +298,58 @@ func Execute(c Collector, s CopyStrategy, collectionArgs Args, hook shutdown.Hoo
 			simplelog.Errorf("error during cleanup %v", err)
 		}
 	}, "killing ddc local-collect processes")
+	hook.AddStopTasks(func() {
+		if err := c.InterruptRemote(); err != nil {
+			simplelog.Errorf("error asking local-collect processes to stop %v", err)
+		}
+	}, "asking ddc local-collect processes to archive what they have collected")
+	var nodeResults []NodeResult
+	// once the deadline is reached results that arrive late are no longer packaged
+	var collectionClosed bool
+	// once the deadline is reached no new transfer is started, the ones running are waited for
+	var transfersClosed bool
+	// startTransfer reserves a transfer slot, false when the deadline was reached first
+	startTransfer := func() bool {
+		sem <- struct{}{}
+		m.Lock()
+		defer m.Unlock()
+		if transfersClosed {
+			<-sem
+			return false
+		}
+		transferWg.Add(1)
+		return true
+	}
+	// records the outcome of a node for the summary.json
+	recordNode := func(result NodeResult, err error) {
+		result.EndTimeUTC = time.Now().UTC()
+		result.DurationMillis = result.EndTimeUTC.Sub(result.StartTimeUTC).Milliseconds()
+		if err != nil {
+			result.Status = NodeStatusFailed
+			if (!deadline.IsZero() && !time.Now().Before(deadline)) || errors.Is(ctx.Err(), context.DeadlineExceeded) || isStopped(hook.Stopped()) {
+				result.Status = NodeStatusIncomplete
+			}
+			result.Error = err.Error()
+		} else {
+			result.Status = NodeStatusCompleted
+		}
+		m.Lock()
+		defer m.Unlock()
+		if collectionClosed {
+			return
+		}
+		nodeResults = append(nodeResults, result)
+	}
 	for _, coordinator := range coordinators {
 		nodesConnectedTo++
 		wg.Add(1)
 		go func(host string) {
 			defer wg.Done()
+			result := NodeResult{
+				Host:          host,
+				IsCoordinator: true,
+				StartTimeUTC:  time.Now().UTC(),
+			}
 			coordinatorCaptureConf := HostCaptureConfiguration{
 				Collector:      c,
 				IsCoordinator:  true,
@@ -232,32 +359,44 @@ func Execute(c Collector, s CopyStrategy, collectionArgs Args, hook shutdown.Hoo
 				TransferDir:    transferDir,
 				DremioPAT:      dremioPAT,
 				CollectionMode: collectionMode,
+				Deadline:       deadline,
+				Stopped:        hook.Stopped(),
+				Window:         collectionArgs.Window,
+				SnapshotAt:     collectionArgs.SnapshotAt,
 			}
+			measureSnapshotClock(&coordinatorCaptureConf, &result)
 			// we want to be able to capture the job profiles of all the nodes
 			skipRESTCalls := false
 			err := StartCapture(coordinatorCaptureConf, ddcFilePath, ddcYamlFilePath, skipRESTCalls, disableFreeSpaceCheck, minFreeSpaceGB)
 			if err != nil {
 				simplelog.Errorf("failed generating tarball for host %v: %v", host, err)
+				recordNode(result, err)
+				return
+			}
+			if !startTransfer() {
+				recordNode(result, fmt.Errorf("host %v not transferred: %w", host, context.DeadlineExceeded))
 				return
 			}
-			sem <- struct{}{}
-			transferWg.Add(1)
 			go func() {
 				defer transferWg.Done()
 				size, f, err := TransferCapture(coordinatorCaptureConf, hook, s.GetTmpDir())
-				if err != nil {
-					m.Lock()
-					totalFailedFiles = append(totalFailedFiles, f)
-					m.Unlock()
-				} else {
-					m.Lock()
-					tarballs = append(tarballs, f)
-					files = append(files, helpers.CollectedFile{
-						Path: f,
-						Size: size,
-					})
-					m.Unlock()
+				result.NodeName = nodeNameFromTarball(f)
+				result.BytesWritten = size
+				m.Lock()
+				// once closed it is too late for the tarball to be packaged
+				if !collectionClosed {
+					if err != nil {
+						totalFailedFiles = append(totalFailedFiles, f)
+					} else {
+						tarballs = append(tarballs, f)
+						files = append(files, helpers.CollectedFile{
+							Path: f,
+							Size: size,
+						})
+					}
 				}
+				m.Unlock()
+				recordNode(result, err)
 				<-sem
 			}()
 		}(coordinator)
@@ -268,6 +407,11 @@ func Execute(c Collector, s CopyStrategy, collectionArgs Args, hook shutdown.Hoo
 		wg.Add(1)
 		go func(host string) {
 			defer wg.Done()
+			result := NodeResult{
+				Host:          host,
+				IsCoordinator: false,
+				StartTimeUTC:  time.Now().UTC(),
+			}
 			executorCaptureConf := HostCaptureConfiguration{
 				Collector:      c,
 				IsCoordinator:  false,
@@ -276,39 +420,90 @@ func Execute(c Collector, s CopyStrategy, collectionArgs Args, hook shutdown.Hoo
 				DDCfs:          ddcfs,
 				TransferDir:    transferDir,
 				CollectionMode: collectionMode,
+				Deadline:       deadline,
+				Stopped:        hook.Stopped(),
+				Window:         collectionArgs.Window,
+				SnapshotAt:     collectionArgs.SnapshotAt,
 			}
+			measureSnapshotClock(&executorCaptureConf, &result)
 			// always skip executor calls
 			skipRESTCalls := true
 			err := StartCapture(executorCaptureConf, ddcFilePath, ddcYamlFilePath, skipRESTCalls, disableFreeSpaceCheck, minFreeSpaceGB)
 			if err != nil {
 				simplelog.Errorf("failed generating tarball for host %v: %v", host, err)
+				recordNode(result, err)
+				return
+			}
+			if !startTransfer() {
+				recordNode(result, fmt.Errorf("host %v not transferred: %w", host, context.DeadlineExceeded))
 				return
 			}
-			sem <- struct{}{}
-			transferWg.Add(1)
 			go func() {
 				defer transferWg.Done()
 				size, f, err := TransferCapture(executorCaptureConf, hook, s.GetTmpDir())
-				if err != nil {
-					m.Lock()
-					totalFailedFiles = append(totalFailedFiles, f)
-					m.Unlock()
-				} else {
-					m.Lock()
-					tarballs = append(tarballs, f)
-					files = append(files, helpers.CollectedFile{
-						Path: f,
-						Size: size,
-					})
-					m.Unlock()
+				result.NodeName = nodeNameFromTarball(f)
+				result.BytesWritten = size
+				m.Lock()
+				// once closed it is too late for the tarball to be packaged
+				if !collectionClosed {
+					if err != nil {
+						totalFailedFiles = append(totalFailedFiles, f)
+					} else {
+						tarballs = append(tarballs, f)
+						files = append(files, helpers.CollectedFile{
+							Path: f,
+							Size: size,
+						})
+					}
 				}
+				m.Unlock()
+				recordNode(result, err)
 				<-sem
 			}()
 		}(executor)
 	}
-	wg.Wait()
-	transferWg.Wait()
-	clusterWg.Wait()
+	allDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		transferWg.Wait()
+		clusterWg.Wait()
+		close(allDone)
+	}()
+	var deadlineReached bool
+	select {
+	case <-allDone:
+	case <-deadlineC:
+		deadlineReached = true
+		m.Lock()
+		transfersClosed = true
+		m.Unlock()
+		msg := "max collection time reached: nodes still collecting are marked incomplete, waiting for the running transfers"
+		simplelog.Warning(msg)
+		consoleprint.AddWarningToConsole(msg)
+		// the partial tarballs already being copied are the data the deadline is meant to keep
+		transfersDone := make(chan struct{})
+		go func() {
+			transferWg.Wait()
+			close(transfersDone)
+		}()
+		select {
+		case <-transfersDone:
+		case <-ctx.Done():
+			msg := fmt.Sprintf("transfers stopped before they finished: %v", context.Cause(ctx))
+			simplelog.Warning(msg)
+			consoleprint.AddWarningToConsole(msg)
+		}
+	case <-ctx.Done():
+		// a hung node should not keep us from packaging what was collected
+		deadlineReached = errors.Is(ctx.Err(), context.DeadlineExceeded)
+		msg := fmt.Sprintf("collection stopped before all nodes finished: %v", context.Cause(ctx))
+		simplelog.Warning(msg)
+		consoleprint.AddWarningToConsole(msg)
+	}
+	m.Lock()
+	collectionClosed = true
+	nodeResults = markIncompleteNodes(nodeResults, coordinators, executors, start)
+	m.Unlock()
 	end := time.Now().UTC()
 	var collectionInfo SummaryInfo
 	collectionInfo.EndTimeUTC = end
@@ -366,6 +561,17 @@ func Execute(c Collector, s CopyStrategy, collectionArgs Args, hook shutdown.Hoo
 		collectionInfo.ClusterID = clusterIDs
 		collectionInfo.DremioVersion = versions
 	}
+	jobStats, err := FindJobStats(s.GetTmpDir())
+	if err != nil {
+		simplelog.Errorf("unable to find job stats in %v: %v", s.GetTmpDir(), err)
+	}
+	collectionInfo.NodeResults = MergeJobStats(nodeResults, jobStats)
+	collectionInfo.Parameters = collectionArgs.Parameters
+	collectionInfo.DeadlineReached = deadlineReached
+	collectionInfo.Interrupted = isStopped(hook.Stopped())
+	if previous != nil {
+		collectionInfo = MergeRetrySummary(previous.Summary, collectionInfo)
+	}
 	if len(files) == 0 {
 		return errors.New("no files transferred")
 	}
@@ -414,3 +620,94 @@ func FindClusterID(outputDir string) (clusterStatsList []clusterstats.ClusterSta
 	})
 	return
 }
+
+func FindJobStats(outputDir string) (jobStatsList []jobstats.NodeJobStats, err error) {
+	err = filepath.Walk(outputDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Name() == jobstats.FileName {
+			stats, err := jobstats.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			jobStatsList = append(jobStatsList, stats)
+		}
+		return nil
+	})
+	return
+}
+
+// MergeJobStats attaches the local-collect job results to the matching node, results are sorted by node name
+func MergeJobStats(nodeResults []NodeResult, jobStatsList []jobstats.NodeJobStats) []NodeResult {
+	byNodeName := make(map[string]jobstats.NodeJobStats)
+	for _, stats := range jobStatsList {
+		byNodeName[stats.NodeName] = stats
+	}
+	merged := make([]NodeResult, len(nodeResults))
+	for i, result := range nodeResults {
+		if stats, ok := byNodeName[result.NodeName]; ok && result.NodeName != "" {
+			result.Jobs = stats.Jobs
+			result.TruncatedFiles = stats.TruncatedFiles
+			result.FilesProduced = 0
+			for _, j := range stats.Jobs {
+				result.FilesProduced += j.FilesProduced
+				// the tarball made it but local-collect was stopped early
+				if j.Status == jobstats.StatusIncomplete && result.Status == NodeStatusCompleted {
+					result.Status = NodeStatusIncomplete
+					result.Error = "not every job finished"
+				}
+			}
+		}
+		merged[i] = result
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Host < merged[j].Host
+	})
+	return merged
+}
+
+// nodeNameFromTarball works because local-collect names the tarball after the node
+func nodeNameFromTarball(tarball string) string {
+	if !strings.HasSuffix(tarball, ".tar.gz") {
+		return ""
+	}
+	return strings.TrimSuffix(filepath.Base(tarball), ".tar.gz")
+}
+
+// markIncompleteNodes adds a result for every node that never reported back
+func markIncompleteNodes(nodeResults []NodeResult, coordinators, executors []string, start time.Time) []NodeResult {
+	reported := make(map[string]bool)
+	for _, n := range nodeResults {
+		reported[n.Host] = true
+	}
+	now := time.Now().UTC()
+	addMissing := func(hosts []string, isCoordinator bool) {
+		for _, h := range hosts {
+			if reported[h] {
+				continue
+			}
+			nodeResults = append(nodeResults, NodeResult{
+				Host:           h,
+				IsCoordinator:  isCoordinator,
+				Status:         NodeStatusIncomplete,
+				StartTimeUTC:   start,
+				EndTimeUTC:     now,
+				DurationMillis: now.Sub(start).Milliseconds(),
+				Error:          "node did not finish before the collection was stopped",
+			})
+		}
+	}
+	addMissing(coordinators, true)
+	addMissing(executors, false)
+	return nodeResults
+}
+
+func isStopped(stopped <-chan struct{}) bool {
+	select {
+	case <-stopped:
+		return true
+	default:
+		return false
+	}
+}
@@ -15,7 +15,10 @@
 // collection package provides the interface for collection implementation and the actual collection execution
 package collection
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestFilterCoordinators(t *testing.T) {
 	t.Log("testing filtering duplicates")
@@ -59,3 +62,33 @@ func TestFilterExecutors(t *testing.T) {
 		t.Errorf("expected %v but got %v items", expectedItems, len(filtered))
 	}
 }
+
+func TestLocalCollectBudget(t *testing.T) {
+	if actual := LocalCollectBudget(10 * time.Minute); actual != 8*time.Minute {
+		t.Errorf("expected 8m but got %v", actual)
+	}
+	if actual := LocalCollectBudget(-time.Second); actual > 0 {
+		t.Errorf("expected no budget after the deadline but got %v", actual)
+	}
+}
+
+func TestMarkIncompleteNodes(t *testing.T) {
+	start := time.Now().UTC()
+	results := markIncompleteNodes([]NodeResult{
+		{Host: "coord1", Status: NodeStatusCompleted},
+	}, []string{"coord1"}, []string{"exec1", "exec2"}, start)
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results but got %v", len(results))
+	}
+	for _, r := range results[1:] {
+		if r.Status != NodeStatusIncomplete {
+			t.Errorf("expected %v to be incomplete but was %v", r.Host, r.Status)
+		}
+		if r.IsCoordinator {
+			t.Errorf("expected %v to be an executor", r.Host)
+		}
+	}
+	if results[0].Status != NodeStatusCompleted {
+		t.Errorf("expected coord1 to stay completed but was %v", results[0].Status)
+	}
+}
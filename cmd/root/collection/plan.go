@@ -0,0 +1,235 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// collection package provides the interface for collection implementation and the actual collection execution
+package collection
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/logcollect"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/dryrun"
+)
+
+// DryRunNode is what a collection would do on a single node
+type DryRunNode struct {
+	Host          string                   `json:"host"`
+	IsCoordinator bool                     `json:"isCoordinator"`
+	Steps         []string                 `json:"steps"`
+	LogDir        string                   `json:"logDir"`
+	LogFiles      []logcollect.PlannedFile `json:"logFiles"`
+	// EstimatedBytes is the size of the matching logs before they are gzipped
+	EstimatedBytes int64  `json:"estimatedBytes"`
+	Error          string `json:"error,omitempty"`
+}
+
+// DryRunPlan is everything a collection would touch, it is built without deploying ddc to the nodes
+type DryRunPlan struct {
+	Transport         string                 `json:"transport"`
+	CollectionMode    string                 `json:"collectionMode"`
+	OutputLoc         string                 `json:"outputLoc"`
+	TransferDir       string                 `json:"transferDir"`
+	DDCYamlLoc        string                 `json:"ddcYamlLoc"`
+	EffectiveConfig   map[string]interface{} `json:"effectiveConfig"`
+	EnabledJobs       []string               `json:"enabledJobs"`
+	DisabledJobs      []string               `json:"disabledJobs"`
+	ClusterCollection []string               `json:"clusterCollection"`
+	Nodes             []DryRunNode           `json:"nodes"`
+	EstimatedBytes    int64                  `json:"estimatedBytes"`
+	Notes             []string               `json:"notes"`
+}
+
+// dryRunLog is a log type that can be matched from a listing of the log dir
+type dryRunLog struct {
+	key           string
+	archivePrefix string
+	activeFiles   []string
+	queries       bool
+}
+
+// gc logs are left out as their location is only known once local-collect inspects the dremio process
+var dryRunLogs = []dryRunLog{
+	{conf.KeyCollectServerLogs, "server", []string{"server.log", "server.out"}, false},
+	{conf.KeyCollectQueriesJSON, "queries", []string{"queries.json"}, true},
+	{conf.KeyCollectMetaRefreshLog, "metadata_refresh", []string{"metadata_refresh.log"}, false},
+	{conf.KeyCollectReflectionLog, "reflection", []string{"reflection.log"}, false},
+	{conf.KeyCollectVacuumLog, "vacuum", []string{"vacuum.json"}, false},
+	{conf.KeyCollectAccelerationLog, "acceleration", []string{"acceleration.log"}, false},
+	{conf.KeyCollectAccessLog, "access", []string{"access.log"}, false},
+	{conf.KeyCollectAuditLog, "audit", []string{"audit.json"}, false},
+}
+
+// BuildDryRunPlan resolves the nodes and lists their log dirs with ls, nothing is copied to the nodes.
+// confData is the parsed ddc.yaml with defaults applied.
+func BuildDryRunPlan(c Collector, collectionArgs Args, confData map[string]interface{}, clusterCollection []string) (DryRunPlan, error) {
+	plan := DryRunPlan{
+		Transport:         c.Name(),
+		CollectionMode:    collectionArgs.CollectionMode,
+		OutputLoc:         collectionArgs.Parameters.OutputLoc,
+		TransferDir:       collectionArgs.TransferDir,
+		DDCYamlLoc:        collectionArgs.DDCYamlLoc,
+		EffectiveConfig:   make(map[string]interface{}, len(confData)),
+		EnabledJobs:       collectionArgs.Enabled,
+		DisabledJobs:      collectionArgs.Disabled,
+		ClusterCollection: clusterCollection,
+		Notes: []string{
+			"log dirs are listed with the configured dremio-log-dir, local-collect may autodetect a different one from the dremio process",
+			"gc logs, jfr, jstack, ttop and heap dump sizes are only known on the node, run ddc local-collect --dry-run there for the full plan",
+		},
+	}
+	if collectionArgs.Window.IsSet() {
+		plan.Notes = append(plan.Notes, fmt.Sprintf("logs are trimmed on the node to the window %v, the estimated sizes are of the whole files", collectionArgs.Window))
+	}
+	if collectionArgs.DremioPAT == "" {
+		plan.Notes = append(plan.Notes, "no PAT is set so job profiles, system tables, the kv store report and the wlm report are not collected")
+	}
+	sort.Strings(plan.EnabledJobs)
+	sort.Strings(plan.DisabledJobs)
+	for k, v := range confData {
+		if k == conf.KeyDremioPatToken {
+			if v != "" {
+				plan.EffectiveConfig[k] = "REDACTED"
+			}
+			continue
+		}
+		plan.EffectiveConfig[k] = v
+	}
+	coordinators, err := c.GetCoordinators()
+	if err != nil {
+		return plan, err
+	}
+	executorsRaw, err := c.GetExecutors()
+	if err != nil {
+		return plan, err
+	}
+	executors := FilterExecutors(executorsRaw, coordinators)
+	logDir := conf.GetString(confData, conf.KeyDremioLogDir)
+	now := time.Now()
+	today := now
+	if !collectionArgs.Window.To.IsZero() {
+		today = collectionArgs.Window.To.In(time.Local)
+	}
+	planNode := func(host string, isCoordinator bool) DryRunNode {
+		node := DryRunNode{
+			Host:          host,
+			IsCoordinator: isCoordinator,
+			LogDir:        logDir,
+			LogFiles:      []logcollect.PlannedFile{},
+		}
+		captureConf := HostCaptureConfiguration{
+			Host:           host,
+			TransferDir:    collectionArgs.TransferDir,
+			CollectionMode: collectionArgs.CollectionMode,
+			DremioPAT:      collectionArgs.DremioPAT,
+			Window:         collectionArgs.Window,
+			SnapshotAt:     collectionArgs.SnapshotAt,
+		}
+		pathToDDC := path.Join(collectionArgs.TransferDir, "ddc")
+		// executors never make REST calls
+		args, _, err := LocalCollectArgs(captureConf, pathToDDC, !isCoordinator, collectionArgs.DisableFreeSpaceCheck, collectionArgs.MinFreeSpaceGB)
+		if err != nil {
+			node.Error = err.Error()
+		}
+		node.Steps = []string{
+			fmt.Sprintf("mkdir -p %v", collectionArgs.TransferDir),
+			fmt.Sprintf("copy ddc to %v", pathToDDC),
+			fmt.Sprintf("chmod +x %v", pathToDDC),
+			fmt.Sprintf("copy %v to %v", collectionArgs.DDCYamlLoc, path.Join(collectionArgs.TransferDir, "ddc.yaml")),
+			strings.Join(args, " "),
+			"cat /proc/sys/kernel/hostname",
+			fmt.Sprintf("copy %v/<node name>.tar.gz to %v", collectionArgs.TransferDir, collectionArgs.OutputLoc),
+			fmt.Sprintf("rm %v %v.log %v %v/<node name>.tar.gz", pathToDDC, pathToDDC, path.Join(collectionArgs.TransferDir, "ddc.yaml"), collectionArgs.TransferDir),
+		}
+		var lines []string
+		listErr := c.HostExecuteAndStream(false, host, func(line string) {
+			lines = append(lines, line)
+		}, "", "ls", "-ln", logDir, path.Join(logDir, "archive"))
+		listing := logcollect.ParseLsListing(lines, logDir)
+		if listErr != nil && len(listing) == 0 {
+			node.Error = fmt.Sprintf("unable to list %v: %v", logDir, listErr)
+			return node
+		}
+		for _, l := range dryRunLogs {
+			name := strings.TrimPrefix(l.key, "collect-")
+			enabled := slices.Contains(collectionArgs.Enabled, name)
+			days := conf.GetInt(confData, conf.KeyDremioLogsNumDays)
+			if l.queries {
+				// job profiles need the queries.json
+				enabled = enabled || (isCoordinator && slices.Contains(collectionArgs.Enabled, "job-profiles"))
+				days = conf.GetInt(confData, conf.KeyDremioQueriesJSONNumDays)
+			}
+			if windowDays := collectionArgs.Window.Days(now, time.Local); windowDays > 0 {
+				days = windowDays - 1
+			}
+			if !enabled {
+				continue
+			}
+			for _, f := range logcollect.MatchListing(listing, logDir, l.archivePrefix, days, today, l.activeFiles...) {
+				node.LogFiles = append(node.LogFiles, f)
+				node.EstimatedBytes += f.Size
+			}
+		}
+		return node
+	}
+	for _, host := range coordinators {
+		plan.Nodes = append(plan.Nodes, planNode(host, true))
+	}
+	for _, host := range executors {
+		plan.Nodes = append(plan.Nodes, planNode(host, false))
+	}
+	for _, n := range plan.Nodes {
+		plan.EstimatedBytes += n.EstimatedBytes
+	}
+	return plan, nil
+}
+
+// WriteDryRunPlan writes the plan as text for humans or json for review tooling
+func WriteDryRunPlan(w io.Writer, plan DryRunPlan, format string) error {
+	return dryrun.Write(w, plan, format, func(printf dryrun.Printf) {
+		printf("DRY RUN - nothing was collected and ddc was not copied to any node\n\ntransport:\t%v\ncollection mode:\t%v\noutput:\t%v\ntransfer dir:\t%v\nddc.yaml:\t%v\nestimated log size:\t%v bytes\n\n",
+			plan.Transport, plan.CollectionMode, plan.OutputLoc, plan.TransferDir, plan.DDCYamlLoc, plan.EstimatedBytes)
+		printf("enabled:\t%v\ndisabled:\t%v\n\n", strings.Join(plan.EnabledJobs, ", "), strings.Join(plan.DisabledJobs, ", "))
+		dryrun.WriteConfig(printf, plan.EffectiveConfig)
+		for _, item := range plan.ClusterCollection {
+			printf("cluster:\t%v\n", item)
+		}
+		for _, n := range plan.Nodes {
+			role := "executor"
+			if n.IsCoordinator {
+				role = "coordinator"
+			}
+			printf("\nnode %v (%v) - %v log bytes\n", n.Host, role, n.EstimatedBytes)
+			for _, s := range n.Steps {
+				printf("  run:\t%v\n", s)
+			}
+			for _, f := range n.LogFiles {
+				printf("  log:\t%v\t%v\n", f.Path, f.Size)
+			}
+			if n.Error != "" {
+				printf("  warning:\t%v\n", n.Error)
+			}
+		}
+		printf("\n")
+		for _, note := range plan.Notes {
+			printf("note: %v\n", note)
+		}
+	})
+}
@@ -0,0 +1,143 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/cli"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/dryrun"
+)
+
+// dryRunCollector answers ls with a fixed listing and fails everything that would change a node
+type dryRunCollector struct {
+	listing []string
+	calls   [][]string
+}
+
+func (d *dryRunCollector) CopyFromHost(_, _, _ string) (string, error) {
+	return "", errors.New("dry run must not copy")
+}
+
+func (d *dryRunCollector) CopyToHost(_, _, _ string) (string, error) {
+	return "", errors.New("dry run must not copy")
+}
+
+func (d *dryRunCollector) GetCoordinators() ([]string, error) { return []string{"coord1"}, nil }
+func (d *dryRunCollector) GetExecutors() ([]string, error)    { return []string{"exec1"}, nil }
+
+func (d *dryRunCollector) HostExecute(_ bool, _ string, args ...string) (string, error) {
+	d.calls = append(d.calls, args)
+	return "", errors.New("dry run must only list")
+}
+
+func (d *dryRunCollector) HostExecuteAndStream(_ bool, _ string, output cli.OutputHandler, _ string, args ...string) error {
+	d.calls = append(d.calls, args)
+	for _, l := range d.listing {
+		output(l)
+	}
+	return nil
+}
+
+func (d *dryRunCollector) HelpText() string       { return "" }
+func (d *dryRunCollector) Name() string           { return "Dry Run" }
+func (d *dryRunCollector) SetHostPid(_, _ string) {}
+func (d *dryRunCollector) CleanupRemote() error   { return nil }
+func (d *dryRunCollector) InterruptRemote() error { return nil }
+
+func TestBuildDryRunPlan(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+	old := time.Now().AddDate(0, 0, -30).Format("2006-01-02")
+	c := &dryRunCollector{
+		listing: []string{
+			"/var/log/dremio:",
+			"total 8",
+			"-rw-r--r-- 1 1000 1000 100 Oct 18 10:00 server.log",
+			"-rw-r--r-- 1 1000 1000 5 Oct 18 10:00 queries.json",
+			"drwxr-xr-x 2 1000 1000 4096 Oct 18 10:00 archive",
+			"",
+			"/var/log/dremio/archive:",
+			"-rw-r--r-- 1 1000 1000 20 Oct 18 10:00 server." + today + ".0.log.gz",
+			"-rw-r--r-- 1 1000 1000 999 Oct 18 10:00 server." + old + ".0.log.gz",
+		},
+	}
+	confData := map[string]interface{}{
+		conf.KeyDremioLogDir:             "/var/log/dremio",
+		conf.KeyDremioLogsNumDays:        2,
+		conf.KeyDremioQueriesJSONNumDays: 2,
+		conf.KeyDremioPatToken:           "secret",
+	}
+	args := Args{
+		TransferDir:    "/tmp/ddc-transfer",
+		DDCYamlLoc:     "ddc.yaml",
+		CollectionMode: "light",
+		Enabled:        []string{"server-logs"},
+		Disabled:       []string{"queries-json"},
+		DremioPAT:      "secret",
+	}
+	plan, err := BuildDryRunPlan(c, args, confData, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, call := range c.calls {
+		if call[0] != "ls" {
+			t.Errorf("expected only ls to run but got %v", call)
+		}
+	}
+	if len(plan.Nodes) != 2 {
+		t.Fatalf("expected 2 nodes but got %v", len(plan.Nodes))
+	}
+	coord := plan.Nodes[0]
+	if !coord.IsCoordinator || coord.Host != "coord1" {
+		t.Errorf("expected coordinator first but got %#v", coord)
+	}
+	if coord.EstimatedBytes != 120 {
+		t.Errorf("expected server.log and today's archive only (120 bytes) but got %v: %#v", coord.EstimatedBytes, coord.LogFiles)
+	}
+	if plan.EstimatedBytes != 240 {
+		t.Errorf("expected 240 bytes in total but got %v", plan.EstimatedBytes)
+	}
+	if plan.EffectiveConfig[conf.KeyDremioPatToken] != "REDACTED" {
+		t.Errorf("expected the pat to be redacted but was %v", plan.EffectiveConfig[conf.KeyDremioPatToken])
+	}
+	var localCollect string
+	for _, s := range plan.Nodes[1].Steps {
+		if strings.Contains(s, "local-collect") {
+			localCollect = s
+		}
+	}
+	if !strings.Contains(localCollect, "--disable-rest-api") {
+		t.Errorf("expected executors to skip rest calls but was %q", localCollect)
+	}
+
+	var out bytes.Buffer
+	if err := WriteDryRunPlan(&out, plan, dryrun.FormatText); err != nil {
+		t.Fatal(err)
+	}
+	text := out.String()
+	for _, expected := range []string{"DRY RUN", "coord1 (coordinator)", "exec1 (executor)", "/var/log/dremio/server.log", "server-logs"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("expected plan to contain %q but was\n%v", expected, text)
+		}
+	}
+	if strings.Contains(text, "secret") {
+		t.Errorf("pat leaked into the plan\n%v", text)
+	}
+}
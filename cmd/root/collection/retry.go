@@ -0,0 +1,162 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// collection package provides the interface for collection implementation and the actual collection execution
+package collection
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/helpers"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/archive"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+)
+
+// PreviousCollection is a collection that is being retried, only the failed nodes
+// are collected again and the result is merged with the data of the previous archive
+type PreviousCollection struct {
+	ArchiveLoc string
+	Summary    SummaryInfo
+}
+
+// FailedNodes lists the hosts that did not complete in the collection
+func (summary SummaryInfo) FailedNodes() []string {
+	var failed []string
+	for _, n := range summary.NodeResults {
+		if n.Status != NodeStatusCompleted {
+			failed = append(failed, n.Host)
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
+
+// FilterHosts only keeps the hosts that are found in the allowed list
+func FilterHosts(hosts, allowed []string) []string {
+	var result []string
+	for _, h := range hosts {
+		if slices.Contains(allowed, h) {
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+// MergeRetrySummary combines the summary of the previous collection with the summary of the retried nodes.
+// Node results and tarballs of the retried nodes replace the previous ones, everything else is kept from the previous
+// collection.
+func MergeRetrySummary(previous, retried SummaryInfo) SummaryInfo {
+	merged := retried
+	merged.ClusterInfo = previous.ClusterInfo
+	merged.Coordinators = previous.Coordinators
+	merged.Executors = previous.Executors
+	merged.CollectionsEnabled = previous.CollectionsEnabled
+	merged.CollectionsDisabled = previous.CollectionsDisabled
+	merged.Parameters = previous.Parameters
+	merged.Parameters.OutputLoc = retried.Parameters.OutputLoc
+
+	retriedHosts := make(map[string]bool)
+	for _, n := range retried.NodeResults {
+		retriedHosts[n.Host] = true
+		merged.RetriedNodes = append(merged.RetriedNodes, n.Host)
+	}
+	var nodeResults []NodeResult
+	// the tarballs of the retried nodes are named after the node, not the host
+	retriedNodeNames := make(map[string]bool)
+	for _, n := range previous.NodeResults {
+		if !retriedHosts[n.Host] {
+			nodeResults = append(nodeResults, n)
+		} else if n.NodeName != "" {
+			retriedNodeNames[n.NodeName] = true
+		}
+	}
+	nodeResults = append(nodeResults, retried.NodeResults...)
+	sort.Slice(nodeResults, func(i, j int) bool {
+		return nodeResults[i].Host < nodeResults[j].Host
+	})
+	merged.NodeResults = nodeResults
+
+	// a partial tarball of a retried node is replaced by the one of the retry
+	var collectedFiles []helpers.CollectedFile
+	for _, f := range previous.CollectedFiles {
+		if !retriedNodeNames[nodeNameFromTarball(f.Path)] {
+			collectedFiles = append(collectedFiles, f)
+		}
+	}
+	merged.CollectedFiles = append(collectedFiles, retried.CollectedFiles...)
+	merged.TotalBytesCollected = 0
+	for _, f := range merged.CollectedFiles {
+		merged.TotalBytesCollected += f.Size
+	}
+	merged.SkippedFiles = append(slices.Clone(previous.SkippedFiles), retried.SkippedFiles...)
+	sort.Strings(merged.RetriedNodes)
+	return merged
+}
+
+// SeedFromArchive extracts the DDC folder of a previous archive into the output directory
+// so the retried nodes end up next to the nodes that were already collected
+func SeedFromArchive(archiveLoc, outputDir string) error {
+	staging := outputDir + "-previous"
+	if err := os.MkdirAll(staging, DirPerms); err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(staging); err != nil {
+			simplelog.Warningf("unable to remove staging directory %v: %v", staging, err)
+		}
+	}()
+	if err := archive.ExtractTarGz(archiveLoc, staging); err != nil {
+		return fmt.Errorf("unable to extract %v: %w", archiveLoc, err)
+	}
+	entries, err := os.ReadDir(staging)
+	if err != nil {
+		return err
+	}
+	var ddcDir string
+	for _, e := range entries {
+		if e.IsDir() && strings.HasSuffix(e.Name(), "-DDC") {
+			ddcDir = filepath.Join(staging, e.Name())
+			break
+		}
+	}
+	if ddcDir == "" {
+		return fmt.Errorf("no DDC folder found in %v", archiveLoc)
+	}
+	return filepath.Walk(ddcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(ddcDir, path)
+		if err != nil {
+			return err
+		}
+		// the completed marker is named after the old folder and is written again when archiving
+		if rel == "completed" {
+			return filepath.SkipDir
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		dest := filepath.Join(outputDir, rel)
+		if err := os.MkdirAll(filepath.Dir(dest), DirPerms); err != nil {
+			return err
+		}
+		return os.Rename(path, dest)
+	})
+}
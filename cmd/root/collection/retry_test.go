@@ -0,0 +1,121 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/helpers"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/archive"
+)
+
+func TestFailedNodes(t *testing.T) {
+	summaryInfo := SummaryInfo{
+		NodeResults: []NodeResult{
+			{Host: "exec2", Status: NodeStatusFailed},
+			{Host: "coord1", Status: NodeStatusCompleted},
+			{Host: "exec1", Status: NodeStatusFailed},
+		},
+	}
+	expected := []string{"exec1", "exec2"}
+	if actual := summaryInfo.FailedNodes(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+	filtered := FilterHosts([]string{"coord1", "exec1", "exec3"}, expected)
+	if !reflect.DeepEqual([]string{"exec1"}, filtered) {
+		t.Errorf("expected only exec1 but got %v", filtered)
+	}
+}
+
+func TestMergeRetrySummary(t *testing.T) {
+	previous := SummaryInfo{
+		ClusterInfo:    ClusterInfo{TotalNodesAttempted: 3, NumberNodesContacted: 3},
+		Coordinators:   []string{"coord1"},
+		Executors:      []string{"exec1", "exec2"},
+		CollectedFiles: []helpers.CollectedFile{{Path: "coord1.tar.gz", Size: 10}, {Path: "exec1.tar.gz", Size: 20}, {Path: "/tmp/ddc/exec2-node.tar.gz", Size: 3}},
+		Parameters:     CollectionParameters{CollectionMode: "standard", OutputLoc: "/tmp/diag.tgz"},
+		NodeResults: []NodeResult{
+			{Host: "coord1", NodeName: "coord1", Status: NodeStatusCompleted},
+			{Host: "exec1", NodeName: "exec1", Status: NodeStatusCompleted},
+			{Host: "exec2", NodeName: "exec2-node", Status: NodeStatusIncomplete, Error: "ssh timeout"},
+		},
+	}
+	retried := SummaryInfo{
+		CollectedFiles: []helpers.CollectedFile{{Path: "exec2-node.tar.gz", Size: 5}},
+		Parameters:     CollectionParameters{CollectionMode: "standard", OutputLoc: "/tmp/diag-retry.tgz"},
+		NodeResults: []NodeResult{
+			{Host: "exec2", Status: NodeStatusCompleted},
+		},
+	}
+	merged := MergeRetrySummary(previous, retried)
+	if len(merged.NodeResults) != 3 {
+		t.Fatalf("expected 3 node results but got %v", len(merged.NodeResults))
+	}
+	if len(merged.FailedNodes()) != 0 {
+		t.Errorf("expected no failed nodes but got %v", merged.FailedNodes())
+	}
+	if merged.TotalBytesCollected != 35 {
+		t.Errorf("expected 35 bytes but got %v", merged.TotalBytesCollected)
+	}
+	if len(merged.CollectedFiles) != 3 {
+		t.Errorf("expected 3 collected files but got %v", merged.CollectedFiles)
+	}
+	if merged.ClusterInfo.TotalNodesAttempted != 3 {
+		t.Errorf("expected 3 nodes attempted but got %v", merged.ClusterInfo.TotalNodesAttempted)
+	}
+	if merged.Parameters.OutputLoc != "/tmp/diag-retry.tgz" {
+		t.Errorf("expected the new output location but got %v", merged.Parameters.OutputLoc)
+	}
+	if !reflect.DeepEqual([]string{"exec2"}, merged.RetriedNodes) {
+		t.Errorf("expected exec2 to be retried but got %v", merged.RetriedNodes)
+	}
+}
+
+func TestSeedFromArchive(t *testing.T) {
+	src := t.TempDir()
+	ddcDir := filepath.Join(src, "20240101-120000-DDC")
+	for _, f := range []string{
+		filepath.Join(ddcDir, "logs", "coord1", "server.log"),
+		filepath.Join(ddcDir, "completed", "20240101-120000-DDC"),
+		filepath.Join(src, "summary.json"),
+	} {
+		if err := os.MkdirAll(filepath.Dir(f), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte("data"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archiveLoc := filepath.Join(t.TempDir(), "diag.tgz")
+	if err := archive.TarGzDir(src, archiveLoc); err != nil {
+		t.Fatal(err)
+	}
+	outputDir := filepath.Join(t.TempDir(), "20240102-120000-DDC")
+	if err := SeedFromArchive(archiveLoc, outputDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "logs", "coord1", "server.log")); err != nil {
+		t.Errorf("expected previous log to be copied: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "completed")); err == nil {
+		t.Error("expected the completed folder to be skipped")
+	}
+	if _, err := os.Stat(outputDir + "-previous"); err == nil {
+		t.Error("expected the staging folder to be removed")
+	}
+}
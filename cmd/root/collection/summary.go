@@ -18,9 +18,16 @@ package collection
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/helpers"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/archive"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/jobstats"
 )
 
 type SummaryInfo struct {
@@ -39,6 +46,66 @@ type SummaryInfo struct {
 	DDCVersion          string                  `json:"ddcVersion"`
 	CollectionsEnabled  []string                `json:"collectionsEnabled"`
 	CollectionsDisabled []string                `json:"collectionsDisabled"`
+	NodeResults         []NodeResult            `json:"nodeResults"`
+	Parameters          CollectionParameters    `json:"parameters"`
+	RetriedNodes        []string                `json:"retriedNodes,omitempty"`
+	DeadlineReached     bool                    `json:"deadlineReached"`
+	Interrupted         bool                    `json:"interrupted"`
+}
+
+// CollectionParameters are the arguments the collection was started with so that
+// it can be retried, secrets such as the PAT are never stored
+type CollectionParameters struct {
+	Transport             string `json:"transport"`
+	CollectionMode        string `json:"collectionMode"`
+	OutputLoc             string `json:"outputLoc"`
+	DDCYamlLoc            string `json:"ddcYamlLoc"`
+	TransferDir           string `json:"transferDir"`
+	TransferThreads       int    `json:"transferThreads"`
+	DisableFreeSpaceCheck bool   `json:"disableFreeSpaceCheck"`
+	MinFreeSpaceGB        uint64 `json:"minFreeSpaceGB"`
+	MaxCollectionTime     string `json:"maxCollectionTime,omitempty"`
+	From                  string `json:"from,omitempty"`
+	To                    string `json:"to,omitempty"`
+	// SnapshotAt is not used by a retry as the snapshot time has passed
+	SnapshotAt      string `json:"snapshotAt,omitempty"`
+	FallbackEnabled bool   `json:"fallbackEnabled"`
+	Namespace       string `json:"namespace,omitempty"`
+	LabelSelector   string `json:"labelSelector,omitempty"`
+	K8SContext      string `json:"k8sContext,omitempty"`
+	DisableKubectl  bool   `json:"disableKubectl,omitempty"`
+	SSHUser         string `json:"sshUser,omitempty"`
+	SSHKeyLoc       string `json:"sshKeyLoc,omitempty"`
+	SudoUser        string `json:"sudoUser,omitempty"`
+}
+
+const (
+	NodeStatusCompleted = "completed"
+	NodeStatusFailed    = "failed"
+	// NodeStatusIncomplete is used when the collection deadline was reached or the collection
+	// was interrupted before the node finished
+	NodeStatusIncomplete = "incomplete"
+)
+
+// NodeResult is the outcome of the collection for a single node
+// including the result of every local-collect job that ran on it
+type NodeResult struct {
+	Host           string               `json:"host"`
+	NodeName       string               `json:"nodeName"`
+	IsCoordinator  bool                 `json:"isCoordinator"`
+	Status         string               `json:"status"`
+	StartTimeUTC   time.Time            `json:"startTimeUTC"`
+	EndTimeUTC     time.Time            `json:"endTimeUTC"`
+	DurationMillis int64                `json:"durationMillis"`
+	BytesWritten   int64                `json:"bytesWritten"`
+	FilesProduced  int                  `json:"filesProduced"`
+	Error          string               `json:"error,omitempty"`
+	Jobs           []jobstats.JobResult `json:"jobs"`
+	// TruncatedFiles are the logs cut short or left out by the log budgets of the node
+	TruncatedFiles []jobstats.TruncatedFile `json:"truncatedFiles,omitempty"`
+	// ClockOffsetMillis is how far the clock of the node was ahead of ddc when an incident snapshot was taken
+	ClockOffsetMillis            *int64 `json:"clockOffsetMillis,omitempty"`
+	ClockOffsetUncertaintyMillis int64  `json:"clockOffsetUncertaintyMillis,omitempty"`
 }
 
 type ClusterInfo struct {
@@ -65,3 +132,125 @@ func (summary SummaryInfo) String() (string, error) {
 	}
 	return string(b), nil
 }
+
+// ReadSummary reads the summary.json either directly or from inside of a ddc tarball
+func ReadSummary(loc string) (SummaryInfo, error) {
+	var summary SummaryInfo
+	var b []byte
+	var err error
+	if strings.HasSuffix(loc, ".json") {
+		b, err = os.ReadFile(filepath.Clean(loc))
+	} else {
+		b, err = archive.ReadFileFromTarGz(loc, "summary.json")
+	}
+	if err != nil {
+		return summary, fmt.Errorf("unable to read summary from %v: %w", loc, err)
+	}
+	if err := json.Unmarshal(b, &summary); err != nil {
+		return summary, fmt.Errorf("unable to parse summary from %v: %w", loc, err)
+	}
+	return summary, nil
+}
+
+// WriteSummaryTable prints a table of every node and every job that ran on the node
+func WriteSummaryTable(w io.Writer, summary SummaryInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintf(tw, "ddc version:\t%v\nstart:\t%v\nend:\t%v\nnodes collected:\t%v/%v\n\n",
+		summary.DDCVersion,
+		summary.StartTimeUTC.Format(time.RFC3339),
+		summary.EndTimeUTC.Format(time.RFC3339),
+		len(summary.CollectedFiles),
+		summary.ClusterInfo.TotalNodesAttempted,
+	); err != nil {
+		return err
+	}
+	if summary.Parameters.From != "" || summary.Parameters.To != "" {
+		from, to := summary.Parameters.From, summary.Parameters.To
+		if from == "" {
+			from = "the beginning"
+		}
+		if to == "" {
+			to = "the end of the collection"
+		}
+		if _, err := fmt.Fprintf(tw, "logs from:\t%v\nlogs to:\t%v\n\n", from, to); err != nil {
+			return err
+		}
+	}
+	if summary.DeadlineReached {
+		if _, err := fmt.Fprintf(tw, "WARNING: the max collection time of %v was reached, the collection is incomplete\n\n", summary.Parameters.MaxCollectionTime); err != nil {
+			return err
+		}
+	}
+	if summary.Interrupted {
+		if _, err := fmt.Fprint(tw, "WARNING: the collection was interrupted, the archive only contains what was collected before the interrupt\n\n"); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintln(tw, "NODE\tJOB\tSTATUS\tSTART\tDURATION\tBYTES\tFILES\tERROR"); err != nil {
+		return err
+	}
+	for _, node := range summary.NodeResults {
+		name := node.NodeName
+		if name == "" {
+			name = node.Host
+		}
+		if _, err := fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+			name,
+			"(node)",
+			node.Status,
+			node.StartTimeUTC.Format(time.RFC3339),
+			time.Duration(node.DurationMillis)*time.Millisecond,
+			node.BytesWritten,
+			node.FilesProduced,
+			oneLine(node.Error),
+		); err != nil {
+			return err
+		}
+		for _, job := range node.Jobs {
+			if _, err := fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+				name,
+				job.Name,
+				job.Status,
+				job.StartTimeUTC.Format(time.RFC3339),
+				time.Duration(job.DurationMillis)*time.Millisecond,
+				job.BytesWritten,
+				job.FilesProduced,
+				oneLine(job.Error),
+			); err != nil {
+				return err
+			}
+		}
+	}
+	if err := writeTruncatedFiles(tw, summary.NodeResults); err != nil {
+		return err
+	}
+	return tw.Flush()
+}
+
+// writeTruncatedFiles lists every log the log budgets cut short, nothing is written when no log was
+func writeTruncatedFiles(w io.Writer, nodes []NodeResult) error {
+	header := false
+	for _, node := range nodes {
+		name := node.NodeName
+		if name == "" {
+			name = node.Host
+		}
+		for _, f := range node.TruncatedFiles {
+			if !header {
+				if _, err := fmt.Fprint(w, "\nTRUNCATED LOGS\nNODE\tLOG TYPE\tFILE\tKEPT BYTES\tDROPPED BYTES\tBUDGET\n"); err != nil {
+					return err
+				}
+				header = true
+			}
+			if _, err := fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", name, f.LogType, f.File, f.KeptBytes, f.DroppedBytes, f.Budget); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// oneLine keeps multi-line error messages from breaking up the table
+func oneLine(msg string) string {
+	return strings.Join(strings.Fields(msg), " ")
+}
@@ -0,0 +1,190 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/jobstats"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/timewindow"
+)
+
+func TestReadSummaryAndWriteTable(t *testing.T) {
+	summaryInfo := SummaryInfo{
+		DDCVersion:  "v3.3.0",
+		ClusterInfo: ClusterInfo{TotalNodesAttempted: 2},
+		NodeResults: []NodeResult{
+			{
+				Host:          "node1",
+				NodeName:      "node1",
+				IsCoordinator: true,
+				Status:        NodeStatusCompleted,
+				Jobs: []jobstats.JobResult{
+					{Name: "LOG COLLECTION", Status: jobstats.StatusCompleted, BytesWritten: 100, FilesProduced: 2},
+					{Name: "JFR COLLECTION", Status: jobstats.StatusFailed, Error: "jcmd failed\nsecond line"},
+				},
+			},
+			{
+				Host:   "node2",
+				Status: NodeStatusFailed,
+				Error:  "unable to connect",
+			},
+		},
+	}
+	text, err := summaryInfo.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc := filepath.Join(t.TempDir(), "summary.json")
+	if err := os.WriteFile(loc, []byte(text), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadSummary(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.NodeResults) != 2 {
+		t.Fatalf("expected 2 node results but got %v", len(read.NodeResults))
+	}
+	var out bytes.Buffer
+	if err := WriteSummaryTable(&out, read); err != nil {
+		t.Fatal(err)
+	}
+	table := out.String()
+	for _, expected := range []string{"LOG COLLECTION", "JFR COLLECTION", "jcmd failed second line", "unable to connect", "node2"} {
+		if !strings.Contains(table, expected) {
+			t.Errorf("expected table to contain %q but was\n%v", expected, table)
+		}
+	}
+}
+
+func TestMergeJobStats(t *testing.T) {
+	nodeResults := []NodeResult{
+		{Host: "10.0.0.2", NodeName: "exec1"},
+		{Host: "10.0.0.1", NodeName: "coord1"},
+	}
+	merged := MergeJobStats(nodeResults, []jobstats.NodeJobStats{
+		{NodeName: "coord1", Jobs: []jobstats.JobResult{{Name: "A", FilesProduced: 2}, {Name: "B", FilesProduced: 3}}},
+	})
+	if merged[0].Host != "10.0.0.1" {
+		t.Errorf("expected results sorted by host but got %v first", merged[0].Host)
+	}
+	if merged[0].FilesProduced != 5 || len(merged[0].Jobs) != 2 {
+		t.Errorf("unexpected merge result %#v", merged[0])
+	}
+	if len(merged[1].Jobs) != 0 {
+		t.Errorf("expected no jobs for exec1 but got %v", len(merged[1].Jobs))
+	}
+}
+
+func TestMergeJobStatsMarksInterruptedNodesIncomplete(t *testing.T) {
+	nodeResults := []NodeResult{
+		{Host: "10.0.0.1", NodeName: "coord1", Status: NodeStatusCompleted},
+	}
+	merged := MergeJobStats(nodeResults, []jobstats.NodeJobStats{
+		{NodeName: "coord1", Jobs: []jobstats.JobResult{{Name: "A", Status: jobstats.StatusCompleted}, {Name: "B", Status: jobstats.StatusIncomplete}}},
+	})
+	if merged[0].Status != NodeStatusIncomplete {
+		t.Errorf("expected status %v but was %v", NodeStatusIncomplete, merged[0].Status)
+	}
+}
+
+func TestWriteSummaryTableInterrupted(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteSummaryTable(&out, SummaryInfo{Interrupted: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "WARNING: the collection was interrupted") {
+		t.Errorf("expected interrupted warning but was\n%v", out.String())
+	}
+}
+
+func TestMergeJobStatsAndWriteTruncatedFiles(t *testing.T) {
+	nodeResults := []NodeResult{
+		{Host: "10.0.0.1", NodeName: "coord1", Status: NodeStatusCompleted},
+	}
+	merged := MergeJobStats(nodeResults, []jobstats.NodeJobStats{
+		{NodeName: "coord1", TruncatedFiles: []jobstats.TruncatedFile{
+			{LogType: "server", File: "/var/log/dremio/server.log", OriginalBytes: 300, KeptBytes: 100, DroppedBytes: 200, Budget: "server budget of 100 bytes"},
+		}},
+	})
+	if len(merged[0].TruncatedFiles) != 1 {
+		t.Fatalf("expected the truncated file to be merged but got %#v", merged[0])
+	}
+	var out bytes.Buffer
+	if err := WriteSummaryTable(&out, SummaryInfo{NodeResults: merged}); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"TRUNCATED LOGS", "/var/log/dremio/server.log", "server budget of 100 bytes"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in\n%v", expected, out.String())
+		}
+	}
+}
+
+func TestWriteSummaryTableWindow(t *testing.T) {
+	var out bytes.Buffer
+	summary := SummaryInfo{Parameters: CollectionParameters{From: "2024-01-15T23:50:00-05:00"}}
+	if err := WriteSummaryTable(&out, summary); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"logs from:  2024-01-15T23:50:00-05:00", "logs to:    the end of the collection"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in\n%v", expected, out.String())
+		}
+	}
+}
+
+func TestLocalCollectArgsPassesTheWindow(t *testing.T) {
+	window, err := timewindow.Parse("2024-01-15T23:50:00-05:00", "2024-01-16T00:10:00-05:00", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := HostCaptureConfiguration{Host: "node1", TransferDir: "/tmp/ddc", CollectionMode: "light", Window: window}
+	args, _, err := LocalCollectArgs(c, "/tmp/ddc/ddc", true, false, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	joined := strings.Join(args, " ")
+	if !strings.Contains(joined, "--from 2024-01-15T23:50:00-05:00 --to 2024-01-16T00:10:00-05:00") {
+		t.Errorf("expected the window in %v", joined)
+	}
+}
+
+func TestLocalCollectArgsPassesTheSnapshot(t *testing.T) {
+	at := time.Date(2024, 1, 16, 4, 50, 0, 0, time.UTC)
+	c := HostCaptureConfiguration{Host: "node1", TransferDir: "/tmp/ddc", CollectionMode: "light", SnapshotAt: at.In(time.FixedZone("EST", -5*3600)), SnapshotClockOffset: -1500 * time.Millisecond}
+	args, _, err := LocalCollectArgs(c, "/tmp/ddc/ddc", true, false, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	joined := strings.Join(args, " ")
+	if !strings.Contains(joined, "--snapshot-at 2024-01-16T04:50:00Z --snapshot-clock-offset-ms -1500") {
+		t.Errorf("expected the snapshot in %v", joined)
+	}
+	c.SnapshotAt = time.Time{}
+	args, _, err = LocalCollectArgs(c, "/tmp/ddc/ddc", true, false, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if joined := strings.Join(args, " "); strings.Contains(joined, "snapshot") {
+		t.Errorf("expected no snapshot in %v", joined)
+	}
+}
@@ -16,10 +16,13 @@
 package fallback
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/cli"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
@@ -27,19 +30,54 @@ import (
 
 type Fallback struct {
 	cli cli.CmdExecutor
+	// localCollect runs local-collect in its own process group so the first CTRL+C only reaches ddc
+	localCollect cli.CmdExecutor
+	mu           sync.Mutex
+	pidFile      string
 }
 
 func NewFallback(hook shutdown.CancelHook) *Fallback {
 	return &Fallback{
-		cli: cli.NewCli(hook),
+		cli:          cli.NewCli(hook),
+		localCollect: cli.NewDetachedCli(hook),
 	}
 }
 
-func (c *Fallback) SetHostPid(_, _ string) {
-	// not needed as normal cancellation will work
+func (c *Fallback) SetHostPid(_, pidFile string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pidFile = pidFile
 }
 
 func (c *Fallback) CleanupRemote() error {
+	// not needed as normal cancellation will work
+	return nil
+}
+
+// InterruptRemote sends an interrupt to local-collect so it archives what it has,
+// it runs in its own process group so it does not see the terminal CTRL+C
+func (c *Fallback) InterruptRemote() error {
+	c.mu.Lock()
+	pidFile := c.pidFile
+	c.mu.Unlock()
+	if pidFile == "" {
+		return nil
+	}
+	b, err := os.ReadFile(filepath.Clean(pidFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			// local-collect has not started or is already done
+			return nil
+		}
+		return fmt.Errorf("unable to read pid file %v: %w", pidFile, err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return fmt.Errorf("invalid pid in %v: %w", pidFile, err)
+	}
+	if err := cli.InterruptProcess(pid); err != nil {
+		return fmt.Errorf("unable to interrupt local-collect process %v, press CTRL+C again to kill it and archive nothing: %w", pid, err)
+	}
 	return nil
 }
 
@@ -52,6 +90,9 @@ func (c *Fallback) HelpText() string {
 }
 
 func (c *Fallback) HostExecuteAndStream(mask bool, _ string, output cli.OutputHandler, pat string, args ...string) (err error) {
+	if len(args) > 1 && args[1] == "local-collect" {
+		return c.localCollect.ExecuteAndStreamOutput(mask, output, pat, args...)
+	}
 	return c.cli.ExecuteAndStreamOutput(mask, output, pat, args...)
 }
 
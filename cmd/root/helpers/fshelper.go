@@ -33,6 +33,8 @@ type Filesystem interface {
 	RemoveAll(path string) error
 	Remove(name string) error
 	WriteFile(name string, data []byte, perms os.FileMode) error
+	ReadFile(name string) ([]byte, error)
+	ReadDir(name string) ([]os.DirEntry, error)
 }
 
 type File interface {
@@ -139,6 +141,16 @@ func (f RealFileSystem) WriteFile(name string, data []byte, perms os.FileMode) e
 	return err
 }
 
+// ReadFile
+func (f RealFileSystem) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Clean(name))
+}
+
+// ReadDir
+func (f RealFileSystem) ReadDir(name string) ([]os.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
 // Fake file handlers (for testing)
 
 // Name
@@ -210,3 +222,13 @@ func (f FakeFileSystem) RemoveAll(_ string) error {
 func (f FakeFileSystem) WriteFile(_ string, _ []byte, _ os.FileMode) error {
 	return nil
 }
+
+// ReadFile reads from the real disk like Stat so tests can point at a fixture tree
+func (f FakeFileSystem) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Clean(name))
+}
+
+// ReadDir
+func (f FakeFileSystem) ReadDir(name string) ([]os.DirEntry, error) {
+	return os.ReadDir(name)
+}
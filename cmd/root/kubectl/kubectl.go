@@ -256,7 +256,21 @@ func (c *CliK8sActions) SetHostPid(host, pidFile string) {
 	c.m.Unlock()
 }
 
+// CleanupRemote aborts any running local-collect, they remove what they have collected
 func (c *CliK8sActions) CleanupRemote() error {
+	return c.signalRemote(false)
+}
+
+// InterruptRemote asks any running local-collect to stop its jobs and archive what it has collected
+func (c *CliK8sActions) InterruptRemote() error {
+	return c.signalRemote(true)
+}
+
+func (c *CliK8sActions) signalRemote(interrupt bool) error {
+	signal, statusUX, result := "-15", "FAILED - CANCELLED", consoleprint.ResultFailure
+	if interrupt {
+		signal, statusUX, result = "-2", "INTERRUPTED - ARCHIVING", consoleprint.ResultPending
+	}
 	kill := func(host string, pidFile string) {
 		if pidFile == "" {
 			simplelog.Debugf("pidfile is blank for %v skipping", host)
@@ -282,7 +296,7 @@ func (c *CliK8sActions) CleanupRemote() error {
 		simplelog.Infof("pid for host %v is %v", host, string(out[:]))
 		kubectlArgs = []string{"exec", "-n", c.namespace, "--context", c.k8sContext, "-c", container, host, "--"}
 		kubectlArgs = append(kubectlArgs, "kill")
-		kubectlArgs = append(kubectlArgs, "-15")
+		kubectlArgs = append(kubectlArgs, signal)
 		kubectlArgs = append(kubectlArgs, string(out[:]))
 		ctx, timeoutKill := context.WithTimeout(context.Background(), time.Second*time.Duration(120))
 		defer timeoutKill()
@@ -295,9 +309,13 @@ func (c *CliK8sActions) CleanupRemote() error {
 		consoleprint.UpdateNodeState(consoleprint.NodeState{
 			Node:     host,
 			Status:   consoleprint.Starting,
-			StatusUX: "FAILED - CANCELLED",
-			Result:   consoleprint.ResultFailure,
+			StatusUX: statusUX,
+			Result:   result,
 		})
+		if interrupt {
+			// keep the pid so a second interrupt can still abort it
+			return
+		}
 		c.m.Lock()
 		// cancel out so we can skip if it's called again
 		c.pidHosts[host] = ""
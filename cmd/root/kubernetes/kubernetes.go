@@ -136,7 +136,21 @@ func (c *KubeCtlAPIActions) SetHostPid(host, pidFile string) {
 	c.pidHosts[host] = pidFile
 }
 
+// CleanupRemote aborts any running local-collect, they remove what they have collected
 func (c *KubeCtlAPIActions) CleanupRemote() error {
+	return c.signalRemote(false)
+}
+
+// InterruptRemote asks any running local-collect to stop its jobs and archive what it has collected
+func (c *KubeCtlAPIActions) InterruptRemote() error {
+	return c.signalRemote(true)
+}
+
+func (c *KubeCtlAPIActions) signalRemote(interrupt bool) error {
+	signal, statusUX, result := "-15", "FAILED - CANCELLED", consoleprint.ResultFailure
+	if interrupt {
+		signal, statusUX, result = "-2", "INTERRUPTED - ARCHIVING", consoleprint.ResultPending
+	}
 	kill := func(host string, pidFile string) {
 		if pidFile == "" {
 			simplelog.Debugf("pidfile is blank for %v skipping", host)
@@ -189,7 +203,7 @@ func (c *KubeCtlAPIActions) CleanupRemote() error {
 		cmd = []string{
 			"sh",
 			"-c",
-			fmt.Sprintf("kill -15 %v", w.String()),
+			fmt.Sprintf("kill %v %v", signal, w.String()),
 		}
 		option = &v1.PodExecOptions{
 			Container: containerName,
@@ -222,9 +236,13 @@ func (c *KubeCtlAPIActions) CleanupRemote() error {
 		consoleprint.UpdateNodeState(consoleprint.NodeState{
 			Node:     host,
 			Status:   consoleprint.Starting,
-			StatusUX: "FAILED - CANCELLED",
-			Result:   consoleprint.ResultFailure,
+			StatusUX: statusUX,
+			Result:   result,
 		})
+		if interrupt {
+			// keep the pid so a second interrupt can still abort it
+			return
+		}
 		c.m.Lock()
 		// cancel out so we can skip if it's called again
 		c.pidHosts[host] = ""
@@ -76,7 +76,21 @@ func (c *CmdSSHActions) SetHostPid(host, pidFile string) {
 	c.m.Unlock()
 }
 
+// CleanupRemote aborts any running local-collect, they remove what they have collected
 func (c *CmdSSHActions) CleanupRemote() error {
+	return c.signalRemote(false)
+}
+
+// InterruptRemote asks any running local-collect to stop its jobs and archive what it has collected
+func (c *CmdSSHActions) InterruptRemote() error {
+	return c.signalRemote(true)
+}
+
+func (c *CmdSSHActions) signalRemote(interrupt bool) error {
+	signal, statusUX, result := "-15", "FAILED - CANCELLED", consoleprint.ResultFailure
+	if interrupt {
+		signal, statusUX, result = "-2", "INTERRUPTED - ARCHIVING", consoleprint.ResultPending
+	}
 	kill := func(host string, pidFile string) {
 		if pidFile == "" {
 			simplelog.Debugf("pidfile is blank for %v skipping", host)
@@ -96,7 +110,7 @@ func (c *CmdSSHActions) CleanupRemote() error {
 		sshArgs = append(sshArgs, fmt.Sprintf("%v@%v", c.sshUser, host))
 		sshArgs = c.addSSHUser(sshArgs)
 		sshArgs = append(sshArgs, "kill")
-		sshArgs = append(sshArgs, "-15")
+		sshArgs = append(sshArgs, signal)
 		sshArgs = append(sshArgs, out)
 		out, err = c.cli.Execute(false, sshArgs...)
 		if err != nil {
@@ -106,9 +120,13 @@ func (c *CmdSSHActions) CleanupRemote() error {
 		consoleprint.UpdateNodeState(consoleprint.NodeState{
 			Node:     host,
 			Status:   consoleprint.Starting,
-			StatusUX: "FAILED - CANCELLED",
-			Result:   consoleprint.ResultFailure,
+			StatusUX: statusUX,
+			Result:   result,
 		})
+		if interrupt {
+			// keep the pid so a second interrupt can still abort it
+			return
+		}
 		c.m.Lock()
 		// cancel out so we can skip if it's called again
 		c.pidHosts[host] = ""
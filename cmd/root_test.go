@@ -22,6 +22,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/collection"
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/ssh"
@@ -133,7 +134,7 @@ func TestAllSubCommandsAreWiredUp(t *testing.T) {
 	if err != nil {
 		t.Errorf("unexpected error %v", err)
 	}
-	expected := "Available Commands:\n  awselogs      Log only collect of AWSE from the coordinator node\n  local-collect retrieves all the dremio logs and diagnostics for the local node and saves the results in a compatible format for Dremio support\n  version       Print the version number of DDC\n"
+	expected := "Available Commands:\n  awselogs      Log only collect of AWSE from the coordinator node\n  local-collect retrieves all the dremio logs and diagnostics for the local node and saves the results in a compatible format for Dremio support\n  retry         Re-collect only the nodes that failed in a previous collection\n  summary       Print the per node and per job results of a collection\n  version       Print the version number of DDC\n"
 	if !strings.Contains(helpText, expected) {
 		t.Errorf("missing command text in `%q`", helpText)
 	}
@@ -141,7 +142,7 @@ func TestAllSubCommandsAreWiredUp(t *testing.T) {
 
 func TestValidateDDCYamlValid(t *testing.T) {
 	valid := filepath.Join("testdata", "ddc-valid.yaml")
-	_, err := ValidateAndReadYaml(valid, collects.StandardCollection)
+	_, _, err := ValidateAndReadYaml(valid, collects.StandardCollection)
 	if err != nil {
 		t.Errorf("expected no error for valid yaml: %v", err)
 	}
@@ -149,7 +150,7 @@ func TestValidateDDCYamlValid(t *testing.T) {
 
 func TestValidateDDCYamlNotPresent(t *testing.T) {
 	valid := filepath.Join("testdata", "not-found-anwhere.yaml")
-	_, err := ValidateAndReadYaml(valid, collects.StandardCollection)
+	_, _, err := ValidateAndReadYaml(valid, collects.StandardCollection)
 	if err == nil {
 		t.Error("expected an error for missing yaml")
 	}
@@ -157,7 +158,7 @@ func TestValidateDDCYamlNotPresent(t *testing.T) {
 
 func TestValidateDDCYamlNotValid(t *testing.T) {
 	valid := filepath.Join("testdata", "ddc-invalid.yaml")
-	_, err := ValidateAndReadYaml(valid, collects.StandardCollection)
+	_, _, err := ValidateAndReadYaml(valid, collects.StandardCollection)
 	if err == nil {
 		t.Errorf("expected an error for invalid yaml: %v", err)
 	}
@@ -177,7 +178,7 @@ func TestValidateDDCYamlMaskPAT(t *testing.T) {
 		simplelog.InitLoggerWithFile(filepath.Join(os.TempDir(), "ddc.log"))
 	}()
 	valid := filepath.Join("testdata", "ddc-valid.yaml")
-	_, err := ValidateAndReadYaml(valid, collects.StandardCollection)
+	_, _, err := ValidateAndReadYaml(valid, collects.StandardCollection)
 	if err != nil {
 		t.Errorf("expected no error for valid yaml: %v", err)
 	}
@@ -191,3 +192,27 @@ func TestValidateDDCYamlMaskPAT(t *testing.T) {
 		t.Errorf("expected redacted pat: '%v'", string(b))
 	}
 }
+
+func TestNewCollectionHookKeepsTransferGrace(t *testing.T) {
+	hook, deadline := newCollectionHook(time.Hour)
+	defer hook.Cleanup()
+	if deadline.IsZero() {
+		t.Fatal("expected a deadline")
+	}
+	ctxDeadline, ok := hook.GetContext().Deadline()
+	if !ok {
+		t.Fatal("expected the hook context to have a deadline")
+	}
+	if got := ctxDeadline.Sub(deadline); got != collection.TransferGracePeriod {
+		t.Errorf("expected the hook context to expire %v after the deadline but was %v", collection.TransferGracePeriod, got)
+	}
+
+	hook, deadline = newCollectionHook(0)
+	defer hook.Cleanup()
+	if !deadline.IsZero() {
+		t.Errorf("expected no deadline but got %v", deadline)
+	}
+	if _, ok := hook.GetContext().Deadline(); ok {
+		t.Error("expected the hook context to have no deadline")
+	}
+}
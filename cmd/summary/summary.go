@@ -0,0 +1,45 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// summary package prints the results recorded in the summary.json of a ddc archive
+package summary
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/root/collection"
+	"github.com/spf13/cobra"
+)
+
+var SummaryCmd = &cobra.Command{
+	Use:   "summary [archive or summary.json]",
+	Short: "Print the per node and per job results of a collection",
+	Long:  `Print the per node and per job results of a collection, accepts either the ddc tarball or the summary.json file`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		if err := Execute(args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to print summary: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func Execute(loc string) error {
+	summaryInfo, err := collection.ReadSummary(loc)
+	if err != nil {
+		return err
+	}
+	return collection.WriteSummaryTable(os.Stdout, summaryInfo)
+}
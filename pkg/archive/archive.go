@@ -258,3 +258,31 @@ func ExtractTarGzStream(reader io.Reader, dest, pathToStrip string) error {
 	defer gzReader.Close()
 	return ExtractTarStream(gzReader, dest, pathToStrip)
 }
+
+// ReadFileFromTarGz returns the contents of the first entry in the tarball with a matching name
+func ReadFileFromTarGz(gzFilePath, name string) ([]byte, error) {
+	reader, err := os.Open(path.Clean(gzFilePath))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	gzReader, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	defer gzReader.Close()
+	tarReader := tar.NewReader(gzReader)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("file %v not found in %v", name, gzFilePath)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg || path.Clean(header.Name) != path.Clean(name) {
+			continue
+		}
+		return io.ReadAll(tarReader)
+	}
+}
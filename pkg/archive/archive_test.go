@@ -259,7 +259,7 @@ func TestCopyLog(t *testing.T) {
 	simplelog.InitLogger()
 	simplelog.Infof("test for copy")
 	currLog := simplelog.GetLogLoc()
-	destLog := filepath.Join("testdata", "ddc.log")
+	destLog := filepath.Join(t.TempDir(), "ddc.log")
 	err := simplelog.CopyLog(destLog)
 	if err != nil {
 		t.Errorf("error copying log\n%v", err)
@@ -277,3 +277,25 @@ func TestCopyLog(t *testing.T) {
 		t.Errorf("expected logs to be equal size but they were not:\nFile: %v\nSize: %v\nFile: %v\nSize: %v", currLog, expected.Size(), destLog, actual.Size())
 	}
 }
+
+func TestReadFileFromTarGz(t *testing.T) {
+	src := filepath.Join("testdata", "ddctgz")
+	dest := filepath.Join(t.TempDir(), "output.tgz")
+	if err := archive.TarGzDir(src, dest); err != nil {
+		t.Fatalf("unable to archive file: %v", err)
+	}
+	actual, err := archive.ReadFileFromTarGz(dest, "2050101011-DDC/file1.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := os.ReadFile(filepath.Join(src, "2050101011-DDC", "file1.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected '%q' but got '%q'", string(expected), string(actual))
+	}
+	if _, err := archive.ReadFileFromTarGz(dest, "summary.json"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
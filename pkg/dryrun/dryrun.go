@@ -0,0 +1,70 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// package dryrun writes the plans of the ddc and local-collect dry runs, both are shown as text and can be saved as json
+package dryrun
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Printf writes a part of a text plan, tabs separate the aligned columns
+type Printf func(format string, a ...interface{})
+
+// Write writes the plan as text for humans or json for review tooling, text writes the text plan with printf
+func Write(w io.Writer, plan interface{}, format string, text func(printf Printf)) error {
+	if format == FormatJSON {
+		b, err := json.MarshalIndent(plan, "", "\t")
+		if err != nil {
+			return fmt.Errorf("unable to marshal dry run plan: %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	// only the first write error is kept, later writes are skipped
+	var writeErr error
+	text(func(format string, a ...interface{}) {
+		if writeErr == nil {
+			_, writeErr = fmt.Fprintf(tw, format, a...)
+		}
+	})
+	if writeErr != nil {
+		return writeErr
+	}
+	return tw.Flush()
+}
+
+// WriteConfig writes the effective configuration sorted by key
+func WriteConfig(printf Printf, config map[string]interface{}) {
+	printf("effective configuration:\n")
+	var keys []string
+	for k := range config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		printf("  %v:\t%v\n", k, config[k])
+	}
+	printf("\n")
+}
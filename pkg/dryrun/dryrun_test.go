@@ -0,0 +1,76 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dryrun_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/dryrun"
+)
+
+type plan struct {
+	Node   string                 `json:"node"`
+	Config map[string]interface{} `json:"config"`
+}
+
+func writePlan(printf dryrun.Printf, p plan) {
+	printf("node:\t%v\n", p.Node)
+	dryrun.WriteConfig(printf, p.Config)
+}
+
+func TestWriteText(t *testing.T) {
+	p := plan{Node: "node1", Config: map[string]interface{}{"b-key": 2, "a-key": true}}
+	var out bytes.Buffer
+	if err := dryrun.Write(&out, p, dryrun.FormatText, func(printf dryrun.Printf) { writePlan(printf, p) }); err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	expected := "node:  node1\neffective configuration:\n  a-key:  true\n  b-key:  2\n\n"
+	if out.String() != expected {
+		t.Errorf("expected %q but got %q", expected, out.String())
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	p := plan{Node: "node1", Config: map[string]interface{}{"a-key": "value"}}
+	var out bytes.Buffer
+	if err := dryrun.Write(&out, p, dryrun.FormatJSON, func(dryrun.Printf) { t.Error("expected no text for json") }); err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	var read plan
+	if err := json.Unmarshal(out.Bytes(), &read); err != nil {
+		t.Fatalf("expected json but got %v: %v", out.String(), err)
+	}
+	if read.Node != "node1" || read.Config["a-key"] != "value" {
+		t.Errorf("expected the plan back but got %#v", read)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestWriteTextReturnsTheWriteError(t *testing.T) {
+	p := plan{Node: strings.Repeat("n", 8192)}
+	err := dryrun.Write(failingWriter{}, p, dryrun.FormatText, func(printf dryrun.Printf) { writePlan(printf, p) })
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("expected the disk full error but got %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hocon
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Kind is the type of a resolved value
+type Kind int
+
+const (
+	KindNull Kind = iota
+	KindString
+	KindNumber
+	KindBool
+	KindObject
+	KindArray
+)
+
+// Value is a resolved value, scalars keep their text in String
+type Value struct {
+	Kind   Kind
+	String string
+	Keys   []string
+	Object map[string]*Value
+	Array  []*Value
+}
+
+// Interface converts the value to what encoding/json writes: maps, slices, strings, numbers, bools and nil
+func (v *Value) Interface() interface{} {
+	switch v.Kind {
+	case KindObject:
+		m := make(map[string]interface{}, len(v.Object))
+		for k, child := range v.Object {
+			m[k] = child.Interface()
+		}
+		return m
+	case KindArray:
+		a := make([]interface{}, 0, len(v.Array))
+		for _, child := range v.Array {
+			a = append(a, child.Interface())
+		}
+		return a
+	case KindBool:
+		return v.String == "true"
+	case KindNumber:
+		return jsonNumber(v.String)
+	case KindString:
+		return v.String
+	default:
+		return nil
+	}
+}
+
+// Config is a parsed document with its substitutions resolved
+type Config struct {
+	Root *Value
+	// Fields are every assignment of the source in order
+	Fields []Field
+	// Includes are the include statements, the included files are not read
+	Includes []string
+	// Unresolved are the required substitutions that were not found, they are kept as ${path} in the value
+	Unresolved []string
+}
+
+// Parse parses and resolves a HOCON document. Substitutions are looked up in the document, then vars and then the
+// environment. An optional substitution that is not found leaves the field out, a required one is recorded in Unresolved
+func Parse(text string, vars map[string]string) (*Config, error) {
+	root, p, err := parse(text)
+	if err != nil {
+		return nil, err
+	}
+	r := &resolver{root: root, vars: vars, resolving: make(map[*node]bool), unresolved: make(map[string]bool)}
+	c := &Config{Fields: p.fields, Includes: p.includes}
+	c.Root, _ = r.resolveObject(root)
+	c.Unresolved = r.order
+	return c, nil
+}
+
+// Get returns the value at a dotted path such as paths.local
+func (c *Config) Get(path string) (*Value, bool) {
+	v := c.Root
+	for _, key := range strings.Split(path, ".") {
+		if v == nil || v.Kind != KindObject {
+			return nil, false
+		}
+		v = v.Object[key]
+	}
+	return v, v != nil
+}
+
+// GetString returns the text of a scalar at a dotted path
+func (c *Config) GetString(path string) (string, bool) {
+	v, ok := c.Get(path)
+	if !ok || v.Kind == KindObject || v.Kind == KindArray || v.Kind == KindNull {
+		return "", false
+	}
+	return v.String, true
+}
+
+type resolver struct {
+	root       *object
+	vars       map[string]string
+	resolving  map[*node]bool
+	unresolved map[string]bool
+	order      []string
+}
+
+func (r *resolver) resolveObject(o *object) (*Value, bool) {
+	v := &Value{Kind: KindObject, Object: make(map[string]*Value)}
+	for _, k := range o.keys {
+		child, ok := r.resolve(o.fields[k])
+		if !ok {
+			continue
+		}
+		v.Keys = append(v.Keys, k)
+		v.Object[k] = child
+	}
+	return v, true
+}
+
+// resolve returns false for a value that is only a missing optional substitution
+func (r *resolver) resolve(n *node) (*Value, bool) {
+	switch {
+	case n.obj != nil:
+		return r.resolveObject(n.obj)
+	case n.arr != nil:
+		v := &Value{Kind: KindArray}
+		for _, e := range n.arr {
+			if child, ok := r.resolve(e); ok {
+				v.Array = append(v.Array, child)
+			}
+		}
+		return v, true
+	}
+	if r.resolving[n] {
+		return nil, false
+	}
+	r.resolving[n] = true
+	defer delete(r.resolving, n)
+	if len(n.pieces) == 1 {
+		pc := n.pieces[0]
+		if pc.sub != nil {
+			return r.substitute(pc.sub)
+		}
+		if pc.quoted {
+			return &Value{Kind: KindString, String: pc.text}, true
+		}
+		return scalar(pc.text), true
+	}
+	var b strings.Builder
+	found := false
+	for _, pc := range n.pieces {
+		if pc.sub == nil {
+			b.WriteString(pc.text)
+			continue
+		}
+		v, ok := r.substitute(pc.sub)
+		if !ok {
+			continue
+		}
+		found = true
+		if v.Kind != KindObject && v.Kind != KindArray && v.Kind != KindNull {
+			b.WriteString(v.String)
+		}
+	}
+	hasLiteral := false
+	for _, pc := range n.pieces {
+		if pc.sub == nil && strings.TrimSpace(pc.text) != "" {
+			hasLiteral = true
+		}
+	}
+	if !found && !hasLiteral {
+		return nil, false
+	}
+	return &Value{Kind: KindString, String: b.String()}, true
+}
+
+func (r *resolver) substitute(sub *substitution) (*Value, bool) {
+	if n := r.lookup(sub.path); n != nil {
+		if v, ok := r.resolve(n); ok {
+			return v, true
+		}
+	}
+	if v, ok := r.vars[sub.path]; ok {
+		return &Value{Kind: KindString, String: v}, true
+	}
+	if v, ok := os.LookupEnv(sub.path); ok {
+		return &Value{Kind: KindString, String: v}, true
+	}
+	if sub.optional {
+		return nil, false
+	}
+	if !r.unresolved[sub.path] {
+		r.unresolved[sub.path] = true
+		r.order = append(r.order, sub.path)
+	}
+	return &Value{Kind: KindString, String: "${" + sub.path + "}"}, true
+}
+
+func (r *resolver) lookup(path string) *node {
+	o := r.root
+	keys := strings.Split(path, ".")
+	for i, k := range keys {
+		n, ok := o.fields[k]
+		if !ok {
+			return nil
+		}
+		if i == len(keys)-1 {
+			return n
+		}
+		if n.obj == nil {
+			return nil
+		}
+		o = n.obj
+	}
+	return nil
+}
+
+var number = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+func scalar(text string) *Value {
+	switch {
+	case text == "true" || text == "false":
+		return &Value{Kind: KindBool, String: text}
+	case text == "null":
+		return &Value{Kind: KindNull}
+	case number.MatchString(text):
+		return &Value{Kind: KindNumber, String: text}
+	default:
+		return &Value{Kind: KindString, String: text}
+	}
+}
+
+// jsonNumber keeps the number as written when it is encoded as json
+type jsonNumber string
+
+func (n jsonNumber) MarshalJSON() ([]byte, error) {
+	return []byte(n), nil
+}
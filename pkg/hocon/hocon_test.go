@@ -0,0 +1,136 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hocon_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/hocon"
+)
+
+const dremioConf = `
+# comment
+paths: {
+  local: ${DREMIO_HOME}"/data"
+  dist: "pdfs://"${paths.local}"/pdfs"
+}
+paths.db = ${paths.local}/db // trailing comment
+
+services {
+  coordinator.enabled: true,
+  coordinator.master.enabled = true
+  executor.enabled: false
+  coordinator.web.port: 9047
+  coordinator.web.ssl: { enabled: true, keyStorePassword: ${?KEYSTORE_PASSWORD} }
+  "quoted.key": "a \"b\"\n"
+  fabric.port: 45678
+}
+services.executor.enabled: true
+services.coordinator.web { ssl.keyStore: /opt/dremio/ks.jks }
+debug.urls: [ "http://a", hdfs://namenode:8020/x ]
+debug.urls += "http://c"
+multi: """line1
+line2"""
+include "other.conf"
+`
+
+func TestParse(t *testing.T) {
+	c, err := hocon.Parse(dremioConf, map[string]string{"DREMIO_HOME": "/opt/dremio"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, expected := range map[string]string{
+		"paths.local":                           "/opt/dremio/data",
+		"paths.dist":                            "pdfs:///opt/dremio/data/pdfs",
+		"paths.db":                              "/opt/dremio/data/db",
+		"services.coordinator.web.port":         "9047",
+		"services.executor.enabled":             "true",
+		"services.coordinator.master.enabled":   "true",
+		"services.coordinator.web.ssl.enabled":  "true",
+		"services.coordinator.web.ssl.keyStore": "/opt/dremio/ks.jks",
+		"multi":                                 "line1\nline2",
+	} {
+		if v, ok := c.GetString(path); !ok || v != expected {
+			t.Errorf("expected %v to be %q but was %q (found %v)", path, expected, v, ok)
+		}
+	}
+	// the optional substitution is not set so the key is left out
+	if _, ok := c.Get("services.coordinator.web.ssl.keyStorePassword"); ok {
+		t.Error("expected the missing optional substitution to leave the key out")
+	}
+	quoted, ok := c.Get("services")
+	if !ok || quoted.Object["quoted.key"] == nil || quoted.Object["quoted.key"].String != "a \"b\"\n" {
+		t.Errorf("unexpected quoted key %#v", quoted.Object["quoted.key"])
+	}
+	urls, ok := c.Get("debug.urls")
+	if !ok || len(urls.Array) != 3 || urls.Array[1].String != "hdfs://namenode:8020/x" {
+		t.Errorf("unexpected array %#v", urls)
+	}
+	if len(c.Includes) != 1 || c.Includes[0] != `"other.conf"` {
+		t.Errorf("unexpected includes %v", c.Includes)
+	}
+	b, err := json.Marshal(c.Root.Interface())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"port":9047`) || !strings.Contains(string(b), `"enabled":true`) {
+		t.Errorf("expected numbers and bools in the json %v", string(b))
+	}
+}
+
+func TestParseFieldSpans(t *testing.T) {
+	text := "a.password: \"x\"\nb { password = y, c: [1, 2] }\nd: ${?SECRET}\n"
+	c, err := hocon.Parse(text, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range c.Fields {
+		got = append(got, f.Path+"="+text[f.Start:f.End])
+	}
+	expected := []string{`a.password="x"`, "b={ password = y, c: [1, 2] }", "b.password=y", "b.c=[1, 2]", "d=${?SECRET}"}
+	if strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected %v but got %v", expected, got)
+	}
+	if !c.Fields[4].OnlySubstitutions || c.Fields[0].OnlySubstitutions || !c.Fields[1].IsObject {
+		t.Errorf("unexpected field flags %#v", c.Fields)
+	}
+}
+
+func TestParseUnresolvedAndErrors(t *testing.T) {
+	c, err := hocon.Parse("a: ${NOT_SET_ANYWHERE_DDC}/x\nb: ${a}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := c.GetString("b"); v != "${NOT_SET_ANYWHERE_DDC}/x" {
+		t.Errorf("expected the unresolved substitution to be kept but got %v", v)
+	}
+	if len(c.Unresolved) != 1 || c.Unresolved[0] != "NOT_SET_ANYWHERE_DDC" {
+		t.Errorf("unexpected unresolved %v", c.Unresolved)
+	}
+	for text, expected := range map[string]string{
+		"a {\n b: 1\n":  "line 3: missing '}'",
+		"a: \"open\n":   "line 1: missing closing quote",
+		"a b\n":         "line 1: expected ':', '=' or '{' after the key a",
+		"a: [1, 2\n":    "missing ']' for a",
+		"{ a: 1 } b: 2": "after the closing }",
+	} {
+		if _, err := hocon.Parse(text, nil); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q for %q but got %v", expected, text, err)
+		}
+	}
+}
@@ -0,0 +1,507 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hocon parses HOCON, the format of dremio.conf, keeping where every value is in the source
+// so values can be masked by their key path without reformatting the file
+package hocon
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// node is a parsed value before substitutions are resolved, exactly one of obj, arr and pieces is set
+type node struct {
+	obj    *object
+	arr    []*node
+	pieces []piece
+	// start and end are the byte offsets of the value in the source
+	start, end int
+}
+
+// piece is a part of a value concatenation, a literal or a substitution
+type piece struct {
+	text   string
+	quoted bool
+	sub    *substitution
+}
+
+type substitution struct {
+	path     string
+	optional bool
+}
+
+type object struct {
+	keys   []string
+	fields map[string]*node
+}
+
+func newObject() *object {
+	return &object{fields: make(map[string]*node)}
+}
+
+func (o *object) put(key string, v *node) {
+	if _, ok := o.fields[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.fields[key] = v
+}
+
+// set assigns the value to the key path, objects assigned to the same key are merged and other values replace
+// what was there before
+func (o *object) set(path []string, v *node, appendOp bool) {
+	key := path[0]
+	existing := o.fields[key]
+	if len(path) > 1 {
+		if existing == nil || existing.obj == nil {
+			existing = &node{obj: newObject(), start: v.start, end: v.end}
+			o.put(key, existing)
+		}
+		existing.obj.set(path[1:], v, appendOp)
+		return
+	}
+	switch {
+	case appendOp && existing != nil && existing.arr != nil:
+		existing.arr = append(existing.arr, v)
+	case appendOp:
+		o.put(key, &node{arr: []*node{v}, start: v.start, end: v.end})
+	case existing != nil && existing.obj != nil && v.obj != nil:
+		for _, k := range v.obj.keys {
+			existing.obj.set([]string{k}, v.obj.fields[k], false)
+		}
+	default:
+		o.put(key, v)
+	}
+}
+
+// Field is an assignment in the source, a key that is set more than once has a Field for every assignment
+type Field struct {
+	// Path is the full key path such as services.coordinator.web.port
+	Path string
+	// Start and End are the byte offsets of the value in the source
+	Start, End int
+	// IsObject is true for object values, their fields are listed on their own
+	IsObject bool
+	// OnlySubstitutions is true when the value is only made of substitutions such as ${?PASSWORD}
+	OnlySubstitutions bool
+}
+
+type parser struct {
+	s        string
+	pos      int
+	fields   []Field
+	includes []string
+}
+
+// SyntaxError is a problem in the source with the line it was found on
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %v: %v", e.Line, e.Msg)
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Line: strings.Count(p.s[:p.pos], "\n") + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *parser) hasPrefix(prefix string) bool {
+	return strings.HasPrefix(p.s[p.pos:], prefix)
+}
+
+func (p *parser) atComment() bool {
+	return p.peek() == '#' || p.hasPrefix("//")
+}
+
+func (p *parser) skipLine() {
+	for !p.eof() && p.peek() != '\n' {
+		p.pos++
+	}
+}
+
+// skipSpace skips spaces and tabs
+func (p *parser) skipSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t' || p.peek() == '\r') {
+		p.pos++
+	}
+}
+
+// skipBlank skips whitespace, newlines and comments, with commas when commas is true
+func (p *parser) skipBlank(commas bool) {
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || (commas && c == ','):
+			p.pos++
+		case p.atComment():
+			p.skipLine()
+		case p.hasPrefix("\uFEFF"):
+			p.pos += len("\uFEFF")
+		default:
+			return
+		}
+	}
+}
+
+func parse(text string) (*object, *parser, error) {
+	p := &parser{s: text}
+	p.skipBlank(false)
+	var root *object
+	var err error
+	if p.peek() == '{' {
+		p.pos++
+		root, err = p.parseObjectBody("", '}')
+		if err != nil {
+			return nil, p, err
+		}
+		p.skipBlank(false)
+		if !p.eof() {
+			return nil, p, p.errorf("unexpected %q after the closing }", p.peek())
+		}
+	} else {
+		root, err = p.parseObjectBody("", 0)
+		if err != nil {
+			return nil, p, err
+		}
+	}
+	return root, p, nil
+}
+
+// parseObjectBody parses fields up to the closing brace, 0 means the end of the file
+func (p *parser) parseObjectBody(prefix string, closing byte) (*object, error) {
+	obj := newObject()
+	for {
+		p.skipBlank(true)
+		if p.eof() {
+			if closing != 0 {
+				return nil, p.errorf("missing %q", closing)
+			}
+			return obj, nil
+		}
+		if p.peek() == closing {
+			p.pos++
+			return obj, nil
+		}
+		if p.atInclude() {
+			continue
+		}
+		path, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		appendOp := false
+		switch {
+		case p.peek() == ':' || p.peek() == '=':
+			p.pos++
+		case p.hasPrefix("+="):
+			p.pos += 2
+			appendOp = true
+		case p.peek() == '{':
+		default:
+			return nil, p.errorf("expected ':', '=' or '{' after the key %v", strings.Join(path, "."))
+		}
+		p.skipBlank(false)
+		fullPath := strings.Join(path, ".")
+		if prefix != "" {
+			fullPath = prefix + "." + fullPath
+		}
+		v, err := p.parseValue(fullPath, true)
+		if err != nil {
+			return nil, err
+		}
+		obj.set(path, v, appendOp)
+		p.skipSpace()
+		if !p.eof() && p.peek() != '\n' && p.peek() != ',' && p.peek() != closing && !p.atComment() {
+			return nil, p.errorf("unexpected %q after the value of %v", p.peek(), fullPath)
+		}
+	}
+}
+
+// atInclude records and skips an include statement, the included files are not read
+func (p *parser) atInclude() bool {
+	if !p.hasPrefix("include") {
+		return false
+	}
+	rest := p.s[p.pos+len("include"):]
+	if rest == "" || (rest[0] != ' ' && rest[0] != '\t') {
+		return false
+	}
+	start := p.pos
+	p.skipLine()
+	p.includes = append(p.includes, strings.TrimSpace(p.s[start+len("include"):p.pos]))
+	return true
+}
+
+const forbiddenUnquoted = "$\"{}[]:=,+#`^?!@*&\\ \t\r\n"
+
+func (p *parser) parseKey() ([]string, error) {
+	var path []string
+	var segment strings.Builder
+	started := false
+	for !p.eof() {
+		c := p.peek()
+		switch {
+		case c == '"':
+			s, err := p.parseQuoted()
+			if err != nil {
+				return nil, err
+			}
+			segment.WriteString(s)
+			started = true
+			continue
+		case c == '.':
+			path = append(path, segment.String())
+			segment.Reset()
+			p.pos++
+			continue
+		case strings.IndexByte(forbiddenUnquoted, c) >= 0 || p.hasPrefix("//"):
+		default:
+			segment.WriteByte(c)
+			started = true
+			p.pos++
+			continue
+		}
+		break
+	}
+	if !started {
+		return nil, p.errorf("expected a key but found %q", p.peek())
+	}
+	path = append(path, segment.String())
+	for _, s := range path {
+		if s == "" {
+			return nil, p.errorf("empty key segment in %v", strings.Join(path, "."))
+		}
+	}
+	return path, nil
+}
+
+// atValueEnd is true at the end of a value: end of line, comma, a closing bracket or a comment. A // is only
+// a comment at the start of a value or after whitespace so unquoted urls such as hdfs://host/path still parse
+func (p *parser) atValueEnd(afterSpace bool) bool {
+	c := p.peek()
+	if p.eof() || c == '\n' || c == ',' || c == '}' || c == ']' || c == '#' {
+		return true
+	}
+	return afterSpace && p.hasPrefix("//")
+}
+
+// parseValue parses a value and records it as a Field of the path when record is true
+func (p *parser) parseValue(path string, record bool) (*node, error) {
+	start := p.pos
+	n := &node{start: start}
+	var pending string
+	afterSpace := true
+	for !p.atValueEnd(afterSpace) {
+		c := p.peek()
+		if c == ' ' || c == '\t' || c == '\r' {
+			wsStart := p.pos
+			p.skipSpace()
+			pending = p.s[wsStart:p.pos]
+			afterSpace = true
+			continue
+		}
+		if pending != "" && len(n.pieces) > 0 {
+			n.pieces = append(n.pieces, piece{text: pending})
+		}
+		pending = ""
+		afterSpace = false
+		switch {
+		case c == '{' && len(n.pieces) == 0 && n.obj == nil && n.arr == nil:
+			p.pos++
+			idx := len(p.fields)
+			if record {
+				p.fields = append(p.fields, Field{Path: path, Start: start, IsObject: true})
+			}
+			obj, err := p.parseObjectBody(path, '}')
+			if err != nil {
+				return nil, err
+			}
+			n.obj = obj
+			n.end = p.pos
+			if record {
+				p.fields[idx].End = p.pos
+			}
+			return n, nil
+		case c == '[' && len(n.pieces) == 0 && n.obj == nil && n.arr == nil:
+			arr, err := p.parseArray(path)
+			if err != nil {
+				return nil, err
+			}
+			n.arr = arr
+			n.end = p.pos
+			if record {
+				p.fields = append(p.fields, Field{Path: path, Start: start, End: n.end})
+			}
+			return n, nil
+		case c == '{' || c == '[':
+			return nil, p.errorf("concatenating objects or arrays with other values is not supported for %v", path)
+		case c == '"':
+			s, err := p.parseQuoted()
+			if err != nil {
+				return nil, err
+			}
+			n.pieces = append(n.pieces, piece{text: s, quoted: true})
+		case p.hasPrefix("${"):
+			sub, err := p.parseSubstitution()
+			if err != nil {
+				return nil, err
+			}
+			n.pieces = append(n.pieces, piece{sub: sub})
+		default:
+			from := p.pos
+			for !p.atValueEnd(false) {
+				c := p.peek()
+				if c == ' ' || c == '\t' || c == '\r' || c == '"' || c == '{' || c == '[' || p.hasPrefix("${") {
+					break
+				}
+				_, size := utf8.DecodeRuneInString(p.s[p.pos:])
+				p.pos += size
+			}
+			n.pieces = append(n.pieces, piece{text: p.s[from:p.pos]})
+		}
+	}
+	if len(n.pieces) == 0 {
+		return nil, p.errorf("missing value for %v", path)
+	}
+	n.end = p.pos - len(pending)
+	onlySubs := true
+	for _, pc := range n.pieces {
+		if pc.sub == nil {
+			onlySubs = false
+		}
+	}
+	if record {
+		p.fields = append(p.fields, Field{Path: path, Start: start, End: n.end, OnlySubstitutions: onlySubs})
+	}
+	return n, nil
+}
+
+func (p *parser) parseArray(path string) ([]*node, error) {
+	p.pos++
+	var arr []*node
+	for {
+		p.skipBlank(true)
+		if p.eof() {
+			return nil, p.errorf("missing ']' for %v", path)
+		}
+		if p.peek() == ']' {
+			p.pos++
+			return arr, nil
+		}
+		// elements are part of the array field
+		v, err := p.parseValue(path, false)
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+	}
+}
+
+func (p *parser) parseSubstitution() (*substitution, error) {
+	p.pos += 2
+	sub := &substitution{}
+	if p.peek() == '?' {
+		sub.optional = true
+		p.pos++
+	}
+	end := strings.IndexByte(p.s[p.pos:], '}')
+	if end < 0 {
+		return nil, p.errorf("missing '}' for the substitution")
+	}
+	sub.path = strings.TrimSpace(p.s[p.pos : p.pos+end])
+	if sub.path == "" {
+		return nil, p.errorf("empty substitution")
+	}
+	p.pos += end + 1
+	return sub, nil
+}
+
+func (p *parser) parseQuoted() (string, error) {
+	if p.hasPrefix(`"""`) {
+		p.pos += 3
+		end := strings.Index(p.s[p.pos:], `"""`)
+		if end < 0 {
+			return "", p.errorf(`missing closing """`)
+		}
+		// extra quotes before the closing ones belong to the string
+		for strings.HasPrefix(p.s[p.pos+end+1:], `"""`) {
+			end++
+		}
+		s := p.s[p.pos : p.pos+end]
+		p.pos += end + 3
+		return s, nil
+	}
+	p.pos++
+	var b strings.Builder
+	for !p.eof() {
+		c := p.peek()
+		switch c {
+		case '"':
+			p.pos++
+			return b.String(), nil
+		case '\n':
+			return "", p.errorf("missing closing quote")
+		case '\\':
+			p.pos++
+			if p.eof() {
+				return "", p.errorf("missing closing quote")
+			}
+			e := p.peek()
+			switch e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'u':
+				if len(p.s) < p.pos+5 {
+					return "", p.errorf("invalid unicode escape")
+				}
+				r, err := strconv.ParseUint(p.s[p.pos+1:p.pos+5], 16, 32)
+				if err != nil {
+					return "", p.errorf("invalid unicode escape: %v", err)
+				}
+				b.WriteRune(rune(r))
+				p.pos += 4
+			default:
+				b.WriteByte(e)
+			}
+			p.pos++
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", p.errorf("missing closing quote")
+}
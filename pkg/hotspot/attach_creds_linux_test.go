@@ -0,0 +1,130 @@
+//go:build linux
+// +build linux
+
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hotspot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+func owner(t *testing.T, loc string) uint32 {
+	fi, err := os.Stat(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fi.Sys().(*syscall.Stat_t).Uid
+}
+
+func TestAsUserOnlySwitchesTheAttachingThread(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("switching ids needs root")
+	}
+	const nobody = 65534
+	// t.TempDir is inside of a directory the other uid cannot traverse
+	dir, err := os.MkdirTemp("", "ddc-as-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// #nosec G302 the other uid has to be able to write
+	if err := os.Chmod(dir, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	switched := make(chan struct{})
+	release := make(chan struct{})
+	var writeErr error
+	done := make(chan error, 1)
+	go func() {
+		done <- asUser(nobody, nobody, func() {
+			writeErr = os.WriteFile(filepath.Join(dir, "attach"), []byte("x"), 0o600)
+			close(switched)
+			<-release
+		})
+	}()
+	<-switched
+	// the other jobs keep writing while the attaching thread runs as the jvm user
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- os.WriteFile(filepath.Join(dir, fmt.Sprintf("job-%v", i)), []byte("x"), 0o600)
+		}(i)
+	}
+	wg.Wait()
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if uid := owner(t, filepath.Join(dir, "attach")); uid != nobody {
+		t.Errorf("expected the attaching thread to write as uid %v but was %v", nobody, uid)
+	}
+	for i := 0; i < 20; i++ {
+		if uid := owner(t, filepath.Join(dir, fmt.Sprintf("job-%v", i))); uid != 0 {
+			t.Errorf("expected the concurrent write %v to keep uid 0 but was %v", i, uid)
+		}
+	}
+	if os.Geteuid() != 0 || os.Getegid() != 0 {
+		t.Errorf("expected the process to keep running as root but was %v:%v", os.Geteuid(), os.Getegid())
+	}
+}
+
+func TestWriteTempFileIsOwnedByTheJVMUser(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("chown needs root")
+	}
+	const nobody = 65534
+	dir := t.TempDir()
+	tgt := &target{pid: 1, nsPid: 1, uid: nobody, gid: nobody, tmpDir: dir}
+	jvmPath, remove, err := tgt.writeTempFile("ddc-*.jfc", []byte("<configuration/>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc := filepath.Join(dir, filepath.Base(jvmPath))
+	if jvmPath != filepath.Join("/tmp", filepath.Base(loc)) {
+		t.Errorf("expected the path of the file in the /tmp of the jvm but was %v", jvmPath)
+	}
+	if actual := owner(t, loc); actual != nobody {
+		t.Errorf("expected the file to be owned by %v but was %v", nobody, actual)
+	}
+	fi, err := os.Stat(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o600 {
+		t.Errorf("expected 0600 but was %v", fi.Mode().Perm())
+	}
+	remove()
+	if _, err := os.Stat(loc); !os.IsNotExist(err) {
+		t.Errorf("expected the file to be removed but was %v", err)
+	}
+}
@@ -0,0 +1,341 @@
+//go:build linux
+// +build linux
+
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hotspot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+)
+
+// listenerTimeout is how long the jvm gets to start its attach listener after SIGQUIT
+const listenerTimeout = 10 * time.Second
+
+// target is the jvm as seen from this process
+type target struct {
+	// pid is the pid in our pid namespace, used for /proc and signals
+	pid int
+	// nsPid is the pid inside the namespace of the jvm, used for the socket and attach file names
+	nsPid int
+	uid   int
+	gid   int
+	// tmpDir is the /tmp of the jvm, through /proc/<pid>/root when it runs in another mount namespace
+	tmpDir string
+	// otherUserNS is set when the jvm runs in another user namespace and only trusts its own uid
+	otherUserNS bool
+}
+
+// Attach runs the attach operation op such as "jcmd" or "dumpheap" in the jvm of pid and writes its output to w.
+// The attach listener of the jvm is started with an .attach_pid file and SIGQUIT when its socket does not exist yet
+func Attach(ctx context.Context, pid int, w io.Writer, op string, args ...string) error {
+	t, err := resolveTarget(pid)
+	if err != nil {
+		return err
+	}
+	socket := filepath.Join(t.tmpDir, fmt.Sprintf(".java_pid%d", t.nsPid))
+	if !isSocket(socket) {
+		if err := t.startListener(ctx, socket); err != nil {
+			return err
+		}
+	}
+	conn, err := t.connect(ctx, socket)
+	if err != nil {
+		return fmt.Errorf("unable to connect to %v: %w", socket, err)
+	}
+	defer conn.Close()
+	// the jvm can take long for heap dumps, only a cancelled collection interrupts it
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+	if err := writeRequest(conn, op, args...); err != nil {
+		return fmt.Errorf("unable to send %v to pid %v: %w", op, pid, err)
+	}
+	if err := readResponse(conn, w); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%v on pid %v interrupted: %w", op, pid, ctx.Err())
+		}
+		return err
+	}
+	return nil
+}
+
+// WriteTempFile writes data to a new file in the /tmp of the jvm of pid, owned by the user of the jvm when we run as root so
+// it can read it even when it runs as another user or in a container. It returns the path as the jvm sees it and a func
+// that removes the file
+func WriteTempFile(pid int, pattern string, data []byte) (string, func(), error) {
+	t, err := resolveTarget(pid)
+	if err != nil {
+		return "", nil, err
+	}
+	return t.writeTempFile(pattern, data)
+}
+
+func (t *target) writeTempFile(pattern string, data []byte) (string, func(), error) {
+	f, err := os.CreateTemp(t.tmpDir, pattern)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to create a file in %v for pid %v: %w", t.tmpDir, t.pid, err)
+	}
+	remove := func() {
+		if err := os.Remove(f.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			simplelog.Warningf("unable to remove %v: %v", f.Name(), err)
+		}
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		remove()
+		return "", nil, fmt.Errorf("unable to write %v: %w", f.Name(), err)
+	}
+	if os.Geteuid() == 0 && t.uid > 0 {
+		if err := f.Chown(t.uid, t.gid); err != nil {
+			_ = f.Close()
+			remove()
+			return "", nil, fmt.Errorf("unable to chown %v to the user of pid %v: %w", f.Name(), t.pid, err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		remove()
+		return "", nil, fmt.Errorf("unable to write %v: %w", f.Name(), err)
+	}
+	return filepath.Join("/tmp", filepath.Base(f.Name())), remove, nil
+}
+
+func resolveTarget(pid int) (*target, error) {
+	t, err := readStatus(pid)
+	if err != nil {
+		return nil, err
+	}
+	proc := fmt.Sprintf("/proc/%d", pid)
+	if err := isHotSpot(proc); err != nil {
+		return nil, err
+	}
+	if !sameNamespace(proc, "mnt") {
+		if _, err := os.Stat(filepath.Join(proc, "root", "tmp")); err == nil {
+			t.tmpDir = filepath.Join(proc, "root", "tmp")
+		}
+	}
+	t.otherUserNS = !sameNamespace(proc, "user")
+	return t, nil
+}
+
+// readStatus reads the ids and the namespace pid of pid from /proc/<pid>/status
+func readStatus(pid int) (*target, error) {
+	status, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the status of pid %v: %w", pid, err)
+	}
+	t := &target{pid: pid, nsPid: pid, uid: -1, gid: -1, tmpDir: "/tmp"}
+	for _, line := range strings.Split(string(status), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+		switch key {
+		case "Uid":
+			// real, effective, saved and filesystem ids, the jvm compares with the effective one
+			if len(fields) > 1 {
+				t.uid, _ = strconv.Atoi(fields[1])
+			}
+		case "Gid":
+			if len(fields) > 1 {
+				t.gid, _ = strconv.Atoi(fields[1])
+			}
+		case "NSpid":
+			// the last entry is the pid in the innermost namespace which is the one the jvm knows itself by
+			t.nsPid, _ = strconv.Atoi(fields[len(fields)-1])
+		}
+	}
+	return t, nil
+}
+
+// FindPerfData returns the hsperfdata file of pid, for a jvm in another container it is found through /proc/<pid>/root
+func FindPerfData(pid int) (string, error) {
+	if matches, _ := filepath.Glob(filepath.Join("/tmp", "hsperfdata_*", strconv.Itoa(pid))); len(matches) > 0 {
+		return matches[0], nil
+	}
+	t, err := readStatus(pid)
+	if err != nil {
+		return "", err
+	}
+	pattern := filepath.Join(fmt.Sprintf("/proc/%d/root/tmp", pid), "hsperfdata_*", strconv.Itoa(t.nsPid))
+	if matches, _ := filepath.Glob(pattern); len(matches) > 0 {
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("no hsperfdata for pid %v, the jvm may run with -XX:-UsePerfData or -XX:+PerfDisableSharedMem", pid)
+}
+
+func processAlive(pid int) bool {
+	_, err := os.Stat(fmt.Sprintf("/proc/%d", pid))
+	return err == nil
+}
+
+// isHotSpot makes sure pid runs libjvm, SIGQUIT terminates processes that do not handle it
+func isHotSpot(proc string) error {
+	maps, err := os.ReadFile(filepath.Join(proc, "maps"))
+	if err != nil {
+		return fmt.Errorf("unable to check that %v is a jvm: %w", proc, err)
+	}
+	if !strings.Contains(string(maps), "/libjvm.so") {
+		return fmt.Errorf("%v is not a hotspot jvm", proc)
+	}
+	return nil
+}
+
+// sameNamespace compares the namespace of kind of the process with ours, not being able to read it counts as different
+func sameNamespace(proc, kind string) bool {
+	self, err := os.Readlink(filepath.Join("/proc/self/ns", kind))
+	if err != nil {
+		return false
+	}
+	other, err := os.Readlink(filepath.Join(proc, "ns", kind))
+	if err != nil {
+		return false
+	}
+	return self == other
+}
+
+func isSocket(p string) bool {
+	fi, err := os.Stat(p)
+	return err == nil && fi.Mode()&os.ModeSocket != 0
+}
+
+// startListener creates the attach file the jvm looks for on SIGQUIT in its working dir or tmp dir and waits for the socket
+func (t *target) startListener(ctx context.Context, socket string) error {
+	name := fmt.Sprintf(".attach_pid%d", t.nsPid)
+	attachFile := filepath.Join(fmt.Sprintf("/proc/%d/cwd", t.pid), name)
+	f, err := os.OpenFile(attachFile, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		attachFile = filepath.Join(t.tmpDir, name)
+		if f, err = os.OpenFile(attachFile, os.O_CREATE|os.O_WRONLY, 0o600); err != nil {
+			return fmt.Errorf("unable to create attach file for pid %v: %w", t.pid, err)
+		}
+	}
+	defer func() {
+		if err := os.Remove(attachFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			simplelog.Warningf("unable to remove attach file %v: %v", attachFile, err)
+		}
+	}()
+	// the jvm ignores attach files that are not owned by its own user or root
+	if os.Geteuid() == 0 && t.uid > 0 {
+		if err := f.Chown(t.uid, t.gid); err != nil {
+			simplelog.Debugf("unable to chown attach file %v: %v", attachFile, err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("unable to create attach file %v: %w", attachFile, err)
+	}
+	if err := syscall.Kill(t.pid, syscall.SIGQUIT); err != nil {
+		return fmt.Errorf("unable to signal pid %v: %w", t.pid, err)
+	}
+	deadline := time.Now().Add(listenerTimeout)
+	delay := 20 * time.Millisecond
+	for !isSocket(socket) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the attach listener of pid %v did not start within %v, the jvm may run with -XX:+DisableAttachMechanism", t.pid, listenerTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for the attach listener of pid %v interrupted: %w", t.pid, ctx.Err())
+		case <-time.After(delay):
+		}
+		if delay < 200*time.Millisecond {
+			delay *= 2
+		}
+	}
+	return nil
+}
+
+// connect dials the socket of the jvm. The jvm only accepts peers with its own uid and gid or root,
+// root of another user namespace is not root for the jvm so the connect is made with the ids of the jvm
+func (t *target) connect(ctx context.Context, socket string) (net.Conn, error) {
+	var d net.Dialer
+	if !t.otherUserNS || os.Geteuid() != 0 || t.uid <= 0 {
+		return d.DialContext(ctx, "unix", socket)
+	}
+	var conn net.Conn
+	var dialErr error
+	if err := asUser(t.uid, t.gid, func() {
+		conn, dialErr = d.DialContext(ctx, "unix", socket)
+	}); err != nil {
+		return nil, err
+	}
+	return conn, dialErr
+}
+
+// asUser runs fn on a thread of its own with the effective uid and gid switched. syscall.Seteuid switches
+// every thread of the process, which would run the jobs collecting next to the attach as the jvm user, so
+// the raw syscalls are used on a locked thread. A thread whose ids cannot be switched back is thrown away.
+func asUser(uid, gid int, fn func()) error {
+	errs := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		if err := setThreadEgid(gid); err != nil {
+			runtime.UnlockOSThread()
+			errs <- fmt.Errorf("unable to switch to gid %v: %w", gid, err)
+			return
+		}
+		if err := setThreadEuid(uid); err != nil {
+			if err := setThreadEgid(0); err != nil {
+				simplelog.Errorf("unable to switch back to gid 0, dropping the thread: %v", err)
+			} else {
+				runtime.UnlockOSThread()
+			}
+			errs <- fmt.Errorf("unable to switch to uid %v: %w", uid, err)
+			return
+		}
+		fn()
+		// the uid goes back first, only root may switch the gid back
+		if err := setThreadEuid(0); err != nil {
+			simplelog.Errorf("unable to switch back to uid 0, dropping the thread: %v", err)
+		} else if err := setThreadEgid(0); err != nil {
+			simplelog.Errorf("unable to switch back to gid 0, dropping the thread: %v", err)
+		} else {
+			runtime.UnlockOSThread()
+		}
+		errs <- nil
+	}()
+	return <-errs
+}
+
+// setThreadEuid and setThreadEgid only change the ids of the calling thread, the real and saved ids stay root
+func setThreadEuid(uid int) error {
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_SETRESUID, ^uintptr(0), uintptr(uid), ^uintptr(0)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func setThreadEgid(gid int) error {
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_SETRESGID, ^uintptr(0), uintptr(gid), ^uintptr(0)); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+//go:build linux
+// +build linux
+
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hotspot_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/hotspot"
+)
+
+func TestAttachRefusesProcessesThatAreNotAJVM(t *testing.T) {
+	// the test binary does not handle SIGQUIT so it would die if it was signaled
+	var out bytes.Buffer
+	err := hotspot.Attach(context.Background(), os.Getpid(), &out, "jcmd", "Thread.print")
+	if err == nil || !strings.Contains(err.Error(), "not a hotspot jvm") {
+		t.Errorf("expected a not a jvm error but got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+//go:build !linux
+// +build !linux
+
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hotspot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+)
+
+// Attach is only implemented on linux, the callers fall back to the jdk binaries
+func Attach(_ context.Context, _ int, _ io.Writer, _ string, _ ...string) error {
+	return ErrNotSupported
+}
+
+// FindPerfData is only implemented on linux
+func FindPerfData(_ int) (string, error) {
+	return "", ErrNotSupported
+}
+
+// WriteTempFile writes data to a new file in the temp dir, the jvm runs as the same user outside of linux.
+// It returns the path of the file and a func that removes it
+func WriteTempFile(_ int, pattern string, data []byte) (string, func(), error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to create a temp file: %w", err)
+	}
+	remove := func() {
+		if err := os.Remove(f.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			simplelog.Warningf("unable to remove %v: %v", f.Name(), err)
+		}
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		remove()
+		return "", nil, fmt.Errorf("unable to write %v: %w", f.Name(), err)
+	}
+	if err := f.Close(); err != nil {
+		remove()
+		return "", nil, fmt.Errorf("unable to write %v: %w", f.Name(), err)
+	}
+	return f.Name(), remove, nil
+}
+
+func processAlive(_ int) bool {
+	return true
+}
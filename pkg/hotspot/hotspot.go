@@ -0,0 +1,126 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// package hotspot talks to a running HotSpot JVM through the dynamic attach mechanism so no JDK tools are needed
+package hotspot
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/ddcio"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
+)
+
+// protocolVersion is the only version of the attach protocol understood by every HotSpot release since JDK 6
+const protocolVersion = "1"
+
+// maxArgs is the fixed number of arguments of an attach request
+const maxArgs = 3
+
+// ErrNotSupported is returned on platforms without an attach implementation
+var ErrNotSupported = errors.New("the hotspot attach api is not supported on this platform")
+
+// CommandError is returned when the jvm ran the operation and reported a failure,
+// the binaries would fail the same way so there is no fallback for it
+type CommandError struct {
+	Code   int
+	Output string
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("jvm returned %v: %v", e.Code, strings.TrimSpace(e.Output))
+}
+
+// writeRequest encodes an operation as the protocol version, the name and exactly three arguments, all nul terminated
+func writeRequest(w io.Writer, op string, args ...string) error {
+	if len(args) > maxArgs {
+		return fmt.Errorf("operation %v takes at most %v arguments but got %v", op, maxArgs, len(args))
+	}
+	var b bytes.Buffer
+	b.WriteString(protocolVersion)
+	b.WriteByte(0)
+	b.WriteString(op)
+	b.WriteByte(0)
+	for i := 0; i < maxArgs; i++ {
+		if i < len(args) {
+			b.WriteString(args[i])
+		}
+		b.WriteByte(0)
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// readResponse reads the result code line and copies the rest of the stream to w
+func readResponse(r io.Reader, w io.Writer) error {
+	br := bufio.NewReader(r)
+	line, err := br.ReadString('\n')
+	if err != nil && line == "" {
+		return fmt.Errorf("no response from the jvm: %w", err)
+	}
+	code, convErr := strconv.Atoi(strings.TrimSpace(line))
+	if convErr != nil {
+		return fmt.Errorf("invalid response code %q from the jvm", strings.TrimSpace(line))
+	}
+	if code != 0 {
+		out, _ := io.ReadAll(br)
+		return &CommandError{Code: code, Output: string(out)}
+	}
+	if _, err := io.Copy(w, br); err != nil {
+		return fmt.Errorf("unable to read the jvm response: %w", err)
+	}
+	return nil
+}
+
+// Jcmd runs a diagnostic command such as "Thread.print -l" in the jvm of pid,
+// when attaching fails the jcmd binary is used instead
+func Jcmd(hook shutdown.CancelHook, w io.Writer, pid int, command string) error {
+	return withFallback(hook, w, pid, fmt.Sprintf("jcmd %v %v", pid, command), "jcmd", command)
+}
+
+// HeapDump writes an hprof heap dump of the jvm of pid to file, only reachable objects when live is set,
+// when attaching fails jmap is used instead. The path is resolved by the jvm
+func HeapDump(hook shutdown.CancelHook, w io.Writer, pid int, file string, live bool) error {
+	objects, jmapOpts := "-all", "format=b"
+	if live {
+		objects, jmapOpts = "-live", "live,format=b"
+	}
+	return withFallback(hook, w, pid, fmt.Sprintf("jmap -dump:%v,file=%v %v", jmapOpts, file, pid), "dumpheap", file, objects)
+}
+
+func withFallback(hook shutdown.CancelHook, w io.Writer, pid int, fallback string, op string, args ...string) error {
+	var out bytes.Buffer
+	err := Attach(hook.GetContext(), pid, &out, op, args...)
+	if err == nil {
+		_, err = w.Write(out.Bytes())
+		return err
+	}
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		_, _ = w.Write([]byte(cmdErr.Output))
+		return err
+	}
+	simplelog.Warningf("unable to attach to pid %v, falling back to %q: %v", pid, fallback, err)
+	if shellErr := ddcio.Shell(hook, w, fallback); shellErr != nil {
+		return fmt.Errorf("attach failed with '%v' and %v failed: %w", err, strings.Fields(fallback)[0], shellErr)
+	}
+	return nil
+}
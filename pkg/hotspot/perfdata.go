@@ -0,0 +1,203 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hotspot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// perfDataMagic starts every hsperfdata file, it is always stored big endian
+const perfDataMagic = 0xcafec0c0
+
+// perfDataPrologueSize is the size of the version 2 prologue, version 1 files are from JDK 1.4 and not supported
+const perfDataPrologueSize = 32
+
+// Units of a perf counter as stored in hsperfdata
+type Units byte
+
+const (
+	UnitsNone   Units = 1
+	UnitsBytes  Units = 2
+	UnitsTicks  Units = 3
+	UnitsEvents Units = 4
+	UnitsString Units = 5
+	UnitsHertz  Units = 6
+)
+
+// Counter is one entry of an hsperfdata file, strings are in Text and every other type in Value
+type Counter struct {
+	Value int64
+	Text  string
+	Units Units
+}
+
+// PerfData is the parsed content of an hsperfdata file
+type PerfData struct {
+	Counters map[string]Counter
+}
+
+// ParsePerfData parses the instrumentation the jvm publishes in /tmp/hsperfdata_<user>/<pid> unless it runs with -XX:-UsePerfData
+func ParsePerfData(data []byte) (*PerfData, error) {
+	if len(data) < perfDataPrologueSize {
+		return nil, errors.New("hsperfdata file is too short")
+	}
+	if binary.BigEndian.Uint32(data[0:4]) != perfDataMagic {
+		return nil, errors.New("not an hsperfdata file")
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if data[4] == 1 {
+		order = binary.LittleEndian
+	}
+	if major := data[5]; major != 2 {
+		return nil, fmt.Errorf("unsupported hsperfdata version %v", major)
+	}
+	entryOffset := int(int32(order.Uint32(data[24:28])))
+	numEntries := int(int32(order.Uint32(data[28:32])))
+	p := &PerfData{Counters: make(map[string]Counter, numEntries)}
+	offset := entryOffset
+	for i := 0; i < numEntries; i++ {
+		// entry header: length, name offset, vector length, type, flags, units, variability, data offset
+		if offset < 0 || offset+20 > len(data) {
+			return nil, fmt.Errorf("hsperfdata entry %v is out of bounds", i)
+		}
+		entryLength := int(int32(order.Uint32(data[offset : offset+4])))
+		nameOffset := int(int32(order.Uint32(data[offset+4 : offset+8])))
+		vectorLength := int(int32(order.Uint32(data[offset+8 : offset+12])))
+		dataType := data[offset+12]
+		units := Units(data[offset+14])
+		dataOffset := int(int32(order.Uint32(data[offset+16 : offset+20])))
+		if entryLength <= 0 || offset+entryLength > len(data) {
+			return nil, fmt.Errorf("hsperfdata entry %v has an invalid length %v", i, entryLength)
+		}
+		entry := data[offset : offset+entryLength]
+		if nameOffset >= len(entry) || dataOffset > len(entry) {
+			return nil, fmt.Errorf("hsperfdata entry %v has invalid offsets", i)
+		}
+		name := cString(entry[nameOffset:])
+		c := Counter{Units: units}
+		switch {
+		case vectorLength == 0 && dataType == 'J' && dataOffset+8 <= len(entry):
+			c.Value = int64(order.Uint64(entry[dataOffset : dataOffset+8]))
+		case vectorLength > 0 && dataType == 'B' && dataOffset+vectorLength <= len(entry):
+			c.Text = cString(entry[dataOffset : dataOffset+vectorLength])
+		}
+		p.Counters[name] = c
+		offset += entryLength
+	}
+	return p, nil
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// ReadPerfData reads and parses an hsperfdata file
+func ReadPerfData(loc string) (*PerfData, error) {
+	data, err := os.ReadFile(filepath.Clean(loc))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %v: %w", loc, err)
+	}
+	p, err := ParsePerfData(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %v: %w", loc, err)
+	}
+	return p, nil
+}
+
+// Long returns the value of a numeric counter, 0 when it does not exist
+func (p *PerfData) Long(name string) int64 {
+	return p.Counters[name].Value
+}
+
+// String returns the value of a string counter
+func (p *PerfData) String(name string) string {
+	return p.Counters[name].Text
+}
+
+// Millis converts a counter in ticks to milliseconds with the frequency of the high resolution timer
+func (p *PerfData) Millis(name string) float64 {
+	freq := p.Long("sun.os.hrt.frequency")
+	if freq <= 0 {
+		return 0
+	}
+	return float64(p.Long(name)) * 1000 / float64(freq)
+}
+
+// MainClass is the main class or jar of the jvm, the first word of sun.rt.javaCommand
+func (p *PerfData) MainClass() string {
+	main, _, _ := strings.Cut(p.String("sun.rt.javaCommand"), " ")
+	return main
+}
+
+// Args are the program arguments, the rest of sun.rt.javaCommand
+func (p *PerfData) Args() string {
+	_, args, _ := strings.Cut(p.String("sun.rt.javaCommand"), " ")
+	return args
+}
+
+// JVMArgs are the arguments of the jvm as printed by jps -v
+func (p *PerfData) JVMArgs() string {
+	return p.String("java.rt.vmArgs")
+}
+
+// JVM is a jvm found by its hsperfdata file
+type JVM struct {
+	PID       int    `json:"pid"`
+	User      string `json:"user"`
+	MainClass string `json:"mainClass"`
+	Args      string `json:"args,omitempty"`
+	JVMArgs   string `json:"jvmArgs,omitempty"`
+	PerfData  string `json:"perfData"`
+}
+
+// DiscoverJVMs lists the running jvms that publish hsperfdata in tmpDir, stale files of jvms that are gone are skipped
+func DiscoverJVMs(tmpDir string) ([]JVM, error) {
+	matches, err := filepath.Glob(filepath.Join(tmpDir, "hsperfdata_*", "*"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to list hsperfdata in %v: %w", tmpDir, err)
+	}
+	var jvms []JVM
+	for _, loc := range matches {
+		pid, err := strconv.Atoi(filepath.Base(loc))
+		if err != nil || !processAlive(pid) {
+			continue
+		}
+		p, err := ReadPerfData(loc)
+		if err != nil {
+			continue
+		}
+		jvms = append(jvms, JVM{
+			PID:       pid,
+			User:      strings.TrimPrefix(filepath.Base(filepath.Dir(loc)), "hsperfdata_"),
+			MainClass: p.MainClass(),
+			Args:      p.Args(),
+			JVMArgs:   p.JVMArgs(),
+			PerfData:  loc,
+		})
+	}
+	sort.Slice(jvms, func(i, j int) bool { return jvms[i].PID < jvms[j].PID })
+	return jvms, nil
+}
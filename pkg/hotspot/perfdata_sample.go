@@ -0,0 +1,155 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hotspot
+
+import (
+	"fmt"
+	"time"
+)
+
+// PerfSample is the gc, heap, safepoint, class loading and thread counters of one read of the hsperfdata file
+type PerfSample struct {
+	Time          time.Time    `json:"time"`
+	UptimeSeconds float64      `json:"uptimeSeconds"`
+	GCCause       string       `json:"gcCause,omitempty"`
+	LastGCCause   string       `json:"lastGcCause,omitempty"`
+	Collectors    []Collector  `json:"collectors,omitempty"`
+	Generations   []Generation `json:"generations,omitempty"`
+	Metaspace     *MemoryPool  `json:"metaspace,omitempty"`
+	ClassSpace    *MemoryPool  `json:"compressedClassSpace,omitempty"`
+	Safepoints    Safepoints   `json:"safepoints"`
+	Classes       Classes      `json:"classes"`
+	Threads       Threads      `json:"threads"`
+}
+
+// Collector is a garbage collector such as the young and the old generation collector
+type Collector struct {
+	Name        string  `json:"name"`
+	Invocations int64   `json:"invocations"`
+	TimeMs      float64 `json:"timeMs"`
+}
+
+// Generation is a heap generation and its spaces
+type Generation struct {
+	Name             string       `json:"name"`
+	CapacityBytes    int64        `json:"capacityBytes"`
+	MaxCapacityBytes int64        `json:"maxCapacityBytes"`
+	UsedBytes        int64        `json:"usedBytes"`
+	Spaces           []MemoryPool `json:"spaces,omitempty"`
+}
+
+// MemoryPool is a heap space, the metaspace or the compressed class space
+type MemoryPool struct {
+	Name             string `json:"name,omitempty"`
+	UsedBytes        int64  `json:"usedBytes"`
+	CapacityBytes    int64  `json:"capacityBytes"`
+	MaxCapacityBytes int64  `json:"maxCapacityBytes"`
+}
+
+// Safepoints are the stop the world pauses of the jvm
+type Safepoints struct {
+	Count      int64   `json:"count"`
+	TimeMs     float64 `json:"timeMs"`
+	SyncTimeMs float64 `json:"syncTimeMs"`
+}
+
+// Classes is the class loading activity
+type Classes struct {
+	Loaded     int64   `json:"loaded"`
+	Unloaded   int64   `json:"unloaded"`
+	LoadTimeMs float64 `json:"loadTimeMs"`
+}
+
+// Threads are the java thread counts
+type Threads struct {
+	Live    int64 `json:"live"`
+	Daemon  int64 `json:"daemon"`
+	Peak    int64 `json:"peak"`
+	Started int64 `json:"started"`
+}
+
+// Sample extracts the counters tracked over time
+func (p *PerfData) Sample(now time.Time) PerfSample {
+	s := PerfSample{
+		Time:        now,
+		GCCause:     p.String("sun.gc.cause"),
+		LastGCCause: p.String("sun.gc.lastCause"),
+		Safepoints: Safepoints{
+			Count:      p.Long("sun.rt.safepoints"),
+			TimeMs:     p.Millis("sun.rt.safepointTime"),
+			SyncTimeMs: p.Millis("sun.rt.safepointSyncTime"),
+		},
+		Classes: Classes{
+			Loaded:     p.Long("java.cls.loadedClasses"),
+			Unloaded:   p.Long("java.cls.unloadedClasses"),
+			LoadTimeMs: p.Millis("sun.cls.time"),
+		},
+		Threads: Threads{
+			Live:    p.Long("java.threads.live"),
+			Daemon:  p.Long("java.threads.daemon"),
+			Peak:    p.Long("java.threads.livePeak"),
+			Started: p.Long("java.threads.started"),
+		},
+	}
+	s.UptimeSeconds = p.Millis("sun.os.hrt.ticks") / 1000
+	for i := 0; ; i++ {
+		prefix := fmt.Sprintf("sun.gc.collector.%d.", i)
+		if _, ok := p.Counters[prefix+"name"]; !ok {
+			break
+		}
+		s.Collectors = append(s.Collectors, Collector{
+			Name:        p.String(prefix + "name"),
+			Invocations: p.Long(prefix + "invocations"),
+			TimeMs:      p.Millis(prefix + "time"),
+		})
+	}
+	for i := 0; ; i++ {
+		prefix := fmt.Sprintf("sun.gc.generation.%d.", i)
+		if _, ok := p.Counters[prefix+"name"]; !ok {
+			break
+		}
+		g := Generation{
+			Name:             p.String(prefix + "name"),
+			CapacityBytes:    p.Long(prefix + "capacity"),
+			MaxCapacityBytes: p.Long(prefix + "maxCapacity"),
+		}
+		for j := int64(0); j < p.Long(prefix+"spaces"); j++ {
+			space := fmt.Sprintf("%vspace.%d.", prefix, j)
+			pool := MemoryPool{
+				Name:             p.String(space + "name"),
+				UsedBytes:        p.Long(space + "used"),
+				CapacityBytes:    p.Long(space + "capacity"),
+				MaxCapacityBytes: p.Long(space + "maxCapacity"),
+			}
+			g.UsedBytes += pool.UsedBytes
+			g.Spaces = append(g.Spaces, pool)
+		}
+		s.Generations = append(s.Generations, g)
+	}
+	s.Metaspace = p.pool("sun.gc.metaspace.")
+	s.ClassSpace = p.pool("sun.gc.compressedclassspace.")
+	return s
+}
+
+func (p *PerfData) pool(prefix string) *MemoryPool {
+	if _, ok := p.Counters[prefix+"used"]; !ok {
+		return nil
+	}
+	return &MemoryPool{
+		UsedBytes:        p.Long(prefix + "used"),
+		CapacityBytes:    p.Long(prefix + "capacity"),
+		MaxCapacityBytes: p.Long(prefix + "maxCapacity"),
+	}
+}
@@ -0,0 +1,179 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hotspot_test
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/hotspot"
+)
+
+type testCounter struct {
+	name  string
+	value int64
+	text  string
+	units hotspot.Units
+}
+
+// encodePerfData writes counters in the hsperfdata v2 layout the jvm uses
+func encodePerfData(order binary.ByteOrder, counters []testCounter) []byte {
+	var entries []byte
+	for _, c := range counters {
+		const header = 20
+		name := append([]byte(c.name), 0)
+		nameOffset := header
+		dataOffset := nameOffset + len(name)
+		// longs are 8 byte aligned
+		for dataOffset%8 != 0 {
+			name = append(name, 0)
+			dataOffset++
+		}
+		var data []byte
+		dataType, vectorLength := byte('J'), 0
+		if c.units == hotspot.UnitsString {
+			dataType = 'B'
+			data = append([]byte(c.text), 0)
+			vectorLength = len(data)
+		} else {
+			data = make([]byte, 8)
+			order.PutUint64(data, uint64(c.value))
+		}
+		entryLength := dataOffset + len(data)
+		for entryLength%8 != 0 {
+			data = append(data, 0)
+			entryLength++
+		}
+		e := make([]byte, header)
+		order.PutUint32(e[0:4], uint32(entryLength))
+		order.PutUint32(e[4:8], uint32(nameOffset))
+		order.PutUint32(e[8:12], uint32(vectorLength))
+		e[12] = dataType
+		e[14] = byte(c.units)
+		order.PutUint32(e[16:20], uint32(dataOffset))
+		e = append(e, name...)
+		e = append(e, data...)
+		entries = append(entries, e...)
+	}
+	prologue := make([]byte, 32)
+	binary.BigEndian.PutUint32(prologue[0:4], 0xcafec0c0)
+	if order == binary.LittleEndian {
+		prologue[4] = 1
+	}
+	prologue[5] = 2
+	order.PutUint32(prologue[8:12], uint32(32+len(entries)))
+	order.PutUint32(prologue[24:28], 32)
+	order.PutUint32(prologue[28:32], uint32(len(counters)))
+	return append(prologue, entries...)
+}
+
+var g1Counters = []testCounter{
+	{name: "sun.os.hrt.frequency", value: 1000000000, units: hotspot.UnitsHertz},
+	{name: "sun.os.hrt.ticks", value: 90 * 1000000000, units: hotspot.UnitsTicks},
+	{name: "sun.rt.javaCommand", text: "com.dremio.dac.daemon.DremioDaemon start", units: hotspot.UnitsString},
+	{name: "java.rt.vmArgs", text: "-Xmx4g -XX:+UseG1GC", units: hotspot.UnitsString},
+	{name: "sun.gc.cause", text: "No GC", units: hotspot.UnitsString},
+	{name: "sun.gc.lastCause", text: "G1 Evacuation Pause", units: hotspot.UnitsString},
+	{name: "sun.gc.collector.0.name", text: "G1 incremental collections", units: hotspot.UnitsString},
+	{name: "sun.gc.collector.0.invocations", value: 42, units: hotspot.UnitsEvents},
+	{name: "sun.gc.collector.0.time", value: 1500000000, units: hotspot.UnitsTicks},
+	{name: "sun.gc.collector.1.name", text: "G1 stop-the-world full collections", units: hotspot.UnitsString},
+	{name: "sun.gc.collector.1.invocations", value: 1, units: hotspot.UnitsEvents},
+	{name: "sun.gc.collector.1.time", value: 250000000, units: hotspot.UnitsTicks},
+	{name: "sun.gc.generation.0.name", text: "young", units: hotspot.UnitsString},
+	{name: "sun.gc.generation.0.capacity", value: 1024, units: hotspot.UnitsBytes},
+	{name: "sun.gc.generation.0.maxCapacity", value: 4096, units: hotspot.UnitsBytes},
+	{name: "sun.gc.generation.0.spaces", value: 2, units: hotspot.UnitsNone},
+	{name: "sun.gc.generation.0.space.0.name", text: "eden", units: hotspot.UnitsString},
+	{name: "sun.gc.generation.0.space.0.used", value: 300, units: hotspot.UnitsBytes},
+	{name: "sun.gc.generation.0.space.1.name", text: "s0", units: hotspot.UnitsString},
+	{name: "sun.gc.generation.0.space.1.used", value: 20, units: hotspot.UnitsBytes},
+	{name: "sun.gc.generation.1.name", text: "old", units: hotspot.UnitsString},
+	{name: "sun.gc.generation.1.spaces", value: 1, units: hotspot.UnitsNone},
+	{name: "sun.gc.generation.1.space.0.used", value: 2000, units: hotspot.UnitsBytes},
+	{name: "sun.gc.metaspace.used", value: 128, units: hotspot.UnitsBytes},
+	{name: "sun.rt.safepoints", value: 77, units: hotspot.UnitsEvents},
+	{name: "sun.rt.safepointTime", value: 3000000, units: hotspot.UnitsTicks},
+	{name: "java.cls.loadedClasses", value: 25000, units: hotspot.UnitsEvents},
+	{name: "java.threads.live", value: 310, units: hotspot.UnitsNone},
+	{name: "java.threads.livePeak", value: 350, units: hotspot.UnitsNone},
+}
+
+func TestParsePerfDataAndSample(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		p, err := hotspot.ParsePerfData(encodePerfData(order, g1Counters))
+		if err != nil {
+			t.Fatalf("%v: %v", order, err)
+		}
+		if p.MainClass() != "com.dremio.dac.daemon.DremioDaemon" || p.Args() != "start" || p.JVMArgs() != "-Xmx4g -XX:+UseG1GC" {
+			t.Errorf("%v: unexpected command %q %q %q", order, p.MainClass(), p.Args(), p.JVMArgs())
+		}
+		s := p.Sample(time.Unix(0, 0))
+		if s.UptimeSeconds != 90 {
+			t.Errorf("%v: expected 90 seconds of uptime but got %v", order, s.UptimeSeconds)
+		}
+		if len(s.Collectors) != 2 || s.Collectors[0].Invocations != 42 || s.Collectors[0].TimeMs != 1500 || s.Collectors[1].TimeMs != 250 {
+			t.Errorf("%v: unexpected collectors %+v", order, s.Collectors)
+		}
+		if len(s.Generations) != 2 || s.Generations[0].UsedBytes != 320 || s.Generations[0].MaxCapacityBytes != 4096 || s.Generations[1].UsedBytes != 2000 {
+			t.Errorf("%v: unexpected generations %+v", order, s.Generations)
+		}
+		if s.Metaspace == nil || s.Metaspace.UsedBytes != 128 || s.ClassSpace != nil {
+			t.Errorf("%v: unexpected metaspace %+v %+v", order, s.Metaspace, s.ClassSpace)
+		}
+		if s.Safepoints.Count != 77 || s.Safepoints.TimeMs != 3 || s.Classes.Loaded != 25000 || s.Threads.Live != 310 || s.Threads.Peak != 350 {
+			t.Errorf("%v: unexpected counters %+v %+v %+v", order, s.Safepoints, s.Classes, s.Threads)
+		}
+		if s.LastGCCause != "G1 Evacuation Pause" {
+			t.Errorf("%v: unexpected gc cause %v", order, s.LastGCCause)
+		}
+	}
+}
+
+func TestParsePerfDataRejectsOtherFiles(t *testing.T) {
+	if _, err := hotspot.ParsePerfData(make([]byte, 64)); err == nil {
+		t.Error("expected an error for a file without the magic")
+	}
+	data := encodePerfData(binary.LittleEndian, g1Counters)
+	if _, err := hotspot.ParsePerfData(data[:len(data)-10]); err == nil {
+		t.Error("expected an error for a truncated file")
+	}
+}
+
+func TestDiscoverJVMs(t *testing.T) {
+	tmp := t.TempDir()
+	dir := filepath.Join(tmp, "hsperfdata_dremio")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	// the test process stands in for a running jvm, the pid of a stale file cannot be alive
+	if err := os.WriteFile(filepath.Join(dir, strconv.Itoa(os.Getpid())), encodePerfData(binary.LittleEndian, g1Counters), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "not-a-pid"), []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	jvms, err := hotspot.DiscoverJVMs(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jvms) != 1 || jvms[0].PID != os.Getpid() || jvms[0].User != "dremio" || jvms[0].MainClass != "com.dremio.dac.daemon.DremioDaemon" {
+		t.Errorf("unexpected jvms %+v", jvms)
+	}
+}
@@ -0,0 +1,63 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hotspot
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWriteRequest(t *testing.T) {
+	var b bytes.Buffer
+	if err := writeRequest(&b, "jcmd", "Thread.print -l"); err != nil {
+		t.Fatal(err)
+	}
+	expected := "1\x00jcmd\x00Thread.print -l\x00\x00\x00"
+	if b.String() != expected {
+		t.Errorf("expected %q but got %q", expected, b.String())
+	}
+	if err := writeRequest(&b, "op", "1", "2", "3", "4"); err == nil {
+		t.Error("expected an error for too many arguments")
+	}
+}
+
+func TestReadResponse(t *testing.T) {
+	var out bytes.Buffer
+	if err := readResponse(strings.NewReader("0\nFull thread dump\n\"main\" #1\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "Full thread dump\n\"main\" #1\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	err := readResponse(strings.NewReader("101\nProtocol mismatch\n"), &out)
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Code != 101 || cmdErr.Output != "Protocol mismatch\n" {
+		t.Errorf("expected a command error but got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected no output for a failure but got %q", out.String())
+	}
+
+	if err := readResponse(strings.NewReader(""), &out); err == nil || errors.As(err, &cmdErr) {
+		t.Errorf("expected a connection error for an empty response but got %v", err)
+	}
+	if err := readResponse(strings.NewReader("garbage\n"), &out); err == nil {
+		t.Error("expected an error for an invalid code")
+	}
+}
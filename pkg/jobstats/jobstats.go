@@ -0,0 +1,253 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// package jobstats records the result of every local-collect job, it is written by local-collect and read by the ddc command to build the summary.json
+package jobstats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileName is the name of the file written inside of each node tarball
+const FileName = "job-stats.json"
+
+const (
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped"
+	// StatusIncomplete is used when the collection was stopped, for example by the deadline, before the job finished
+	StatusIncomplete = "incomplete"
+)
+
+// JobResult is the outcome of a single local-collect job
+type JobResult struct {
+	Name           string    `json:"name"`
+	Status         string    `json:"status"`
+	StartTimeUTC   time.Time `json:"startTimeUTC"`
+	EndTimeUTC     time.Time `json:"endTimeUTC"`
+	DurationMillis int64     `json:"durationMillis"`
+	BytesWritten   int64     `json:"bytesWritten"`
+	FilesProduced  int       `json:"filesProduced"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// TruncatedFile is a log that was cut short or left out to stay within the log budgets
+type TruncatedFile struct {
+	LogType       string `json:"logType"`
+	File          string `json:"file"`
+	OriginalBytes int64  `json:"originalBytes"`
+	KeptBytes     int64  `json:"keptBytes"`
+	DroppedBytes  int64  `json:"droppedBytes"`
+	// Budget is the limit that was reached
+	Budget string `json:"budget"`
+}
+
+// NodeJobStats is the full list of job results for a node
+type NodeJobStats struct {
+	NodeName       string          `json:"nodeName"`
+	Jobs           []JobResult     `json:"jobs"`
+	TruncatedFiles []TruncatedFile `json:"truncatedFiles,omitempty"`
+}
+
+// Recorder tracks the job results for a local-collect run. Bytes and files are found
+// by comparing the outputs a job declares before and after it runs, only the outputs
+// are walked so jobs running at the same time are not credited with each other's files.
+type Recorder struct {
+	mu        sync.Mutex
+	ctx       context.Context
+	jobs      []JobResult
+	truncated []TruncatedFile
+	now       func() time.Time
+}
+
+// NewRecorder uses the context to tell jobs that were stopped early apart from jobs that failed
+func NewRecorder(ctx context.Context) *Recorder {
+	return NewRecorderWithTimeService(ctx, time.Now)
+}
+
+func NewRecorderWithTimeService(ctx context.Context, now func() time.Time) *Recorder {
+	return &Recorder{
+		ctx: ctx,
+		now: now,
+	}
+}
+
+// TrackOutputs wraps the job so that its result is recorded when it is executed. Only the
+// files under the given outputs are counted, an output is either a directory, in which case
+// every file under it counts, or a file glob. A job without outputs fails without running.
+func (r *Recorder) TrackOutputs(name string, outputs []string, process func() error) func() error {
+	return func() error {
+		if len(outputs) == 0 {
+			now := r.now().UTC()
+			err := fmt.Errorf("job %v declares no outputs", name)
+			r.add(JobResult{
+				Name:         name,
+				Status:       StatusFailed,
+				StartTimeUTC: now,
+				EndTimeUTC:   now,
+				Error:        err.Error(),
+			})
+			return err
+		}
+		if err := r.ctx.Err(); err != nil {
+			now := r.now().UTC()
+			r.add(JobResult{
+				Name:         name,
+				Status:       StatusIncomplete,
+				StartTimeUTC: now,
+				EndTimeUTC:   now,
+				Error:        fmt.Sprintf("not started: %v", context.Cause(r.ctx)),
+			})
+			return fmt.Errorf("job %v not started: %w", name, err)
+		}
+		before := snapshot(outputs)
+		start := r.now().UTC()
+		err := process()
+		end := r.now().UTC()
+		bytesWritten, filesProduced := diff(before, snapshot(outputs))
+		result := JobResult{
+			Name:           name,
+			Status:         StatusCompleted,
+			StartTimeUTC:   start,
+			EndTimeUTC:     end,
+			DurationMillis: end.Sub(start).Milliseconds(),
+			BytesWritten:   bytesWritten,
+			FilesProduced:  filesProduced,
+		}
+		if err != nil {
+			result.Status = StatusFailed
+			if r.ctx.Err() != nil || errors.Is(err, context.DeadlineExceeded) {
+				result.Status = StatusIncomplete
+			}
+			result.Error = err.Error()
+		}
+		r.add(result)
+		return err
+	}
+}
+
+// Skip records a job that was not run
+func (r *Recorder) Skip(name, reason string) {
+	now := r.now().UTC()
+	r.add(JobResult{
+		Name:         name,
+		Status:       StatusSkipped,
+		StartTimeUTC: now,
+		EndTimeUTC:   now,
+		Error:        reason,
+	})
+}
+
+func (r *Recorder) add(result JobResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs = append(r.jobs, result)
+}
+
+// AddTruncated records logs that were cut short by the log budgets
+func (r *Recorder) AddTruncated(files ...TruncatedFile) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.truncated = append(r.truncated, files...)
+}
+
+// Results returns a copy of all the results recorded so far
+func (r *Recorder) Results() []JobResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	results := make([]JobResult, len(r.jobs))
+	copy(results, r.jobs)
+	return results
+}
+
+// WriteFile writes the recorded results as json to the destination file
+func (r *Recorder) WriteFile(nodeName, dest string) error {
+	r.mu.Lock()
+	truncated := make([]TruncatedFile, len(r.truncated))
+	copy(truncated, r.truncated)
+	r.mu.Unlock()
+	stats := NodeJobStats{
+		NodeName:       nodeName,
+		Jobs:           r.Results(),
+		TruncatedFiles: truncated,
+	}
+	b, err := json.MarshalIndent(stats, "", "\t")
+	if err != nil {
+		return fmt.Errorf("unable to marshal job stats: %w", err)
+	}
+	if err := os.WriteFile(filepath.Clean(dest), b, 0o600); err != nil {
+		return fmt.Errorf("unable to write job stats %v: %w", dest, err)
+	}
+	return nil
+}
+
+// ReadFile reads a job stats file written by WriteFile
+func ReadFile(loc string) (NodeJobStats, error) {
+	var stats NodeJobStats
+	b, err := os.ReadFile(filepath.Clean(loc))
+	if err != nil {
+		return stats, err
+	}
+	if err := json.Unmarshal(b, &stats); err != nil {
+		return stats, fmt.Errorf("unable to parse job stats %v: %w", loc, err)
+	}
+	return stats, nil
+}
+
+// snapshot has the size of every file under the outputs, globs are expanded first
+func snapshot(outputs []string) map[string]int64 {
+	files := make(map[string]int64)
+	for _, o := range outputs {
+		matches, err := filepath.Glob(o)
+		if err != nil {
+			continue
+		}
+		for _, m := range matches {
+			// missing directories and unreadable files are just not counted
+			_ = filepath.Walk(m, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return nil
+				}
+				if info.Mode().IsRegular() {
+					files[path] = info.Size()
+				}
+				return nil
+			})
+		}
+	}
+	return files
+}
+
+// diff counts the new files and the bytes added between two snapshots
+func diff(before, after map[string]int64) (bytesWritten int64, filesProduced int) {
+	for path, size := range after {
+		oldSize, ok := before[path]
+		if !ok {
+			filesProduced++
+			bytesWritten += size
+			continue
+		}
+		if size > oldSize {
+			bytesWritten += size - oldSize
+		}
+	}
+	return bytesWritten, filesProduced
+}
@@ -0,0 +1,186 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobstats_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/jobstats"
+)
+
+func TestRecorderTracksFilesAndBytes(t *testing.T) {
+	outDir := t.TempDir()
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	r := jobstats.NewRecorderWithTimeService(context.Background(), func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	})
+	job := r.TrackOutputs("LOG COLLECTION", []string{outDir}, func() error {
+		if err := os.WriteFile(filepath.Join(outDir, "a.log"), []byte("12345"), 0o600); err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(outDir, "b.log"), []byte("123"), 0o600)
+	})
+	if err := job(); err != nil {
+		t.Fatal(err)
+	}
+	results := r.Results()
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result but got %v", len(results))
+	}
+	result := results[0]
+	if result.Status != jobstats.StatusCompleted {
+		t.Errorf("expected status %v but got %v", jobstats.StatusCompleted, result.Status)
+	}
+	if result.FilesProduced != 2 {
+		t.Errorf("expected 2 files but got %v", result.FilesProduced)
+	}
+	if result.BytesWritten != 8 {
+		t.Errorf("expected 8 bytes but got %v", result.BytesWritten)
+	}
+	if result.DurationMillis != 1000 {
+		t.Errorf("expected 1000 millis but got %v", result.DurationMillis)
+	}
+}
+
+func TestRecorderFailedAndSkipped(t *testing.T) {
+	outDir := t.TempDir()
+	r := jobstats.NewRecorder(context.Background())
+	expectedErr := errors.New("jcmd not found")
+	if err := r.TrackOutputs("JFR COLLECTION", []string{outDir}, func() error { return expectedErr })(); !errors.Is(err, expectedErr) {
+		t.Errorf("expected error %v but got %v", expectedErr, err)
+	}
+	r.Skip("WLM COLLECTION", "disabled")
+
+	dest := filepath.Join(outDir, jobstats.FileName)
+	if err := r.WriteFile("node1", dest); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := jobstats.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.NodeName != "node1" {
+		t.Errorf("expected node1 but got %v", stats.NodeName)
+	}
+	if len(stats.Jobs) != 2 {
+		t.Fatalf("expected 2 jobs but got %v", len(stats.Jobs))
+	}
+	if stats.Jobs[0].Status != jobstats.StatusFailed || stats.Jobs[0].Error != expectedErr.Error() {
+		t.Errorf("unexpected failed job %#v", stats.Jobs[0])
+	}
+	if stats.Jobs[1].Status != jobstats.StatusSkipped || stats.Jobs[1].Error != "disabled" {
+		t.Errorf("unexpected skipped job %#v", stats.Jobs[1])
+	}
+}
+
+func TestRecorderMarksJobsIncompleteWhenCancelled(t *testing.T) {
+	outDir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	r := jobstats.NewRecorder(ctx)
+	if err := r.TrackOutputs("JSTACK COLLECTION", []string{outDir}, func() error {
+		cancel()
+		return ctx.Err()
+	})(); err == nil {
+		t.Error("expected an error from the cancelled job")
+	}
+	var ran bool
+	if err := r.TrackOutputs("JFR COLLECTION", []string{outDir}, func() error {
+		ran = true
+		return nil
+	})(); err == nil {
+		t.Error("expected an error for the job that did not start")
+	}
+	if ran {
+		t.Error("expected job to not run after the context was cancelled")
+	}
+	for _, result := range r.Results() {
+		if result.Status != jobstats.StatusIncomplete {
+			t.Errorf("expected %v to be incomplete but was %v", result.Name, result.Status)
+		}
+	}
+}
+
+func TestRecorderOnlyCountsOutputsOfConcurrentJobs(t *testing.T) {
+	outDir := t.TempDir()
+	logsDir := filepath.Join(outDir, "logs")
+	if err := os.MkdirAll(filepath.Join(outDir, "jfr", "thread-dumps"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(logsDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	r := jobstats.NewRecorder(context.Background())
+	serverStarted := make(chan struct{})
+	gcWritten := make(chan struct{})
+	serverLog := r.TrackOutputs("SERVER LOG COLLECTION", []string{filepath.Join(logsDir, "server.log*")}, func() error {
+		close(serverStarted)
+		// the gc job writes while this job is still running
+		<-gcWritten
+		return os.WriteFile(filepath.Join(logsDir, "server.log.gz"), []byte("1234"), 0o600)
+	})
+	gcLog := r.TrackOutputs("GC LOG COLLECTION", []string{filepath.Join(logsDir, "server*.gc*")}, func() error {
+		<-serverStarted
+		defer close(gcWritten)
+		return os.WriteFile(filepath.Join(logsDir, "server.gc.0"), []byte("123456"), 0o600)
+	})
+	jstack := r.TrackOutputs("JSTACK COLLECTION", []string{filepath.Join(outDir, "jfr", "thread-dumps")}, func() error {
+		return os.WriteFile(filepath.Join(outDir, "jfr", "thread-dumps", "threadDump-1.txt"), []byte("12"), 0o600)
+	})
+	errs := make(chan error, 2)
+	go func() { errs <- serverLog() }()
+	go func() { errs <- gcLog() }()
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := jstack(); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]int64{
+		"SERVER LOG COLLECTION": 4,
+		"GC LOG COLLECTION":     6,
+		"JSTACK COLLECTION":     2,
+	}
+	for _, result := range r.Results() {
+		if result.FilesProduced != 1 || result.BytesWritten != expected[result.Name] {
+			t.Errorf("expected %v to produce 1 file of %v bytes but got %v files of %v bytes", result.Name, expected[result.Name], result.FilesProduced, result.BytesWritten)
+		}
+	}
+}
+
+func TestRecorderFailsJobsWithoutOutputs(t *testing.T) {
+	r := jobstats.NewRecorder(context.Background())
+	var ran bool
+	if err := r.TrackOutputs("LOG COLLECTION", nil, func() error {
+		ran = true
+		return nil
+	})(); err == nil {
+		t.Error("expected an error for a job without outputs")
+	}
+	if ran {
+		t.Error("expected job without outputs to not run")
+	}
+	results := r.Results()
+	if len(results) != 1 || results[0].Status != jobstats.StatusFailed {
+		t.Errorf("expected a failed result but got %#v", results)
+	}
+}
@@ -18,14 +18,62 @@ package jps
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"strings"
 
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/ddcio"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/hotspot"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
 )
 
+// CaptureFlagsFromPID returns the jvm arguments of pid, read through the attach api,
+// from the hsperfdata of the jvm when attaching fails and with jps -v as the last resort
 func CaptureFlagsFromPID(hook shutdown.CancelHook, pid int) (string, error) {
+	flags, err := captureFlagsWithAttach(hook.GetContext(), pid)
+	if err == nil {
+		return flags, nil
+	}
+	simplelog.Warningf("unable to read the jvm flags of pid %v through the attach api, falling back to hsperfdata: %v", pid, err)
+	flags, err = captureFlagsWithPerfData(pid)
+	if err == nil {
+		return flags, nil
+	}
+	simplelog.Warningf("unable to read the jvm flags of pid %v from hsperfdata, falling back to jps: %v", pid, err)
+	return captureFlagsWithJps(hook, pid)
+}
+
+func captureFlagsWithPerfData(pid int) (string, error) {
+	loc, err := hotspot.FindPerfData(pid)
+	if err != nil {
+		return "", err
+	}
+	p, err := hotspot.ReadPerfData(loc)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(p.JVMArgs()) == "" {
+		return "", fmt.Errorf("no jvm arguments in %v", loc)
+	}
+	return strings.TrimSpace(p.JVMArgs()), nil
+}
+
+func captureFlagsWithAttach(ctx context.Context, pid int) (string, error) {
+	var buf bytes.Buffer
+	if err := hotspot.Attach(ctx, pid, &buf, "jcmd", "VM.command_line"); err != nil {
+		return "", err
+	}
+	// the same arguments jps -v prints are on the jvm_args line
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if flags, ok := strings.CutPrefix(line, "jvm_args:"); ok && strings.TrimSpace(flags) != "" {
+			return strings.TrimSpace(flags), nil
+		}
+	}
+	return "", fmt.Errorf("no jvm_args in the VM.command_line output of pid %v: '%v'", pid, buf.String())
+}
+
+func captureFlagsWithJps(hook shutdown.CancelHook, pid int) (string, error) {
 	var buf bytes.Buffer
 	if err := ddcio.Shell(hook, &buf, "jps -v"); err != nil {
 		return "", fmt.Errorf("failed getting flags: '%w', output was: '%v'", err, buf.String())
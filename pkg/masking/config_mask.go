@@ -16,7 +16,6 @@
 package masking
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"path"
@@ -24,6 +23,7 @@ import (
 	"strings"
 
 	"github.com/dremio/dremio-diagnostic-collector/v3/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/hocon"
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/simplelog"
 )
 
@@ -74,6 +74,29 @@ func maskConfigSecret(line string) string {
 	return line
 }
 
+// assignmentSecret is the value of a key=value line such as a properties file or a shell script
+var assignmentSecret = regexp.MustCompile(`=\s*([^\s,;]+)`)
+
+// MaskSecretLine masks the value of a line that looks like it contains a secret, both key: value and
+// key=value lines are handled and every other line is returned unchanged
+func MaskSecretLine(line string) string {
+	if !checkStringForSecret(line) {
+		return line
+	}
+	return maskLineValue(line)
+}
+
+// maskLineValue masks the value of a key: value or key=value line
+func maskLineValue(line string) string {
+	if masked := maskConfigSecret(line); masked != line {
+		return masked
+	}
+	if m := assignmentSecret.FindStringSubmatchIndex(line); m != nil {
+		return line[:m[2]] + "\"<REMOVED_POTENTIAL_SECRET>\"" + line[m[3]:]
+	}
+	return line
+}
+
 func MaskPAT(line string) string {
 	regexPattern := `--` + conf.KeyDremioPatToken + ` [^ ]+`
 	regexPattern2 := `-t [^ ]+`
@@ -96,47 +119,61 @@ func MaskPAT(line string) string {
 	return line
 }
 
-// RemoveSecretsFromDremioConf takes a configuration file as an input and masks any potential secrets.
-// It returns an error if it encounters any issue during the process.
-func RemoveSecretsFromDremioConf(configFile string) error {
-	// Check if the input file is a Dremio configuration file
-	if strings.HasSuffix(configFile, "dremio.conf") {
-		simplelog.Debugf("... Removing potential secrets from %s\n", configFile)
-
-		// Open the file. Clean the configFile path before opening to remove any relative or redundant path elements.
-		// If there is an issue opening the file, an error is returned.
-		file, err := os.Open(path.Clean(configFile))
-		if err != nil {
-			return fmt.Errorf("unable to open file %v: %w", configFile, err)
-		}
-		scanner := bufio.NewScanner(file)
-
-		// This slice will hold all the lines from the file, after potentially modifying them
-		cleansedData := []string{}
-
-		for scanner.Scan() {
-			line := scanner.Text()
-			// If the line contains a potential secret, mask the secret
-			if checkStringForSecret(line) {
-				line = maskConfigSecret(line)
-			}
-			cleansedData = append(cleansedData, line)
+// MaskHOCON masks the values of the fields of a HOCON file such as dremio.conf whose key path looks like a secret,
+// nested objects, quoted keys and multiline values are handled. A value that is only substitutions such as
+// ${?KEYSTORE_PASSWORD} is kept as it names where the secret comes from
+func MaskHOCON(text string) (string, error) {
+	c, err := hocon.Parse(text, nil)
+	if err != nil {
+		return "", err
+	}
+	var spans [][2]int
+	for _, f := range c.Fields {
+		if f.IsObject || f.OnlySubstitutions || !IsSecretKey(f.Path) {
+			continue
 		}
+		spans = append(spans, [2]int{f.Start, f.End})
+	}
+	// the fields are in source order so replacing from the end keeps the offsets valid
+	for i := len(spans) - 1; i >= 0; i-- {
+		text = text[:spans[i][0]] + removedSecret + text[spans[i][1]:]
+	}
+	return maskSASSignatures(text), nil
+}
 
-		// Close the file and check for any errors. If there is an issue closing the file, an error is returned.
-		if err := file.Close(); err != nil {
-			return fmt.Errorf("unable to close file %v: %w", configFile, err)
+// maskConfLines is the line based masking used when dremio.conf is not valid HOCON
+func maskConfLines(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if checkStringForSecret(line) {
+			lines[i] = maskConfigSecret(line)
 		}
+	}
+	return strings.Join(lines, "\n")
+}
 
-		// Write the contents of cleansedData back into the file.
-		// If there is an issue writing to the file, an error is returned.
-		if err := os.WriteFile(configFile, []byte(strings.Join(cleansedData, "\n")), 0o600); err != nil {
-			return fmt.Errorf("unable to write new file %v: %w", configFile, err)
-		}
-	} else {
-		// If the configFile does not end with "dremio.conf", an error is returned.
+// RemoveSecretsFromDremioConf takes a configuration file as an input and masks any potential secrets.
+// It returns an error if it encounters any issue during the process.
+func RemoveSecretsFromDremioConf(configFile string) error {
+	// Check if the input file is a Dremio configuration file
+	if !strings.HasSuffix(configFile, "dremio.conf") {
 		return fmt.Errorf("expected file with name '%s', got '%s' instead", "dremio.conf", configFile)
 	}
-	// If all steps complete without error, return nil to indicate success
+	simplelog.Debugf("... Removing potential secrets from %s\n", configFile)
+	b, err := os.ReadFile(path.Clean(configFile))
+	if err != nil {
+		return fmt.Errorf("unable to open file %v: %w", configFile, err)
+	}
+	masked, err := MaskHOCON(string(b))
+	if err != nil {
+		simplelog.Warningf("%v is not valid HOCON (%v), masking it line by line", configFile, err)
+		masked = maskConfLines(string(b))
+	}
+	if masked == string(b) {
+		return nil
+	}
+	if err := os.WriteFile(configFile, []byte(masked), 0o600); err != nil {
+		return fmt.Errorf("unable to write new file %v: %w", configFile, err)
+	}
 	return nil
 }
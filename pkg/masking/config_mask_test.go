@@ -113,3 +113,49 @@ func TestPATMask(t *testing.T) {
 		t.Errorf("\nexpected %v\nreturned %v\n", expected, returned)
 	}
 }
+
+func TestMaskSecretLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{`password: "abc"`, `password: "<REMOVED_POTENTIAL_SECRET>"`},
+		{`export AWS_SECRET=abc123`, `export AWS_SECRET="<REMOVED_POTENTIAL_SECRET>"`},
+		{`hostname=dremio-1`, `hostname=dremio-1`},
+	}
+	for _, tt := range tests {
+		if actual := masking.MaskSecretLine(tt.line); actual != tt.expected {
+			t.Errorf("expected %q but got %q", tt.expected, actual)
+		}
+	}
+}
+
+func TestMaskHOCON(t *testing.T) {
+	text := `services.coordinator.web.ssl {
+  keyStorePassword: """multi
+line"""
+  trustStore: "/opt/dremio/ts.jks"
+}
+"javax.net.ssl.keyStorePassword" = plain-secret
+provisioning.yarn.keytab.secret = [ "a", "b" ]
+services.coordinator.web.ssl.trustStorePassword: ${?TRUST_PASSWORD}
+`
+	masked, err := masking.MaskHOCON(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `services.coordinator.web.ssl {
+  keyStorePassword: "<REMOVED_POTENTIAL_SECRET>"
+  trustStore: "/opt/dremio/ts.jks"
+}
+"javax.net.ssl.keyStorePassword" = "<REMOVED_POTENTIAL_SECRET>"
+provisioning.yarn.keytab.secret = "<REMOVED_POTENTIAL_SECRET>"
+services.coordinator.web.ssl.trustStorePassword: ${?TRUST_PASSWORD}
+`
+	if masked != expected {
+		t.Errorf("expected\n%v\nbut was\n%v", expected, masked)
+	}
+	if _, err := masking.MaskHOCON("a: {"); err == nil {
+		t.Error("expected an error for invalid HOCON")
+	}
+}
@@ -0,0 +1,206 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package masking
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const removedSecret = "\"<REMOVED_POTENTIAL_SECRET>\""
+
+// propertyKeywords are more keywords for the keys of xml, properties and jaas files, a key such as
+// fs.azure.account.key.<account> or fs.s3a.access.key is a secret without one of the secretKeywords
+var propertyKeywords = []string{
+	"access.key",
+	"account.key",
+	"private.key",
+	"token",
+}
+
+var (
+	// sasKey matches azure keys such as fs.azure.sas.<container>.<account> but not sasl
+	sasKey = regexp.MustCompile(`(?i)(^|[._-])sas([._-]|token|$)`)
+	// sasSignature is the signature of a SAS token wherever it is found
+	sasSignature = regexp.MustCompile(`(?i)(\bsig=)[^&\s"'<;]+`)
+	xmlProperty  = regexp.MustCompile(`(?s)<property\b[^>]*>.*?</property>`)
+	xmlName      = regexp.MustCompile(`(?s)<name>\s*(.*?)\s*</name>`)
+	xmlValue     = regexp.MustCompile(`(?s)(<value>).*?(</value>)`)
+	// xmlElement is an element with only text inside, such as <password>x</password> or <entry key="password">x</entry>
+	xmlElement = regexp.MustCompile(`<([A-Za-z_][\w.:-]*)(\s[^<>]*)?>([^<]*)</([A-Za-z_][\w.:-]*)\s*>`)
+	// xmlKeyAttr is a key or name attribute naming the text of the element
+	xmlKeyAttr = regexp.MustCompile(`(?:^|\s)(?:key|name)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	// xmlTag is an opening or empty element tag, comments, declarations and closing tags are left out
+	xmlTag  = regexp.MustCompile(`<[A-Za-z_][^<>]*>`)
+	xmlAttr = regexp.MustCompile(`([A-Za-z_][\w.:-]*)(\s*=\s*)("[^"]*"|'[^']*')`)
+	// confKeyLine is the key of a key: value or key=value line, quoted json keys and shell exports included
+	confKeyLine = regexp.MustCompile(`^\s*(?:export\s+)?["']?([A-Za-z0-9_.-]+)["']?\s*[:=]`)
+	// propertyLine splits a java properties line in the key, the separator and the value
+	propertyLine = regexp.MustCompile(`^(\s*)((?:\\.|[^=:\s\\])+)(\s*[=:]\s*|\s+)(.*)$`)
+	jaasOption   = regexp.MustCompile(`([A-Za-z0-9_.-]+)(\s*=\s*)("[^"]*"|[^\s;]+)`)
+)
+
+// IsSecretKey is true when the name of a property looks like it holds a secret
+func IsSecretKey(key string) bool {
+	if checkStringForSecret(key) || sasKey.MatchString(key) {
+		return true
+	}
+	lower := strings.ToLower(key)
+	for _, keyword := range propertyKeywords {
+		if strings.Contains(lower, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func maskSASSignatures(text string) string {
+	return sasSignature.ReplaceAllString(text, "${1}<REMOVED_POTENTIAL_SECRET>")
+}
+
+// escapedRemovedSecret keeps a masked xml file valid
+const escapedRemovedSecret = "&lt;REMOVED_POTENTIAL_SECRET&gt;"
+
+// MaskXMLProperties masks the values of hadoop style <property> entries with a secret name such as
+// core-site.xml or hive-site.xml. For every other xml the text of elements with a secret name or a secret
+// key or name attribute, such as <password>x</password> or <entry key="password">x</entry>, and the values
+// of attributes with a secret name, such as password="x", are masked too.
+func MaskXMLProperties(text string) string {
+	text = xmlProperty.ReplaceAllStringFunc(text, func(property string) string {
+		name := xmlName.FindStringSubmatch(property)
+		if name == nil || !IsSecretKey(name[1]) {
+			return property
+		}
+		return xmlValue.ReplaceAllString(property, "${1}"+escapedRemovedSecret+"${2}")
+	})
+	text = xmlElement.ReplaceAllStringFunc(text, func(element string) string {
+		m := xmlElement.FindStringSubmatch(element)
+		if m[1] != m[4] || strings.TrimSpace(m[3]) == "" || !isSecretXMLElement(m[1], m[2]) {
+			return element
+		}
+		return "<" + m[1] + m[2] + ">" + escapedRemovedSecret + "</" + m[4] + ">"
+	})
+	text = xmlTag.ReplaceAllStringFunc(text, func(tag string) string {
+		return xmlAttr.ReplaceAllStringFunc(tag, func(attr string) string {
+			m := xmlAttr.FindStringSubmatch(attr)
+			if !IsSecretKey(m[1]) {
+				return attr
+			}
+			quote := m[3][:1]
+			return m[1] + m[2] + quote + escapedRemovedSecret + quote
+		})
+	})
+	return sasSignature.ReplaceAllString(text, "${1}"+escapedRemovedSecret)
+}
+
+// isSecretXMLElement is true when the element name or its key or name attribute looks like a secret
+func isSecretXMLElement(name, attrs string) bool {
+	if IsSecretKey(name) {
+		return true
+	}
+	for _, m := range xmlKeyAttr.FindAllStringSubmatch(attrs, -1) {
+		if IsSecretKey(m[1] + m[2]) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskConfLine masks key: value and key=value lines containing a secret keyword or with a secret key
+func maskConfLine(line string) string {
+	if masked := MaskSecretLine(line); masked != line {
+		return masked
+	}
+	if m := confKeyLine.FindStringSubmatch(line); m != nil && IsSecretKey(m[1]) {
+		return maskLineValue(line)
+	}
+	return line
+}
+
+// MaskProperties masks the values of java properties with a secret key, the continuation lines of a masked value are masked too
+func MaskProperties(text string) string {
+	lines := strings.Split(text, "\n")
+	continuation := false
+	for i, line := range lines {
+		if continuation {
+			continuation = strings.HasSuffix(line, "\\")
+			lines[i] = ""
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "!") {
+			continue
+		}
+		m := propertyLine.FindStringSubmatch(line)
+		if m == nil || m[4] == "" || !IsSecretKey(m[2]) {
+			lines[i] = maskSASSignatures(line)
+			continue
+		}
+		continuation = strings.HasSuffix(line, "\\")
+		lines[i] = m[1] + m[2] + m[3] + removedSecret
+	}
+	return strings.Join(lines, "\n")
+}
+
+// MaskJAAS masks the options of JAAS login modules with a secret name such as password="..."
+func MaskJAAS(text string) string {
+	text = jaasOption.ReplaceAllStringFunc(text, func(option string) string {
+		m := jaasOption.FindStringSubmatch(option)
+		if !IsSecretKey(m[1]) {
+			return option
+		}
+		return m[1] + m[2] + removedSecret
+	})
+	return maskSASSignatures(text)
+}
+
+// MaskConfText picks the masker from the file name: xml files, java properties, JAAS files and line based
+// masking of key: value and key=value lines with a secret keyword or a secret key for everything else
+func MaskConfText(name, text string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".xml"):
+		return MaskXMLProperties(text)
+	case strings.Contains(lower, "jaas"):
+		return MaskJAAS(text)
+	case strings.HasSuffix(lower, ".properties"):
+		return MaskProperties(text)
+	default:
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			lines[i] = maskSASSignatures(maskConfLine(line))
+		}
+		return strings.Join(lines, "\n")
+	}
+}
+
+// MaskConfFile masks a copied configuration file in place, see MaskConfText
+func MaskConfFile(loc string) error {
+	b, err := os.ReadFile(filepath.Clean(loc))
+	if err != nil {
+		return fmt.Errorf("unable to read %v for masking: %w", loc, err)
+	}
+	masked := MaskConfText(filepath.Base(loc), string(b))
+	if masked == string(b) {
+		return nil
+	}
+	if err := os.WriteFile(loc, []byte(masked), 0o600); err != nil {
+		return fmt.Errorf("unable to write masked %v: %w", loc, err)
+	}
+	return nil
+}
@@ -16,6 +16,7 @@ package shutdown
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -33,11 +34,17 @@ type Hook interface {
 	AddFinalSteps(p func(), name string)
 	Add(p func(), name string)
 	AddCancelOnlyTasks(p func(), name string)
+	AddStopTasks(p func(), name string)
+	Stop(cancelRunning bool)
+	Stopped() <-chan struct{}
 	Cleanup()
 	Interrupt()
 	AddUIStop(func())
 }
 
+// ErrStopped is the cause of the context cancellation when Stop is called
+var ErrStopped = errors.New("collection was interrupted")
+
 // hookImpl is a thread safe queue of cleanup work to be run.
 // this is to be used for things that need to be cleaned up if the process
 // receives an interrupt (as defers would not be run)
@@ -46,17 +53,38 @@ type hookImpl struct {
 	cleanups     []cleanupTask
 	cancelOnly   []cleanupTask
 	finalSteps   []cleanupTask
+	stopTasks    []cleanupTask
 	ctx          context.Context
+	cancel       context.CancelCauseFunc
+	stopped      chan struct{}
+	stopOnce     sync.Once
 	stopUIThread func()
 }
 
 func NewHook() Hook {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancelCause(context.Background())
+	return newHook(ctx, cancel, func() {})
+}
+
+// NewHookWithDeadline works like NewHook but the shared context is also cancelled
+// once the deadline is reached so every execution using it stops
+func NewHookWithDeadline(deadline time.Time) Hook {
+	parent, cancel := context.WithCancelCause(context.Background())
+	ctx, cancelDeadline := context.WithDeadline(parent, deadline)
+	return newHook(ctx, cancel, cancelDeadline)
+}
+
+func newHook(ctx context.Context, cancel context.CancelCauseFunc, release func()) Hook {
 	hook := &hookImpl{
 		ctx:          ctx,
+		cancel:       cancel,
+		stopped:      make(chan struct{}),
 		stopUIThread: func() {},
 	}
-	hook.Add(cancel, "cancelling all cancellable executions")
+	hook.Add(func() {
+		cancel(nil)
+		release()
+	}, "cancelling all cancellable executions")
 	return hook
 }
 
@@ -92,6 +120,38 @@ func (h *hookImpl) AddCancelOnlyTasks(p func(), name string) {
 	h.cancelOnly = append(h.cancelOnly, cleanupTask{name: name, p: p})
 }
 
+// AddStopTasks are run once when Stop is called, they are used to ask running work to wrap up
+func (h *hookImpl) AddStopTasks(p func(), name string) {
+	defer h.mu.Unlock()
+	h.mu.Lock()
+	h.stopTasks = append(h.stopTasks, cleanupTask{name: name, p: p})
+}
+
+// Stop is the first stage of an interrupt, no new work should start and running work
+// should wrap up so what was already collected can still be archived. When cancelRunning
+// is true the shared context is cancelled with ErrStopped. Only the first call has an effect.
+func (h *hookImpl) Stop(cancelRunning bool) {
+	h.stopOnce.Do(func() {
+		close(h.stopped)
+		if cancelRunning {
+			h.cancel(ErrStopped)
+		}
+		h.mu.Lock()
+		tasks := h.stopTasks
+		h.stopTasks = []cleanupTask{}
+		h.mu.Unlock()
+		for _, j := range tasks {
+			simplelog.Debugf("stop task: %v", j.name)
+			j.p()
+		}
+	})
+}
+
+// Stopped is closed once Stop has been called
+func (h *hookImpl) Stopped() <-chan struct{} {
+	return h.stopped
+}
+
 // AddFinalSteps run last after everything has stopped
 func (h *hookImpl) AddFinalSteps(p func(), name string) {
 	defer h.mu.Unlock()
@@ -15,7 +15,10 @@
 package shutdown_test
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/shutdown"
 )
@@ -43,3 +46,50 @@ func TestShutdownRunsAllTasksInOrder(t *testing.T) {
 		t.Errorf("expected 3 but was %v", items[2])
 	}
 }
+
+func TestHookWithDeadlineCancelsContext(t *testing.T) {
+	deadline := time.Now().Add(10 * time.Millisecond)
+	hook := shutdown.NewHookWithDeadline(deadline)
+	defer hook.Cleanup()
+	actual, ok := hook.GetContext().Deadline()
+	if !ok || !actual.Equal(deadline) {
+		t.Errorf("expected deadline %v but got %v", deadline, actual)
+	}
+	select {
+	case <-hook.GetContext().Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected context to be cancelled at the deadline")
+	}
+	if !errors.Is(hook.GetContext().Err(), context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded but got %v", hook.GetContext().Err())
+	}
+}
+
+func TestStopRunsStopTasksOnce(t *testing.T) {
+	hook := shutdown.NewHook()
+	var calls int
+	hook.AddStopTasks(func() {
+		calls++
+	}, "")
+	hook.Stop(false)
+	hook.Stop(false)
+	if calls != 1 {
+		t.Errorf("expected the stop task to run once but ran %v times", calls)
+	}
+	select {
+	case <-hook.Stopped():
+	default:
+		t.Error("expected stopped to be closed")
+	}
+	if hook.GetContext().Err() != nil {
+		t.Errorf("expected the context to still be usable but was %v", hook.GetContext().Err())
+	}
+}
+
+func TestStopCancelsRunning(t *testing.T) {
+	hook := shutdown.NewHook()
+	hook.Stop(true)
+	if !errors.Is(context.Cause(hook.GetContext()), shutdown.ErrStopped) {
+		t.Errorf("expected cause %v but was %v", shutdown.ErrStopped, context.Cause(hook.GetContext()))
+	}
+}
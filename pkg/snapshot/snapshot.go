@@ -0,0 +1,101 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// package snapshot schedules an incident snapshot, the same captures taken at the same instants on every node of a cluster
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Instant is one capture of the snapshot on a node, the times are on the clock of the node
+type Instant struct {
+	Index   int       `json:"index"`
+	Planned time.Time `json:"planned"`
+	Started time.Time `json:"started"`
+	// LateMillis is how long after the planned time the captures started
+	LateMillis int64     `json:"lateMillis"`
+	Finished   time.Time `json:"finished"`
+	Files      []string  `json:"files"`
+	Errors     []string  `json:"errors,omitempty"`
+}
+
+// Result is written by every node next to its captures, the offset aligns the times of the nodes:
+// the clock of ddc is the clock of the node minus ClockOffsetMillis
+type Result struct {
+	Node              string    `json:"node"`
+	PID               int       `json:"pid"`
+	At                time.Time `json:"at"`
+	Count             int       `json:"count"`
+	IntervalSeconds   int       `json:"intervalSeconds"`
+	ClockOffsetMillis int64     `json:"clockOffsetMillis"`
+	Instants          []Instant `json:"instants"`
+}
+
+// ParseAt reads --snapshot-at, either RFC3339 with the timezone or a duration from now such as 2m
+func ParseAt(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("the snapshot cannot be %v in the past", -d)
+		}
+		return now.Add(d), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("the snapshot time '%v' is neither RFC3339 such as 2024-01-15T23:40:00-05:00 nor a duration such as 2m: %w", value, err)
+	}
+	return t, nil
+}
+
+// Schedule is when each of the count captures fires on the clock of a node that is clockOffset ahead of ddc
+func Schedule(at time.Time, count int, interval, clockOffset time.Duration) []time.Time {
+	instants := make([]time.Time, 0, count)
+	for i := 0; i < count; i++ {
+		instants = append(instants, at.Add(time.Duration(i)*interval+clockOffset))
+	}
+	return instants
+}
+
+// ParseRemoteClock reads the output of date +%s.%N, date implementations without %N print it as is
+// so the clock only has a resolution of a second
+func ParseRemoteClock(out string) (t time.Time, resolution time.Duration, err error) {
+	out = strings.TrimSpace(out)
+	if out == "" {
+		return time.Time{}, 0, errors.New("no output for the remote clock")
+	}
+	secs, frac, _ := strings.Cut(out, ".")
+	s, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid remote clock '%v': %w", out, err)
+	}
+	if n, err := strconv.ParseInt(frac, 10, 64); err == nil && len(frac) == 9 {
+		return time.Unix(s, n), time.Nanosecond, nil
+	}
+	return time.Unix(s, 0), time.Second, nil
+}
+
+// ClockOffset is how far the remote clock read between before and after is ahead of the local clock,
+// the uncertainty is half of the round trip
+func ClockOffset(before, after, remote time.Time) (offset, uncertainty time.Duration) {
+	rtt := after.Sub(before)
+	midpoint := before.Add(rtt / 2)
+	return remote.Sub(midpoint), rtt / 2
+}
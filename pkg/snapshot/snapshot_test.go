@@ -0,0 +1,95 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/snapshot"
+)
+
+func TestParseAt(t *testing.T) {
+	now := time.Date(2024, 1, 15, 23, 40, 0, 0, time.UTC)
+	at, err := snapshot.ParseAt("2m", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !at.Equal(now.Add(2 * time.Minute)) {
+		t.Errorf("expected 2 minutes from now but got %v", at)
+	}
+	at, err = snapshot.ParseAt("2024-01-15T23:45:00-05:00", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := time.Date(2024, 1, 16, 4, 45, 0, 0, time.UTC); !at.Equal(expected) {
+		t.Errorf("expected %v but got %v", expected, at)
+	}
+	if at, err := snapshot.ParseAt("", now); err != nil || !at.IsZero() {
+		t.Errorf("expected no snapshot but got %v %v", at, err)
+	}
+	for _, invalid := range []string{"-2m", "tomorrow", "2024-01-15 23:45"} {
+		if _, err := snapshot.ParseAt(invalid, now); err == nil {
+			t.Errorf("expected an error for %v", invalid)
+		}
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	at := time.Date(2024, 1, 15, 23, 40, 0, 0, time.UTC)
+	instants := snapshot.Schedule(at, 3, 10*time.Second, -1500*time.Millisecond)
+	expected := []time.Time{
+		at.Add(-1500 * time.Millisecond),
+		at.Add(8500 * time.Millisecond),
+		at.Add(18500 * time.Millisecond),
+	}
+	if len(instants) != len(expected) {
+		t.Fatalf("expected %v but got %v", expected, instants)
+	}
+	for i := range expected {
+		if !instants[i].Equal(expected[i]) {
+			t.Errorf("expected instant %v at %v but got %v", i, expected[i], instants[i])
+		}
+	}
+}
+
+func TestClockOffset(t *testing.T) {
+	remote, resolution, err := snapshot.ParseRemoteClock("1705362000.250000000\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolution != time.Nanosecond {
+		t.Errorf("expected a nanosecond resolution but got %v", resolution)
+	}
+	before := time.Unix(1705362001, 0)
+	after := before.Add(200 * time.Millisecond)
+	offset, uncertainty := snapshot.ClockOffset(before, after, remote)
+	if offset != -850*time.Millisecond {
+		t.Errorf("expected the remote clock to be 850ms behind but got %v", offset)
+	}
+	if uncertainty != 100*time.Millisecond {
+		t.Errorf("expected an uncertainty of 100ms but got %v", uncertainty)
+	}
+	remote, resolution, err = snapshot.ParseRemoteClock("1705362000.N")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolution != time.Second || !remote.Equal(time.Unix(1705362000, 0)) {
+		t.Errorf("expected seconds only but got %v with a resolution of %v", remote, resolution)
+	}
+	if _, _, err := snapshot.ParseRemoteClock("date: invalid option"); err == nil {
+		t.Error("expected an error")
+	}
+}
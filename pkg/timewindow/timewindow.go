@@ -0,0 +1,244 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// package timewindow limits a collection to an absolute time window and trims log files to the lines inside of it
+package timewindow
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Window is the time range to collect, a zero From or To leaves that side open
+type Window struct {
+	From time.Time
+	To   time.Time
+}
+
+// Parse reads the --from and --to flags, both are RFC3339 and either may be blank
+func Parse(from, to string, now time.Time) (Window, error) {
+	var w Window
+	if from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return Window{}, fmt.Errorf("invalid --from '%v' it must be RFC3339 such as 2024-01-15T23:40:00-05:00: %w", from, err)
+		}
+		if t.After(now) {
+			return Window{}, fmt.Errorf("invalid --from '%v' it is in the future", from)
+		}
+		w.From = t
+	}
+	if to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return Window{}, fmt.Errorf("invalid --to '%v' it must be RFC3339 such as 2024-01-16T00:10:00-05:00: %w", to, err)
+		}
+		w.To = t
+	}
+	if !w.From.IsZero() && !w.To.IsZero() && !w.From.Before(w.To) {
+		return Window{}, fmt.Errorf("invalid window --from '%v' must be before --to '%v'", from, to)
+	}
+	return w, nil
+}
+
+// IsSet is false when neither side of the window was given
+func (w Window) IsSet() bool {
+	return !w.From.IsZero() || !w.To.IsZero()
+}
+
+// Contains is true when t is inside of the window, both ends included
+func (w Window) Contains(t time.Time) bool {
+	if !w.From.IsZero() && t.Before(w.From) {
+		return false
+	}
+	if !w.To.IsZero() && t.After(w.To) {
+		return false
+	}
+	return true
+}
+
+// FromString is the start of the window as RFC3339 or blank when it is open
+func (w Window) FromString() string {
+	if w.From.IsZero() {
+		return ""
+	}
+	return w.From.Format(time.RFC3339)
+}
+
+// ToString is the end of the window as RFC3339 or blank when it is open
+func (w Window) ToString() string {
+	if w.To.IsZero() {
+		return ""
+	}
+	return w.To.Format(time.RFC3339)
+}
+
+func (w Window) String() string {
+	from := w.FromString()
+	if from == "" {
+		from = "the beginning"
+	}
+	to := w.ToString()
+	if to == "" {
+		to = "now"
+	}
+	return fmt.Sprintf("%v to %v", from, to)
+}
+
+// Days is the number of calendar days in loc touched by the window counting back from the day of the end,
+// now is used when the end is open. It is 0 when the start is open.
+func (w Window) Days(now time.Time, loc *time.Location) int {
+	if w.From.IsZero() {
+		return 0
+	}
+	end := w.To
+	if end.IsZero() {
+		end = now
+	}
+	y, m, d := w.From.In(loc).Date()
+	first := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	y, m, d = end.In(loc).Date()
+	last := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	return int(last.Sub(first).Hours()/24) + 1
+}
+
+var (
+	// logback ISO8601 such as 2024-01-15 10:23:45,123 and gc logs such as [2024-01-15T10:23:45.123+0000]
+	isoTimestamp = regexp.MustCompile(`^\[?(\d{4}-\d{2}-\d{2})[T ](\d{2}:\d{2}:\d{2})(?:[.,](\d{1,9}))?(Z|[+-]\d{2}:?\d{2})?`)
+	// access logs in the common log format such as 127.0.0.1 - - [15/Jan/2024:10:23:45 +0000] "GET /"
+	clfTimestamp = regexp.MustCompile(`^\S+ \S+ \S+ \[(\d{2}/[A-Za-z]{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4})\]`)
+)
+
+// jsonTimeKeys are checked in order for json lines, queries.json uses start and the audit log uses timestamp
+var jsonTimeKeys = []string{"start", "timestamp", "eventTime", "time", "@timestamp"}
+
+// ParseLine finds the timestamp a log line starts with, lines without one such as stack
+// trace frames are continuations of the line before them. Timestamps without an offset
+// are read in loc which should be the timezone of the node the log was written on.
+func ParseLine(line string, loc *time.Location) (time.Time, bool) {
+	if strings.HasPrefix(line, "{") {
+		return parseJSONLine(line, loc)
+	}
+	if m := isoTimestamp.FindStringSubmatch(line); m != nil {
+		value := m[1] + "T" + m[2]
+		layout := "2006-01-02T15:04:05"
+		if m[3] != "" {
+			value += "." + m[3]
+			layout += "." + strings.Repeat("0", len(m[3]))
+		}
+		if m[4] == "" {
+			t, err := time.ParseInLocation(layout, value, loc)
+			return t, err == nil
+		}
+		zone := m[4]
+		if zone != "Z" && !strings.Contains(zone, ":") {
+			zone = zone[:3] + ":" + zone[3:]
+		}
+		t, err := time.Parse(layout+"Z07:00", value+zone)
+		return t, err == nil
+	}
+	if m := clfTimestamp.FindStringSubmatch(line); m != nil {
+		t, err := time.Parse("02/Jan/2006:15:04:05 -0700", m[1])
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+func parseJSONLine(line string, loc *time.Location) (time.Time, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return time.Time{}, false
+	}
+	for _, key := range jsonTimeKeys {
+		raw, ok := fields[key]
+		if !ok {
+			continue
+		}
+		raw = bytes.TrimSpace(raw)
+		if len(raw) > 0 && raw[0] == '"' {
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				continue
+			}
+			if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+				return t, true
+			}
+			if t, ok := ParseLine(s, loc); ok {
+				return t, true
+			}
+			continue
+		}
+		n, err := strconv.ParseInt(string(raw), 10, 64)
+		if err != nil {
+			continue
+		}
+		// anything this large is epoch millis, otherwise seconds
+		if n > 100000000000 {
+			return time.UnixMilli(n), true
+		}
+		return time.Unix(n, 0), true
+	}
+	return time.Time{}, false
+}
+
+// Stats is what Trim kept of a file
+type Stats struct {
+	LinesRead int64
+	LinesKept int64
+	// Timestamped is false when no line had a timestamp and the file was kept whole
+	Timestamped bool
+}
+
+// Trim copies the lines of r that fall inside of the window to out. Lines without a timestamp
+// go with the line before them so stack traces stay attached to their header, lines before the
+// first timestamp such as the header of a gc log are always kept.
+func Trim(r io.Reader, out io.Writer, w Window, loc *time.Location) (Stats, error) {
+	var stats Stats
+	reader := bufio.NewReader(r)
+	writer := bufio.NewWriter(out)
+	keep := true
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			stats.LinesRead++
+			if t, ok := ParseLine(line, loc); ok {
+				stats.Timestamped = true
+				keep = w.Contains(t)
+			}
+			if keep {
+				stats.LinesKept++
+				if _, werr := writer.WriteString(line); werr != nil {
+					return stats, fmt.Errorf("unable to write trimmed log: %w", werr)
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, fmt.Errorf("unable to read log: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return stats, fmt.Errorf("unable to write trimmed log: %w", err)
+	}
+	return stats, nil
+}
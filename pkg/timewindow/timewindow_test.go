@@ -0,0 +1,159 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timewindow_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/timewindow"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC)
+	w, err := timewindow.Parse("2024-01-15T23:50:00-05:00", "2024-01-16T00:10:00-05:00", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !w.From.Equal(time.Date(2024, 1, 16, 4, 50, 0, 0, time.UTC)) {
+		t.Errorf("unexpected from %v", w.From)
+	}
+	if w.FromString() != "2024-01-15T23:50:00-05:00" {
+		t.Errorf("expected the offset to be kept but got %v", w.FromString())
+	}
+	if _, err := timewindow.Parse("2024-01-15 23:50", "", now); err == nil {
+		t.Error("expected an error for a non RFC3339 time")
+	}
+	if _, err := timewindow.Parse("2024-01-16T00:10:00Z", "2024-01-16T00:00:00Z", now); err == nil {
+		t.Error("expected an error when from is after to")
+	}
+	if _, err := timewindow.Parse("2024-02-01T00:00:00Z", "", now); err == nil {
+		t.Error("expected an error when from is in the future")
+	}
+	w, err = timewindow.Parse("", "", now)
+	if err != nil || w.IsSet() {
+		t.Errorf("expected an unset window but got %v %v", w, err)
+	}
+}
+
+func TestDays(t *testing.T) {
+	now := time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC)
+	w, err := timewindow.Parse("2024-01-15T23:55:00Z", "2024-01-16T00:15:00Z", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if days := w.Days(now, time.UTC); days != 2 {
+		t.Errorf("expected 2 days but got %v", days)
+	}
+	// in new york the whole window is on the 15th
+	ny := time.FixedZone("EST", -5*3600)
+	if days := w.Days(now, ny); days != 1 {
+		t.Errorf("expected 1 day but got %v", days)
+	}
+	w.To = time.Time{}
+	if days := w.Days(now, time.UTC); days != 6 {
+		t.Errorf("expected 6 days up to now but got %v", days)
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	utc := time.UTC
+	expected := time.Date(2024, 1, 15, 10, 23, 45, 123000000, time.UTC)
+	for _, line := range []string{
+		"2024-01-15 10:23:45,123 [main] INFO  c.d.d.DremioDaemon - Dremio daemon started\n",
+		"[2024-01-15T10:23:45.123+0000][info][gc] GC(12) Pause Young (Normal) 120M->20M(1024M) 5.123ms\n",
+		"2024-01-15T10:23:45.123+0000: 12.345: [GC (Allocation Failure) 1024K->512K(2048K), 0.0012 secs]\n",
+		`{"queryId":"1a2b","start":1705314225123,"finish":1705314226000,"outcome":"COMPLETED"}` + "\n",
+		`{"timestamp":"2024-01-15T10:23:45.123Z","event":"LOGIN"}`,
+	} {
+		ts, ok := timewindow.ParseLine(line, utc)
+		if !ok {
+			t.Errorf("expected a timestamp for %q", line)
+			continue
+		}
+		if !ts.Equal(expected) {
+			t.Errorf("expected %v but got %v for %q", expected, ts, line)
+		}
+	}
+	ts, ok := timewindow.ParseLine(`127.0.0.1 - - [15/Jan/2024:10:23:45 +0000] "GET /apiv2/login HTTP/1.1" 200 12`, utc)
+	if !ok || !ts.Equal(expected.Truncate(time.Second)) {
+		t.Errorf("unexpected access log timestamp %v %v", ts, ok)
+	}
+	// no offset means the timezone of the node
+	ny := time.FixedZone("EST", -5*3600)
+	ts, ok = timewindow.ParseLine("2024-01-15 05:23:45,123 [main] INFO", ny)
+	if !ok || !ts.Equal(expected) {
+		t.Errorf("expected %v but got %v", expected, ts)
+	}
+	for _, line := range []string{
+		"\tat com.dremio.exec.work.foreman.Foreman.run(Foreman.java:123)\n",
+		"Caused by: java.lang.IllegalStateException: boom\n",
+		"[12.345s][info][gc] GC(12) Pause Young\n",
+	} {
+		if _, ok := timewindow.ParseLine(line, utc); ok {
+			t.Errorf("expected no timestamp for %q", line)
+		}
+	}
+}
+
+func TestTrimKeepsStackTracesWithTheirHeader(t *testing.T) {
+	log := strings.Join([]string{
+		"2024-01-15 23:40:00,000 [main] INFO  before the window",
+		"2024-01-15 23:55:00,000 [e1] ERROR failed in the window",
+		"java.lang.IllegalStateException: boom",
+		"\tat com.dremio.Foo.bar(Foo.java:1)",
+		"2024-01-16 00:05:00,000 [e1] INFO  also in the window",
+		"2024-01-16 00:20:00,000 [e2] ERROR after the window",
+		"\tat com.dremio.Foo.baz(Foo.java:2)",
+		"",
+	}, "\n")
+	w, err := timewindow.Parse("2024-01-15T23:50:00Z", "2024-01-16T00:10:00Z", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	stats, err := timewindow.Trim(strings.NewReader(log), &out, w, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Join([]string{
+		"2024-01-15 23:55:00,000 [e1] ERROR failed in the window",
+		"java.lang.IllegalStateException: boom",
+		"\tat com.dremio.Foo.bar(Foo.java:1)",
+		"2024-01-16 00:05:00,000 [e1] INFO  also in the window",
+		"",
+	}, "\n")
+	if out.String() != expected {
+		t.Errorf("expected\n%q\nbut got\n%q", expected, out.String())
+	}
+	if stats.LinesRead != 7 || stats.LinesKept != 4 || !stats.Timestamped {
+		t.Errorf("unexpected stats %#v", stats)
+	}
+}
+
+func TestTrimKeepsFilesWithoutTimestamps(t *testing.T) {
+	log := "[0.010s][info][gc] Using G1\n[12.345s][info][gc] GC(0) Pause Young\n"
+	w := timewindow.Window{From: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)}
+	var out bytes.Buffer
+	stats, err := timewindow.Trim(strings.NewReader(log), &out, w, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != log || stats.Timestamped {
+		t.Errorf("expected the whole file to be kept but got %q %#v", out.String(), stats)
+	}
+}
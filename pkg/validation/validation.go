@@ -17,13 +17,26 @@ package validation
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/dremio/dremio-diagnostic-collector/v3/pkg/collects"
 )
 
-func ValidateCollectMode(collectionMode string) error {
-	if collectionMode != collects.HealthCheckCollection && collectionMode != collects.QuickCollection && collectionMode != collects.StandardCollection && collectionMode != collects.StandardPlusJSTACKCollection {
+// ValidateCollectMode checks the --collect option is a built-in mode or one of the custom modes declared in ddc.yaml
+func ValidateCollectMode(collectionMode string, customModes ...string) error {
+	if collectionMode == collects.HealthCheckCollection || collectionMode == collects.QuickCollection || collectionMode == collects.StandardCollection || collectionMode == collects.StandardPlusJSTACKCollection {
+		return nil
+	}
+	for _, m := range customModes {
+		if m == collectionMode {
+			return nil
+		}
+	}
+	if len(customModes) == 0 {
 		return fmt.Errorf("invalid --collect option '%v' the only valid options are %v, %v, %v, and %v", collectionMode, collects.QuickCollection, collects.StandardCollection, collects.StandardPlusJSTACKCollection, collects.HealthCheckCollection)
 	}
-	return nil
+	custom := append([]string{}, customModes...)
+	sort.Strings(custom)
+	return fmt.Errorf("invalid --collect option '%v' the only valid options are %v, %v, %v, %v and the custom modes from ddc.yaml %v", collectionMode, collects.QuickCollection, collects.StandardCollection, collects.StandardPlusJSTACKCollection, collects.HealthCheckCollection, strings.Join(custom, ", "))
 }